@@ -3,12 +3,19 @@ package pgo
 import (
     "flag"
     "fmt"
+    "io/ioutil"
     "os"
     "path/filepath"
     "reflect"
     "runtime"
+    "sort"
+    "strconv"
     "strings"
     "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/pinguo/pgo/Util"
 )
 
 // app initialization steps:
@@ -23,27 +30,110 @@ import (
 //     "runtimePath": "@app/runtime",
 //     "publicPath": "@app/public",
 //     "viewPath": "@viewPath",
+//     "initProfile": false,
+//     "initThreshold": "1s",
+//     "banner": true,
 //     "server": {},
-//     "components": {}
+//     "components": {
+//         "metrics": {"class": "@app/Lib/Metrics", "optional": true},
+//         "profiler": {"class": "@app/Lib/Profiler", "enabled": ["dev", "test"]},
+//         "queue": {"class": "@app/Lib/Queue", "stopOrder": -1, "stopTimeout": "10s"}
+//     }
 // }
+//
+// optional component is skipped with error log if it fails to init.
+// "enabled" is true by default, false or a list of envs(or a comma
+// separated string) disables component entirely in other envs, Get
+// returns nil for disabled component, core components are always enabled.
+//
+// shutdown: components implementing IStopper are stopped after background
+// jobs, in ascending "stopOrder"(0 by default), components of the same
+// order are stopped in reverse load order, each one is skipped with a
+// warning if its Stop exceeds "stopTimeout"(5s by default).
+//
+// init timing: init duration of each component is recorded in load order,
+// see InitTimings, component taking longer than initThreshold is warned,
+// initProfile logs a summary of components loaded before serving(eager)
+// when server starts, components loaded later on first use are lazy.
+//
+// banner logs a startup summary when server starts: name, env, profiles,
+// mode, listen address, version and names of enabled components and
+// plugins, config values are never included, see GetBanner.
+//
+// profile: --profile region=eu,tier=premium(or env "profile") selects
+// named overlays besides env, config is layered in deterministic order:
+// conf, conf/{env}, then conf/{dimension}/{name} for each profile in
+// the order given, eg. conf/region/eu then conf/tier/premium, later
+// directory deep-merges over the former.
+//
+// command line: only framework flags(--env, --profile, --cmd, --base,
+// --dotenv, --permissive, --check) are parsed on init, other arguments are kept
+// in order and can be parsed by command with its own flag.FlagSet, eg.
+// `app --cmd import --env prod --file a.csv` => args: [--file a.csv]
+// arguments after "--" are never treated as framework flags.
 type Application struct {
     mode        int
     env         string
+    profiles    [][2]string
     name        string
     basePath    string
     runtimePath string
     publicPath  string
     viewPath    string
+    baseFrom    string
+    warnings    []string
+    commands    map[string]*command
+    args        []string
     config      *Config
     container   *Container
     server      *Server
     components  map[string]interface{}
+    loading     map[string]*componentLoad
     lock        sync.RWMutex
+    initTimings []InitTiming
+    initProfile bool
+    initWarn    time.Duration
+    banner      bool
+    booted      bool
     router      *Router
     log         *Dispatcher
     status      *Status
     i18n        *I18n
     view        *View
+    render      *Render
+    health      *Health
+    auth        *Auth
+    buildInfo   BuildInfo
+    done        chan struct{}
+    doneOnce    sync.Once
+    stopOnce    sync.Once
+    jobLock     sync.Mutex
+    numJobs     int64
+    stopping    bool          // Stop is called
+    drained     chan struct{} // closed when no job is running on stop
+}
+
+// build info of running binary, reported by server's versionPath
+type BuildInfo struct {
+    Version    string `json:"version"`
+    Commit     string `json:"commit"`
+    BuildTime  string `json:"buildTime"`
+    GoVersion  string `json:"goVersion"`
+    PgoVersion string `json:"pgoVersion"`
+}
+
+// init timing of component
+type InitTiming struct {
+    Id       string        `json:"id"`
+    Class    string        `json:"class"`
+    Duration time.Duration `json:"duration"`
+    Lazy     bool          `json:"lazy"`
+}
+
+// component being loaded, panic is set if load failed
+type componentLoad struct {
+    done  chan struct{}
+    panic interface{}
 }
 
 func (app *Application) Construct() {
@@ -55,17 +145,60 @@ func (app *Application) Construct() {
     app.mode = ModeWeb
     app.name = strings.TrimSuffix(exeBase, exeExt)
     app.basePath, _ = filepath.Abs(filepath.Join(exeDir, ".."))
+    app.baseFrom = "parent of executable dir " + exeDir
     app.config = &Config{}
     app.container = &Container{}
     app.server = &Server{}
     app.components = make(map[string]interface{})
+    app.loading = make(map[string]*componentLoad)
+    app.initWarn = DefaultInitWarn
+    app.done = make(chan struct{})
+    app.drained = make(chan struct{})
 }
 
 func (app *Application) Init() {
     env := flag.String("env", "", "set running env, eg. --env prod")
+    profile := flag.String("profile", "", "set config profiles, eg. --profile region=eu,tier=premium")
     cmd := flag.String("cmd", "", "set running cmd, eg. --cmd /foo/bar")
     base := flag.String("base", "", "set base path, eg. --base /base/path")
-    flag.Parse()
+    permissive := flag.Bool("permissive", false, "skip missing or broken config, eg. --permissive")
+    dotenv := flag.String("dotenv", "@app/.env", "set .env file, empty to skip, eg. --dotenv /path/to/.env")
+    check := flag.Bool("check", false, "validate config and paths then exit, eg. --check")
+    sets := &setFlags{}
+    flag.Var(sets, "set", "override config, repeatable, eg. --set app.server.addr=:9000")
+
+    // parse framework flags only, the rest are kept for commands
+    fwArgs, args := splitArgs(flag.CommandLine, os.Args[1:])
+    flag.CommandLine.Parse(fwArgs)
+    app.args = args
+
+    // overwrite running mode
+    if len(*cmd) > 0 {
+        app.mode = ModeCmd
+    }
+
+    // overwrite base path
+    if len(*base) > 0 {
+        app.basePath, _ = filepath.Abs(*base)
+        app.baseFrom = "--base flag"
+    }
+
+    // resolve symlinks, missing path is reported by config
+    if realPath, e := filepath.EvalSymlinks(app.basePath); e == nil {
+        app.basePath = realPath
+    }
+
+    // load .env file into environment, real environment takes precedence
+    if len(*dotenv) > 0 {
+        path := *dotenv
+        if strings.HasPrefix(path, "@app") {
+            path = app.basePath + path[4:]
+        }
+
+        if e := Util.LoadEnvFile(path); e != nil && !os.IsNotExist(e) {
+            panic(fmt.Sprintf("failed to load %s, %s", path, e))
+        }
+    }
 
     // overwrite running env
     if len(*env) > 0 {
@@ -77,19 +210,18 @@ func (app *Application) Init() {
         }
     }
 
-    // overwrite running mode
-    if len(*cmd) > 0 {
-        app.mode = ModeCmd
+    // set config profiles in the given order
+    if len(*profile) == 0 {
+        *profile = os.Getenv("profile")
     }
+    app.profiles = parseProfiles(*profile)
 
-    // overwrite base path
-    if len(*base) > 0 {
-        app.basePath, _ = filepath.Abs(*base)
+    // initialize config object, then add overrides of env and flags
+    ConstructAndInit(app.config, nil, *permissive)
+    for _, o := range parseOverrides(os.Environ(), ConfigEnvPrefix, *sets) {
+        app.config.Override(o[0].(string), o[1])
     }
 
-    // initialize config object
-    ConstructAndInit(app.config, nil)
-
     // initialize container object
     ConstructAndInit(app.container, nil)
 
@@ -103,6 +235,23 @@ func (app *Application) Init() {
     SetAlias("@app", app.basePath)
     SetAlias("@pgo", strings.TrimPrefix(pkgPath, VendorPrefix))
 
+    app.applyConfig(false)
+
+    if *check {
+        app.config.checkDir()
+        app.printCheck()
+    }
+}
+
+// apply app config of name, paths and component classes, server
+// config is applied again if reload, eg. config fs is set
+func (app *Application) applyConfig(reload bool) {
+    if reload {
+        svrConf, _ := app.config.Get("app.server").(map[string]interface{})
+        Configure(app.server, svrConf)
+        app.warnings = nil
+    }
+
     // overwrite app name
     if name := app.config.GetString("app.name", ""); len(name) > 0 {
         app.name = name
@@ -113,19 +262,26 @@ func (app *Application) Init() {
         runtime.GOMAXPROCS(n)
     }
 
-    // set runtime path
-    runtimePath := app.config.GetString("app.runtimePath", "@app/runtime")
-    app.runtimePath, _ = filepath.Abs(GetAlias(runtimePath))
+    // set init profiling of components
+    app.initProfile = app.config.GetBool("app.initProfile", false)
+    if v := app.config.GetString("app.initThreshold", ""); len(v) > 0 {
+        if d, e := time.ParseDuration(v); e != nil {
+            app.warnings = append(app.warnings, "invalid initThreshold, "+e.Error())
+        } else {
+            app.initWarn = d
+        }
+    }
+
+    app.banner = app.config.GetBool("app.banner", true)
+
+    // set runtime, public and view path
+    app.runtimePath = app.resolvePath("app.runtimePath", "@app/runtime")
     SetAlias("@runtime", app.runtimePath)
 
-    // set public path
-    publicPath := app.config.GetString("app.publicPath", "@app/public")
-    app.publicPath, _ = filepath.Abs(GetAlias(publicPath))
+    app.publicPath = app.resolvePath("app.publicPath", "@app/public")
     SetAlias("@public", app.publicPath)
 
-    // set view path
-    viewPath := app.config.GetString("app.viewPath", "@app/view")
-    app.viewPath, _ = filepath.Abs(GetAlias(viewPath))
+    app.viewPath = app.resolvePath("app.viewPath", "@app/view")
     SetAlias("@view", app.viewPath)
 
     // set core components
@@ -134,12 +290,303 @@ func (app *Application) Init() {
         app.config.Set(key, class)
     }
 
-    // create runtime directory if not exists
+    // set class of default components if not configured
+    for id, class := range app.defaultComponents() {
+        key := fmt.Sprintf("app.components.%s.class", id)
+        if app.config.Get(key) == nil {
+            app.config.Set(key, class)
+        }
+    }
+
+    // validate paths, create runtime directory if not exists
+    app.checkPaths()
+}
+
+// resolve path config to absolute path with symlinks evaluated
+func (app *Application) resolvePath(key, dft string) string {
+    path, _ := filepath.Abs(GetAlias(app.config.GetString(key, dft)))
+    if realPath, e := filepath.EvalSymlinks(path); e == nil {
+        path = realPath
+    }
+
+    return path
+}
+
+// runtime path must be writable, public and view path
+// should exist, warnings are logged when server starts
+func (app *Application) checkPaths() {
+    from := func(key, dft string) string {
+        return fmt.Sprintf("from %s=%q, base path %s is %s", key,
+            app.config.GetString(key, dft), app.basePath, app.baseFrom)
+    }
+
     if _, e := os.Stat(app.runtimePath); os.IsNotExist(e) {
         if e := os.MkdirAll(app.runtimePath, 0755); e != nil {
-            panic(fmt.Sprintf("failed to create %s, %s", app.runtimePath, e))
+            panic(fmt.Sprintf("failed to create runtime path %s(%s), %s", app.runtimePath, from("app.runtimePath", "@app/runtime"), e))
+        }
+    }
+
+    if h, e := ioutil.TempFile(app.runtimePath, ".pgo-check-"); e != nil {
+        panic(fmt.Sprintf("runtime path %s is not writable(%s), %s", app.runtimePath, from("app.runtimePath", "@app/runtime"), e))
+    } else {
+        h.Close()
+        os.Remove(h.Name())
+    }
+
+    if info, e := os.Stat(app.publicPath); e != nil || !info.IsDir() {
+        app.warnings = append(app.warnings, fmt.Sprintf("public path %s is not a directory(%s)", app.publicPath, from("app.publicPath", "@app/public")))
+    }
+
+    if info, e := os.Stat(app.viewPath); e != nil || !info.IsDir() {
+        app.warnings = append(app.warnings, fmt.Sprintf("view path %s is not a directory(%s)", app.viewPath, from("app.viewPath", "@app/view")))
+    }
+}
+
+// print check result for --check and exit
+func (app *Application) printCheck() {
+    fmt.Printf("env: %s, base path: %s(%s)\n", app.env, app.basePath, app.baseFrom)
+    for _, p := range app.profiles {
+        fmt.Printf("profile: %s=%s\n", p[0], p[1])
+    }
+    fmt.Printf("runtime: %s\npublic: %s\nview: %s\n", app.runtimePath, app.publicPath, app.viewPath)
+
+    for _, w := range app.warnings {
+        fmt.Println("warning:", w)
+    }
+
+    errs := app.config.GetErrors()
+    for _, e := range errs {
+        fmt.Println("error:", e.Error())
+    }
+
+    if len(errs) > 0 {
+        os.Exit(1)
+    }
+
+    fmt.Println("check ok")
+    os.Exit(0)
+}
+
+// get command line arguments except framework flags
+func (app *Application) GetArgs() []string {
+    return app.args
+}
+
+// parse profiles, eg. "region=eu,tier=premium" => [[region eu] [tier premium]],
+// repeated dimension keeps its first position with the last name
+func parseProfiles(s string) [][2]string {
+    profiles := make([][2]string, 0)
+    for _, item := range strings.Split(s, ",") {
+        if item = strings.TrimSpace(item); len(item) == 0 {
+            continue
+        }
+
+        parts := strings.SplitN(item, "=", 2)
+        dim, name := strings.TrimSpace(parts[0]), ""
+        if len(parts) == 2 {
+            name = strings.TrimSpace(parts[1])
+        }
+
+        if len(dim) == 0 || len(name) == 0 || strings.ContainsAny(dim+name, `/\.`) {
+            panic("invalid profile: " + item + ", expected format: dimension=name")
+        }
+
+        found := false
+        for i := range profiles {
+            if profiles[i][0] == dim {
+                profiles[i][1], found = name, true
+            }
+        }
+
+        if !found {
+            profiles = append(profiles, [2]string{dim, name})
         }
     }
+
+    return profiles
+}
+
+// repeatable --set flag of config overrides
+type setFlags []string
+
+func (s *setFlags) String() string {
+    return strings.Join(*s, ",")
+}
+
+func (s *setFlags) Set(v string) error {
+    *s = append(*s, v)
+    return nil
+}
+
+// split arguments into framework flags defined in fs and the rest
+func splitArgs(fs *flag.FlagSet, arguments []string) (fwArgs, args []string) {
+    fwArgs, args = make([]string, 0), make([]string, 0)
+    for i := 0; i < len(arguments); i++ {
+        arg := arguments[i]
+        if arg == "--" {
+            args = append(args, arguments[i+1:]...)
+            break
+        }
+
+        if len(arg) < 2 || arg[0] != '-' {
+            args = append(args, arg)
+            continue
+        }
+
+        name := strings.TrimLeft(arg, "-")
+        pos := strings.IndexByte(name, '=')
+        if pos != -1 {
+            name = name[:pos]
+        }
+
+        f := fs.Lookup(name)
+        if f == nil {
+            args = append(args, arg)
+            continue
+        }
+
+        fwArgs = append(fwArgs, arg)
+        if bf, ok := f.Value.(interface{ IsBoolFlag() bool }); pos == -1 && !(ok && bf.IsBoolFlag()) && i+1 < len(arguments) {
+            i++
+            fwArgs = append(fwArgs, arguments[i])
+        }
+    }
+
+    return
+}
+
+// get channel closed on app shutdown
+func (app *Application) GetDone() <-chan struct{} {
+    return app.done
+}
+
+// close done channel to cancel background jobs
+func (app *Application) cancel() {
+    app.doneOnce.Do(func() { close(app.done) })
+}
+
+// stop app, wait background jobs started by ctx.Go to finish within
+// timeout, then close the done channel to cancel them, jobs still
+// running are abandoned with a warning, only the first call stops
+// app, later calls wait until it's stopped and return
+func (app *Application) Stop(timeout time.Duration) {
+    app.stopOnce.Do(func() {
+        app.jobLock.Lock()
+        if app.stopping = true; app.numJobs == 0 {
+            app.closeDrained()
+        }
+        app.jobLock.Unlock()
+
+        timer := time.NewTimer(timeout)
+        defer timer.Stop()
+
+        select {
+        case <-app.drained:
+        case <-timer.C:
+            GLogger().Warn("abandon %d background jobs after %s", app.GetJobCount(), timeout)
+        }
+
+        app.cancel()
+        app.stopComponents()
+    })
+}
+
+// stop loaded components implementing IStopper in deterministic order
+func (app *Application) stopComponents() {
+    type stopper struct {
+        id      string
+        order   int
+        timeout time.Duration
+        obj     IStopper
+    }
+
+    // reverse load order, components loaded later may depend on former,
+    // components set by SetComponent are regarded as the latest
+    app.lock.RLock()
+    ids, loaded := make([]string, 0, len(app.components)), make(map[string]bool)
+    for i := len(app.initTimings) - 1; i >= 0; i-- {
+        loaded[app.initTimings[i].Id] = true
+    }
+    for id := range app.components {
+        if !loaded[id] {
+            ids = append(ids, id)
+        }
+    }
+    sort.Strings(ids)
+    for i := len(app.initTimings) - 1; i >= 0; i-- {
+        ids = append(ids, app.initTimings[i].Id)
+    }
+    app.lock.RUnlock()
+
+    stoppers := make([]stopper, 0)
+    for _, id := range ids {
+        app.lock.RLock()
+        obj, ok := app.components[id].(IStopper)
+        app.lock.RUnlock()
+        if !ok {
+            continue
+        }
+
+        prefix := "app.components." + id
+        timeout, e := time.ParseDuration(app.config.GetString(prefix+".stopTimeout", ""))
+        if e != nil || timeout <= 0 {
+            timeout = DefaultStopTimeout
+        }
+
+        stoppers = append(stoppers, stopper{id, app.config.GetInt(prefix+".stopOrder", 0), timeout, obj})
+    }
+
+    sort.SliceStable(stoppers, func(i, j int) bool { return stoppers[i].order < stoppers[j].order })
+
+    for _, s := range stoppers {
+        result := make(chan error, 1)
+        go func(obj IStopper) {
+            defer func() {
+                if v := recover(); v != nil {
+                    result <- fmt.Errorf("panic, %s", Util.ToString(v))
+                }
+            }()
+            result <- obj.Stop()
+        }(s.obj)
+
+        timer := time.NewTimer(s.timeout)
+        select {
+        case e := <-result:
+            if e != nil {
+                GLogger().Warn("stop component %s failed, %s", s.id, e)
+            } else {
+                GLogger().Info("component %s stopped", s.id)
+            }
+        case <-timer.C:
+            GLogger().Warn("stop component %s timeout after %s, skipped", s.id, s.timeout)
+        }
+        timer.Stop()
+    }
+}
+
+// get number of running background jobs started by ctx.Go
+func (app *Application) GetJobCount() int {
+    return int(atomic.LoadInt64(&app.numJobs))
+}
+
+// count running jobs, drained is closed when the last one ends on stop
+func (app *Application) addJob(delta int) {
+    app.jobLock.Lock()
+    defer app.jobLock.Unlock()
+
+    num := atomic.AddInt64(&app.numJobs, int64(delta))
+    if app.stopping && num == 0 {
+        app.closeDrained()
+    }
+}
+
+// close drained once, job started after drain doesn't reopen it
+func (app *Application) closeDrained() {
+    select {
+    case <-app.drained:
+    default:
+        close(app.drained)
+    }
 }
 
 func (app *Application) GetMode() int {
@@ -150,6 +597,39 @@ func (app *Application) GetEnv() string {
     return app.env
 }
 
+// set build info, usually injected by ldflags, eg.
+// go build -ldflags "-X main.version=1.2.0 -X main.commit=$(git rev-parse HEAD)"
+// pgo.App.SetBuildInfo(version, commit, buildTime)
+func (app *Application) SetBuildInfo(version, commit, buildTime string) {
+    app.buildInfo.Version = version
+    app.buildInfo.Commit = commit
+    app.buildInfo.BuildTime = buildTime
+}
+
+// get build info, including go runtime and pgo framework version
+func (app *Application) GetBuildInfo() BuildInfo {
+    info := app.buildInfo
+    info.GoVersion = runtime.Version()
+    info.PgoVersion = FrameworkVersion
+    return info
+}
+
+// get config profiles in order, each is a pair of dimension and name
+func (app *Application) GetProfiles() [][2]string {
+    return app.profiles
+}
+
+// get profile name of dimension, empty if not selected
+func (app *Application) GetProfile(dimension string) string {
+    for _, p := range app.profiles {
+        if p[0] == dimension {
+            return p[1]
+        }
+    }
+
+    return ""
+}
+
 func (app *Application) GetName() string {
     return app.name
 }
@@ -222,9 +702,46 @@ func (app *Application) GetView() *View {
     return app.view
 }
 
+func (app *Application) GetRender() *Render {
+    if app.render == nil {
+        app.render = app.Get("render").(*Render)
+    }
+
+    return app.render
+}
+
+func (app *Application) GetHealth() *Health {
+    if app.health == nil {
+        app.health = app.Get("health").(*Health)
+    }
+
+    return app.health
+}
+
+func (app *Application) GetAuth() *Auth {
+    if app.auth == nil {
+        app.auth = app.Get("auth").(*Auth)
+    }
+
+    return app.auth
+}
+
+// get component by id, component with "optional": true in config
+// is skipped if it fails to initialize, nil is returned for skipped
+// component, so use type assertion with ok to check availability, eg.
+// if metrics, ok := pgo.App.Get("metrics").(*Metrics); ok {...}
 func (app *Application) Get(id string) interface{} {
     if _, ok := app.components[id]; !ok {
-        app.loadComponent(id)
+        timing, e := app.loadComponent(id)
+        if e != nil {
+            GLogger().Error("optional component %s skipped, %s", id, e)
+        }
+
+        // logging is deferred after loading to avoid lock reentry
+        if timing != nil && app.initWarn > 0 && timing.Duration > app.initWarn {
+            GLogger().Warn("slow component init, %s(%s) took %dms, threshold %dms", timing.Id,
+                timing.Class, timing.Duration/time.Millisecond, app.initWarn/time.Millisecond)
+        }
     }
 
     app.lock.RLock()
@@ -233,21 +750,206 @@ func (app *Application) Get(id string) interface{} {
     return app.components[id]
 }
 
-func (app *Application) loadComponent(id string) {
+// set component instance by id, eg. to replace a component by mock in
+// test, it must be called before the component is first used because
+// core components are cached by their getters, eg. GetRedis().
+func (app *Application) SetComponent(id string, obj interface{}) {
     app.lock.Lock()
     defer app.lock.Unlock()
 
-    // avoid repeated loading
+    app.components[id] = obj
+}
+
+// load component, return error if optional component failed
+func (app *Application) loadComponent(id string) (timing *InitTiming, err error) {
+    app.lock.Lock()
+
+    // avoid repeated loading, wait for the one being loaded
     if _, ok := app.components[id]; ok {
-        return
+        app.lock.Unlock()
+        return nil, nil
+    } else if load, ok := app.loading[id]; ok {
+        app.lock.Unlock()
+        <-load.done
+        if load.panic != nil {
+            panic(load.panic)
+        }
+        return nil, nil
     }
 
     conf := app.config.Get("app.components." + id)
     if conf == nil {
+        app.lock.Unlock()
         panic("component not found: " + id)
     }
 
-    app.components[id] = CreateObject(conf)
+    // disabled component is never constructed
+    if m, ok := conf.(map[string]interface{}); ok && !app.isEnabled(id, m["enabled"]) {
+        app.components[id] = nil
+        app.lock.Unlock()
+        return nil, nil
+    }
+
+    // construct without lock, so component can get others in Init,
+    // eg. db client registers its health check on init
+    load := &componentLoad{done: make(chan struct{})}
+    app.loading[id] = load
+    app.lock.Unlock()
+
+    // record init duration, including failed optional component,
+    // failure of required component is passed to waiters
+    var obj interface{}
+    start := time.Now()
+    defer func() {
+        v := recover()
+        class := app.config.GetString("app.components."+id+".class", "")
+        timing = &InitTiming{id, class, time.Since(start), app.booted}
+
+        app.lock.Lock()
+        if v == nil {
+            app.components[id] = obj
+        }
+        app.initTimings = append(app.initTimings, *timing)
+        delete(app.loading, id)
+        app.lock.Unlock()
+
+        load.panic = v
+        close(load.done)
+        if v != nil {
+            panic(v)
+        }
+    }()
+
+    if m, ok := conf.(map[string]interface{}); ok && Util.ToBool(m["optional"]) {
+        defer func() {
+            if v := recover(); v != nil {
+                obj, err = nil, fmt.Errorf("%s", Util.ToString(v))
+            }
+        }()
+    }
+
+    obj = CreateObject(conf)
+    return nil, nil
+}
+
+// check whether component is enabled in config for current env,
+// it's false for unknown component
+func (app *Application) IsEnabled(id string) bool {
+    if conf := app.config.Get("app.components." + id); conf == nil {
+        return false
+    } else if m, ok := conf.(map[string]interface{}); ok {
+        return app.isEnabled(id, m["enabled"])
+    }
+
+    return true
+}
+
+// check "enabled" config of component, true, false or envs
+func (app *Application) isEnabled(id string, enabled interface{}) bool {
+    if _, ok := app.coreComponents()[id]; ok || enabled == nil {
+        return true
+    }
+
+    var envs []string
+    switch v := enabled.(type) {
+    case bool:
+        return v
+    case string:
+        if b, e := strconv.ParseBool(v); e == nil {
+            return b
+        }
+        envs = strings.Split(v, ",")
+    case []interface{}:
+        for _, env := range v {
+            envs = append(envs, Util.ToString(env))
+        }
+    default:
+        return Util.ToBool(v)
+    }
+
+    for _, env := range envs {
+        if strings.TrimSpace(env) == app.env {
+            return true
+        }
+    }
+
+    return false
+}
+
+// get init timings of loaded components in load order
+func (app *Application) InitTimings() []InitTiming {
+    app.lock.RLock()
+    defer app.lock.RUnlock()
+
+    return append([]InitTiming(nil), app.initTimings...)
+}
+
+// mark end of boot, components loaded later are lazy,
+// log summary of init timings if initProfile enabled
+func (app *Application) finishBoot() {
+    app.lock.Lock()
+    app.booted = true
+    app.lock.Unlock()
+
+    if !app.initProfile {
+        return
+    }
+
+    var total time.Duration
+    timings := app.InitTimings()
+    for _, t := range timings {
+        total += t.Duration
+        mode := "eager"
+        if t.Lazy {
+            mode = "lazy"
+        }
+
+        GLogger().Info("component init, %-12s %8.2fms %-5s %s", t.Id, float64(t.Duration)/float64(time.Millisecond), mode, t.Class)
+    }
+
+    GLogger().Info("component init, %d components, total %.2fms", len(timings), float64(total)/float64(time.Millisecond))
+}
+
+// get startup summary, only names are included, so it's safe to log
+func (app *Application) GetBanner() string {
+    mode, addr := "web", app.server.http.Addr
+    if app.mode == ModeCmd {
+        mode, addr = "cmd", "-"
+    } else if app.server.IsTls() {
+        addr = "https://" + addr
+    } else {
+        addr = "http://" + addr
+    }
+
+    profiles := make([]string, 0, len(app.profiles))
+    for _, p := range app.profiles {
+        profiles = append(profiles, p[0]+"="+p[1])
+    }
+
+    components := make([]string, 0)
+    if conf, ok := app.config.Get("app.components").(map[string]interface{}); ok {
+        for id := range conf {
+            if app.IsEnabled(id) {
+                if _, core := app.coreComponents()[id]; !core {
+                    components = append(components, id)
+                }
+            }
+        }
+    }
+    sort.Strings(components)
+
+    plugins := make([]string, 0, len(app.server.pluginConf))
+    for _, v := range app.server.pluginConf {
+        if m, ok := v.(map[string]interface{}); ok {
+            v = m["class"]
+        }
+        plugins = append(plugins, Util.ToString(v))
+    }
+
+    info := app.GetBuildInfo()
+    return fmt.Sprintf("startup, name:%s, env:%s, profiles:[%s], mode:%s, addr:%s, version:%s, pgo:%s, components:[%s], plugins:[%s]",
+        app.name, app.env, strings.Join(profiles, ","), mode, addr, info.Version, info.PgoVersion,
+        strings.Join(components, ","), strings.Join(plugins, ","))
 }
 
 func (app *Application) coreComponents() map[string]string {
@@ -257,7 +959,17 @@ func (app *Application) coreComponents() map[string]string {
         "status": "@pgo/Status",
         "i18n":   "@pgo/I18n",
         "view":   "@pgo/View",
+        "render": "@pgo/Render",
+        "health": "@pgo/Health",
+        "auth":   "@pgo/Auth",
 
         "http": "@pgo/Client/Http/Client",
     }
 }
+
+// components with replaceable class, eg. "cache" can be a Redis Client
+func (app *Application) defaultComponents() map[string]string {
+    return map[string]string{
+        "cache": "@pgo/Client/Memory/Client",
+    }
+}
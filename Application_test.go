@@ -0,0 +1,283 @@
+package pgo
+
+import (
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "github.com/pinguo/pgo/Util"
+)
+
+var testInits, testComponents int32
+
+type testComponent struct {
+    fail  bool
+    delay time.Duration
+}
+
+func (c *testComponent) SetFail(v bool) {
+    c.fail = v
+}
+
+func (c *testComponent) SetDelay(v string) {
+    c.delay, _ = time.ParseDuration(v)
+}
+
+func (c *testComponent) Init() {
+    atomic.AddInt32(&testInits, 1)
+    time.Sleep(10*time.Millisecond + c.delay)
+    if c.fail {
+        panic("init failed")
+    }
+}
+
+func init() {
+    App.GetContainer().Bind(&testComponent{})
+}
+
+// add config of component with a new id, so tests can run repeatedly
+func setTestComponent(conf map[string]interface{}) string {
+    id := "testComponent" + strconv.Itoa(int(atomic.AddInt32(&testComponents, 1)))
+    conf["class"] = "@pgo/testComponent"
+    App.GetConfig().Set("app.components."+id, conf)
+    return id
+}
+
+func TestApplicationGetComponent(t *testing.T) {
+    id := setTestComponent(map[string]interface{}{})
+    before := atomic.LoadInt32(&testInits)
+
+    wg := sync.WaitGroup{}
+    for i := 0; i < 4; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            if _, ok := App.Get(id).(*testComponent); !ok {
+                t.Error("want component created")
+            }
+        }()
+    }
+    wg.Wait()
+
+    if n := atomic.LoadInt32(&testInits) - before; n != 1 {
+        t.Errorf("want component initialized once, got %d", n)
+    }
+}
+
+func TestApplicationOptionalComponent(t *testing.T) {
+    id := setTestComponent(map[string]interface{}{"fail": true, "optional": true})
+    before := atomic.LoadInt32(&testInits)
+
+    for i := 0; i < 2; i++ {
+        if v, ok := App.Get(id).(*testComponent); ok {
+            t.Errorf("call %d: want failed optional component skipped, got %v", i, v)
+        }
+    }
+
+    if n := atomic.LoadInt32(&testInits) - before; n != 1 {
+        t.Errorf("want failed optional component initialized once, got %d", n)
+    }
+}
+
+func TestApplicationRequiredComponentFailure(t *testing.T) {
+    id := setTestComponent(map[string]interface{}{"fail": true})
+
+    // waiters of the failed load get the same panic instead of nil
+    panics := int32(0)
+    wg := sync.WaitGroup{}
+    for i := 0; i < 4; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            defer func() {
+                if v := recover(); v == "init failed" {
+                    atomic.AddInt32(&panics, 1)
+                }
+            }()
+            App.Get(id)
+        }()
+    }
+    wg.Wait()
+
+    if panics != 4 {
+        t.Errorf("want all callers panic with init error, got %d", panics)
+    }
+}
+
+func newPathTestApp(t *testing.T, paths map[string]string) *Application {
+    app := &Application{config: newTestConfig(), basePath: t.TempDir(), baseFrom: "test"}
+    for key, path := range paths {
+        app.config.Set("app."+key, path)
+    }
+
+    app.runtimePath = app.resolvePath("app.runtimePath", "")
+    app.publicPath = app.resolvePath("app.publicPath", "")
+    app.viewPath = app.resolvePath("app.viewPath", "")
+    return app
+}
+
+func TestApplicationResolvePath(t *testing.T) {
+    dir := t.TempDir()
+    real := filepath.Join(dir, "real")
+    link := filepath.Join(dir, "link")
+    os.Mkdir(real, 0755)
+    if e := os.Symlink(real, link); e != nil {
+        t.Skip("symlink not supported,", e)
+    }
+
+    app := newPathTestApp(t, map[string]string{"publicPath": link + "/../link/"})
+    if want, _ := filepath.EvalSymlinks(real); app.publicPath != want {
+        t.Errorf("want symlink resolved to %s, got %s", want, app.publicPath)
+    }
+}
+
+func TestApplicationCheckPaths(t *testing.T) {
+    dir := t.TempDir()
+    os.Mkdir(filepath.Join(dir, "view"), 0755)
+    os.WriteFile(filepath.Join(dir, "public"), nil, 0644)
+
+    app := newPathTestApp(t, map[string]string{
+        "runtimePath": filepath.Join(dir, "runtime", "nested"),
+        "publicPath":  filepath.Join(dir, "public"),
+        "viewPath":    filepath.Join(dir, "view"),
+    })
+    app.checkPaths()
+
+    if info, e := os.Stat(app.runtimePath); e != nil || !info.IsDir() {
+        t.Errorf("want missing runtime path created, got %v", e)
+    }
+
+    if len(app.warnings) != 1 || !strings.Contains(app.warnings[0], "public path") {
+        t.Errorf("want warning of public path only, got %v", app.warnings)
+    }
+
+    // runtime path under a file is not usable
+    app = newPathTestApp(t, map[string]string{"runtimePath": filepath.Join(dir, "public", "runtime")})
+    defer func() {
+        if v := recover(); v == nil || !strings.Contains(Util.ToString(v), "runtime path "+app.runtimePath) {
+            t.Errorf("want panic of runtime path, got %v", v)
+        }
+    }()
+    app.checkPaths()
+}
+
+func TestApplicationInitTimings(t *testing.T) {
+    id := setTestComponent(map[string]interface{}{"delay": "40ms"})
+    initWarn := App.initWarn
+    App.initWarn = 30 * time.Millisecond
+    defer func() { App.initWarn = initWarn }()
+
+    target := &recordTarget{}
+    GLogger().SetTap(target.Process)
+    defer GLogger().SetTap(nil)
+
+    App.Get(id)
+
+    var timing *InitTiming
+    for _, v := range App.InitTimings() {
+        if v.Id == id {
+            timing = &v
+        }
+    }
+
+    if timing == nil || timing.Class != "@pgo/testComponent" || timing.Duration < 50*time.Millisecond {
+        t.Fatalf("want init timing of slow component, got %+v", timing)
+    }
+
+    want := "slow component init, " + id + "(@pgo/testComponent) took "
+    if msgs := target.messages(); len(msgs) != 1 || !strings.HasPrefix(msgs[0], want) || !strings.HasSuffix(msgs[0], "threshold 30ms") {
+        t.Errorf("want threshold warning, got %v", msgs)
+    }
+}
+
+// app apart from the global App, eg. for stop tests, so App keeps running
+func newIsolatedApp() *Application {
+    app := &Application{}
+    app.Construct()
+    app.config = newTestConfig()
+    return app
+}
+
+func TestApplicationStopDrainJobs(t *testing.T) {
+    app := newIsolatedApp()
+    app.addJob(1)
+    go func() {
+        time.Sleep(30 * time.Millisecond)
+        app.addJob(-1)
+    }()
+
+    start := time.Now()
+    app.Stop(time.Second)
+    if elapsed := time.Since(start); elapsed < 20*time.Millisecond || elapsed > 500*time.Millisecond {
+        t.Errorf("want stop after job finished, took %s", elapsed)
+    }
+
+    select {
+    case <-app.GetDone():
+    default:
+        t.Error("want done closed after stop")
+    }
+}
+
+func TestApplicationStopTimeout(t *testing.T) {
+    app := newIsolatedApp()
+    app.addJob(1)
+
+    start := time.Now()
+    app.Stop(30 * time.Millisecond)
+    if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+        t.Errorf("want stuck job abandoned at timeout, took %s", elapsed)
+    }
+
+    // stopped once, later call doesn't wait again
+    start = time.Now()
+    app.Stop(time.Second)
+    if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+        t.Errorf("second stop: want no-op, took %s", elapsed)
+    }
+
+    // job finishing after stop doesn't close drained again
+    app.addJob(-1)
+    app.addJob(1)
+    app.addJob(-1)
+}
+
+func TestApplicationBanner(t *testing.T) {
+    app := newIsolatedApp()
+    app.name, app.env, app.mode = "demo", "prod", ModeWeb
+    app.profiles = [][2]string{{"region", "eu"}, {"tier", "premium"}}
+    app.SetBuildInfo("1.2.0", "abc", "")
+
+    app.server = &Server{}
+    app.server.Construct()
+    app.server.http.Addr = "0.0.0.0:8000"
+    app.server.SetPlugins([]interface{}{map[string]interface{}{"class": "@pgo/Plugin/Gzip"}, "@pgo/Plugin/Cors"})
+
+    app.config.Get("app")
+    app.config.Set("app.components", map[string]interface{}{
+        "db":    map[string]interface{}{"dsn": "user:secret@tcp(db)/app"},
+        "cache": map[string]interface{}{"enabled": "dev,prod"},
+        "old":   map[string]interface{}{"enabled": false},
+        "log":   map[string]interface{}{},
+    })
+
+    want := "startup, name:demo, env:prod, profiles:[region=eu,tier=premium], mode:web, addr:http://0.0.0.0:8000, version:1.2.0, pgo:" +
+        FrameworkVersion + ", components:[cache,db], plugins:[@pgo/Plugin/Gzip,@pgo/Plugin/Cors]"
+    if v := app.GetBanner(); v != want {
+        t.Errorf("want\n%s\ngot\n%s", want, v)
+    }
+
+    if strings.Contains(app.GetBanner(), "secret") {
+        t.Error("want no config values in banner")
+    }
+
+    app.mode = ModeCmd
+    if v := app.GetBanner(); !strings.Contains(v, "mode:cmd, addr:-,") {
+        t.Errorf("cmd mode: want no addr, got %q", v)
+    }
+}
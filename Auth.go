@@ -0,0 +1,220 @@
+package pgo
+
+import (
+    "crypto/subtle"
+    "errors"
+    "net/http"
+    "strings"
+    "sync"
+)
+
+// returned by strategy if request carries no credentials of its kind,
+// so the next strategy is tried
+var ErrNoCredentials = errors.New("no credentials")
+
+// verify callback of username and password, return principal on success
+type BasicVerifier func(user, password string) (interface{}, error)
+
+// verify callback of bearer token or api key, return principal on success
+type TokenVerifier func(token string) (interface{}, error)
+
+// auth component, strategies are registered by application code with
+// credential verifying callbacks, so the framework stays storage agnostic,
+// routes select strategies by @pgo/Plugin/Auth, configuration:
+// "auth": {
+//     "realm": "pgo"
+// }
+//
+// usage:
+// pgo.App.GetAuth().AddStrategy("basic", pgo.NewBasicAuth(checkUser))
+// pgo.App.GetAuth().AddStrategy("token", pgo.NewBearerAuth(checkToken))
+// pgo.App.GetAuth().AddStrategy("key", pgo.NewApiKeyAuth("X-Api-Key", checkKey))
+//
+// the principal of authenticated request is got by ctx.GetPrincipal().
+type Auth struct {
+    lock       sync.RWMutex
+    realm      string
+    strategies map[string]IAuthStrategy
+    names      []string
+}
+
+func (a *Auth) Construct() {
+    a.realm = App.GetName()
+    a.strategies = make(map[string]IAuthStrategy)
+}
+
+// set realm of WWW-Authenticate challenge
+func (a *Auth) SetRealm(realm string) {
+    a.realm = realm
+}
+
+// get realm of WWW-Authenticate challenge
+func (a *Auth) GetRealm() string {
+    return a.realm
+}
+
+// add strategy by name, existing strategy of the same name is replaced
+func (a *Auth) AddStrategy(name string, strategy IAuthStrategy) {
+    a.lock.Lock()
+    defer a.lock.Unlock()
+
+    if _, ok := a.strategies[name]; !ok {
+        a.names = append(a.names, name)
+    }
+
+    a.strategies[name] = strategy
+}
+
+// get strategy by name, nil if not found
+func (a *Auth) GetStrategy(name string) IAuthStrategy {
+    a.lock.RLock()
+    defer a.lock.RUnlock()
+
+    return a.strategies[name]
+}
+
+// authenticate request by strategies in order, all registered strategies
+// are tried if names is empty, the first strategy that finds credentials
+// decides the result, principal is set to ctx on success, ErrNoCredentials
+// is returned if none is found, otherwise an exception with status 401.
+func (a *Auth) Authenticate(ctx *Context, names ...string) (interface{}, error) {
+    if len(names) == 0 {
+        a.lock.RLock()
+        names = a.names
+        a.lock.RUnlock()
+    }
+
+    for _, name := range names {
+        strategy := a.GetStrategy(name)
+        if strategy == nil {
+            panic("Auth: strategy not found, " + name)
+        }
+
+        principal, e := strategy.Authenticate(ctx)
+        if e == ErrNoCredentials {
+            continue
+        } else if e != nil {
+            ctx.Warn("Auth: %s failed, %s", name, e)
+            return nil, NewException(http.StatusUnauthorized, "invalid credentials")
+        }
+
+        ctx.SetPrincipal(principal)
+        return principal, nil
+    }
+
+    return nil, ErrNoCredentials
+}
+
+// set WWW-Authenticate challenges of strategies to response
+func (a *Auth) Challenge(ctx *Context, names ...string) {
+    if len(names) == 0 {
+        a.lock.RLock()
+        names = a.names
+        a.lock.RUnlock()
+    }
+
+    for _, name := range names {
+        if strategy := a.GetStrategy(name); strategy != nil {
+            if v := strategy.Challenge(a.realm); len(v) > 0 {
+                ctx.GetOutput().Header().Add("WWW-Authenticate", v)
+            }
+        }
+    }
+}
+
+// basic auth strategy, RFC 7617
+type BasicAuth struct {
+    verify BasicVerifier
+}
+
+func NewBasicAuth(verify BasicVerifier) *BasicAuth {
+    return &BasicAuth{verify: verify}
+}
+
+func (b *BasicAuth) Authenticate(ctx *Context) (interface{}, error) {
+    user, password, ok := ctx.GetInput().BasicAuth()
+    if !ok {
+        return nil, ErrNoCredentials
+    }
+
+    return b.verify(user, password)
+}
+
+func (b *BasicAuth) Challenge(realm string) string {
+    return `Basic realm="` + realm + `", charset="UTF-8"`
+}
+
+// bearer token strategy, RFC 6750, verify callback can check jwt
+type BearerAuth struct {
+    verify TokenVerifier
+}
+
+func NewBearerAuth(verify TokenVerifier) *BearerAuth {
+    return &BearerAuth{verify: verify}
+}
+
+func (b *BearerAuth) Authenticate(ctx *Context) (interface{}, error) {
+    header := ctx.GetHeader("Authorization", "")
+    if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+        return nil, ErrNoCredentials
+    }
+
+    token := strings.TrimSpace(header[7:])
+    if len(token) == 0 {
+        return nil, ErrNoCredentials
+    }
+
+    return b.verify(token)
+}
+
+func (b *BearerAuth) Challenge(realm string) string {
+    return `Bearer realm="` + realm + `"`
+}
+
+// api key strategy, key is read from header, or query if header is empty
+type ApiKeyAuth struct {
+    header string
+    query  string
+    verify TokenVerifier
+}
+
+func NewApiKeyAuth(header string, verify TokenVerifier) *ApiKeyAuth {
+    return &ApiKeyAuth{header: header, verify: verify}
+}
+
+// also read key from query parameter, header takes precedence
+func (k *ApiKeyAuth) SetQuery(name string) *ApiKeyAuth {
+    k.query = name
+    return k
+}
+
+func (k *ApiKeyAuth) Authenticate(ctx *Context) (interface{}, error) {
+    key := ctx.GetHeader(k.header, "")
+    if len(key) == 0 && len(k.query) > 0 {
+        key = ctx.GetQuery(k.query, "")
+    }
+
+    if len(key) == 0 {
+        return nil, ErrNoCredentials
+    }
+
+    return k.verify(key)
+}
+
+// api key has no standard challenge, use custom scheme with header name
+func (k *ApiKeyAuth) Challenge(realm string) string {
+    return `ApiKey realm="` + realm + `", header="` + k.header + `"`
+}
+
+// verifier matches static keys in constant time, eg. for internal service
+func StaticTokens(tokens map[string]interface{}) TokenVerifier {
+    return func(token string) (interface{}, error) {
+        for k, principal := range tokens {
+            if subtle.ConstantTimeCompare([]byte(k), []byte(token)) == 1 {
+                return principal, nil
+            }
+        }
+
+        return nil, errors.New("unknown token")
+    }
+}
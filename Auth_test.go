@@ -0,0 +1,85 @@
+package pgo
+
+import (
+    "errors"
+    "net/http"
+    "testing"
+)
+
+func newTestAuth() *Auth {
+    a := &Auth{}
+    a.Construct()
+    a.SetRealm("test")
+    a.AddStrategy("basic", NewBasicAuth(func(user, password string) (interface{}, error) {
+        if user == "admin" && password == "secret" {
+            return "admin", nil
+        }
+        return nil, errors.New("wrong password")
+    }))
+    a.AddStrategy("token", NewBearerAuth(StaticTokens(map[string]interface{}{"t1": "user1"})))
+    a.AddStrategy("key", NewApiKeyAuth("X-Api-Key", StaticTokens(map[string]interface{}{"k1": "service"})).SetQuery("api_key"))
+    return a
+}
+
+func TestAuthStrategies(t *testing.T) {
+    a := newTestAuth()
+    tests := []struct {
+        url, header, value string
+        want               interface{}
+    }{
+        {"/", "Authorization", "Basic YWRtaW46c2VjcmV0", "admin"},
+        {"/", "Authorization", "bearer t1", "user1"},
+        {"/", "X-Api-Key", "k1", "service"},
+        {"/?api_key=k1", "", "", "service"},
+    }
+
+    for _, test := range tests {
+        ctx, _ := newRequestContext("GET", test.url, nil)
+        if len(test.header) > 0 {
+            ctx.GetInput().Header.Set(test.header, test.value)
+        }
+
+        principal, e := a.Authenticate(ctx)
+        if e != nil || principal != test.want || ctx.GetPrincipal() != test.want {
+            t.Errorf("%s %s: want principal %v, got %v %v", test.url, test.value, test.want, principal, e)
+        }
+    }
+}
+
+func TestAuthFailure(t *testing.T) {
+    a := newTestAuth()
+    ctx, _ := newRequestContext("GET", "/", nil)
+    if _, e := a.Authenticate(ctx); e != ErrNoCredentials {
+        t.Errorf("no credentials: want ErrNoCredentials, got %v", e)
+    }
+
+    ctx, _ = newRequestContext("GET", "/", nil)
+    ctx.GetInput().Header.Set("Authorization", "Bearer wrong")
+    _, e := a.Authenticate(ctx)
+    if ex, ok := e.(*Exception); !ok || ex.GetStatus() != http.StatusUnauthorized {
+        t.Errorf("invalid token: want 401 exception, got %v", e)
+    }
+
+    if ctx.GetPrincipal() != nil {
+        t.Errorf("invalid token: want nil principal, got %v", ctx.GetPrincipal())
+    }
+
+    // only selected strategies are tried
+    ctx, _ = newRequestContext("GET", "/", nil)
+    ctx.GetInput().Header.Set("X-Api-Key", "k1")
+    if _, e := a.Authenticate(ctx, "basic", "token"); e != ErrNoCredentials {
+        t.Errorf("unselected strategy: want ErrNoCredentials, got %v", e)
+    }
+}
+
+func TestAuthChallenge(t *testing.T) {
+    a := newTestAuth()
+    ctx, w := newRequestContext("GET", "/", nil)
+    a.Challenge(ctx, "basic", "key")
+
+    want := []string{`Basic realm="test", charset="UTF-8"`, `ApiKey realm="test", header="X-Api-Key"`}
+    got := w.Header()["Www-Authenticate"]
+    if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+        t.Errorf("want challenges %q, got %q", want, got)
+    }
+}
@@ -0,0 +1,101 @@
+package pgo
+
+import (
+    "net/http"
+    "reflect"
+    "strings"
+
+    "github.com/pinguo/pgo/Util"
+)
+
+// validator implemented by bound struct param of action
+type paramValidator interface {
+    Validate() error
+}
+
+// bind route params to action params, string param receives the captured
+// group as is, scalar param(bool, int, uint, float) is parsed from the
+// captured group in order, 400 if failed, struct or pointer of struct param
+// is bound from query and post form(or json body) and named groups by tag
+// "param", then validated by its Validate() method if any, 422 if failed, eg.
+// rule: "^/api/user/(?P<id>\d+)$ => /api/user/update"
+// func (u *UserController) ActionUpdate(id int, form *UpdateForm)
+func bindParams(ctx *Context, rule *routeRule, action reflect.Value, params []string) []reflect.Value {
+    at := action.Type()
+    callParams := make([]reflect.Value, 0, at.NumIn())
+
+    for i, pos := 0, 0; i < at.NumIn(); i++ {
+        pt := at.In(i)
+        if st := structParam(pt); st != nil {
+            ptr := bindStruct(ctx, rule, params, st)
+            if pt.Kind() == reflect.Struct {
+                ptr = ptr.Elem()
+            }
+            callParams = append(callParams, ptr)
+            continue
+        }
+
+        param := ""
+        if pos < len(params) {
+            param = params[pos]
+        }
+        pos++
+
+        pv := reflect.New(pt).Elem()
+        if len(param) > 0 && !Util.ParseValue(pv, param) {
+            panic(NewException(http.StatusBadRequest, "invalid param %d, expect %s: %s", pos, pt.Kind(), param))
+        }
+
+        callParams = append(callParams, pv)
+    }
+
+    return callParams
+}
+
+// struct type of struct or pointer of struct param, nil for other params
+func structParam(pt reflect.Type) reflect.Type {
+    if pt.Kind() == reflect.Ptr {
+        pt = pt.Elem()
+    }
+
+    if pt.Kind() == reflect.Struct {
+        return pt
+    }
+    return nil
+}
+
+func bindStruct(ctx *Context, rule *routeRule, params []string, st reflect.Type) reflect.Value {
+    ptr := reflect.New(st)
+    values := make(map[string][]string)
+
+    if r := ctx.GetInput(); r != nil {
+        if strings.HasPrefix(ctx.GetHeader("Content-Type", ""), "application/json") {
+            if e := ctx.GetJsonBody(ptr.Interface()); e != nil {
+                panic(WrapException(e, http.StatusBadRequest, "invalid json body, %s", e))
+            }
+            values = r.URL.Query()
+        } else {
+            r.ParseMultipartForm(int64(App.GetServer().MaxMemoryBytes))
+            values = r.Form
+        }
+    }
+
+    // named groups take precedence over query and body
+    if rule != nil {
+        for i, name := range rule.rePat.SubexpNames() {
+            if len(name) > 0 && i-1 < len(params) {
+                values[name] = []string{params[i-1]}
+            }
+        }
+    }
+
+    Util.STBindValues(ptr.Interface(), values, "param")
+
+    if v, ok := ptr.Interface().(paramValidator); ok {
+        if e := v.Validate(); e != nil {
+            panic(WrapException(e, http.StatusUnprocessableEntity))
+        }
+    }
+
+    return ptr
+}
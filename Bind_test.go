@@ -0,0 +1,81 @@
+package pgo
+
+import (
+    "errors"
+    "net/http"
+    "reflect"
+    "testing"
+)
+
+type bindForm struct {
+    Name string `param:"name"`
+    Age  int    `param:"age"`
+}
+
+type bindValidForm struct {
+    Name string `param:"name"`
+}
+
+func (f *bindValidForm) Validate() error {
+    if len(f.Name) == 0 {
+        return errors.New("name is required")
+    }
+    return nil
+}
+
+func newBindContext(url string) *Context {
+    ctx, _ := newRequestContext("GET", url, nil)
+    return ctx
+}
+
+func TestBindStructParams(t *testing.T) {
+    var byPtr *bindForm
+    var byValue bindForm
+    action := reflect.ValueOf(func(id int, p *bindForm, v bindForm) {
+        byPtr, byValue = p, v
+    })
+
+    ctx := newBindContext("/user?name=foo&age=20")
+    action.Call(bindParams(ctx, nil, action, []string{"12"}))
+
+    want := bindForm{Name: "foo", Age: 20}
+    if byPtr == nil || *byPtr != want {
+        t.Errorf("pointer param: want %+v, got %+v", want, byPtr)
+    }
+
+    if byValue != want {
+        t.Errorf("value param: want %+v, got %+v", want, byValue)
+    }
+}
+
+func TestBindScalarParams(t *testing.T) {
+    var gotId int
+    var gotName string
+    action := reflect.ValueOf(func(id int, name string) { gotId, gotName = id, name })
+
+    action.Call(bindParams(newBindContext("/user"), nil, action, []string{"12", "foo"}))
+    if gotId != 12 || gotName != "foo" {
+        t.Errorf("want 12 foo, got %d %s", gotId, gotName)
+    }
+
+    defer func() {
+        ex, ok := recover().(*Exception)
+        if !ok || ex.GetStatus() != http.StatusBadRequest {
+            t.Errorf("invalid int param: want 400 exception, got %v", ex)
+        }
+    }()
+    bindParams(newBindContext("/user"), nil, action, []string{"abc"})
+}
+
+func TestBindStructValidate(t *testing.T) {
+    action := reflect.ValueOf(func(v bindValidForm) {})
+    action.Call(bindParams(newBindContext("/user?name=foo"), nil, action, nil))
+
+    defer func() {
+        ex, ok := recover().(*Exception)
+        if !ok || ex.GetStatus() != http.StatusUnprocessableEntity {
+            t.Errorf("invalid value param: want 422 exception, got %v", ex)
+        }
+    }()
+    bindParams(newBindContext("/user"), nil, action, nil)
+}
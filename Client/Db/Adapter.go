@@ -0,0 +1,76 @@
+package Db
+
+import (
+    "database/sql"
+    "time"
+
+    "github.com/pinguo/pgo"
+)
+
+// Adapter of Db Client, add context support, queries are canceled
+// when the context is done, see pgo.Context.GetStdContext.
+// usage: db := this.GetObject("@pgo/Client/Db/Adapter").(*Adapter)
+type Adapter struct {
+    pgo.Object
+    client *Client
+}
+
+func (a *Adapter) Construct(componentId ...string) {
+    id := defaultComponentId
+    if len(componentId) > 0 {
+        id = componentId[0]
+    }
+
+    a.client = pgo.App.Get(id).(*Client)
+}
+
+func (a *Adapter) GetClient() *Client {
+    return a.client
+}
+
+// log query slower than slowQuery of client
+func (a *Adapter) checkSlow(start time.Time, query string) {
+    if elapse := time.Since(start); a.client.slowQuery > 0 && elapse > a.client.slowQuery {
+        a.GetContext().Warn("Db: slow query, %dms, %s", elapse/time.Millisecond, query)
+    }
+}
+
+// query rows from slave db
+func (a *Adapter) Query(query string, args ...interface{}) (*sql.Rows, error) {
+    profile := "Db.Query"
+    a.GetContext().ProfileStart(profile)
+    defer a.GetContext().ProfileStop(profile)
+    defer a.checkSlow(time.Now(), query)
+
+    return a.client.GetSlave().QueryContext(a.GetContext().GetStdContext(), query, args...)
+}
+
+// query one row from slave db
+func (a *Adapter) QueryRow(query string, args ...interface{}) *sql.Row {
+    profile := "Db.QueryRow"
+    a.GetContext().ProfileStart(profile)
+    defer a.GetContext().ProfileStop(profile)
+    defer a.checkSlow(time.Now(), query)
+
+    return a.client.GetSlave().QueryRowContext(a.GetContext().GetStdContext(), query, args...)
+}
+
+// query rows from master db, for read-after-write
+func (a *Adapter) QueryMaster(query string, args ...interface{}) (*sql.Rows, error) {
+    profile := "Db.QueryMaster"
+    a.GetContext().ProfileStart(profile)
+    defer a.GetContext().ProfileStop(profile)
+    defer a.checkSlow(time.Now(), query)
+
+    return a.client.GetDb().QueryContext(a.GetContext().GetStdContext(), query, args...)
+}
+
+// execute statement on master db
+func (a *Adapter) Exec(query string, args ...interface{}) (sql.Result, error) {
+    profile := "Db.Exec"
+    a.GetContext().ProfileStart(profile)
+    defer a.GetContext().ProfileStop(profile)
+    defer a.checkSlow(time.Now(), query)
+
+    return a.client.GetDb().ExecContext(a.GetContext().GetStdContext(), query, args...)
+}
@@ -0,0 +1,48 @@
+package Db
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/pinguo/pgo"
+    "github.com/pinguo/pgo/Client/Http"
+    "github.com/pinguo/pgo/Test"
+)
+
+func TestAdapterLogIdWithHttp(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+    defer srv.Close()
+
+    c, db := newTestClient(t, "dbtest")
+    c.SetSlowQuery("10ms")
+    db.delay = 20 * time.Millisecond
+
+    r := Test.NewRequest("GET", "/", nil)
+    r.Header.Set(pgo.LogIdHeader, "handler-id")
+    ctx, _ := Test.NewRequestContext(r)
+
+    var items []*pgo.LogItem
+    ctx.SetTap(func(item *pgo.LogItem) { items = append(items, item) })
+
+    a := &Adapter{client: c}
+    a.SetContext(ctx)
+    a.Query("SELECT * FROM user")
+
+    h := &Http.Adapter{}
+    h.Construct()
+    h.SetContext(ctx)
+    h.Get(srv.URL, nil)
+
+    if len(items) != 2 || !strings.HasPrefix(items[0].Message, "Db: slow query, ") || !strings.HasPrefix(items[1].Message, "Http: GET ") {
+        t.Fatalf("want db slow query and http request logged, got %d items", len(items))
+    }
+
+    for _, item := range items {
+        if item.LogId != "handler-id" {
+            t.Errorf("want log id of handler, got %q of %q", item.LogId, item.Message)
+        }
+    }
+}
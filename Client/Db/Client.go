@@ -0,0 +1,209 @@
+package Db
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "sync/atomic"
+    "time"
+
+    "github.com/pinguo/pgo"
+    "github.com/pinguo/pgo/Util"
+)
+
+// Db Client component, wrap database/sql with pool settings,
+// the driver package must be imported by application, eg.
+// import _ "github.com/go-sql-driver/mysql"
+// configuration:
+// "db": {
+//     "class": "@pgo/Client/Db/Client",
+//     "driver": "mysql",
+//     "dsn": "user:pass@tcp(127.0.0.1:3306)/db?charset=utf8mb4",
+//     "slaves": [
+//         "user:pass@tcp(127.0.0.2:3306)/db?charset=utf8mb4"
+//     ],
+//     "maxOpenConn": 100,
+//     "maxIdleConn": 10,
+//     "connMaxLifetime": "1h",
+//     "connMaxIdleTime": "10m",
+//     "pingTimeout": "3s",
+//     "slowQuery": "200ms",
+//     "healthName": "db"
+// }
+//
+// read queries are dispatched to slaves by round-robin, master
+// is used if no slave configured, each db is pinged on init and
+// registered as health check for the readiness endpoint. queries of
+// Adapter are canceled when request is done, and query slower than
+// slowQuery is logged as warning, 0 to disable.
+type Client struct {
+    driver      string
+    dsn         string
+    slaveDsn    []string
+    maxOpenConn int
+    maxIdleConn int
+    maxLifetime time.Duration
+    maxIdleTime time.Duration
+    pingTimeout time.Duration
+    slowQuery   time.Duration
+    healthName  string
+
+    master *sql.DB
+    slaves []*sql.DB
+    index  uint32
+}
+
+func (c *Client) Construct() {
+    c.slaveDsn = make([]string, 0)
+    c.maxOpenConn = defaultMaxOpenConn
+    c.maxIdleConn = defaultMaxIdleConn
+    c.maxLifetime = defaultMaxLifetime
+    c.pingTimeout = defaultPingTimeout
+    c.slowQuery = defaultSlowQuery
+    c.healthName = defaultComponentId
+}
+
+func (c *Client) Init() {
+    if len(c.driver) == 0 || len(c.dsn) == 0 {
+        panic(fmt.Sprintf(errSetProp, "driver/dsn", "driver and dsn are required"))
+    }
+
+    c.master = c.open(c.dsn)
+    c.slaves = make([]*sql.DB, 0, len(c.slaveDsn))
+    for _, dsn := range c.slaveDsn {
+        c.slaves = append(c.slaves, c.open(dsn))
+    }
+
+    if len(c.healthName) > 0 {
+        pgo.App.GetHealth().AddCheck(c.healthName, c.Ping)
+    }
+}
+
+func (c *Client) SetDriver(driver string) {
+    c.driver = driver
+}
+
+func (c *Client) SetDsn(dsn string) {
+    c.dsn = dsn
+}
+
+func (c *Client) SetSlaves(v []interface{}) {
+    for _, dsn := range v {
+        c.slaveDsn = append(c.slaveDsn, Util.ToString(dsn))
+    }
+}
+
+func (c *Client) SetMaxOpenConn(maxOpenConn int) {
+    c.maxOpenConn = maxOpenConn
+}
+
+func (c *Client) SetMaxIdleConn(maxIdleConn int) {
+    c.maxIdleConn = maxIdleConn
+}
+
+func (c *Client) SetConnMaxLifetime(v string) {
+    if maxLifetime, e := time.ParseDuration(v); e != nil {
+        panic(fmt.Sprintf(errSetProp, "connMaxLifetime", e.Error()))
+    } else {
+        c.maxLifetime = maxLifetime
+    }
+}
+
+func (c *Client) SetConnMaxIdleTime(v string) {
+    if maxIdleTime, e := time.ParseDuration(v); e != nil {
+        panic(fmt.Sprintf(errSetProp, "connMaxIdleTime", e.Error()))
+    } else {
+        c.maxIdleTime = maxIdleTime
+    }
+}
+
+func (c *Client) SetSlowQuery(v string) {
+    if slowQuery, e := time.ParseDuration(v); e != nil {
+        panic(fmt.Sprintf(errSetProp, "slowQuery", e.Error()))
+    } else {
+        c.slowQuery = slowQuery
+    }
+}
+
+// get threshold of slow query log, 0 if disabled
+func (c *Client) GetSlowQuery() time.Duration {
+    return c.slowQuery
+}
+
+func (c *Client) SetPingTimeout(v string) {
+    if pingTimeout, e := time.ParseDuration(v); e != nil {
+        panic(fmt.Sprintf(errSetProp, "pingTimeout", e.Error()))
+    } else {
+        c.pingTimeout = pingTimeout
+    }
+}
+
+// set name of health check, empty to disable
+func (c *Client) SetHealthName(name string) {
+    c.healthName = name
+}
+
+// get master db for write and read-after-write
+func (c *Client) GetDb() *sql.DB {
+    return c.master
+}
+
+// get slave db for read by round-robin, master if no slave
+func (c *Client) GetSlave() *sql.DB {
+    if n := len(c.slaves); n > 0 {
+        return c.slaves[atomic.AddUint32(&c.index, 1)%uint32(n)]
+    }
+
+    return c.master
+}
+
+// ping master and slaves, return the first error
+func (c *Client) Ping() error {
+    ctx, cancel := context.WithTimeout(context.Background(), c.pingTimeout)
+    defer cancel()
+
+    if e := c.master.PingContext(ctx); e != nil {
+        return e
+    }
+
+    for _, db := range c.slaves {
+        if e := db.PingContext(ctx); e != nil {
+            return e
+        }
+    }
+
+    return nil
+}
+
+// close master and slaves on app shutdown, see pgo.IStopper
+func (c *Client) Stop() error {
+    err := c.master.Close()
+    for _, db := range c.slaves {
+        if e := db.Close(); e != nil && err == nil {
+            err = e
+        }
+    }
+
+    return err
+}
+
+func (c *Client) open(dsn string) *sql.DB {
+    db, e := sql.Open(c.driver, dsn)
+    if e != nil {
+        panic(fmt.Sprintf(errOpenFail, c.driver, e.Error()))
+    }
+
+    db.SetMaxOpenConns(c.maxOpenConn)
+    db.SetMaxIdleConns(c.maxIdleConn)
+    db.SetConnMaxLifetime(c.maxLifetime)
+    db.SetConnMaxIdleTime(c.maxIdleTime)
+
+    ctx, cancel := context.WithTimeout(context.Background(), c.pingTimeout)
+    defer cancel()
+
+    if e := db.PingContext(ctx); e != nil {
+        panic(fmt.Sprintf(errPingFail, c.driver, e.Error()))
+    }
+
+    return db
+}
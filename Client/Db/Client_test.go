@@ -0,0 +1,66 @@
+package Db
+
+import (
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/pinguo/pgo"
+    "github.com/pinguo/pgo/Test"
+)
+
+func TestClientInitPingFail(t *testing.T) {
+    master, dsn := newFakeDb()
+    slave, slaveDsn := newFakeDb()
+
+    for name, db := range map[string]*fakeDb{"master": master, "slave": slave} {
+        func() {
+            db.failPing = true
+            defer func() { db.failPing = false }()
+
+            defer func() {
+                if v := recover(); v != "db: failed to ping dbtest, ping failed" {
+                    t.Errorf("%s: want ping failure on init, got %v", name, v)
+                }
+            }()
+
+            c := &Client{}
+            c.Construct()
+            c.SetDriver("dbtest")
+            c.SetDsn(dsn)
+            c.SetSlaves([]interface{}{slaveDsn})
+            c.SetHealthName("")
+            c.Init()
+        }()
+    }
+}
+
+func TestAdapterSlowQuery(t *testing.T) {
+    c, db := newTestClient(t, "dbtest")
+    c.SetSlowQuery("20ms")
+
+    ctx, _ := Test.NewTestContext("GET", "/", nil)
+    var items []*pgo.LogItem
+    ctx.SetTap(func(item *pgo.LogItem) { items = append(items, item) })
+
+    a := &Adapter{client: c}
+    a.SetContext(ctx)
+
+    a.Exec("UPDATE user SET age = ?", 1)
+    if len(items) != 0 {
+        t.Errorf("fast query: want no log, got %q", items[0].Message)
+    }
+
+    db.delay = 30 * time.Millisecond
+    a.Exec("UPDATE user SET age = ?", 2)
+    if len(items) != 1 || items[0].Level != pgo.LevelWarn || !strings.HasPrefix(items[0].Message, "Db: slow query, ") ||
+        !strings.HasSuffix(items[0].Message, "ms, UPDATE user SET age = ?") {
+        t.Errorf("slow query: want warning with query, got %d items", len(items))
+    }
+
+    c.SetSlowQuery("0s")
+    a.Exec("UPDATE user SET age = ?", 3)
+    if len(items) != 1 {
+        t.Errorf("slowQuery 0: want disabled, got %d items", len(items))
+    }
+}
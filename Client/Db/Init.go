@@ -0,0 +1,27 @@
+package Db
+
+import (
+    "time"
+
+    "github.com/pinguo/pgo"
+)
+
+const (
+    defaultComponentId = "db"
+    defaultMaxOpenConn = 100
+    defaultMaxIdleConn = 10
+    defaultMaxLifetime = time.Hour
+    defaultPingTimeout = 3 * time.Second
+    defaultSlowQuery   = 200 * time.Millisecond
+
+    errSetProp  = "db: failed to set %s, %s"
+    errOpenFail = "db: failed to open %s, %s"
+    errPingFail = "db: failed to ping %s, %s"
+)
+
+func init() {
+    container := pgo.App.GetContainer()
+
+    container.Bind(&Adapter{})
+    container.Bind(&Client{})
+}
@@ -0,0 +1,437 @@
+package Db
+
+import (
+    "database/sql"
+    "errors"
+    "fmt"
+    "reflect"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+var (
+    ErrNoWhere = errors.New("db: update or delete without where")
+
+    identRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+    orderRe = regexp.MustCompile(`(?i)^([A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?)(\s+(ASC|DESC))?$`)
+)
+
+// Query lightweight query builder, values are always passed as args
+// of placeholders(? or $n for postgres), table, column and order names
+// are validated as identifiers, usage:
+// var users []User
+// e := db.Table("users").Where("age > ?", 18).OrderBy("id DESC").Limit(10).Get(&users)
+// res, e := db.Table("users").Insert(&User{Name: "x", Age: 20})
+// n, e := db.Table("users").Where("id = ?", 1).Update(map[string]interface{}{"age": 21})
+//
+// struct field maps to column by "db" tag or field name with lower
+// first letter, `db:"-"` is skipped, `db:"id,omitempty"` skips zero
+// value when insert or update, eg. auto increment id.
+type Query struct {
+    client  *Client
+    adapter *Adapter
+    tx      *Tx
+    table   string
+    columns []string
+    wheres  []string
+    args    []interface{}
+    orders  []string
+    limit   int
+    offset  int
+    master  bool
+}
+
+// build query of table, queries run without context, see Adapter.Table
+func (c *Client) Table(name string) *Query {
+    return newQuery(c, nil, name)
+}
+
+// build query of table, queries are profiled and canceled with context
+func (a *Adapter) Table(name string) *Query {
+    return newQuery(a.client, a, name)
+}
+
+func newQuery(client *Client, adapter *Adapter, table string) *Query {
+    return &Query{client: client, adapter: adapter, table: checkIdent(table)}
+}
+
+// select columns, all columns by default
+func (q *Query) Select(columns ...string) *Query {
+    for _, column := range columns {
+        q.columns = append(q.columns, checkIdent(column))
+    }
+
+    return q
+}
+
+// add where condition with ? placeholders, conditions are joined by AND
+func (q *Query) Where(cond string, args ...interface{}) *Query {
+    if n := strings.Count(cond, "?"); n != len(args) {
+        panic(fmt.Sprintf("db: where %q requires %d args, %d given", cond, n, len(args)))
+    }
+
+    q.wheres = append(q.wheres, "("+cond+")")
+    q.args = append(q.args, args...)
+    return q
+}
+
+// add where condition of column IN values, it's false if values is empty
+func (q *Query) WhereIn(column string, values ...interface{}) *Query {
+    if len(values) == 0 {
+        return q.Where("1 = 0")
+    }
+
+    holders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+    return q.Where(checkIdent(column)+" IN ("+holders+")", values...)
+}
+
+// add order, eg. "id" or "id DESC"
+func (q *Query) OrderBy(orders ...string) *Query {
+    for _, order := range orders {
+        if !orderRe.MatchString(strings.TrimSpace(order)) {
+            panic("db: invalid order, " + order)
+        }
+        q.orders = append(q.orders, strings.TrimSpace(order))
+    }
+
+    return q
+}
+
+func (q *Query) Limit(limit int) *Query {
+    q.limit = limit
+    return q
+}
+
+func (q *Query) Offset(offset int) *Query {
+    q.offset = offset
+    return q
+}
+
+// read from master db, eg. for read-after-write
+func (q *Query) Master() *Query {
+    q.master = true
+    return q
+}
+
+// get select statement and args
+func (q *Query) ToSql() (string, []interface{}) {
+    columns := "*"
+    if len(q.columns) > 0 {
+        columns = strings.Join(q.columns, ", ")
+    }
+
+    return q.selectSql(columns, true)
+}
+
+// query rows into out, out is pointer of slice of struct or struct pointer
+func (q *Query) Get(out interface{}) error {
+    sv := reflect.ValueOf(out)
+    if sv.Kind() != reflect.Ptr || sv.Elem().Kind() != reflect.Slice {
+        panic("db: Get requires pointer of slice")
+    }
+
+    query, args := q.ToSql()
+    rows, e := q.query(query, args)
+    if e != nil {
+        return e
+    }
+    defer rows.Close()
+
+    slice, et := sv.Elem(), sv.Elem().Type().Elem()
+    isPtr := et.Kind() == reflect.Ptr
+    if isPtr {
+        et = et.Elem()
+    }
+
+    slice.SetLen(0)
+    for rows.Next() {
+        item := reflect.New(et)
+        if e := scanRow(rows, item); e != nil {
+            return e
+        }
+
+        if isPtr {
+            slice.Set(reflect.Append(slice, item))
+        } else {
+            slice.Set(reflect.Append(slice, item.Elem()))
+        }
+    }
+
+    return rows.Err()
+}
+
+// query the first row into out, out is pointer of struct,
+// sql.ErrNoRows is returned if no row found
+func (q *Query) One(out interface{}) error {
+    rv := reflect.ValueOf(out)
+    if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+        panic("db: One requires pointer of struct")
+    }
+
+    q.limit = 1
+    query, args := q.ToSql()
+    rows, e := q.query(query, args)
+    if e != nil {
+        return e
+    }
+    defer rows.Close()
+
+    if !rows.Next() {
+        if e := rows.Err(); e != nil {
+            return e
+        }
+        return sql.ErrNoRows
+    }
+
+    return scanRow(rows, rv)
+}
+
+// count rows matching where conditions
+func (q *Query) Count() (int64, error) {
+    var count int64
+    query, args := q.selectSql("COUNT(*)", false)
+    rows, e := q.query(query, args)
+    if e != nil {
+        return 0, e
+    }
+    defer rows.Close()
+
+    if rows.Next() {
+        e = rows.Scan(&count)
+    }
+
+    if e == nil {
+        e = rows.Err()
+    }
+
+    return count, e
+}
+
+// insert a row from struct or map[string]interface{}
+func (q *Query) Insert(v interface{}) (sql.Result, error) {
+    columns, values := columnValues(v)
+    if len(columns) == 0 {
+        panic("db: insert without columns")
+    }
+
+    holders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+    query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", q.table, strings.Join(columns, ", "), holders)
+    return q.exec(query, values)
+}
+
+// update rows matching where conditions from struct or map[string]interface{},
+// ErrNoWhere is returned if no condition, use Where("1 = 1") to update all
+func (q *Query) Update(v interface{}) (int64, error) {
+    if len(q.wheres) == 0 {
+        return 0, ErrNoWhere
+    }
+
+    columns, values := columnValues(v)
+    if len(columns) == 0 {
+        panic("db: update without columns")
+    }
+
+    for i, column := range columns {
+        columns[i] = column + " = ?"
+    }
+
+    query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", q.table, strings.Join(columns, ", "), strings.Join(q.wheres, " AND "))
+    return rowsAffected(q.exec(query, append(values, q.args...)))
+}
+
+// delete rows matching where conditions, ErrNoWhere is returned if no condition
+func (q *Query) Delete() (int64, error) {
+    if len(q.wheres) == 0 {
+        return 0, ErrNoWhere
+    }
+
+    query := fmt.Sprintf("DELETE FROM %s WHERE %s", q.table, strings.Join(q.wheres, " AND "))
+    return rowsAffected(q.exec(query, q.args))
+}
+
+func (q *Query) selectSql(columns string, paging bool) (string, []interface{}) {
+    buf := &strings.Builder{}
+    fmt.Fprintf(buf, "SELECT %s FROM %s", columns, q.table)
+    if len(q.wheres) > 0 {
+        buf.WriteString(" WHERE " + strings.Join(q.wheres, " AND "))
+    }
+
+    args := append([]interface{}(nil), q.args...)
+    if paging {
+        if len(q.orders) > 0 {
+            buf.WriteString(" ORDER BY " + strings.Join(q.orders, ", "))
+        }
+
+        if q.limit > 0 {
+            buf.WriteString(" LIMIT ?")
+            args = append(args, q.limit)
+        }
+
+        if q.offset > 0 {
+            buf.WriteString(" OFFSET ?")
+            args = append(args, q.offset)
+        }
+    }
+
+    return q.client.rebind(buf.String()), args
+}
+
+func (q *Query) query(query string, args []interface{}) (*sql.Rows, error) {
+    if q.tx != nil {
+        return q.tx.Query(query, args...)
+    } else if q.adapter != nil && q.master {
+        return q.adapter.QueryMaster(query, args...)
+    } else if q.adapter != nil {
+        return q.adapter.Query(query, args...)
+    } else if q.master {
+        return q.client.GetDb().Query(query, args...)
+    }
+
+    return q.client.GetSlave().Query(query, args...)
+}
+
+func (q *Query) exec(query string, args []interface{}) (sql.Result, error) {
+    query = q.client.rebind(query)
+    if q.tx != nil {
+        return q.tx.Exec(query, args...)
+    } else if q.adapter != nil {
+        return q.adapter.Exec(query, args...)
+    }
+
+    return q.client.GetDb().Exec(query, args...)
+}
+
+// convert ? placeholders to $n for postgres drivers
+func (c *Client) rebind(query string) string {
+    switch c.driver {
+    case "postgres", "pgx", "pq":
+    default:
+        return query
+    }
+
+    buf, n := &strings.Builder{}, 0
+    for _, r := range query {
+        if r == '?' {
+            n++
+            buf.WriteString("$" + strconv.Itoa(n))
+        } else {
+            buf.WriteRune(r)
+        }
+    }
+
+    return buf.String()
+}
+
+func checkIdent(name string) string {
+    if name != "*" && !identRe.MatchString(name) {
+        panic("db: invalid identifier, " + name)
+    }
+
+    return name
+}
+
+func rowsAffected(res sql.Result, e error) (int64, error) {
+    if e != nil {
+        return 0, e
+    }
+
+    return res.RowsAffected()
+}
+
+// column of struct field
+type fieldInfo struct {
+    index     []int
+    omitEmpty bool
+}
+
+// get columns of struct type by db tag, embedded struct is mapped recursively
+func structFields(rt reflect.Type) map[string]fieldInfo {
+    fields := make(map[string]fieldInfo)
+    for i, n := 0, rt.NumField(); i < n; i++ {
+        sf := rt.Field(i)
+        if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+            for name, f := range structFields(sf.Type) {
+                if _, ok := fields[name]; !ok {
+                    fields[name] = fieldInfo{append([]int{i}, f.index...), f.omitEmpty}
+                }
+            }
+            continue
+        }
+
+        if len(sf.PkgPath) > 0 {
+            continue // unexported
+        }
+
+        tag := strings.Split(sf.Tag.Get("db"), ",")
+        name := tag[0]
+        if name == "-" {
+            continue
+        } else if len(name) == 0 {
+            name = strings.ToLower(sf.Name[:1]) + sf.Name[1:]
+        }
+
+        fields[name] = fieldInfo{[]int{i}, len(tag) > 1 && tag[1] == "omitempty"}
+    }
+
+    return fields
+}
+
+// scan current row into struct pointer, unknown columns are discarded
+func scanRow(rows *sql.Rows, ptr reflect.Value) error {
+    columns, e := rows.Columns()
+    if e != nil {
+        return e
+    }
+
+    rv, fields := ptr.Elem(), structFields(ptr.Elem().Type())
+    dest := make([]interface{}, len(columns))
+    for i, column := range columns {
+        if f, ok := fields[column]; ok {
+            dest[i] = rv.FieldByIndex(f.index).Addr().Interface()
+        } else {
+            dest[i] = new(interface{})
+        }
+    }
+
+    return rows.Scan(dest...)
+}
+
+// get sorted columns and values of struct or map
+func columnValues(v interface{}) ([]string, []interface{}) {
+    var columns []string
+    var values []interface{}
+
+    if m, ok := v.(map[string]interface{}); ok {
+        for column := range m {
+            columns = append(columns, checkIdent(column))
+        }
+
+        sort.Strings(columns)
+        for _, column := range columns {
+            values = append(values, m[column])
+        }
+
+        return columns, values
+    }
+
+    rv := reflect.Indirect(reflect.ValueOf(v))
+    if rv.Kind() != reflect.Struct {
+        panic(fmt.Sprintf("db: invalid row type %T, require struct or map", v))
+    }
+
+    fields := structFields(rv.Type())
+    for column, f := range fields {
+        if field := rv.FieldByIndex(f.index); !f.omitEmpty || !field.IsZero() {
+            columns = append(columns, checkIdent(column))
+        }
+    }
+
+    sort.Strings(columns)
+    for _, column := range columns {
+        values = append(values, rv.FieldByIndex(fields[column].index).Interface())
+    }
+
+    return columns, values
+}
@@ -0,0 +1,298 @@
+package Db
+
+import (
+    "context"
+    "database/sql"
+    "database/sql/driver"
+    "errors"
+    "fmt"
+    "io"
+    "reflect"
+    "strconv"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+// fake driver records statements and returns rows set by test
+type fakeDb struct {
+    lock     sync.Mutex
+    log      []string
+    columns  []string
+    rows     [][]driver.Value
+    failExec string        // exec of statement with this prefix fails
+    failPing bool          // ping of connection fails
+    delay    time.Duration // delay of exec and query
+}
+
+var (
+    fakeDbs  sync.Map
+    fakeSeq  int64
+    fakeOnce sync.Once
+)
+
+func (d *fakeDb) record(query string, args []driver.NamedValue) {
+    d.lock.Lock()
+    defer d.lock.Unlock()
+
+    values := make([]string, 0, len(args))
+    for _, arg := range args {
+        values = append(values, fmt.Sprint(arg.Value))
+    }
+
+    if len(values) > 0 {
+        query += " " + strings.Join(values, ",")
+    }
+    d.log = append(d.log, query)
+}
+
+func (d *fakeDb) statements() []string {
+    d.lock.Lock()
+    defer d.lock.Unlock()
+
+    return append([]string(nil), d.log...)
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+    db, ok := fakeDbs.Load(name)
+    if !ok {
+        return nil, errors.New("unknown fake db " + name)
+    }
+    return &fakeConn{db.(*fakeDb)}, nil
+}
+
+type fakeConn struct {
+    db *fakeDb
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+    return nil, errors.New("prepare not supported")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+    return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+    c.db.record("BEGIN", nil)
+    return &fakeTx{c.db}, nil
+}
+
+func (c *fakeConn) Ping(ctx context.Context) error {
+    if c.db.failPing {
+        return errors.New("ping failed")
+    }
+    return nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+    c.db.record(query, args)
+    time.Sleep(c.db.delay)
+    if len(c.db.failExec) > 0 && strings.HasPrefix(query, c.db.failExec) {
+        return nil, errors.New("exec failed")
+    }
+    return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+    c.db.record(query, args)
+    time.Sleep(c.db.delay)
+    c.db.lock.Lock()
+    defer c.db.lock.Unlock()
+
+    return &fakeRows{columns: c.db.columns, rows: c.db.rows}, nil
+}
+
+type fakeTx struct {
+    db *fakeDb
+}
+
+func (t *fakeTx) Commit() error {
+    t.db.record("COMMIT", nil)
+    return nil
+}
+
+func (t *fakeTx) Rollback() error {
+    t.db.record("ROLLBACK", nil)
+    return nil
+}
+
+type fakeRows struct {
+    columns []string
+    rows    [][]driver.Value
+    pos     int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+    if r.pos >= len(r.rows) {
+        return io.EOF
+    }
+
+    copy(dest, r.rows[r.pos])
+    r.pos++
+    return nil
+}
+
+// add a new fake db, return its dsn
+func newFakeDb() (*fakeDb, string) {
+    fakeOnce.Do(func() {
+        sql.Register("dbtest", fakeDriver{})
+        sql.Register("postgres", fakeDriver{})
+    })
+
+    fakeSeq++
+    db, dsn := &fakeDb{}, "fake"+strconv.FormatInt(fakeSeq, 10)
+    fakeDbs.Store(dsn, db)
+
+    return db, dsn
+}
+
+// create client of a new fake db, driver name selects placeholder style
+func newTestClient(t *testing.T, driverName string) (*Client, *fakeDb) {
+    db, dsn := newFakeDb()
+
+    c := &Client{}
+    c.Construct()
+    c.SetDriver(driverName)
+    c.SetDsn(dsn)
+    c.SetHealthName("")
+    c.Init()
+    t.Cleanup(func() { c.Stop() })
+
+    return c, db
+}
+
+type testUser struct {
+    Id    int64  `db:"id,omitempty"`
+    Name  string `db:"name"`
+    Age   int
+    Extra string `db:"-"`
+}
+
+func TestQueryToSql(t *testing.T) {
+    c, _ := newTestClient(t, "dbtest")
+    query, args := c.Table("users").Select("id", "name").Where("age > ?", 18).WhereIn("status", 1, 2).
+        OrderBy("id DESC", "name").Limit(10).Offset(20).ToSql()
+
+    want := "SELECT id, name FROM users WHERE (age > ?) AND (status IN (?, ?)) ORDER BY id DESC, name LIMIT ? OFFSET ?"
+    if query != want || !reflect.DeepEqual(args, []interface{}{18, 1, 2, 10, 20}) {
+        t.Errorf("want %q [18 1 2 10 20], got %q %v", want, query, args)
+    }
+
+    if query, _ := c.Table("users").WhereIn("id").ToSql(); query != "SELECT * FROM users WHERE (1 = 0)" {
+        t.Errorf("empty in: want false condition, got %q", query)
+    }
+
+    pg, _ := newTestClient(t, "postgres")
+    if query, _ := pg.Table("users").Where("id = ? OR name = ?", 1, "x").Limit(1).ToSql(); query != "SELECT * FROM users WHERE (id = $1 OR name = $2) LIMIT $3" {
+        t.Errorf("postgres: want $n placeholders, got %q", query)
+    }
+}
+
+func TestQueryInvalidInput(t *testing.T) {
+    c, _ := newTestClient(t, "dbtest")
+    tests := map[string]func(){
+        "table":  func() { c.Table("users; DROP TABLE users") },
+        "column": func() { c.Table("users").Select("name, (SELECT 1)") },
+        "order":  func() { c.Table("users").OrderBy("id; DROP") },
+        "args":   func() { c.Table("users").Where("id = ? AND age = ?", 1) },
+    }
+
+    for name, fn := range tests {
+        func() {
+            defer func() {
+                if recover() == nil {
+                    t.Errorf("invalid %s: want panic", name)
+                }
+            }()
+            fn()
+        }()
+    }
+}
+
+func TestQueryGetAndOne(t *testing.T) {
+    c, db := newTestClient(t, "dbtest")
+    db.columns = []string{"id", "name", "age", "unknown"}
+    db.rows = [][]driver.Value{{int64(1), "foo", int64(20), "x"}, {int64(2), "bar", int64(30), "y"}}
+
+    var users []testUser
+    if e := c.Table("users").Get(&users); e != nil {
+        t.Fatal(e)
+    }
+
+    want := []testUser{{Id: 1, Name: "foo", Age: 20}, {Id: 2, Name: "bar", Age: 30}}
+    if !reflect.DeepEqual(users, want) {
+        t.Errorf("want %+v, got %+v", want, users)
+    }
+
+    var ptrs []*testUser
+    if e := c.Table("users").Get(&ptrs); e != nil || len(ptrs) != 2 || *ptrs[1] != want[1] {
+        t.Errorf("pointer slice: want %+v, got %v %v", want, ptrs, e)
+    }
+
+    var user testUser
+    if e := c.Table("users").Where("id = ?", 1).One(&user); e != nil || user != want[0] {
+        t.Errorf("one: want %+v, got %+v %v", want[0], user, e)
+    }
+
+    if stmts := db.statements(); stmts[len(stmts)-1] != "SELECT * FROM users WHERE (id = ?) LIMIT ? 1,1" {
+        t.Errorf("one: want limit 1, got %q", stmts[len(stmts)-1])
+    }
+
+    db.rows = nil
+    if e := c.Table("users").One(&user); e != sql.ErrNoRows {
+        t.Errorf("no rows: want sql.ErrNoRows, got %v", e)
+    }
+}
+
+func TestQueryCount(t *testing.T) {
+    c, db := newTestClient(t, "dbtest")
+    db.columns = []string{"COUNT(*)"}
+    db.rows = [][]driver.Value{{int64(42)}}
+
+    n, e := c.Table("users").Where("age > ?", 18).OrderBy("id").Limit(5).Count()
+    if e != nil || n != 42 {
+        t.Errorf("want 42, got %d %v", n, e)
+    }
+
+    // order and paging do not apply to count
+    if stmts := db.statements(); stmts[0] != "SELECT COUNT(*) FROM users WHERE (age > ?) 18" {
+        t.Errorf("want count without paging, got %q", stmts[0])
+    }
+}
+
+func TestQueryWrite(t *testing.T) {
+    c, db := newTestClient(t, "dbtest")
+    c.Table("users").Insert(&testUser{Name: "foo", Age: 20, Extra: "skip"})
+    c.Table("users").Insert(map[string]interface{}{"name": "bar", "age": 30})
+    c.Table("users").Where("id = ?", 1).Update(map[string]interface{}{"age": 21})
+    c.Table("users").Where("id = ?", 2).Delete()
+
+    want := []string{
+        "INSERT INTO users (age, name) VALUES (?, ?) 20,foo",
+        "INSERT INTO users (age, name) VALUES (?, ?) 30,bar",
+        "UPDATE users SET age = ? WHERE (id = ?) 21,1",
+        "DELETE FROM users WHERE (id = ?) 2",
+    }
+
+    if stmts := db.statements(); !reflect.DeepEqual(stmts, want) {
+        t.Errorf("want %q, got %q", want, stmts)
+    }
+
+    if _, e := c.Table("users").Update(map[string]interface{}{"age": 1}); e != ErrNoWhere {
+        t.Errorf("update without where: want ErrNoWhere, got %v", e)
+    }
+
+    if _, e := c.Table("users").Delete(); e != ErrNoWhere {
+        t.Errorf("delete without where: want ErrNoWhere, got %v", e)
+    }
+}
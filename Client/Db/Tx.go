@@ -0,0 +1,122 @@
+package Db
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "strconv"
+)
+
+// Tx transaction of master db, queries run with context of transaction,
+// nested Transaction call uses savepoint, usage:
+// e := db.Transaction(ctx, func(tx *Db.Tx) error {
+//     if _, e := tx.Table("account").Where("id = ?", 1).Update(map[string]interface{}{"balance": 0}); e != nil {
+//         return e
+//     }
+//     return tx.Transaction(func(tx *Db.Tx) error { ... })
+// })
+type Tx struct {
+    *sql.Tx
+    client *Client
+    ctx    context.Context
+    depth  int
+}
+
+// run fn in a transaction of master db, it's committed if fn returns nil,
+// and rolled back if fn returns error or panics(panic is propagated after
+// rollback), or ctx is done before commit.
+func (c *Client) Transaction(ctx context.Context, fn func(tx *Tx) error) error {
+    if ctx == nil {
+        ctx = context.Background()
+    }
+
+    stx, e := c.GetDb().BeginTx(ctx, nil)
+    if e != nil {
+        return e
+    }
+
+    tx := &Tx{Tx: stx, client: c, ctx: ctx}
+    return tx.run(fn, stx.Commit, stx.Rollback)
+}
+
+// run fn in a transaction canceled with context, see Client.Transaction
+func (a *Adapter) Transaction(fn func(tx *Tx) error) error {
+    profile := "Db.Transaction"
+    a.GetContext().ProfileStart(profile)
+    defer a.GetContext().ProfileStop(profile)
+
+    return a.client.Transaction(a.GetContext().GetStdContext(), fn)
+}
+
+// run fn in a nested transaction using savepoint, only changes of fn are
+// rolled back on error or panic, the outer transaction continues.
+func (t *Tx) Transaction(fn func(tx *Tx) error) error {
+    t.depth++
+    defer func() { t.depth-- }()
+
+    savepoint := "sp_" + strconv.Itoa(t.depth)
+    if _, e := t.Exec("SAVEPOINT " + savepoint); e != nil {
+        return e
+    }
+
+    release := func() error {
+        _, e := t.Exec("RELEASE SAVEPOINT " + savepoint)
+        return e
+    }
+
+    rollback := func() error {
+        _, e := t.Exec("ROLLBACK TO SAVEPOINT " + savepoint)
+        return e
+    }
+
+    return t.run(fn, release, rollback)
+}
+
+// build query of table in this transaction
+func (t *Tx) Table(name string) *Query {
+    q := newQuery(t.client, nil, name)
+    q.tx = t
+    return q
+}
+
+// query rows in transaction
+func (t *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+    return t.QueryContext(t.ctx, query, args...)
+}
+
+// query one row in transaction
+func (t *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+    return t.QueryRowContext(t.ctx, query, args...)
+}
+
+// execute statement in transaction
+func (t *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+    return t.ExecContext(t.ctx, query, args...)
+}
+
+func (t *Tx) run(fn func(tx *Tx) error, commit, rollback func() error) error {
+    done := false
+    defer func() {
+        // fn panics, rollback and let the panic go on
+        if !done {
+            rollback()
+        }
+    }()
+
+    e := fn(t)
+    done = true
+
+    if e == nil && t.ctx.Err() != nil {
+        e = t.ctx.Err()
+    }
+
+    if e != nil {
+        if re := rollback(); re != nil && !errors.Is(re, sql.ErrTxDone) {
+            return fmt.Errorf("%w, rollback failed, %v", e, re)
+        }
+        return e
+    }
+
+    return commit()
+}
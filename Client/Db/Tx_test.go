@@ -0,0 +1,131 @@
+package Db
+
+import (
+    "context"
+    "errors"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestTransactionCommit(t *testing.T) {
+    c, db := newTestClient(t, "dbtest")
+    e := c.Transaction(nil, func(tx *Tx) error {
+        _, e := tx.Table("account").Where("id = ?", 1).Update(map[string]interface{}{"balance": 0})
+        return e
+    })
+
+    want := []string{"BEGIN", "UPDATE account SET balance = ? WHERE (id = ?) 0,1", "COMMIT"}
+    if stmts := db.statements(); e != nil || !reflect.DeepEqual(stmts, want) {
+        t.Errorf("want %q, got %q %v", want, stmts, e)
+    }
+}
+
+func TestTransactionRollbackOnError(t *testing.T) {
+    c, db := newTestClient(t, "dbtest")
+    db.failExec = "UPDATE"
+    e := c.Transaction(context.Background(), func(tx *Tx) error {
+        if _, e := tx.Exec("INSERT INTO log (msg) VALUES (?)", "x"); e != nil {
+            return e
+        }
+        _, e := tx.Table("account").Where("id = ?", 1).Update(map[string]interface{}{"balance": 0})
+        return e
+    })
+
+    if e == nil || e.Error() != "exec failed" {
+        t.Errorf("want error of fn, got %v", e)
+    }
+
+    if stmts := db.statements(); stmts[len(stmts)-1] != "ROLLBACK" {
+        t.Errorf("want rollback, got %q", stmts)
+    }
+}
+
+func TestTransactionRollbackOnPanic(t *testing.T) {
+    c, db := newTestClient(t, "dbtest")
+    defer func() {
+        if v := recover(); v != "boom" {
+            t.Errorf("want panic propagated, got %v", v)
+        }
+
+        if stmts := db.statements(); !reflect.DeepEqual(stmts, []string{"BEGIN", "ROLLBACK"}) {
+            t.Errorf("want rollback before panic goes on, got %q", stmts)
+        }
+    }()
+
+    c.Transaction(nil, func(tx *Tx) error { panic("boom") })
+}
+
+func TestTransactionContextDone(t *testing.T) {
+    c, db := newTestClient(t, "dbtest")
+    ctx, cancel := context.WithCancel(context.Background())
+    e := c.Transaction(ctx, func(tx *Tx) error {
+        cancel()
+        return nil
+    })
+
+    if !errors.Is(e, context.Canceled) {
+        t.Errorf("want context.Canceled, got %v", e)
+    }
+
+    for _, stmt := range db.statements() {
+        if stmt == "COMMIT" {
+            t.Error("ctx done before commit: want no commit")
+        }
+    }
+}
+
+func TestTransactionSavepoint(t *testing.T) {
+    c, db := newTestClient(t, "dbtest")
+    e := c.Transaction(nil, func(tx *Tx) error {
+        tx.Exec("UPDATE a SET n = 1")
+        inner := tx.Transaction(func(tx *Tx) error {
+            tx.Exec("UPDATE b SET n = 1")
+            return errors.New("inner failed")
+        })
+
+        if inner == nil {
+            t.Error("inner transaction: want its error")
+        }
+
+        return tx.Transaction(func(tx *Tx) error {
+            return tx.Transaction(func(tx *Tx) error {
+                _, e := tx.Exec("UPDATE c SET n = 1")
+                return e
+            })
+        })
+    })
+
+    want := []string{
+        "BEGIN",
+        "UPDATE a SET n = 1",
+        "SAVEPOINT sp_1",
+        "UPDATE b SET n = 1",
+        "ROLLBACK TO SAVEPOINT sp_1",
+        "SAVEPOINT sp_1",
+        "SAVEPOINT sp_2",
+        "UPDATE c SET n = 1",
+        "RELEASE SAVEPOINT sp_2",
+        "RELEASE SAVEPOINT sp_1",
+        "COMMIT",
+    }
+
+    if stmts := db.statements(); e != nil || !reflect.DeepEqual(stmts, want) {
+        t.Errorf("want\n%s\ngot\n%s %v", strings.Join(want, "\n"), strings.Join(stmts, "\n"), e)
+    }
+}
+
+func TestTransactionSavepointPanic(t *testing.T) {
+    c, db := newTestClient(t, "dbtest")
+    func() {
+        defer func() { recover() }()
+        c.Transaction(nil, func(tx *Tx) error {
+            return tx.Transaction(func(tx *Tx) error { panic("boom") })
+        })
+    }()
+
+    want := []string{"BEGIN", "SAVEPOINT sp_1", "ROLLBACK TO SAVEPOINT sp_1", "ROLLBACK"}
+    if stmts := db.statements(); !reflect.DeepEqual(stmts, want) {
+        t.Errorf("want %q, got %q", want, stmts)
+    }
+}
@@ -1,6 +1,7 @@
 package Http
 
 import (
+    "io"
     "net/http"
     "sync"
     "time"
@@ -9,7 +10,8 @@ import (
     "github.com/pinguo/pgo/Util"
 )
 
-// Adapter of Http Client, add context support.
+// Adapter of Http Client, add context support, log id of context is
+// sent as X-Log-Id header and requests are logged by logger of context.
 // usage: http := this.GetObject("@pgo/Client/Http/Adapter").(*Adapter)
 type Adapter struct {
     pgo.Object
@@ -43,34 +45,89 @@ func (a *Adapter) handlePanic() {
     }
 }
 
+// copy option with log id header of context, so downstream logs can be
+// correlated with this request, option of caller is not modified
+func (a *Adapter) withLogId(option []*Option) []*Option {
+    opt := &Option{}
+    if len(option) > 0 && option[0] != nil {
+        *opt = *option[0]
+        opt.Header = option[0].Header.Clone()
+    }
+
+    if len(opt.Header.Get(pgo.LogIdHeader)) == 0 {
+        opt.SetHeader(pgo.LogIdHeader, a.GetContext().GetLogId())
+    }
+
+    return []*Option{opt}
+}
+
+// log request by logger of context, so it carries log id of the request
+func (a *Adapter) logRequest(method, addr string, start time.Time, res **http.Response) {
+    elapse := time.Since(start) / time.Millisecond
+    if *res == nil {
+        a.GetContext().Debug("Http: %s %s, failed, %dms", method, addr, elapse)
+    } else {
+        a.GetContext().Debug("Http: %s %s, status %d, %dms", method, addr, (*res).StatusCode, elapse)
+    }
+}
+
 // Get perform a get request
-func (a *Adapter) Get(addr string, data interface{}, option ...*Option) *http.Response {
+func (a *Adapter) Get(addr string, data interface{}, option ...*Option) (res *http.Response) {
     profile := baseUrl(addr)
     a.GetContext().ProfileStart(profile)
     defer a.GetContext().ProfileStop(profile)
     defer a.handlePanic()
+    defer a.logRequest(http.MethodGet, profile, time.Now(), &res)
 
-    return a.client.Get(addr, data, option...)
+    return a.client.Get(addr, data, a.withLogId(option)...)
 }
 
 // Post perform a post request
-func (a *Adapter) Post(addr string, data interface{}, option ...*Option) *http.Response {
+func (a *Adapter) Post(addr string, data interface{}, option ...*Option) (res *http.Response) {
     profile := baseUrl(addr)
     a.GetContext().ProfileStart(profile)
     defer a.GetContext().ProfileStop(profile)
     defer a.handlePanic()
+    defer a.logRequest(http.MethodPost, profile, time.Now(), &res)
 
-    return a.client.Post(addr, data, option...)
+    return a.client.Post(addr, data, a.withLogId(option)...)
 }
 
 // Do perform a single request
-func (a *Adapter) Do(req *http.Request, option ...*Option) *http.Response {
+func (a *Adapter) Do(req *http.Request, option ...*Option) (res *http.Response) {
     profile := baseUrl(req.URL.String())
     a.GetContext().ProfileStart(profile)
     defer a.GetContext().ProfileStop(profile)
     defer a.handlePanic()
+    defer a.logRequest(req.Method, profile, time.Now(), &res)
 
-    return a.client.Do(req, option...)
+    return a.client.Do(req, a.withLogId(option)...)
+}
+
+// Download perform a get request and stream body to w
+func (a *Adapter) Download(addr string, w io.Writer, option ...*Option) int64 {
+    profile := baseUrl(addr)
+    a.GetContext().ProfileStart(profile)
+    defer a.GetContext().ProfileStop(profile)
+    defer a.handlePanic()
+
+    return a.client.Download(addr, w, a.withLogId(option)...)
+}
+
+// Paginate perform paginated requests with context, request of every
+// page is canceled with context and carries log id, see Client.Paginate
+func (a *Adapter) Paginate(req *http.Request, next NextPage, fn func(page *Page) error, option ...*Option) error {
+    option = a.withLogId(option)
+    req = req.WithContext(a.GetContext().GetStdContext())
+
+    return paginate(req, next, fn, func(req *http.Request) (res *http.Response) {
+        profile := baseUrl(req.URL.String())
+        a.GetContext().ProfileStart(profile)
+        defer a.GetContext().ProfileStop(profile)
+        defer a.logRequest(req.Method, profile, time.Now(), &res)
+
+        return a.client.Do(req, option...)
+    })
 }
 
 // DoMulti perform multi requests concurrently
@@ -103,10 +160,12 @@ func (a *Adapter) DoMulti(reqArr []*http.Request, option ...*Option) []*http.Res
             lock.Unlock()
         }()
 
-        if len(option) > 0 && option[k] != nil {
-            res = a.client.Do(reqArr[k], option[k])
+        defer a.logRequest(reqArr[k].Method, profile, start, &res)
+
+        if len(option) > 0 {
+            res = a.client.Do(reqArr[k], a.withLogId(option[k:k+1])...)
         } else {
-            res = a.client.Do(reqArr[k])
+            res = a.client.Do(reqArr[k], a.withLogId(nil)...)
         }
     }
 
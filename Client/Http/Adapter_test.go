@@ -0,0 +1,54 @@
+package Http
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync"
+    "testing"
+
+    "github.com/pinguo/pgo"
+    "github.com/pinguo/pgo/Test"
+)
+
+func TestAdapterLogId(t *testing.T) {
+    var lock sync.Mutex
+    var got []string
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        lock.Lock()
+        got = append(got, r.Header.Get(pgo.LogIdHeader))
+        lock.Unlock()
+    }))
+    defer srv.Close()
+
+    c := &Client{}
+    c.Construct()
+
+    r := Test.NewRequest("GET", "/", nil)
+    r.Header.Set(pgo.LogIdHeader, "upstream-id")
+    ctx, _ := Test.NewRequestContext(r)
+
+    var items []*pgo.LogItem
+    ctx.SetTap(func(item *pgo.LogItem) { items = append(items, item) })
+
+    a := &Adapter{client: c}
+    a.SetContext(ctx)
+
+    option := (&Option{}).SetHeader("X-Other", "1")
+    a.Get(srv.URL+"/a", nil, option)
+    a.Post(srv.URL+"/b", nil)
+    a.Get(srv.URL+"/c", nil, (&Option{}).SetHeader(pgo.LogIdHeader, "explicit-id"))
+
+    want := []string{"upstream-id", "upstream-id", "explicit-id"}
+    if strings.Join(got, ",") != strings.Join(want, ",") {
+        t.Errorf("want downstream log ids %v, got %v", want, got)
+    }
+
+    if len(option.Header.Get(pgo.LogIdHeader)) > 0 {
+        t.Error("want option of caller not modified")
+    }
+
+    if len(items) != 3 || items[0].LogId != "upstream-id" || !strings.HasPrefix(items[0].Message, "Http: GET "+srv.URL+"/a, status 200") {
+        t.Errorf("want requests logged with log id of context, got %d items", len(items))
+    }
+}
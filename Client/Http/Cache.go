@@ -0,0 +1,247 @@
+package Http
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "regexp"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/pinguo/pgo"
+    "github.com/pinguo/pgo/Util"
+)
+
+// cache policy of urls matching pattern
+type cachePolicy struct {
+    pattern *regexp.Regexp
+    ttl     time.Duration
+    stale   time.Duration
+    query   []string // query params in key, nil for whole url
+    headers []string // request headers in key
+}
+
+// cached response, fields are exported for pgo.Encode
+type cachedResponse struct {
+    Status int
+    Header http.Header
+    Body   []byte
+    Fresh  int64 // unix nano until which response is fresh
+}
+
+// set read-through cache policies of GET requests, the first policy
+// matching url(with query) applies, eg.
+// "cachePolicies": [{
+//     "pattern": "^https://api\\.partner\\.com/v1/items",
+//     "ttl": "60s",
+//     "stale": "5m",
+//     "query": ["id", "lang"],
+//     "headers": ["Accept-Language"]
+// }]
+//
+// key of response is url, or url without query plus only the listed
+// query params if query is set, plus listed headers. response of 200
+// is cached for ttl, then served stale for up to stale while one
+// request refreshes it in background, request with no-cache header
+// skips lookup but stores response, response with no-store or private
+// is not stored, storage is id of an ICache component("cache" by
+// default), see SetCacheStorage.
+func (c *Client) SetCachePolicies(policies []interface{}) {
+    c.cachePolicies = make([]*cachePolicy, 0, len(policies))
+    for _, v := range policies {
+        m, ok := v.(map[string]interface{})
+        if !ok {
+            panic(fmt.Sprintf("http invalid cache policy: %v", v))
+        }
+
+        ttl, e := time.ParseDuration(Util.ToString(m["ttl"]))
+        if e != nil || ttl <= 0 {
+            panic(fmt.Sprintf("http invalid cache ttl: %v", m["ttl"]))
+        }
+
+        var stale time.Duration
+        if s, ok := m["stale"]; ok {
+            if stale, e = time.ParseDuration(Util.ToString(s)); e != nil {
+                panic(fmt.Sprintf("http invalid cache stale: %v", s))
+            }
+        }
+
+        policy := &cachePolicy{pattern: regexp.MustCompile(Util.ToString(m["pattern"])), ttl: ttl, stale: stale}
+        if query, ok := m["query"].([]interface{}); ok {
+            policy.query = make([]string, 0, len(query))
+            for _, q := range query {
+                policy.query = append(policy.query, Util.ToString(q))
+            }
+            sort.Strings(policy.query)
+        }
+
+        if headers, ok := m["headers"].([]interface{}); ok {
+            for _, h := range headers {
+                policy.headers = append(policy.headers, http.CanonicalHeaderKey(Util.ToString(h)))
+            }
+        }
+
+        c.cachePolicies = append(c.cachePolicies, policy)
+    }
+}
+
+// set id of ICache component to store cached responses
+func (c *Client) SetCacheStorage(storage string) {
+    c.cacheStorage = storage
+}
+
+// get cache policy of request, nil if not cacheable
+func (c *Client) getCachePolicy(req *http.Request, option []*Option) *cachePolicy {
+    if len(c.cachePolicies) == 0 || req.Method != http.MethodGet {
+        return nil
+    } else if len(option) > 0 && option[0] != nil && option[0].Stream {
+        return nil
+    }
+
+    addr := req.URL.String()
+    for _, policy := range c.cachePolicies {
+        if policy.pattern.MatchString(addr) {
+            return policy
+        }
+    }
+
+    return nil
+}
+
+// perform request through cache of policy
+func (c *Client) doCached(req *http.Request, policy *cachePolicy, option []*Option) *http.Response {
+    key := policy.buildKey(req)
+    if !hasNoCache(req.Header.Get("Cache-Control")) && !hasNoCache(req.Header.Get("Pragma")) {
+        if cached := c.loadCache(key); cached != nil {
+            if time.Now().UnixNano() < cached.Fresh {
+                return cached.toResponse(req, "HIT")
+            }
+
+            // stale, refresh in background
+            go func() {
+                if r := <-c.fetchShared(req, key, policy, option); r.Err != nil {
+                    pgo.GLogger().Warn("http failed to refresh cache of %s, %s", baseUrl(req.URL.String()), r.Err)
+                }
+            }()
+            return cached.toResponse(req, "STALE")
+        }
+    }
+
+    // cold miss, wait for the shared fetch until request is canceled
+    select {
+    case r := <-c.fetchShared(req, key, policy, option):
+        if r.Err != nil {
+            panic("http request failed, " + r.Err.Error())
+        }
+        return r.Value.(*cachedResponse).toResponse(req, "MISS")
+    case <-req.Context().Done():
+        panic("http request failed, " + req.Context().Err().Error())
+    }
+}
+
+// fetch response of key once for concurrent callers, the request is
+// detached from caller so one canceled caller does not fail others
+func (c *Client) fetchShared(req *http.Request, key string, policy *cachePolicy, option []*Option) <-chan Util.FlightResult {
+    sharedReq := req.Clone(context.Background())
+    return c.cacheFlight.DoChan(key, func() (interface{}, error) {
+        res := c.fetchCache(sharedReq, key, policy, option)
+        defer res.Body.Close()
+
+        body, e := ioutil.ReadAll(res.Body)
+        if e != nil {
+            return nil, e
+        }
+
+        return &cachedResponse{res.StatusCode, res.Header.Clone(), body, 0}, nil
+    })
+}
+
+// perform request and store cacheable response
+func (c *Client) fetchCache(req *http.Request, key string, policy *cachePolicy, option []*Option) *http.Response {
+    res := c.do(req, option...)
+    cc := strings.ToLower(res.Header.Get("Cache-Control"))
+    if res.StatusCode != http.StatusOK || strings.Contains(cc, "no-store") || strings.Contains(cc, "private") {
+        return res
+    }
+
+    body, e := ioutil.ReadAll(res.Body)
+    res.Body.Close()
+    if e != nil {
+        panic("http request failed, " + e.Error())
+    }
+
+    res.Body = ioutil.NopCloser(bytes.NewReader(body))
+    if len(body) <= maxCacheBytes {
+        cached := &cachedResponse{res.StatusCode, res.Header.Clone(), body, time.Now().Add(policy.ttl).UnixNano()}
+        pgo.App.Get(c.cacheStorage).(pgo.ICache).Set(key, pgo.Encode(cached), policy.ttl+policy.stale)
+    }
+
+    return res
+}
+
+func (c *Client) loadCache(key string) *cachedResponse {
+    v := pgo.App.Get(c.cacheStorage).(pgo.ICache).Get(key)
+    if v == nil || !v.Valid() {
+        return nil
+    }
+
+    cached := &cachedResponse{}
+    if e := v.TryDecode(cached); e != nil {
+        return nil
+    }
+
+    return cached
+}
+
+func (p *cachePolicy) buildKey(req *http.Request) string {
+    buf := &bytes.Buffer{}
+    if p.query == nil {
+        buf.WriteString(req.URL.String())
+    } else {
+        u := *req.URL
+        u.RawQuery, u.Fragment = "", ""
+        buf.WriteString(u.String())
+
+        query := req.URL.Query()
+        for _, q := range p.query {
+            buf.WriteByte('\n')
+            buf.WriteString(q)
+            buf.WriteByte('=')
+            buf.WriteString(strings.Join(query[q], ","))
+        }
+    }
+
+    for _, h := range p.headers {
+        buf.WriteByte('\n')
+        buf.WriteString(h)
+        buf.WriteByte(':')
+        buf.WriteString(req.Header.Get(h))
+    }
+
+    return "pgo_hc_" + Util.Md5String(buf.Bytes())
+}
+
+// build response of cached, X-Cache header is HIT or STALE
+func (r *cachedResponse) toResponse(req *http.Request, state string) *http.Response {
+    header := r.Header.Clone()
+    header.Set("X-Cache", state)
+
+    return &http.Response{
+        Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+        StatusCode:    r.Status,
+        Proto:         "HTTP/1.1",
+        ProtoMajor:    1,
+        ProtoMinor:    1,
+        Header:        header,
+        Body:          ioutil.NopCloser(bytes.NewReader(r.Body)),
+        ContentLength: int64(len(r.Body)),
+        Request:       req,
+    }
+}
+
+func hasNoCache(v string) bool {
+    return strings.Contains(strings.ToLower(v), "no-cache")
+}
@@ -0,0 +1,102 @@
+package Http
+
+import (
+    "io/ioutil"
+    "net/http"
+    "net/http/httptest"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "github.com/pinguo/pgo"
+    "github.com/pinguo/pgo/Client/Memory"
+)
+
+func newCacheClient(storage string) *Client {
+    mem := &Memory.Client{}
+    mem.Construct()
+    pgo.App.SetComponent(storage, mem)
+
+    c := &Client{}
+    c.Construct()
+    c.SetCacheStorage(storage)
+    c.SetCachePolicies([]interface{}{map[string]interface{}{
+        "pattern": "/items",
+        "ttl":     "60s",
+    }})
+
+    return c
+}
+
+func TestCacheColdMissSharesFetch(t *testing.T) {
+    var hits int32
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        atomic.AddInt32(&hits, 1)
+        time.Sleep(50 * time.Millisecond)
+        w.Write([]byte("items"))
+    }))
+    defer srv.Close()
+
+    c := newCacheClient("httpCacheColdMiss")
+    wg := sync.WaitGroup{}
+    for i := 0; i < 5; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            res := c.Get(srv.URL+"/items", nil)
+            body, _ := ioutil.ReadAll(res.Body)
+            res.Body.Close()
+
+            if string(body) != "items" || res.Header.Get("X-Cache") != "MISS" {
+                t.Errorf("want MISS items, got %s %q", res.Header.Get("X-Cache"), body)
+            }
+        }()
+    }
+    wg.Wait()
+
+    if n := atomic.LoadInt32(&hits); n != 1 {
+        t.Errorf("concurrent cold miss: want 1 upstream request, got %d", n)
+    }
+
+    res := c.Get(srv.URL+"/items", nil)
+    res.Body.Close()
+    if res.Header.Get("X-Cache") != "HIT" || atomic.LoadInt32(&hits) != 1 {
+        t.Errorf("want HIT from cache, got %s with %d upstream requests", res.Header.Get("X-Cache"), hits)
+    }
+}
+
+func TestCacheNoStore(t *testing.T) {
+    var hits int32
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        atomic.AddInt32(&hits, 1)
+        w.Header().Set("Cache-Control", "no-store")
+        w.Write([]byte("items"))
+    }))
+    defer srv.Close()
+
+    c := newCacheClient("httpCacheNoStore")
+    for i := 0; i < 2; i++ {
+        res := c.Get(srv.URL+"/items", nil)
+        res.Body.Close()
+    }
+
+    if n := atomic.LoadInt32(&hits); n != 2 {
+        t.Errorf("no-store: want 2 upstream requests, got %d", n)
+    }
+}
+
+func TestCacheNotMatched(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Write([]byte("other"))
+    }))
+    defer srv.Close()
+
+    c := newCacheClient("httpCacheNotMatched")
+    res := c.Get(srv.URL+"/other", nil)
+    res.Body.Close()
+
+    if v := res.Header.Get("X-Cache"); v != "" {
+        t.Errorf("url without policy: want no X-Cache, got %q", v)
+    }
+}
@@ -2,36 +2,79 @@ package Http
 
 import (
     "bytes"
+    "context"
     "crypto/tls"
     "fmt"
     "io"
+    "math"
+    "net"
     "net/http"
     "net/url"
     "reflect"
     "strings"
+    "sync/atomic"
     "time"
 
     "github.com/pinguo/pgo"
     "github.com/pinguo/pgo/Util"
 )
 
+type rateLimit struct {
+    bucket *Util.TokenBucket
+    block  bool
+}
+
 // Http Client component, configuration:
 // "http": {
 //     "class": "@pgo/Client/Http/Client",
 //     "verifyPeer": false,
 //     "userAgent": "PGO Framework",
-//     "timeout": "10s"
+//     "timeout": "10s",
+//     "dialTimeout": "30s",
+//     "dnsCache": "60s",
+//     "hosts": {"api.partner.com": ["10.0.0.1", "10.0.0.2"]},
+//     "maxHedges": 100,
+//     "cachePolicies": [{"pattern": "^https://api\\.partner\\.com/v1/items", "ttl": "60s", "stale": "5m"}],
+//     "cacheStorage": "cache",
+//     "rateLimits": {
+//         "api.partner.com": {"rate": 10, "burst": 20, "block": true},
+//         "*": {"rate": 100, "burst": 100, "block": false}
+//     }
 // }
+//
+// rateLimits limits outbound requests per host by token bucket,
+// rate is tokens per second, "*" matches hosts not configured,
+// block waits for token until request deadline, otherwise fails fast.
+// dnsCache caches lookup result for the duration, cached entry is
+// dropped when dialing its ip failed, hosts pins host to ips, it
+// overrides dns and is never expired, eg. for testing.
+// maxHedges limits hedged requests in flight, see Option.SetHedge,
+// hedged request dials ips of host in rotated order, so it usually
+// goes to another instance. cachePolicies caches GET responses of
+// matched urls in cacheStorage, see SetCachePolicies.
 type Client struct {
-    verifyPeer bool          // verify https peer or not
-    userAgent  string        // default User-Agent header
-    timeout    time.Duration // default request timeout
+    verifyPeer bool                  // verify https peer or not
+    userAgent  string                // default User-Agent header
+    timeout    time.Duration         // default request timeout
+    rateLimits map[string]*rateLimit // outbound rate limit by host
+    resolver   *resolver             // dialer with dns cache
+    maxHedges  int64                 // max hedged requests in flight
+    hedges     int64                 // hedged requests in flight
+
+    cachePolicies []*cachePolicy     // read-through cache by url pattern
+    cacheStorage  string             // id of cache component
+    cacheFlight   *Util.SingleFlight // background refresh of stale cache
 }
 
 func (c *Client) Construct() {
     c.verifyPeer = false
     c.userAgent = defaultUserAgent
     c.timeout = defaultTimeout
+    c.rateLimits = make(map[string]*rateLimit)
+    c.resolver = newResolver()
+    c.maxHedges = defaultMaxHedges
+    c.cacheStorage = defaultCacheStorage
+    c.cacheFlight = Util.NewSingleFlight()
 }
 
 func (c *Client) SetVerifyPeer(verifyPeer bool) {
@@ -50,6 +93,76 @@ func (c *Client) SetTimeout(v string) {
     }
 }
 
+func (c *Client) SetDialTimeout(v string) {
+    if timeout, err := time.ParseDuration(v); err != nil {
+        panic("http parse dialTimeout failed, " + err.Error())
+    } else {
+        c.resolver.dialer.Timeout = timeout
+    }
+}
+
+func (c *Client) SetDnsCache(v string) {
+    if ttl, err := time.ParseDuration(v); err != nil {
+        panic("http parse dnsCache failed, " + err.Error())
+    } else {
+        c.resolver.ttl = ttl
+    }
+}
+
+func (c *Client) SetHosts(v map[string]interface{}) {
+    for host, val := range v {
+        ips := make([]string, 0)
+        if list, ok := val.([]interface{}); ok {
+            for _, ip := range list {
+                ips = append(ips, Util.ToString(ip))
+            }
+        } else {
+            ips = append(ips, Util.ToString(val))
+        }
+
+        c.SetHost(host, ips...)
+    }
+}
+
+// SetHost pin host to ips instead of dns lookup, no ips to unpin.
+func (c *Client) SetHost(host string, ips ...string) {
+    for _, ip := range ips {
+        if net.ParseIP(ip) == nil {
+            panic(fmt.Sprintf("http invalid ip for %s: %s", host, ip))
+        }
+    }
+
+    c.resolver.pin(host, ips)
+}
+
+func (c *Client) SetMaxHedges(n int) {
+    c.maxHedges = int64(n)
+}
+
+func (c *Client) SetRateLimits(v map[string]interface{}) {
+    for host, conf := range v {
+        m, ok := conf.(map[string]interface{})
+        if !ok {
+            panic(fmt.Sprintf("http invalid rate limit for %s: %v", host, conf))
+        }
+
+        rate := Util.ToFloat(m["rate"])
+        burst := Util.ToInt(m["burst"])
+        if burst <= 0 {
+            burst = int(rate)
+        }
+
+        c.SetRateLimit(host, rate, burst, Util.ToBool(m["block"]))
+    }
+}
+
+// SetRateLimit limit outbound requests to host, rate is tokens per
+// second, block waits for token until timeout or request deadline if
+// true, it waits without limit if neither is set.
+func (c *Client) SetRateLimit(host string, rate float64, burst int, block bool) {
+    c.rateLimits[strings.ToLower(host)] = &rateLimit{Util.NewTokenBucket(rate, burst), block}
+}
+
 // Get perform a get request, and return a response pointer.
 // addr is the request url. data is the params associated
 // and will be append to addr if not empty, data type can be
@@ -147,9 +260,20 @@ func (c *Client) Post(addr string, data interface{}, option ...*Option) *http.Re
     return c.Do(req, option...)
 }
 
-// Do perform a request specified by req param, and return response pointer.
+// Do perform a request specified by req param, and return response pointer,
+// GET request matching cache policy is served through cache, X-Cache header
+// of response is HIT, STALE or MISS, see SetCachePolicies.
 func (c *Client) Do(req *http.Request, option ...*Option) *http.Response {
-    timeout, verifyPeer := c.timeout, c.verifyPeer
+    if policy := c.getCachePolicy(req, option); policy != nil {
+        return c.doCached(req, policy, option)
+    }
+
+    return c.do(req, option...)
+}
+
+func (c *Client) do(req *http.Request, option ...*Option) *http.Response {
+    timeout, verifyPeer, stream, hedge := c.timeout, c.verifyPeer, false, time.Duration(0)
+    retry, backoff := 0, time.Duration(0)
 
     if c.userAgent != "" {
         req.Header.Set("User-Agent", c.userAgent)
@@ -169,26 +293,267 @@ func (c *Client) Do(req *http.Request, option ...*Option) *http.Response {
             }
         }
 
-        for len(opt.Cookies) > 0 {
-            for _, cookie := range opt.Cookies {
-                req.AddCookie(cookie)
+        for _, cookie := range opt.Cookies {
+            req.AddCookie(cookie)
+        }
+
+        if opt.Idempotent {
+            key := opt.IdempotencyKey
+            if len(key) == 0 {
+                key = Util.GenUniqueId()
             }
+            req.Header.Set(idempotencyHeader, key)
         }
+
+        stream, hedge = opt.Stream, opt.Hedge
+        retry, backoff = opt.Retry, opt.Backoff
+    }
+
+    if retry > 0 && !canRetry(req) {
+        retry = 0
     }
 
-    client := http.Client{
-        Transport: &http.Transport{
-            TLSClientConfig: &tls.Config{
-                InsecureSkipVerify: !verifyPeer,
-            },
+    c.waitRateLimit(req, timeout)
+
+    transport := &http.Transport{
+        DialContext: c.resolver.dialContext,
+        TLSClientConfig: &tls.Config{
+            InsecureSkipVerify: !verifyPeer,
         },
-        Timeout: timeout,
     }
 
-    res, err := client.Do(req)
+    client := http.Client{Transport: transport, Timeout: timeout}
+    if stream {
+        // body is read by caller, only limit time to response header
+        transport.ResponseHeaderTimeout, client.Timeout = timeout, 0
+    }
+
+    var res *http.Response
+    var err error
+    for i := 0; ; i++ {
+        if hedge > 0 && (req.Method == http.MethodGet || req.Method == http.MethodHead) {
+            res, err = c.doHedged(&client, req, hedge)
+        } else {
+            res, err = client.Do(req)
+        }
+
+        if i >= retry || err == nil && res.StatusCode < http.StatusInternalServerError {
+            break
+        }
+
+        // last response is kept if request is canceled while waiting
+        if !c.waitRetry(req, i, backoff) {
+            break
+        }
+
+        // discard failed response before next attempt
+        if res != nil {
+            io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+            res.Body.Close()
+        }
+    }
+
     if err != nil {
         panic("http request failed, " + err.Error())
     }
 
     return res
 }
+
+// check if request can be retried, POST and PATCH need idempotency
+// key, body must be replayable
+func canRetry(req *http.Request) bool {
+    switch req.Method {
+    case http.MethodPost, http.MethodPatch:
+        if len(req.Header.Get(idempotencyHeader)) == 0 {
+            return false
+        }
+    }
+
+    return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// wait backoff of attempt and replay body, false if request is canceled
+func (c *Client) waitRetry(req *http.Request, attempt int, backoff time.Duration) bool {
+    if backoff <= 0 {
+        backoff = defaultBackoff
+    }
+
+    timer := time.NewTimer(backoff << uint(attempt))
+    defer timer.Stop()
+
+    select {
+    case <-timer.C:
+    case <-req.Context().Done():
+        return false
+    }
+
+    if req.GetBody != nil {
+        body, e := req.GetBody()
+        if e != nil {
+            return false
+        }
+        req.Body = body
+    }
+
+    return true
+}
+
+type hedgeResult struct {
+    res     *http.Response
+    err     error
+    attempt int
+}
+
+// send request, and a hedged one if no response within delay, the
+// first success wins, the other is canceled and its body is closed
+func (c *Client) doHedged(client *http.Client, req *http.Request, delay time.Duration) (*http.Response, error) {
+    results := make(chan hedgeResult, 2)
+    cancels := make([]context.CancelFunc, 0, 2)
+    send := func() {
+        attempt := len(cancels)
+        ctx, cancel := context.WithCancel(context.WithValue(req.Context(), hedgeKey{}, attempt))
+        cancels = append(cancels, cancel)
+
+        go func() {
+            if attempt > 0 {
+                defer atomic.AddInt64(&c.hedges, -1)
+            }
+
+            res, err := client.Do(req.WithContext(ctx))
+            results <- hedgeResult{res, err, attempt}
+        }()
+    }
+
+    send()
+    pending := 1
+    timer := time.NewTimer(delay)
+    defer timer.Stop()
+
+    for {
+        select {
+        case r := <-results:
+            pending--
+            if r.err != nil && pending > 0 {
+                continue // wait for the other attempt
+            }
+
+            // cancel the others, context of winner is canceled on close
+            for i, cancel := range cancels {
+                if i != r.attempt || r.err != nil {
+                    cancel()
+                }
+            }
+
+            if pending > 0 {
+                go func(n int) {
+                    for ; n > 0; n-- {
+                        if late := <-results; late.res != nil {
+                            late.res.Body.Close()
+                        }
+                    }
+                }(pending)
+            }
+
+            if r.err != nil {
+                return nil, r.err
+            }
+
+            r.res.Body = &cancelBody{r.res.Body, cancels[r.attempt]}
+            return r.res, nil
+
+        case <-timer.C:
+            if atomic.AddInt64(&c.hedges, 1) > c.maxHedges {
+                atomic.AddInt64(&c.hedges, -1)
+                continue
+            }
+
+            send()
+            pending++
+        }
+    }
+}
+
+// body cancels context of request on close
+type cancelBody struct {
+    io.ReadCloser
+    cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+    err := b.ReadCloser.Close()
+    b.cancel()
+    return err
+}
+
+// Download perform a get request in stream mode and copy response
+// body to w without buffering, return number of bytes copied, panic
+// if response status is not 2xx or copy failed.
+func (c *Client) Download(addr string, w io.Writer, option ...*Option) int64 {
+    req, err := http.NewRequest("GET", addr, nil)
+    if err != nil {
+        panic("http download bad request, " + err.Error())
+    }
+
+    opt := &Option{}
+    if len(option) > 0 && option[0] != nil {
+        *opt = *option[0]
+    }
+
+    res := c.Do(req, opt.SetStream(true))
+    defer res.Body.Close()
+
+    if res.StatusCode < 200 || res.StatusCode >= 300 {
+        panic(fmt.Sprintf("http download failed, status: %d", res.StatusCode))
+    }
+
+    n, err := io.Copy(w, res.Body)
+    if err != nil {
+        panic("http download failed, " + err.Error())
+    }
+
+    return n
+}
+
+// wait token of the request host, panic if rate limit exceeded
+func (c *Client) waitRateLimit(req *http.Request, timeout time.Duration) {
+    host := strings.ToLower(req.URL.Hostname())
+    limit := c.rateLimits[host]
+    if limit == nil {
+        if limit = c.rateLimits["*"]; limit == nil {
+            return
+        }
+    }
+
+    // block without timeout and deadline waits as long as needed
+    maxWait := time.Duration(0)
+    if limit.block {
+        maxWait = time.Duration(math.MaxInt64)
+        if timeout > 0 {
+            maxWait = timeout
+        }
+
+        if deadline, ok := req.Context().Deadline(); ok {
+            if d := time.Until(deadline); d < maxWait {
+                maxWait = d
+            }
+        }
+    }
+
+    wait, ok := limit.bucket.Take(maxWait)
+    if !ok {
+        panic("http rate limit exceeded, " + host)
+    }
+
+    if wait > 0 {
+        timer := time.NewTimer(wait)
+        defer timer.Stop()
+
+        select {
+        case <-timer.C:
+        case <-req.Context().Done():
+            limit.bucket.Refund()
+            panic("http rate limit wait canceled, " + req.Context().Err().Error())
+        }
+    }
+}
@@ -5,12 +5,21 @@ import (
     "time"
 
     "github.com/pinguo/pgo"
+    _ "github.com/pinguo/pgo/Client/Memory"
 )
 
 const (
     defaultComponentId = "http"
     defaultUserAgent   = "PGO Framework"
     defaultTimeout     = 10 * time.Second
+    defaultDialTimeout = 30 * time.Second
+    defaultKeepAlive   = 30 * time.Second
+    defaultMaxHedges   = 100
+    defaultBackoff     = 100 * time.Millisecond
+    idempotencyHeader  = "Idempotency-Key"
+
+    defaultCacheStorage = "cache"
+    maxCacheBytes       = 1 << 20
 )
 
 func init() {
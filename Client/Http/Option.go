@@ -10,6 +10,13 @@ type Option struct {
     Header  http.Header
     Cookies []*http.Cookie
     Timeout time.Duration
+    Stream  bool
+    Hedge   time.Duration
+    Retry   int
+    Backoff time.Duration
+
+    Idempotent     bool
+    IdempotencyKey string
 }
 
 // SetHeader set request header for the current request
@@ -33,3 +40,42 @@ func (o *Option) SetTimeout(timeout time.Duration) *Option {
     o.Timeout = timeout
     return o
 }
+
+// SetStream set stream mode for the current request, in stream mode
+// timeout only limits waiting for response header, reading body is
+// not limited, caller must read and close the response body
+func (o *Option) SetStream(stream bool) *Option {
+    o.Stream = stream
+    return o
+}
+
+// SetHedge send a hedged request to another ip of host if no response
+// within delay, the first response wins and the other is canceled,
+// only for GET and HEAD, hedges in flight are limited by maxHedges
+func (o *Option) SetHedge(delay time.Duration) *Option {
+    o.Hedge = delay
+    return o
+}
+
+// SetRetry retry request up to n times on network error and 5xx
+// response, backoff doubles after each retry(default 100ms), only
+// idempotent methods are retried, POST and PATCH are retried only
+// if idempotency key is set, request body must be replayable, eg.
+// created by http.NewRequest with bytes or strings reader
+func (o *Option) SetRetry(n int, backoff time.Duration) *Option {
+    o.Retry, o.Backoff = n, backoff
+    return o
+}
+
+// SetIdempotencyKey set Idempotency-Key header of request, it's the
+// same for all retries of the request, a unique key is generated for
+// each request if key is not given, eg. retry payment safely:
+// opt := (&Option{}).SetIdempotencyKey().SetRetry(2, 0)
+func (o *Option) SetIdempotencyKey(key ...string) *Option {
+    o.Idempotent, o.IdempotencyKey = true, ""
+    if len(key) > 0 {
+        o.IdempotencyKey = key[0]
+    }
+
+    return o
+}
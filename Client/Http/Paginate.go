@@ -0,0 +1,129 @@
+package Http
+
+import (
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "regexp"
+
+    "github.com/pinguo/pgo/Util"
+)
+
+var linkNextRe = regexp.MustCompile(`<([^>]+)>\s*;[^,]*\brel="?next"?`)
+
+// Page page of paginated response, body is read and response is closed
+type Page struct {
+    Number   int // page number from 1
+    Request  *http.Request
+    Response *http.Response
+    Body     []byte
+}
+
+// NextPage build request of next page from current page, nil to end
+type NextPage func(page *Page) (*http.Request, error)
+
+// NextByQuery set query param of request to cursor extracted from page,
+// empty cursor ends pagination, eg. token in json body:
+// Http.NextByQuery("pageToken", func(page *Http.Page) string {
+//     var v struct{ Next string `json:"nextPageToken"` }
+//     json.Unmarshal(page.Body, &v)
+//     return v.Next
+// })
+func NextByQuery(param string, cursor func(page *Page) string) NextPage {
+    return func(page *Page) (*http.Request, error) {
+        next := cursor(page)
+        if len(next) == 0 {
+            return nil, nil
+        }
+
+        req := page.Request.Clone(page.Request.Context())
+        query := req.URL.Query()
+        query.Set(param, next)
+        req.URL.RawQuery = query.Encode()
+        return req, nil
+    }
+}
+
+// NextByLink follow url of rel="next" in Link header(RFC 8288),
+// relative url is resolved against url of current request
+func NextByLink() NextPage {
+    return func(page *Page) (*http.Request, error) {
+        for _, link := range page.Response.Header.Values("Link") {
+            if m := linkNextRe.FindStringSubmatch(link); m != nil {
+                u, e := page.Request.URL.Parse(m[1])
+                if e != nil {
+                    return nil, e
+                }
+
+                req := page.Request.Clone(page.Request.Context())
+                req.URL, req.Host = u, u.Host
+                return req, nil
+            }
+        }
+
+        return nil, nil
+    }
+}
+
+// Paginate perform request and requests of next pages built by next,
+// fn is called with every page in order, pagination ends when next
+// returns nil, and stops with error if fn or next returns error, request
+// fails, status is not 2xx, or context of request is done, option is
+// applied to every page, so retry is per page, eg.
+// e := client.Paginate(req, Http.NextByLink(), func(page *Http.Page) error {
+//     return json.Unmarshal(page.Body, &items)
+// })
+func (c *Client) Paginate(req *http.Request, next NextPage, fn func(page *Page) error, option ...*Option) error {
+    return paginate(req, next, fn, func(req *http.Request) *http.Response {
+        return c.Do(req, option...)
+    })
+}
+
+func paginate(req *http.Request, next NextPage, fn func(page *Page) error, do func(req *http.Request) *http.Response) error {
+    for i := 1; req != nil; i++ {
+        if e := req.Context().Err(); e != nil {
+            return e
+        }
+
+        page, e := doPage(req, i, do)
+        if e != nil {
+            return e
+        }
+
+        if e := fn(page); e != nil {
+            return e
+        }
+
+        if req, e = next(page); e != nil {
+            return e
+        } else if req != nil && req.URL.String() == page.Request.URL.String() {
+            return fmt.Errorf("http paginate cursor not advanced at page %d, %s", i, req.URL)
+        }
+    }
+
+    return nil
+}
+
+// perform request of a page, panic of request is returned as error
+func doPage(req *http.Request, number int, do func(req *http.Request) *http.Response) (page *Page, err error) {
+    defer func() {
+        if v := recover(); v != nil {
+            err = fmt.Errorf("http paginate page %d, %s", number, Util.ToString(v))
+        }
+    }()
+
+    res := do(req)
+    if res == nil {
+        return nil, fmt.Errorf("http paginate page %d, request failed", number)
+    }
+    defer res.Body.Close()
+
+    body, e := ioutil.ReadAll(res.Body)
+    if e != nil {
+        return nil, fmt.Errorf("http paginate page %d, %s", number, e)
+    } else if res.StatusCode < 200 || res.StatusCode >= 300 {
+        return nil, fmt.Errorf("http paginate page %d, status: %d", number, res.StatusCode)
+    }
+
+    return &Page{Number: number, Request: req, Response: res, Body: body}, nil
+}
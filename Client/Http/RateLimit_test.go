@@ -0,0 +1,69 @@
+package Http
+
+import (
+    "context"
+    "net/http"
+    "testing"
+    "time"
+)
+
+func TestRateLimitFailFast(t *testing.T) {
+    c := &Client{}
+    c.Construct()
+    c.SetRateLimit("api.example.com", 1, 1, false)
+
+    req, _ := http.NewRequest("GET", "http://api.example.com/items", nil)
+    c.waitRateLimit(req, time.Second)
+
+    defer func() {
+        if recover() == nil {
+            t.Error("exhausted without block: want panic")
+        }
+    }()
+    c.waitRateLimit(req, time.Second)
+}
+
+func TestRateLimitBlockWithoutTimeout(t *testing.T) {
+    c := &Client{}
+    c.Construct()
+    c.SetRateLimit("*", 20, 1, true)
+
+    req, _ := http.NewRequest("GET", "http://api.example.com/items", nil)
+    c.waitRateLimit(req, 0)
+
+    start := time.Now()
+    c.waitRateLimit(req, 0)
+    if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+        t.Errorf("block without timeout and deadline: want wait for token, got %v", elapsed)
+    }
+}
+
+func TestRateLimitCancelRefund(t *testing.T) {
+    c := &Client{}
+    c.Construct()
+    c.SetRateLimit("api.example.com", 2, 1, true)
+
+    req, _ := http.NewRequest("GET", "http://api.example.com/items", nil)
+    c.waitRateLimit(req, 0)
+
+    // the canceled waiter gives back its reserved token
+    ctx, cancel := context.WithCancel(context.Background())
+    go func() {
+        time.Sleep(20 * time.Millisecond)
+        cancel()
+    }()
+
+    func() {
+        defer func() {
+            if recover() == nil {
+                t.Error("canceled wait: want panic")
+            }
+        }()
+        c.waitRateLimit(req.WithContext(ctx), 0)
+    }()
+
+    wait, ok := c.rateLimits["api.example.com"].bucket.Take(time.Second)
+    if !ok || wait > 500*time.Millisecond {
+        t.Errorf("after refund: want next token within 500ms, got %v %v", wait, ok)
+    }
+}
@@ -0,0 +1,151 @@
+package Http
+
+import (
+    "context"
+    "net"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/pinguo/pgo/Util"
+)
+
+// context key of hedged attempt, ips are rotated by it
+type hedgeKey struct{}
+
+type dnsEntry struct {
+    ips    []string
+    expire time.Time // zero for pinned host
+}
+
+// resolver with optional dns cache and pinned hosts, go resolver
+// does not expose record ttl, so ttl is the max time to cache a
+// lookup result, entry is invalidated if dialing its ip failed.
+type resolver struct {
+    ttl     time.Duration
+    dialer  *net.Dialer
+    entries map[string]*dnsEntry
+    flight  *Util.SingleFlight
+    lock    sync.RWMutex
+}
+
+func newResolver() *resolver {
+    return &resolver{
+        dialer:  &net.Dialer{Timeout: defaultDialTimeout, KeepAlive: defaultKeepAlive},
+        entries: make(map[string]*dnsEntry),
+        flight:  Util.NewSingleFlight(),
+    }
+}
+
+// pin host to ips, empty ips to remove the pinned host
+func (r *resolver) pin(host string, ips []string) {
+    r.lock.Lock()
+    defer r.lock.Unlock()
+
+    if host = strings.ToLower(host); len(ips) == 0 {
+        delete(r.entries, host)
+    } else {
+        r.entries[host] = &dnsEntry{ips: ips}
+    }
+}
+
+// get ips of host from pinned hosts, cache or dns lookup
+func (r *resolver) lookup(ctx context.Context, host string) ([]string, error) {
+    host = strings.ToLower(host)
+    r.lock.RLock()
+    entry := r.entries[host]
+    r.lock.RUnlock()
+
+    if entry != nil && (entry.expire.IsZero() || time.Now().Before(entry.expire)) {
+        return entry.ips, nil
+    }
+
+    if r.ttl <= 0 {
+        return net.DefaultResolver.LookupHost(ctx, host)
+    }
+
+    // shared lookup is detached from callers and limited by dial
+    // timeout, so one canceled caller does not fail the others
+    ch := r.flight.DoChan(host, func() (interface{}, error) {
+        lookupCtx, cancel := context.WithTimeout(context.Background(), r.lookupTimeout())
+        defer cancel()
+
+        ips, e := net.DefaultResolver.LookupHost(lookupCtx, host)
+        if e == nil {
+            r.lock.Lock()
+            r.entries[host] = &dnsEntry{ips: ips, expire: time.Now().Add(r.ttl)}
+            r.lock.Unlock()
+        }
+        return ips, e
+    })
+
+    select {
+    case res := <-ch:
+        if res.Err != nil {
+            return nil, res.Err
+        }
+        return res.Value.([]string), nil
+    case <-ctx.Done():
+        return nil, ctx.Err()
+    }
+}
+
+func (r *resolver) lookupTimeout() time.Duration {
+    if r.dialer.Timeout > 0 {
+        return r.dialer.Timeout
+    }
+
+    return defaultDialTimeout
+}
+
+// remove cached entry of host if it contains the stale ip
+func (r *resolver) invalidate(host, ip string) {
+    r.lock.Lock()
+    defer r.lock.Unlock()
+
+    host = strings.ToLower(host)
+    if entry := r.entries[host]; entry != nil && !entry.expire.IsZero() {
+        for _, v := range entry.ips {
+            if v == ip {
+                delete(r.entries, host)
+                return
+            }
+        }
+    }
+}
+
+// dial resolved ips in order until one succeeds
+func (r *resolver) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+    host, port, e := net.SplitHostPort(addr)
+    if e != nil || net.ParseIP(host) != nil {
+        return r.dialer.DialContext(ctx, network, addr)
+    }
+
+    ips, e := r.lookup(ctx, host)
+    if e != nil {
+        return nil, e
+    }
+
+    if n, ok := ctx.Value(hedgeKey{}).(int); ok && len(ips) > 1 {
+        n %= len(ips)
+        ips = append(append(make([]string, 0, len(ips)), ips[n:]...), ips[:n]...)
+    }
+
+    for _, ip := range ips {
+        var conn net.Conn
+        if conn, e = r.dialer.DialContext(ctx, network, net.JoinHostPort(ip, port)); e == nil {
+            return conn, nil
+        }
+
+        r.invalidate(host, ip)
+        if ctx.Err() != nil {
+            break
+        }
+    }
+
+    if e == nil {
+        e = &net.DNSError{Err: "no such host", Name: host}
+    }
+
+    return nil, e
+}
@@ -0,0 +1,72 @@
+package Http
+
+import (
+    "context"
+    "net"
+    "reflect"
+    "testing"
+    "time"
+)
+
+func TestResolverPin(t *testing.T) {
+    r := newResolver()
+    r.pin("Api.Example.com", []string{"10.0.0.1", "10.0.0.2"})
+
+    ips, e := r.lookup(context.Background(), "api.example.com")
+    if e != nil || !reflect.DeepEqual(ips, []string{"10.0.0.1", "10.0.0.2"}) {
+        t.Errorf("pinned host: want pinned ips, got %v %v", ips, e)
+    }
+
+    // pinned entry is never invalidated by dial failure
+    r.invalidate("api.example.com", "10.0.0.1")
+    if ips, _ := r.lookup(context.Background(), "api.example.com"); len(ips) != 2 {
+        t.Errorf("after invalidate: want pinned ips kept, got %v", ips)
+    }
+}
+
+func TestResolverCache(t *testing.T) {
+    r := newResolver()
+    r.ttl = time.Minute
+
+    ips, e := r.lookup(context.Background(), "localhost")
+    if e != nil || len(ips) == 0 {
+        t.Skipf("localhost not resolvable, %v", e)
+    }
+
+    r.lock.RLock()
+    entry := r.entries["localhost"]
+    r.lock.RUnlock()
+    if entry == nil || entry.expire.IsZero() {
+        t.Fatal("want lookup result cached with expire")
+    }
+
+    r.invalidate("localhost", ips[0])
+    r.lock.RLock()
+    entry = r.entries["localhost"]
+    r.lock.RUnlock()
+    if entry != nil {
+        t.Error("after invalidate: want cached entry removed")
+    }
+}
+
+func TestResolverCanceledCaller(t *testing.T) {
+    r := newResolver()
+    r.ttl = time.Minute
+
+    // a canceled caller returns at once, the shared lookup goes on
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    if _, e := r.lookup(ctx, "localhost"); e != context.Canceled {
+        if e == nil {
+            t.Skip("lookup finished before cancel was observed")
+        }
+        t.Errorf("canceled caller: want context.Canceled, got %v", e)
+    }
+
+    if _, e := r.lookup(context.Background(), "localhost"); e != nil {
+        if _, ok := e.(*net.DNSError); ok {
+            t.Skipf("localhost not resolvable, %v", e)
+        }
+        t.Errorf("other caller: want lookup ok, got %v", e)
+    }
+}
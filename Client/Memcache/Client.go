@@ -16,13 +16,33 @@ import (
 //     "maxIdleTime": "60s",
 //     "netTimeout": "1s",
 //     "probInterval": "0s",
+//     "serializer": "json",
 //     "servers": [
 //         "127.0.0.1:11211",
 //         "127.0.0.1:11212"
 //     ]
 // }
+//
+// serializer encodes struct, map and slice values, "json", "gob" or
+// class of a pgo.ISerializer, values should be decoded by Client.Decode
+// if not json, scalar values are always stored as plain text.
 type Client struct {
     Pool
+    serializer pgo.ISerializer
+}
+
+// set serializer, json is done by pgo.Encode, see pgo.CreateSerializer
+func (c *Client) SetSerializer(v interface{}) {
+    if v == "json" {
+        c.serializer = nil
+    } else {
+        c.serializer = pgo.CreateSerializer(v)
+    }
+}
+
+// decode value got from memcache by the configured serializer
+func (c *Client) Decode(v *pgo.Value, ptr interface{}) error {
+    return pgo.Unserialize(c.serializer, v, ptr)
 }
 
 func (c *Client) Get(key string) *pgo.Value {
@@ -46,26 +66,36 @@ func (c *Client) MGet(keys []string) map[string]*pgo.Value {
     return result
 }
 
+// same as MGet
+func (c *Client) GetMulti(keys []string) map[string]*pgo.Value {
+    return c.MGet(keys)
+}
+
 func (c *Client) Set(key string, value interface{}, expire ...time.Duration) bool {
-    return c.Store(CmdSet, &Item{Key: key, Data: pgo.Encode(value)}, expire...)
+    return c.Store(CmdSet, &Item{Key: key, Data: c.encode(value)}, expire...)
 }
 
 func (c *Client) MSet(items map[string]interface{}, expire ...time.Duration) bool {
     newItems := make([]*Item, 0, len(items))
     for key, value := range items {
-        newItems = append(newItems, &Item{Key: key, Data: pgo.Encode(value)})
+        newItems = append(newItems, &Item{Key: key, Data: c.encode(value)})
     }
     return c.MultiStore(CmdSet, newItems, expire...)
 }
 
+// same as MSet
+func (c *Client) SetMulti(items map[string]interface{}, expire ...time.Duration) bool {
+    return c.MSet(items, expire...)
+}
+
 func (c *Client) Add(key string, value interface{}, expire ...time.Duration) bool {
-    return c.Store(CmdAdd, &Item{Key: key, Data: pgo.Encode(value)}, expire...)
+    return c.Store(CmdAdd, &Item{Key: key, Data: c.encode(value)}, expire...)
 }
 
 func (c *Client) MAdd(items map[string]interface{}, expire ...time.Duration) bool {
     newItems := make([]*Item, 0, len(items))
     for key, value := range items {
-        newItems = append(newItems, &Item{Key: key, Data: pgo.Encode(value)})
+        newItems = append(newItems, &Item{Key: key, Data: c.encode(value)})
     }
     return c.MultiStore(CmdAdd, newItems, expire...)
 }
@@ -100,7 +130,12 @@ func (c *Client) MDel(keys []string) bool {
 }
 
 func (c *Client) Exists(key string) bool {
-    return c.Get(key) != nil
+    return c.Retrieve(CmdGet, key) != nil
+}
+
+// same as Exists
+func (c *Client) Has(key string) bool {
+    return c.Exists(key)
 }
 
 func (c *Client) Incr(key string, delta int) int {
@@ -180,3 +215,8 @@ func (c *Client) MultiStore(cmd string, items []*Item, expire ...time.Duration)
     wg.Wait()
     return success == uint32(len(items))
 }
+
+// encode value by serializer to store
+func (c *Client) encode(value interface{}) []byte {
+    return pgo.Encode(pgo.Serialize(c.serializer, value))
+}
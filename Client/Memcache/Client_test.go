@@ -0,0 +1,29 @@
+package Memcache
+
+import (
+    "testing"
+
+    "github.com/pinguo/pgo"
+)
+
+type user struct {
+    Name string
+    Age  int
+}
+
+func TestClientEncode(t *testing.T) {
+    for _, name := range []string{"json", "gob"} {
+        c := &Client{}
+        c.Construct()
+        c.SetSerializer(name)
+
+        got := user{}
+        if e := c.Decode(pgo.NewValue(c.encode(&user{"pgo", 3})), &got); e != nil || got != (user{"pgo", 3}) {
+            t.Errorf("%s: want user decoded, got %+v %v", name, got, e)
+        }
+
+        if data := string(c.encode(10)); data != "10" {
+            t.Errorf("%s: want scalar as plain text, got %q", name, data)
+        }
+    }
+}
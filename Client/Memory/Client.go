@@ -1,8 +1,10 @@
 package Memory
 
 import (
+    "container/list"
     "fmt"
     "sync"
+    "sync/atomic"
     "time"
 
     "github.com/pinguo/pgo"
@@ -10,35 +12,65 @@ import (
 )
 
 type item struct {
+    key    string
     value  interface{}
     expire time.Time
+    elem   *list.Element
 }
 
 func (i item) isExpired() bool {
     return !i.expire.IsZero() && time.Since(i.expire) > 0
 }
 
+// cache statistics
+type Stats struct {
+    Hits      uint64 `json:"hits"`
+    Misses    uint64 `json:"misses"`
+    Evictions uint64 `json:"evictions"`
+    Items     int    `json:"items"`
+}
+
 // Memory Client component, configuration:
 // "memory": {
 //     "class": "@pgo/Client/Memory/Client",
 //     "gcInterval": "60s",
-//     "gcMaxItems": 1000
+//     "gcMaxItems": 1000,
+//     "maxItems": 0,
+//     "serializer": ""
 // }
+//
+// the least recently used item is evicted if maxItems is
+// exceeded, 0 means no limit, the "cache" core component
+// is a Memory Client by default. values are kept as is if
+// serializer is empty, otherwise struct, map and slice values
+// are stored encoded by "json", "gob" or class of a
+// pgo.ISerializer, and should be decoded by Client.Decode.
 type Client struct {
-    lock       sync.RWMutex
+    lock       sync.Mutex
     items      map[string]*item
+    lru        *list.List
+    maxItems   int
     gcInterval time.Duration
     gcMaxItems int
+
+    hits      uint64
+    misses    uint64
+    evictions uint64
+
+    flight     *Util.SingleFlight
+    serializer pgo.ISerializer
 }
 
 func (c *Client) Construct() {
     c.items = make(map[string]*item)
+    c.lru = list.New()
+    c.flight = Util.NewSingleFlight()
     c.gcInterval = defaultGcInterval
     c.gcMaxItems = defaultGcMaxItems
 }
 
 func (c *Client) Init() {
-    go c.gcLoop()
+    pgo.Go(c.gcLoop)
 }
 
 func (c *Client) SetGcInterval(v string) {
@@ -55,86 +87,97 @@ func (c *Client) SetGcMaxItems(gcMaxItems int) {
     }
 }
 
-func (c *Client) Get(key string) *pgo.Value {
-    c.lock.RLock()
-    defer c.lock.RUnlock()
+func (c *Client) SetMaxItems(maxItems int) {
+    if maxItems >= 0 {
+        c.maxItems = maxItems
+    }
+}
 
-    if item := c.items[key]; item != nil && !item.isExpired() {
-        return pgo.NewValue(item.value)
+// set serializer, see pgo.CreateSerializer
+func (c *Client) SetSerializer(v interface{}) {
+    if v != "" {
+        c.serializer = pgo.CreateSerializer(v)
     }
+}
 
-    return pgo.NewValue(nil)
+// decode value got from memory by the configured serializer
+func (c *Client) Decode(v *pgo.Value, ptr interface{}) error {
+    return pgo.Unserialize(c.serializer, v, ptr)
+}
+
+func (c *Client) Get(key string) *pgo.Value {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+
+    return pgo.NewValue(c.get(key))
 }
 
 func (c *Client) MGet(keys []string) map[string]*pgo.Value {
-    c.lock.RLock()
-    defer c.lock.RUnlock()
+    c.lock.Lock()
+    defer c.lock.Unlock()
 
     result := make(map[string]*pgo.Value)
     for _, key := range keys {
-        if item := c.items[key]; item != nil && !item.isExpired() {
-            result[key] = pgo.NewValue(item.value)
-        } else {
-            result[key] = pgo.NewValue(nil)
-        }
+        result[key] = pgo.NewValue(c.get(key))
     }
 
     return result
 }
 
+// same as MGet
+func (c *Client) GetMulti(keys []string) map[string]*pgo.Value {
+    return c.MGet(keys)
+}
+
 func (c *Client) Set(key string, value interface{}, expire ...time.Duration) bool {
+    value = pgo.Serialize(c.serializer, value)
     c.lock.Lock()
     defer c.lock.Unlock()
 
-    expire, now := append(expire, defaultExpire), time.Now()
-    c.items[key] = &item{
-        value:  value,
-        expire: now.Add(expire[0]),
-    }
-
+    expire = append(expire, defaultExpire)
+    c.set(key, value, time.Now().Add(expire[0]))
     return true
 }
 
 func (c *Client) MSet(items map[string]interface{}, expire ...time.Duration) bool {
+    items = c.serializeItems(items)
     c.lock.Lock()
     defer c.lock.Unlock()
 
     expire, now := append(expire, defaultExpire), time.Now()
     for key, value := range items {
-        c.items[key] = &item{
-            value:  value,
-            expire: now.Add(expire[0]),
-        }
+        c.set(key, value, now.Add(expire[0]))
     }
     return true
 }
 
+// same as MSet
+func (c *Client) SetMulti(items map[string]interface{}, expire ...time.Duration) bool {
+    return c.MSet(items, expire...)
+}
+
 func (c *Client) Add(key string, value interface{}, expire ...time.Duration) bool {
+    value = pgo.Serialize(c.serializer, value)
     c.lock.Lock()
     defer c.lock.Unlock()
 
     expire, now := append(expire, defaultExpire), time.Now()
     if old := c.items[key]; old == nil || old.isExpired() {
-        c.items[key] = &item{
-            value:  value,
-            expire: now.Add(expire[0]),
-        }
+        c.set(key, value, now.Add(expire[0]))
         return true
     }
     return false
 }
 
 func (c *Client) MAdd(items map[string]interface{}, expire ...time.Duration) bool {
+    items = c.serializeItems(items)
     c.lock.Lock()
     defer c.lock.Unlock()
 
     expire, now, success := append(expire, defaultExpire), time.Now(), 0
     for key, value := range items {
         if old := c.items[key]; old == nil || old.isExpired() {
-            c.items[key] = &item{
-                value:  value,
-                expire: now.Add(expire[0]),
-            }
+            c.set(key, value, now.Add(expire[0]))
             success++
         }
     }
@@ -146,11 +189,7 @@ func (c *Client) Del(key string) bool {
     c.lock.Lock()
     defer c.lock.Unlock()
 
-    if _, ok := c.items[key]; ok {
-        delete(c.items, key)
-        return true
-    }
-    return false
+    return c.del(key)
 }
 
 func (c *Client) MDel(keys []string) bool {
@@ -159,8 +198,7 @@ func (c *Client) MDel(keys []string) bool {
 
     success := 0
     for _, key := range keys {
-        if _, ok := c.items[key]; ok {
-            delete(c.items, key)
+        if c.del(key) {
             success++
         }
     }
@@ -169,11 +207,16 @@ func (c *Client) MDel(keys []string) bool {
 }
 
 func (c *Client) Exists(key string) bool {
-    c.lock.RLock()
-    defer c.lock.RUnlock()
+    c.lock.Lock()
+    defer c.lock.Unlock()
 
-    _, ok := c.items[key]
-    return ok
+    item, ok := c.items[key]
+    return ok && !item.isExpired()
+}
+
+// same as Exists
+func (c *Client) Has(key string) bool {
+    return c.Exists(key)
 }
 
 func (c *Client) Incr(key string, delta int) int {
@@ -182,8 +225,7 @@ func (c *Client) Incr(key string, delta int) int {
 
     cur := c.items[key]
     if cur == nil {
-        cur = &item{value: 0}
-        c.items[key] = cur
+        cur = c.set(key, 0, time.Time{})
     }
 
     newVal := Util.ToInt(cur.value) + delta
@@ -191,40 +233,139 @@ func (c *Client) Incr(key string, delta int) int {
     return newVal
 }
 
+// get value of key, call loader and set value with expire if
+// key not exists, concurrent calls of the same missing key share
+// one loader call, error of loader is returned to all callers
+// and the value is not cached.
+func (c *Client) GetOrSet(key string, expire time.Duration, loader func() (interface{}, error)) (*pgo.Value, error) {
+    if v := c.Get(key); v.Valid() {
+        return v, nil
+    }
+
+    value, e := c.flight.Do(key, func() (interface{}, error) {
+        // value may be set by the call just finished
+        if value := c.peek(key); value != nil {
+            return value, nil
+        }
+
+        value, e := loader()
+        if e == nil {
+            value = pgo.Serialize(c.serializer, value)
+            c.lock.Lock()
+            c.set(key, value, time.Now().Add(expire))
+            c.lock.Unlock()
+        }
+
+        return value, e
+    })
+
+    return pgo.NewValue(value), e
+}
+
+// get hit/miss statistics and number of items
+func (c *Client) GetStats() Stats {
+    c.lock.Lock()
+    num := len(c.items)
+    c.lock.Unlock()
+
+    return Stats{
+        Hits:      atomic.LoadUint64(&c.hits),
+        Misses:    atomic.LoadUint64(&c.misses),
+        Evictions: atomic.LoadUint64(&c.evictions),
+        Items:     num,
+    }
+}
+
+// serialize values of items, items are returned as is without serializer
+func (c *Client) serializeItems(items map[string]interface{}) map[string]interface{} {
+    if c.serializer == nil {
+        return items
+    }
+
+    serialized := make(map[string]interface{}, len(items))
+    for key, value := range items {
+        serialized[key] = pgo.Serialize(c.serializer, value)
+    }
+
+    return serialized
+}
+
+// get value and mark item as recently used, lock must be held
+func (c *Client) get(key string) interface{} {
+    if item := c.items[key]; item != nil && !item.isExpired() {
+        c.lru.MoveToFront(item.elem)
+        atomic.AddUint64(&c.hits, 1)
+        return item.value
+    }
+
+    atomic.AddUint64(&c.misses, 1)
+    return nil
+}
+
+// get value without changing lru and stats
+func (c *Client) peek(key string) interface{} {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+
+    if item := c.items[key]; item != nil && !item.isExpired() {
+        return item.value
+    }
+
+    return nil
+}
+
+// set value and evict the least recently used items, lock must be held
+func (c *Client) set(key string, value interface{}, expire time.Time) *item {
+    if old := c.items[key]; old != nil {
+        old.value, old.expire = value, expire
+        c.lru.MoveToFront(old.elem)
+        return old
+    }
+
+    cur := &item{key: key, value: value, expire: expire}
+    cur.elem = c.lru.PushFront(cur)
+    c.items[key] = cur
+
+    for c.maxItems > 0 && len(c.items) > c.maxItems {
+        c.del(c.lru.Back().Value.(*item).key)
+        atomic.AddUint64(&c.evictions, 1)
+    }
+
+    return cur
+}
+
+// delete item, lock must be held
+func (c *Client) del(key string) bool {
+    if item, ok := c.items[key]; ok {
+        c.lru.Remove(item.elem)
+        delete(c.items, key)
+        return true
+    }
+    return false
+}
+
 func (c *Client) gcLoop() {
     if c.gcInterval < minGcInterval || c.gcInterval > maxGcInterval {
         c.gcInterval = defaultGcInterval
     }
 
-    getExpireKeys := func() []string {
-        c.lock.RLock()
-        defer c.lock.RUnlock()
+    clearExpiredKeys := func() {
+        c.lock.Lock()
+        defer c.lock.Unlock()
 
-        keys, now := make([]string, 0), time.Now()
+        num, now := 0, time.Now()
         for key, item := range c.items {
             if !item.expire.IsZero() && item.expire.Sub(now) < 0 {
-                keys = append(keys, key)
-                if len(keys) >= c.gcMaxItems {
+                c.del(key)
+                if num++; num >= c.gcMaxItems {
                     break
                 }
             }
         }
-        return keys
-    }
-
-    clearExpiredKeys := func(keys []string) {
-        c.lock.Lock()
-        defer c.lock.Unlock()
-
-        for _, key := range keys {
-            delete(c.items, key)
-        }
     }
 
     for {
         <-time.After(c.gcInterval)
-        if expiredKeys := getExpireKeys(); len(expiredKeys) > 0 {
-            clearExpiredKeys(expiredKeys)
-        }
+        clearExpiredKeys()
     }
 }
@@ -0,0 +1,164 @@
+package Memory
+
+import (
+    "errors"
+    "reflect"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+type user struct {
+    Name string
+    Age  int
+}
+
+func newClient(serializer string) *Client {
+    c := &Client{}
+    c.Construct()
+    c.SetSerializer(serializer)
+    return c
+}
+
+func TestClientMulti(t *testing.T) {
+    c := newClient("")
+    c.SetMulti(map[string]interface{}{"a": 1, "b": "x"}, time.Minute)
+
+    res := c.GetMulti([]string{"a", "b", "c"})
+    if res["a"].Int() != 1 || res["b"].String() != "x" || res["c"].Valid() {
+        t.Errorf("want a=1 b=x and c missing, got %v %v %v", res["a"], res["b"], res["c"])
+    }
+
+    if !c.Has("a") || c.Has("c") {
+        t.Error("want a present and c absent")
+    }
+}
+
+func TestClientSerializer(t *testing.T) {
+    for _, name := range []string{"json", "gob"} {
+        c := newClient(name)
+        origin := &user{"pgo", 3}
+        c.Set("user", origin)
+
+        // stored value is a copy, later change is not visible
+        origin.Age = 4
+
+        got := user{}
+        if e := c.Decode(c.Get("user"), &got); e != nil || got != (user{"pgo", 3}) {
+            t.Errorf("%s: want stored user, got %+v %v", name, got, e)
+        }
+
+        c.Set("num", 1)
+        if c.Incr("num", 2) != 3 {
+            t.Errorf("%s: want scalar stored plain for Incr", name)
+        }
+    }
+}
+
+func TestClientWithoutSerializer(t *testing.T) {
+    c := newClient("")
+    origin := &user{"pgo", 3}
+    c.Set("user", origin)
+
+    if v := c.Get("user"); !reflect.DeepEqual(v.Data(), origin) {
+        t.Errorf("want value kept as is, got %v", v.Data())
+    }
+}
+
+func TestClientGetOrSet(t *testing.T) {
+    c := newClient("json")
+    calls := 0
+    loader := func() (interface{}, error) {
+        calls++
+        return &user{"pgo", 3}, nil
+    }
+
+    for i := 0; i < 2; i++ {
+        v, e := c.GetOrSet("user", time.Minute, loader)
+        got := user{}
+        if e != nil || c.Decode(v, &got) != nil || got != (user{"pgo", 3}) {
+            t.Errorf("call %d: want loaded user, got %+v %v", i, got, e)
+        }
+    }
+
+    if calls != 1 {
+        t.Errorf("want loader called once, got %d", calls)
+    }
+
+    if _, e := c.GetOrSet("bad", time.Minute, func() (interface{}, error) { return nil, errors.New("fail") }); e == nil || c.Has("bad") {
+        t.Error("loader error: want error returned and no value cached")
+    }
+}
+
+func TestClientExpire(t *testing.T) {
+    c := newClient("")
+    c.Set("short", 1, 20*time.Millisecond)
+    c.Set("long", 2, time.Minute)
+    c.Set("forever", 3)
+
+    time.Sleep(40 * time.Millisecond)
+    if c.Get("short").Valid() || c.Has("short") {
+        t.Error("expired key: want missing")
+    }
+
+    if c.Get("long").Int() != 2 || c.Get("forever").Int() != 3 {
+        t.Error("unexpired keys: want values kept")
+    }
+
+    // expired key can be added again
+    if !c.Add("short", 4) || c.Get("short").Int() != 4 {
+        t.Error("add expired key: want new value")
+    }
+}
+
+func TestClientLru(t *testing.T) {
+    c := newClient("")
+    c.SetMaxItems(2)
+    c.Set("a", 1)
+    c.Set("b", 2)
+
+    // a becomes the most recently used, so b is evicted
+    c.Get("a")
+    c.Set("c", 3)
+
+    if !c.Has("a") || c.Has("b") || !c.Has("c") {
+        t.Errorf("want b evicted, got a=%v b=%v c=%v", c.Has("a"), c.Has("b"), c.Has("c"))
+    }
+
+    c.Get("b")
+    stats := c.GetStats()
+    if stats.Evictions != 1 || stats.Items != 2 || stats.Hits != 1 || stats.Misses != 1 {
+        t.Errorf("want 1 eviction, 2 items, 1 hit and 1 miss, got %+v", stats)
+    }
+}
+
+func TestClientGetOrSetConcurrent(t *testing.T) {
+    c := newClient("")
+    calls := int32(0)
+    release := make(chan struct{})
+    loader := func() (interface{}, error) {
+        atomic.AddInt32(&calls, 1)
+        <-release
+        return "value", nil
+    }
+
+    wg := sync.WaitGroup{}
+    for i := 0; i < 8; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            if v, e := c.GetOrSet("key", time.Minute, loader); e != nil || v.String() != "value" {
+                t.Errorf("want value, got %v %v", v, e)
+            }
+        }()
+    }
+
+    time.Sleep(20 * time.Millisecond)
+    close(release)
+    wg.Wait()
+
+    if n := atomic.LoadInt32(&calls); n != 1 {
+        t.Errorf("want loader called once, got %d", n)
+    }
+}
@@ -0,0 +1,34 @@
+package Queue
+
+import (
+    "github.com/pinguo/pgo"
+)
+
+// Adapter of Queue Client, add context support, jobs carry log id of context.
+// usage: queue := this.GetObject("@pgo/Client/Queue/Adapter").(*Adapter)
+type Adapter struct {
+    pgo.Object
+    client *Client
+}
+
+func (a *Adapter) Construct(componentId ...string) {
+    id := defaultComponentId
+    if len(componentId) > 0 {
+        id = componentId[0]
+    }
+
+    a.client = pgo.App.Get(id).(*Client)
+}
+
+func (a *Adapter) GetClient() *Client {
+    return a.client
+}
+
+// push job of type with payload, see Client.Enqueue
+func (a *Adapter) Enqueue(jobType string, payload interface{}) (string, error) {
+    profile := "Queue.Enqueue"
+    a.GetContext().ProfileStart(profile)
+    defer a.GetContext().ProfileStop(profile)
+
+    return a.client.Enqueue(jobType, payload, a.GetContext().GetLogId())
+}
@@ -0,0 +1,172 @@
+package Queue
+
+import (
+    "errors"
+    "fmt"
+    "sync"
+    "time"
+
+    "github.com/pinguo/pgo"
+    "github.com/pinguo/pgo/Client/Redis"
+    "github.com/pinguo/pgo/Util"
+)
+
+var errQueueFull = errors.New("queue: memory queue is full")
+
+// queue backend storing encoded jobs, Pop waits up to timeout
+// and returns nil data if no job, dead letters are kept aside
+type IBackend interface {
+    Push(data []byte) error
+    Pop(timeout time.Duration) ([]byte, error)
+    PushDead(data []byte) error
+}
+
+// in-process queue backed by channel, jobs are lost on exit,
+// configuration:
+// "backend": {
+//     "class": "@pgo/Client/Queue/MemoryBackend",
+//     "size": 1000
+// }
+//
+// Push fails if queue is full, the latest size dead letters are kept.
+type MemoryBackend struct {
+    size int
+    ch   chan []byte
+    dead [][]byte
+    lock sync.Mutex
+}
+
+func (m *MemoryBackend) Construct() {
+    m.size = defaultMemorySize
+}
+
+func (m *MemoryBackend) Init() {
+    m.ch = make(chan []byte, m.size)
+}
+
+func (m *MemoryBackend) SetSize(size int) {
+    if size > 0 {
+        m.size = size
+    }
+}
+
+func (m *MemoryBackend) Push(data []byte) error {
+    select {
+    case m.ch <- data:
+        return nil
+    default:
+        return errQueueFull
+    }
+}
+
+func (m *MemoryBackend) Pop(timeout time.Duration) ([]byte, error) {
+    timer := time.NewTimer(timeout)
+    defer timer.Stop()
+
+    select {
+    case data := <-m.ch:
+        return data, nil
+    case <-timer.C:
+        return nil, nil
+    }
+}
+
+func (m *MemoryBackend) PushDead(data []byte) error {
+    m.lock.Lock()
+    defer m.lock.Unlock()
+
+    if m.dead = append(m.dead, data); len(m.dead) > m.size {
+        m.dead = m.dead[len(m.dead)-m.size:]
+    }
+
+    return nil
+}
+
+// get dead letters kept
+func (m *MemoryBackend) GetDead() [][]byte {
+    m.lock.Lock()
+    defer m.lock.Unlock()
+
+    return append([][]byte(nil), m.dead...)
+}
+
+// queue backed by redis list, jobs are pushed by LPUSH and popped by
+// BRPOP, dead letters are pushed to list of key with ":dead" suffix,
+// configuration:
+// "backend": {
+//     "class": "@pgo/Client/Queue/RedisBackend",
+//     "redis": "redis",
+//     "key": "queue"
+// }
+//
+// redis is id of Redis Client component, key is prefixed by its prefix.
+type RedisBackend struct {
+    redis string
+    key   string
+}
+
+func (r *RedisBackend) Construct() {
+    r.redis = defaultRedisId
+    r.key = defaultRedisKey
+}
+
+func (r *RedisBackend) SetRedis(id string) {
+    r.redis = id
+}
+
+func (r *RedisBackend) SetKey(key string) {
+    r.key = key
+}
+
+func (r *RedisBackend) Push(data []byte) error {
+    return r.push(r.key, data)
+}
+
+func (r *RedisBackend) PushDead(data []byte) error {
+    return r.push(r.key+":dead", data)
+}
+
+func (r *RedisBackend) Pop(timeout time.Duration) (data []byte, err error) {
+    defer func() {
+        if v := recover(); v != nil {
+            err = fmt.Errorf("queue: redis pop failed, %s", Util.ToString(v))
+        }
+    }()
+
+    client := pgo.App.Get(r.redis).(*Redis.Client)
+    key, seconds := client.BuildKey(r.key), int(timeout/time.Second)
+    if seconds < 1 {
+        seconds = 1
+    }
+
+    conn := client.GetConnByKey(key)
+    conn.ExtendDeadLine(time.Duration(seconds)*time.Second + time.Second)
+    reply := conn.Do("BRPOP", key, seconds)
+    conn.Close(false)
+
+    // reply is nil on timeout, or [key, data]
+    if items, ok := reply.([]interface{}); ok && len(items) == 2 {
+        data, _ = items[1].([]byte)
+    }
+
+    return data, nil
+}
+
+func (r *RedisBackend) push(key string, data []byte) (err error) {
+    defer func() {
+        if v := recover(); v != nil {
+            err = fmt.Errorf("queue: redis push failed, %s", Util.ToString(v))
+        }
+    }()
+
+    client := pgo.App.Get(r.redis).(*Redis.Client)
+    key = client.BuildKey(key)
+    conn := client.GetConnByKey(key)
+    defer conn.Close(false)
+
+    if _, ok := conn.Do("LPUSH", key, data).(int); !ok {
+        return errors.New("queue: redis push failed, unexpected reply")
+    }
+
+    return nil
+}
@@ -0,0 +1,349 @@
+package Queue
+
+import (
+    "encoding/json"
+    "fmt"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/pinguo/pgo"
+    "github.com/pinguo/pgo/Util"
+)
+
+// handler of job type, returned error or panic fails the job
+type Handler func(ctx *pgo.Context, job *Job) error
+
+// job in queue, payload is json of value passed to Enqueue
+type Job struct {
+    Id       string          `json:"id"`
+    Type     string          `json:"type"`
+    Payload  json.RawMessage `json:"payload"`
+    LogId    string          `json:"logId"`
+    Attempt  int             `json:"attempt"`
+    Error    string          `json:"error,omitempty"`
+    Enqueued int64           `json:"enqueued"`
+}
+
+// decode payload into ptr
+func (j *Job) Decode(ptr interface{}) error {
+    return json.Unmarshal(j.Payload, ptr)
+}
+
+// worker pool statistics
+type Stats struct {
+    Processed uint64 `json:"processed"`
+    Failed    uint64 `json:"failed"`
+    Retried   uint64 `json:"retried"`
+    Dead      uint64 `json:"dead"`
+    InFlight  int64  `json:"inFlight"`
+}
+
+// Queue Client component, worker pool consuming jobs from backend,
+// configuration:
+// "queue": {
+//     "class": "@pgo/Client/Queue/Client",
+//     "backend": {"class": "@pgo/Client/Queue/RedisBackend", "redis": "redis", "key": "queue"},
+//     "concurrency": 4,
+//     "retry": 3,
+//     "backoff": "1s",
+//     "pollTimeout": "1s"
+// }
+//
+// backend defaults to MemoryBackend, failed job is retried up to retry
+// times with backoff doubled after each attempt(max 10m), then it's
+// pushed to dead letter of backend, job without handler goes to dead
+// letter at once, retry waits in process, so it's pushed at once when
+// stopping, register handlers and call Start, eg. in main:
+// q := pgo.App.Get("queue").(*Queue.Client)
+// q.Register("mail", sendMail)
+// q.Start()
+//
+// Stop waits in-flight jobs, it's called by app shutdown, see stopTimeout.
+type Client struct {
+    backend     IBackend
+    concurrency int
+    retry       int
+    backoff     time.Duration
+    pollTimeout time.Duration
+
+    handlers map[string]Handler
+    lock     sync.RWMutex
+    started  bool
+    stop     chan struct{}
+    workers  sync.WaitGroup
+    retries  map[*time.Timer][]byte
+
+    processed uint64
+    failed    uint64
+    retried   uint64
+    dead      uint64
+    inFlight  int64
+}
+
+func (c *Client) Construct() {
+    c.concurrency = defaultConcurrency
+    c.retry = defaultRetry
+    c.backoff = defaultBackoff
+    c.pollTimeout = defaultPollTimeout
+    c.handlers = make(map[string]Handler)
+    c.retries = make(map[*time.Timer][]byte)
+    c.stop = make(chan struct{})
+}
+
+func (c *Client) Init() {
+    if c.backend == nil {
+        c.backend = pgo.CreateObject("@pgo/Client/Queue/MemoryBackend").(IBackend)
+    }
+}
+
+// set backend by class or object configuration
+func (c *Client) SetBackend(v interface{}) {
+    if backend, ok := pgo.CreateObject(v).(IBackend); ok {
+        c.backend = backend
+    } else {
+        panic(fmt.Sprintf(errSetProp, "backend", "invalid backend "+Util.ToString(v)))
+    }
+}
+
+func (c *Client) GetBackend() IBackend {
+    return c.backend
+}
+
+func (c *Client) SetConcurrency(concurrency int) {
+    if concurrency > 0 {
+        c.concurrency = concurrency
+    }
+}
+
+// set max retries of failed job, 0 for no retry
+func (c *Client) SetRetry(retry int) {
+    if retry >= 0 {
+        c.retry = retry
+    }
+}
+
+func (c *Client) SetBackoff(v string) {
+    if backoff, e := time.ParseDuration(v); e != nil {
+        panic(fmt.Sprintf(errSetProp, "backoff", e.Error()))
+    } else {
+        c.backoff = backoff
+    }
+}
+
+// set max time of waiting job from backend, it also bounds stop latency
+func (c *Client) SetPollTimeout(v string) {
+    if pollTimeout, e := time.ParseDuration(v); e != nil || pollTimeout <= 0 {
+        panic(fmt.Sprintf(errSetProp, "pollTimeout", v))
+    } else {
+        c.pollTimeout = pollTimeout
+    }
+}
+
+// register handler of job type, existing handler is replaced
+func (c *Client) Register(jobType string, handler Handler) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+
+    c.handlers[jobType] = handler
+}
+
+// push job of type with payload encoded as json, logId is
+// optional to correlate logs of the job with its producer
+func (c *Client) Enqueue(jobType string, payload interface{}, logId ...string) (string, error) {
+    data, e := json.Marshal(payload)
+    if e != nil {
+        return "", e
+    }
+
+    job := &Job{Id: Util.GenUniqueId(), Type: jobType, Payload: data, Enqueued: time.Now().Unix()}
+    if len(logId) > 0 {
+        job.LogId = logId[0]
+    }
+
+    raw, _ := json.Marshal(job)
+    return job.Id, c.backend.Push(raw)
+}
+
+// start workers, it's no-op if started
+func (c *Client) Start() {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+
+    if c.started {
+        return
+    }
+
+    c.started = true
+    c.workers.Add(c.concurrency)
+    for i := 0; i < c.concurrency; i++ {
+        pgo.Go(c.work)
+    }
+}
+
+// stop workers and wait in-flight jobs, waiting retries are pushed
+// back to backend at once, implements pgo.IStopper
+func (c *Client) Stop() error {
+    c.lock.Lock()
+    if !c.started {
+        c.lock.Unlock()
+        return nil
+    }
+
+    c.started = false
+    close(c.stop)
+    c.lock.Unlock()
+
+    c.workers.Wait()
+
+    c.lock.Lock()
+    defer c.lock.Unlock()
+
+    var err error
+    for timer, raw := range c.retries {
+        // timer fired is left to push by itself
+        if timer.Stop() {
+            delete(c.retries, timer)
+            if e := c.backend.Push(raw); e != nil {
+                err = e
+            }
+        }
+    }
+
+    c.stop = make(chan struct{})
+    return err
+}
+
+func (c *Client) GetStats() Stats {
+    return Stats{
+        Processed: atomic.LoadUint64(&c.processed),
+        Failed:    atomic.LoadUint64(&c.failed),
+        Retried:   atomic.LoadUint64(&c.retried),
+        Dead:      atomic.LoadUint64(&c.dead),
+        InFlight:  atomic.LoadInt64(&c.inFlight),
+    }
+}
+
+func (c *Client) work() {
+    defer c.workers.Done()
+
+    c.lock.RLock()
+    stop := c.stop
+    c.lock.RUnlock()
+
+    for {
+        select {
+        case <-stop:
+            return
+        default:
+        }
+
+        raw, e := c.backend.Pop(c.pollTimeout)
+        if e != nil {
+            pgo.GLogger().Warn("Queue: failed to pop job, %s", e)
+            select {
+            case <-stop:
+                return
+            case <-time.After(c.pollTimeout):
+            }
+            continue
+        } else if raw == nil {
+            continue
+        }
+
+        c.process(raw)
+    }
+}
+
+func (c *Client) process(raw []byte) {
+    atomic.AddInt64(&c.inFlight, 1)
+    defer atomic.AddInt64(&c.inFlight, -1)
+
+    job := &Job{}
+    if e := json.Unmarshal(raw, job); e != nil {
+        pgo.GLogger().Error("Queue: invalid job, %s, %s", e, raw)
+        c.pushDead(raw)
+        return
+    }
+
+    c.lock.RLock()
+    handler := c.handlers[job.Type]
+    c.lock.RUnlock()
+
+    pgo.RunContext(job.LogId, func(ctx *pgo.Context) {
+        var e error
+        if handler == nil {
+            e = fmt.Errorf("no handler of type %s", job.Type)
+        } else {
+            e = c.call(ctx, handler, job)
+        }
+
+        atomic.AddUint64(&c.processed, 1)
+        if e == nil {
+            return
+        }
+
+        atomic.AddUint64(&c.failed, 1)
+        job.Attempt++
+        job.Error = e.Error()
+        raw, _ := json.Marshal(job)
+
+        if handler == nil || job.Attempt > c.retry {
+            ctx.Error("Queue: job %s of %s is dead after %d attempts, %s", job.Id, job.Type, job.Attempt, e)
+            c.pushDead(raw)
+            return
+        }
+
+        delay := c.backoff << uint(job.Attempt-1)
+        if delay > maxBackoff || delay <= 0 {
+            delay = maxBackoff
+        }
+
+        ctx.Warn("Queue: job %s of %s failed, retry in %s, %s", job.Id, job.Type, delay, e)
+        c.retryLater(raw, delay)
+    })
+}
+
+// call handler, panic is recovered as error
+func (c *Client) call(ctx *pgo.Context, handler Handler, job *Job) (err error) {
+    defer func() {
+        if v := recover(); v != nil {
+            err = fmt.Errorf("panic, %s", Util.ToString(v))
+            ctx.Error("Queue: job %s of %s panic, %s, trace[%s]", job.Id, job.Type, Util.ToString(v), Util.PanicTrace(pgo.TraceMaxDepth, false))
+        }
+    }()
+
+    return handler(ctx, job)
+}
+
+// push job back to backend after delay
+func (c *Client) retryLater(raw []byte, delay time.Duration) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+
+    var timer *time.Timer
+    timer = time.AfterFunc(delay, func() {
+        c.lock.Lock()
+        _, ok := c.retries[timer]
+        delete(c.retries, timer)
+        c.lock.Unlock()
+
+        if !ok {
+            return
+        }
+
+        atomic.AddUint64(&c.retried, 1)
+        if e := c.backend.Push(raw); e != nil {
+            pgo.GLogger().Error("Queue: failed to push retry, %s, %s", e, raw)
+        }
+    })
+
+    c.retries[timer] = raw
+}
+
+func (c *Client) pushDead(raw []byte) {
+    atomic.AddUint64(&c.dead, 1)
+    if e := c.backend.PushDead(raw); e != nil {
+        pgo.GLogger().Error("Queue: failed to push dead letter, %s, %s", e, raw)
+    }
+}
@@ -0,0 +1,188 @@
+package Queue
+
+import (
+    "encoding/json"
+    "errors"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/pinguo/pgo"
+)
+
+func init() {
+    // failed jobs are logged, keep test output clean
+    pgo.App.GetLog().SetLevels(pgo.LevelNone)
+}
+
+func newTestClient(retry int, backoff string) *Client {
+    c := &Client{}
+    c.Construct()
+    c.SetConcurrency(2)
+    c.SetRetry(retry)
+    c.SetBackoff(backoff)
+    c.SetPollTimeout("10ms")
+    c.Init()
+    return c
+}
+
+// wait until fn returns true or timeout
+func waitFor(t *testing.T, what string, fn func() bool) {
+    t.Helper()
+    for deadline := time.Now().Add(2 * time.Second); !fn(); time.Sleep(5 * time.Millisecond) {
+        if time.Now().After(deadline) {
+            t.Fatalf("timeout waiting %s", what)
+        }
+    }
+}
+
+func deadJobs(c *Client) []*Job {
+    jobs := make([]*Job, 0)
+    for _, raw := range c.GetBackend().(*MemoryBackend).GetDead() {
+        job := &Job{}
+        json.Unmarshal(raw, job)
+        jobs = append(jobs, job)
+    }
+
+    return jobs
+}
+
+func TestClientRetryBackoff(t *testing.T) {
+    c := newTestClient(3, "20ms")
+    defer c.Stop()
+
+    var lock sync.Mutex
+    var attempts []int
+    var times []time.Time
+    c.Register("mail", func(ctx *pgo.Context, job *Job) error {
+        lock.Lock()
+        defer lock.Unlock()
+
+        var to string
+        if e := job.Decode(&to); e != nil || to != "a@example.com" {
+            t.Errorf("want payload decoded, got %q %v", to, e)
+        }
+
+        attempts, times = append(attempts, job.Attempt), append(times, time.Now())
+        if len(attempts) < 3 {
+            return errors.New("smtp down")
+        }
+        return nil
+    })
+
+    c.Start()
+    if _, e := c.Enqueue("mail", "a@example.com"); e != nil {
+        t.Fatal(e)
+    }
+
+    waitFor(t, "job succeeded", func() bool { return c.GetStats().Processed == 3 })
+
+    lock.Lock()
+    defer lock.Unlock()
+    if len(attempts) != 3 || attempts[0] != 0 || attempts[1] != 1 || attempts[2] != 2 {
+        t.Fatalf("want attempts 0 1 2, got %v", attempts)
+    }
+
+    // backoff is doubled after each attempt
+    if d := times[1].Sub(times[0]); d < 20*time.Millisecond {
+        t.Errorf("first retry: want backoff 20ms, got %s", d)
+    }
+
+    if d := times[2].Sub(times[1]); d < 40*time.Millisecond {
+        t.Errorf("second retry: want backoff 40ms, got %s", d)
+    }
+
+    if s := c.GetStats(); s.Failed != 2 || s.Retried != 2 || s.Dead != 0 {
+        t.Errorf("want 2 failed and retried, got %+v", s)
+    }
+}
+
+func TestClientDeadLetter(t *testing.T) {
+    c := newTestClient(1, "1ms")
+    defer c.Stop()
+
+    c.Register("fail", func(ctx *pgo.Context, job *Job) error { return errors.New("bad job") })
+    c.Register("panic", func(ctx *pgo.Context, job *Job) error { panic("boom") })
+    c.Start()
+
+    for _, jobType := range []string{"fail", "panic", "unknown"} {
+        c.Enqueue(jobType, nil)
+    }
+
+    waitFor(t, "dead letters", func() bool { return c.GetStats().Dead == 3 })
+
+    want := map[string]Job{
+        "fail":    {Attempt: 2, Error: "bad job"},
+        "panic":   {Attempt: 2, Error: "panic, boom"},
+        "unknown": {Attempt: 1, Error: "no handler of type unknown"},
+    }
+
+    jobs := deadJobs(c)
+    if len(jobs) != 3 {
+        t.Fatalf("want 3 dead letters, got %d", len(jobs))
+    }
+
+    for _, job := range jobs {
+        if w := want[job.Type]; job.Attempt != w.Attempt || job.Error != w.Error {
+            t.Errorf("%s: want dead after %d attempts with %q, got %d %q", job.Type, w.Attempt, w.Error, job.Attempt, job.Error)
+        }
+    }
+
+    // invalid job can not be decoded, it goes to dead letter as it is
+    c.GetBackend().Push([]byte("not json"))
+    waitFor(t, "invalid job dead", func() bool { return c.GetStats().Dead == 4 })
+}
+
+func TestClientStopDrain(t *testing.T) {
+    c := newTestClient(3, "1h")
+
+    started, release := make(chan bool), make(chan bool)
+    c.Register("slow", func(ctx *pgo.Context, job *Job) error {
+        started <- true
+        <-release
+        return nil
+    })
+    c.Register("flaky", func(ctx *pgo.Context, job *Job) error { return errors.New("retry later") })
+    c.Start()
+
+    // failed job waits its backoff in process
+    c.Enqueue("flaky", nil)
+    waitFor(t, "flaky job failed", func() bool { return c.GetStats().Failed == 1 })
+
+    c.Enqueue("slow", nil)
+    select {
+    case <-started:
+    case <-time.After(time.Second):
+        t.Fatal("want slow job started")
+    }
+
+    stopped := make(chan error)
+    go func() { stopped <- c.Stop() }()
+
+    select {
+    case <-stopped:
+        t.Fatal("want Stop to wait in-flight job")
+    case <-time.After(50 * time.Millisecond):
+    }
+
+    close(release)
+    select {
+    case e := <-stopped:
+        if e != nil {
+            t.Errorf("want stopped, got %v", e)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("want Stop returned after in-flight job finished")
+    }
+
+    if s := c.GetStats(); s.Processed != 2 || s.InFlight != 0 {
+        t.Errorf("want in-flight job finished, got %+v", s)
+    }
+
+    // waiting retry is pushed back to backend at once
+    raw, _ := c.GetBackend().Pop(10 * time.Millisecond)
+    job := &Job{}
+    if json.Unmarshal(raw, job); job.Type != "flaky" || job.Attempt != 1 {
+        t.Errorf("want retry of flaky job pushed back, got %s", raw)
+    }
+}
@@ -0,0 +1,31 @@
+package Queue
+
+import (
+    "time"
+
+    "github.com/pinguo/pgo"
+)
+
+const (
+    defaultComponentId = "queue"
+    defaultConcurrency = 4
+    defaultRetry       = 3
+    defaultBackoff     = time.Second
+    defaultPollTimeout = time.Second
+    defaultMemorySize  = 1000
+    defaultRedisId     = "redis"
+    defaultRedisKey    = "queue"
+
+    maxBackoff = 10 * time.Minute
+
+    errSetProp = "queue: failed to set %s, %s"
+)
+
+func init() {
+    container := pgo.App.GetContainer()
+
+    container.Bind(&Adapter{})
+    container.Bind(&Client{})
+    container.Bind(&MemoryBackend{})
+    container.Bind(&RedisBackend{})
+}
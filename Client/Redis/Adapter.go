@@ -73,6 +73,11 @@ func (a *Adapter) MGet(keys []string) map[string]*pgo.Value {
     return res
 }
 
+// same as MGet
+func (a *Adapter) GetMulti(keys []string) map[string]*pgo.Value {
+    return a.MGet(keys)
+}
+
 func (a *Adapter) Set(key string, value interface{}, expire ...time.Duration) bool {
     profile := "Redis.Set"
     a.GetContext().ProfileStart(profile)
@@ -91,6 +96,11 @@ func (a *Adapter) MSet(items map[string]interface{}, expire ...time.Duration) bo
     return a.client.MSet(items, expire...)
 }
 
+// same as MSet
+func (a *Adapter) SetMulti(items map[string]interface{}, expire ...time.Duration) bool {
+    return a.MSet(items, expire...)
+}
+
 func (a *Adapter) Add(key string, value interface{}, expire ...time.Duration) bool {
     profile := "Redis.Add"
     a.GetContext().ProfileStart(profile)
@@ -136,6 +146,11 @@ func (a *Adapter) Exists(key string) bool {
     return a.client.Exists(key)
 }
 
+// same as Exists
+func (a *Adapter) Has(key string) bool {
+    return a.Exists(key)
+}
+
 func (a *Adapter) Incr(key string, delta int) int {
     profile := "Redis.Incr"
     a.GetContext().ProfileStart(profile)
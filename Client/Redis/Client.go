@@ -7,6 +7,7 @@ import (
     "time"
 
     "github.com/pinguo/pgo"
+    "github.com/pinguo/pgo/Util"
 )
 
 // Redis Client component, require redis-server 2.6.12+
@@ -20,16 +21,47 @@ import (
 //     "maxIdleTime": "60s",
 //     "netTimeout": "1s",
 //     "probInterval": "0s",
+//     "serializer": "json",
+//     "fallback": "",
 //     "servers": [
 //         "127.0.0.1:6379",
 //         "127.0.0.1:6380"
 //     ]
 // }
+//
+// serializer encodes struct, map and slice values, "json", "gob" or
+// class of a pgo.ISerializer(eg. "@app/Lib/MsgpackSerializer"), values
+// should be decoded by Client.Decode if not json, scalar values are
+// always stored as plain text so Incr works, fallback is id of an ICache
+// component(eg. "memory") used by single key operations when redis
+// is unreachable, error is raised if empty.
 type Client struct {
     Pool
+    serializer pgo.ISerializer
+    fallback   string
 }
 
-func (c *Client) Get(key string) *pgo.Value {
+// set serializer, json is done by conn, see pgo.CreateSerializer
+func (c *Client) SetSerializer(v interface{}) {
+    if v == "json" {
+        c.serializer = nil
+    } else {
+        c.serializer = pgo.CreateSerializer(v)
+    }
+}
+
+func (c *Client) SetFallback(fallback string) {
+    c.fallback = fallback
+}
+
+// decode value got from redis by the configured serializer
+func (c *Client) Decode(v *pgo.Value, ptr interface{}) error {
+    return pgo.Unserialize(c.serializer, v, ptr)
+}
+
+func (c *Client) Get(key string) (v *pgo.Value) {
+    defer c.handleFallback(func(cache pgo.ICache) { v = cache.Get(key) })
+
     newKey := c.BuildKey(key)
     conn := c.GetConnByKey(newKey)
     defer conn.Close(false)
@@ -60,7 +92,14 @@ func (c *Client) MGet(keys []string) map[string]*pgo.Value {
     return result
 }
 
-func (c *Client) Set(key string, value interface{}, expire ...time.Duration) bool {
+// same as MGet
+func (c *Client) GetMulti(keys []string) map[string]*pgo.Value {
+    return c.MGet(keys)
+}
+
+func (c *Client) Set(key string, value interface{}, expire ...time.Duration) (ok bool) {
+    defer c.handleFallback(func(cache pgo.ICache) { ok = cache.Set(key, value, expire...) })
+
     expire = append(expire, defaultExpire)
     return c.set(key, value, expire[0], "")
 }
@@ -70,7 +109,14 @@ func (c *Client) MSet(items map[string]interface{}, expire ...time.Duration) boo
     return c.mset(items, expire[0], "")
 }
 
-func (c *Client) Add(key string, value interface{}, expire ...time.Duration) bool {
+// same as MSet
+func (c *Client) SetMulti(items map[string]interface{}, expire ...time.Duration) bool {
+    return c.MSet(items, expire...)
+}
+
+func (c *Client) Add(key string, value interface{}, expire ...time.Duration) (ok bool) {
+    defer c.handleFallback(func(cache pgo.ICache) { ok = cache.Add(key, value, expire...) })
+
     expire = append(expire, defaultExpire)
     return c.set(key, value, expire[0], "NX")
 }
@@ -80,7 +126,9 @@ func (c *Client) MAdd(items map[string]interface{}, expire ...time.Duration) boo
     return c.mset(items, expire[0], "NX")
 }
 
-func (c *Client) Del(key string) bool {
+func (c *Client) Del(key string) (ok bool) {
+    defer c.handleFallback(func(cache pgo.ICache) { ok = cache.Del(key) })
+
     newKey := c.BuildKey(key)
     conn := c.GetConnByKey(newKey)
     defer conn.Close(false)
@@ -106,7 +154,9 @@ func (c *Client) MDel(keys []string) bool {
     return success == uint32(len(keys))
 }
 
-func (c *Client) Exists(key string) bool {
+func (c *Client) Exists(key string) (ok bool) {
+    defer c.handleFallback(func(cache pgo.ICache) { ok = cache.Exists(key) })
+
     newKey := c.BuildKey(key)
     conn := c.GetConnByKey(newKey)
     defer conn.Close(false)
@@ -115,12 +165,19 @@ func (c *Client) Exists(key string) bool {
     return ok && num == 1
 }
 
-func (c *Client) Incr(key string, delta int) int {
+// same as Exists
+func (c *Client) Has(key string) bool {
+    return c.Exists(key)
+}
+
+func (c *Client) Incr(key string, delta int) (num int) {
+    defer c.handleFallback(func(cache pgo.ICache) { num = cache.Incr(key, delta) })
+
     newKey := c.BuildKey(key)
     conn := c.GetConnByKey(newKey)
     defer conn.Close(false)
 
-    num, _ := conn.Do("INCRBY", newKey, delta).(int)
+    num, _ = conn.Do("INCRBY", newKey, delta).(int)
     return num
 }
 
@@ -130,7 +187,7 @@ func (c *Client) set(key string, value interface{}, expire time.Duration, flag s
     defer conn.Close(false)
 
     var res interface{}
-    if len(flag) == 0 {
+    if value = pgo.Serialize(c.serializer, value); len(flag) == 0 {
         res = conn.Do("SET", newKey, value, "EX", expire/time.Second)
     } else {
         res = conn.Do("SET", newKey, value, "EX", expire/time.Second, flag)
@@ -148,10 +205,10 @@ func (c *Client) mset(items map[string]interface{}, expire time.Duration, flag s
     for addr, keys := range addrKeys {
         go c.RunAddrFunc(addr, keys, wg, func(conn *Conn, keys []string) {
             for _, key := range keys {
-                if oldKey := newKeys[key]; len(flag) == 0 {
-                    conn.WriteCmd("SET", key, items[oldKey], "EX", expire/time.Second)
+                if value := pgo.Serialize(c.serializer, items[newKeys[key]]); len(flag) == 0 {
+                    conn.WriteCmd("SET", key, value, "EX", expire/time.Second)
                 } else {
-                    conn.WriteCmd("SET", key, items[oldKey], "EX", expire/time.Second, flag)
+                    conn.WriteCmd("SET", key, value, "EX", expire/time.Second, flag)
                 }
             }
 
@@ -167,3 +224,17 @@ func (c *Client) mset(items map[string]interface{}, expire time.Duration, flag s
     wg.Wait()
     return success == uint32(len(items))
 }
+
+// call fn with fallback cache if redis operation panics,
+// panic continues if fallback is not configured
+func (c *Client) handleFallback(fn func(cache pgo.ICache)) {
+    if len(c.fallback) == 0 {
+        return
+    }
+
+    if v := recover(); v != nil {
+        pgo.GLogger().Warn("redis: fallback to %s, %s", c.fallback, Util.ToString(v))
+        fn(pgo.App.Get(c.fallback).(pgo.ICache))
+    }
+}
+
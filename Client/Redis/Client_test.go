@@ -0,0 +1,215 @@
+package Redis
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "net"
+    "strconv"
+    "strings"
+    "sync"
+    "testing"
+
+    "github.com/pinguo/pgo"
+    _ "github.com/pinguo/pgo/Client/Memory"
+)
+
+type user struct {
+    Name string
+    Age  int
+}
+
+// fake redis server supports commands used by Client
+type fakeServer struct {
+    ln   net.Listener
+    lock sync.Mutex
+    data map[string]string
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+    ln, e := net.Listen("tcp", "127.0.0.1:0")
+    if e != nil {
+        t.Fatal(e)
+    }
+
+    s := &fakeServer{ln: ln, data: make(map[string]string)}
+    go func() {
+        for {
+            nc, e := ln.Accept()
+            if e != nil {
+                return
+            }
+            go s.serve(nc)
+        }
+    }()
+
+    t.Cleanup(func() { ln.Close() })
+    return s
+}
+
+func (s *fakeServer) serve(nc net.Conn) {
+    defer nc.Close()
+    r := bufio.NewReader(nc)
+    for {
+        args, e := readCommand(r)
+        if e != nil {
+            return
+        }
+
+        s.lock.Lock()
+        reply := s.exec(strings.ToUpper(args[0]), args[1:])
+        s.lock.Unlock()
+
+        if _, e := io.WriteString(nc, reply); e != nil {
+            return
+        }
+    }
+}
+
+func (s *fakeServer) get(key string) string {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+    return s.data[key]
+}
+
+func (s *fakeServer) exec(cmd string, args []string) string {
+    bulk := func(k string) string {
+        if v, ok := s.data[k]; ok {
+            return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+        }
+        return "$-1\r\n"
+    }
+
+    switch cmd {
+    case "GET":
+        return bulk(args[0])
+    case "MGET":
+        reply := fmt.Sprintf("*%d\r\n", len(args))
+        for _, k := range args {
+            reply += bulk(k)
+        }
+        return reply
+    case "SET":
+        if _, ok := s.data[args[0]]; ok && len(args) > 4 && args[4] == "NX" {
+            return "$-1\r\n"
+        }
+        s.data[args[0]] = args[1]
+        return "+OK\r\n"
+    case "DEL", "EXISTS":
+        _, ok := s.data[args[0]]
+        if cmd == "DEL" {
+            delete(s.data, args[0])
+        }
+        if ok {
+            return ":1\r\n"
+        }
+        return ":0\r\n"
+    case "INCRBY":
+        n, _ := strconv.Atoi(s.data[args[0]])
+        delta, _ := strconv.Atoi(args[1])
+        s.data[args[0]] = strconv.Itoa(n + delta)
+        return ":" + s.data[args[0]] + "\r\n"
+    }
+
+    return "-ERR unknown command " + cmd + "\r\n"
+}
+
+// read command of resp array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+    line, e := r.ReadString('\n')
+    if e != nil {
+        return nil, e
+    }
+
+    argc, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+    args := make([]string, argc)
+    for i := range args {
+        if line, e = r.ReadString('\n'); e != nil {
+            return nil, e
+        }
+
+        size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+        buf := make([]byte, size+2)
+        if _, e := io.ReadFull(r, buf); e != nil {
+            return nil, e
+        }
+        args[i] = string(buf[:size])
+    }
+
+    return args, nil
+}
+
+func newClient(addr, serializer string) *Client {
+    c := &Client{}
+    c.Construct()
+    c.SetServers([]interface{}{addr})
+    c.SetSerializer(serializer)
+    c.Init()
+    return c
+}
+
+func TestClientSerializer(t *testing.T) {
+    server := newFakeServer(t)
+    for _, name := range []string{"json", "gob"} {
+        c := newClient(server.ln.Addr().String(), name)
+        if !c.Set("user", &user{"pgo", 3}) {
+            t.Fatalf("%s: set failed", name)
+        }
+
+        got := user{}
+        if e := c.Decode(c.Get("user"), &got); e != nil || got != (user{"pgo", 3}) {
+            t.Errorf("%s: want stored user, got %+v %v", name, got, e)
+        }
+
+        // scalar is stored as plain text, so Incr works
+        c.Set("num", 1)
+        if n := c.Incr("num", 2); n != 3 || server.get(c.BuildKey("num")) != "3" {
+            t.Errorf("%s: want num 3, got %d", name, n)
+        }
+
+        if c.Add("num", 5) || !c.Exists("num") || !c.Del("num") || c.Exists("num") {
+            t.Errorf("%s: want Add of existing key failed and Del removing key", name)
+        }
+    }
+}
+
+func TestClientMGet(t *testing.T) {
+    server := newFakeServer(t)
+    c := newClient(server.ln.Addr().String(), "json")
+    c.MSet(map[string]interface{}{"a": 1, "b": "x"})
+
+    res := c.MGet([]string{"a", "b", "c"})
+    if res["a"].Int() != 1 || res["b"].String() != "x" || res["c"].Valid() {
+        t.Errorf("want a=1 b=x and c missing, got %v %v %v", res["a"], res["b"], res["c"])
+    }
+}
+
+func TestClientFallback(t *testing.T) {
+    // nothing listens on closed listener, so redis is unreachable
+    ln, _ := net.Listen("tcp", "127.0.0.1:0")
+    addr := ln.Addr().String()
+    ln.Close()
+
+    c := newClient(addr, "json")
+    func() {
+        defer func() {
+            if recover() == nil {
+                t.Error("without fallback: want panic")
+            }
+        }()
+        c.Get("key")
+    }()
+
+    c.SetFallback("cache")
+    if !c.Set("key", "value") || c.Get("key").String() != "value" {
+        t.Error("with fallback: want value kept by fallback cache")
+    }
+
+    if v := pgo.App.Get("cache").(pgo.ICache).Get("key"); v.String() != "value" {
+        t.Errorf("want value set in fallback cache, got %v", v)
+    }
+
+    if c.Incr("num", 2) != 2 || !c.Del("key") || c.Exists("key") {
+        t.Error("with fallback: want Incr, Del and Exists by fallback cache")
+    }
+}
@@ -7,6 +7,7 @@ import (
     "sync"
     "time"
 
+    "github.com/pinguo/pgo"
     "github.com/pinguo/pgo/Util"
 )
 
@@ -66,7 +67,7 @@ func (p *Pool) Init() {
             p.probeInterval = minProbeInterval
         }
 
-        go p.probeLoop()
+        pgo.Go(p.probeLoop)
     }
 }
 
@@ -0,0 +1,94 @@
+package pgo
+
+import (
+    "fmt"
+    "io"
+    "os"
+    "sort"
+
+    "github.com/pinguo/pgo/Util"
+)
+
+// context of registered command
+type CmdContext struct {
+    *Context
+    Name string   // command name
+    Args []string // arguments except framework flags
+}
+
+type command struct {
+    fn   func(ctx *CmdContext) int
+    desc string
+}
+
+// register command function for cmd mode, `--cmd name` runs the
+// function and its return value is used as the exit code, `--cmd help`
+// lists registered commands, name must not start with "/", names not
+// registered are routed to Command controllers, eg. `--cmd foo/bar`,
+// and unknown ones list commands with exit code 2,
+// ctx.Args holds arguments except framework flags for command to parse
+// its own flags, eg.
+// pgo.App.RegisterCommand("migrate", func(ctx *pgo.CmdContext) int {
+//     fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+//     steps := fs.Int("steps", 1, "steps to migrate")
+//     if e := fs.Parse(ctx.Args); e != nil {
+//         return 2
+//     }
+//     return 0
+// }, "run database migrations")
+func (app *Application) RegisterCommand(name string, fn func(ctx *CmdContext) int, desc ...string) {
+    if len(name) == 0 || name[0] == '/' || name == CommandHelp {
+        panic("RegisterCommand: invalid command name: " + name)
+    }
+
+    if app.commands == nil {
+        app.commands = make(map[string]*command)
+    }
+
+    app.commands[name] = &command{fn, append(desc, "")[0]}
+}
+
+// check whether name is a registered command or help
+func (app *Application) hasCommand(name string) bool {
+    _, ok := app.commands[name]
+    return ok || name == CommandHelp
+}
+
+// run registered command, return exit code
+func (app *Application) runCommand(ctx *Context, name string) (code int) {
+    cmd, ok := app.commands[name]
+    if name == CommandHelp || !ok {
+        return app.showCommands(os.Stdout, os.Stderr, name)
+    }
+
+    defer func() {
+        if v := recover(); v != nil {
+            ctx.Error("command %s panic, %s, trace[%s]", name, Util.ToString(v), Util.PanicTrace(TraceMaxDepth, false))
+            code = 1
+        }
+    }()
+
+    return cmd.fn(&CmdContext{Context: ctx, Name: name, Args: app.GetArgs()})
+}
+
+// list registered commands for help or unknown command, exit code
+// is 2 for unknown command
+func (app *Application) showCommands(stdout, stderr io.Writer, name string) (code int) {
+    if name != CommandHelp {
+        fmt.Fprintf(stderr, "unknown command: %s\n", name)
+        code = 2
+    }
+
+    names := make([]string, 0, len(app.commands))
+    for name := range app.commands {
+        names = append(names, name)
+    }
+
+    sort.Strings(names)
+    fmt.Fprintln(stdout, "commands:")
+    for _, name := range names {
+        fmt.Fprintf(stdout, "  %-20s %s\n", name, app.commands[name].desc)
+    }
+
+    return
+}
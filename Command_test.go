@@ -0,0 +1,162 @@
+package pgo
+
+import (
+    "bytes"
+    "flag"
+    "fmt"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestRunCommand(t *testing.T) {
+    App.RegisterCommand("test-exit", func(ctx *CmdContext) int {
+        if ctx.Name != "test-exit" {
+            t.Errorf("want name test-exit, got %q", ctx.Name)
+        }
+        return 3
+    })
+    App.RegisterCommand("test-panic", func(ctx *CmdContext) int {
+        panic("boom")
+    })
+
+    ctx := &Context{}
+    ctx.Init()
+
+    if code := App.runCommand(ctx, "test-exit"); code != 3 {
+        t.Errorf("test-exit: want exit code 3, got %d", code)
+    }
+
+    if code := App.runCommand(ctx, "test-panic"); code != 1 {
+        t.Errorf("test-panic: want exit code 1, got %d", code)
+    }
+}
+
+func TestCommandOwnFlags(t *testing.T) {
+    fs := flag.NewFlagSet("pgo", flag.ContinueOnError)
+    env := fs.String("env", "", "")
+    cmd := fs.String("cmd", "", "")
+    check := fs.Bool("check", false, "")
+
+    arguments := []string{"--env", "prod", "--steps", "3", "--cmd=migrate", "-check", "-v", "users", "--", "--env", "x"}
+    fwArgs, args := splitArgs(fs, arguments)
+    if e := fs.Parse(fwArgs); e != nil || *env != "prod" || *cmd != "migrate" || !*check {
+        t.Fatalf("want framework flags consumed, got %v, env=%q cmd=%q check=%v", e, *env, *cmd, *check)
+    }
+
+    if want := []string{"--steps", "3", "-v", "users", "--env", "x"}; !reflect.DeepEqual(args, want) {
+        t.Errorf("want %v left for command, got %v", want, args)
+    }
+
+    oldArgs := App.args
+    App.args = args
+    defer func() { App.args = oldArgs }()
+
+    App.RegisterCommand("test-flags", func(ctx *CmdContext) int {
+        fs := flag.NewFlagSet(ctx.Name, flag.ContinueOnError)
+        steps := fs.Int("steps", 1, "")
+        verbose := fs.Bool("v", false, "")
+        if e := fs.Parse(ctx.Args); e != nil {
+            return 2
+        }
+
+        if *steps != 3 || !*verbose || !reflect.DeepEqual(fs.Args(), []string{"users", "--env", "x"}) {
+            t.Errorf("want own flags parsed, got steps=%d v=%v args=%v", *steps, *verbose, fs.Args())
+        }
+        return 0
+    })
+
+    ctx := &Context{}
+    ctx.Init()
+    if code := App.runCommand(ctx, "test-flags"); code != 0 {
+        t.Errorf("want exit code 0, got %d", code)
+    }
+}
+
+func TestHasCommand(t *testing.T) {
+    App.RegisterCommand("test-has", func(ctx *CmdContext) int { return 0 })
+
+    cases := map[string]bool{
+        "test-has":     true,
+        CommandHelp:    true,
+        "foo/bar":      false,
+        "/foo/bar":     false,
+        "test-unknown": false,
+        "":             false,
+    }
+
+    for name, want := range cases {
+        if got := App.hasCommand(name); got != want {
+            t.Errorf("hasCommand(%q): want %v, got %v", name, want, got)
+        }
+    }
+}
+
+func TestRegisterCommandInvalid(t *testing.T) {
+    for _, name := range []string{"", "/foo", CommandHelp} {
+        func() {
+            defer func() {
+                if recover() == nil {
+                    t.Errorf("RegisterCommand(%q): want panic", name)
+                }
+            }()
+            App.RegisterCommand(name, func(ctx *CmdContext) int { return 0 })
+        }()
+    }
+}
+
+func TestShowCommands(t *testing.T) {
+    App.RegisterCommand("test-list-b", func(ctx *CmdContext) int { return 0 }, "second command")
+    App.RegisterCommand("test-list-a", func(ctx *CmdContext) int { return 0 }, "first command")
+
+    tests := []struct {
+        name, stderr string
+        code         int
+    }{
+        {CommandHelp, "", 0},
+        {"test-missing", "unknown command: test-missing\n", 2},
+    }
+
+    for _, test := range tests {
+        name := test.name
+        stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+        if code := App.showCommands(stdout, stderr, name); code != test.code {
+            t.Errorf("%s: want exit code %d, got %d", name, test.code, code)
+        }
+
+        out := stdout.String()
+        a := strings.Index(out, "  test-list-a          first command\n")
+        b := strings.Index(out, "  test-list-b          second command\n")
+        if !strings.HasPrefix(out, "commands:\n") || a == -1 || b < a {
+            t.Errorf("%s: want sorted command list with descriptions, got\n%s", name, out)
+        }
+
+        if stderr.String() != test.stderr {
+            t.Errorf("%s: want stderr %q, got %q", name, test.stderr, stderr.String())
+        }
+    }
+}
+
+func TestServeCMD(t *testing.T) {
+    App.RegisterCommand("test-serve", func(ctx *CmdContext) int { return 3 })
+
+    path, handled := fmt.Sprintf("/test/serve%d", time.Now().UnixNano()), false
+    App.GetRouter().AddHandler("^"+path+"$", func(ctx *Context) { handled = true }, nil)
+
+    defer flag.Set("cmd", "")
+    defer func() { App.GetServer().exitCode = 0 }()
+
+    for name, want := range map[string]int{"test-serve": 3, path: 0, "test-serve-typo": 2} {
+        App.GetServer().exitCode = 0
+        flag.Set("cmd", name)
+        App.GetServer().ServeCMD()
+        if code := App.GetServer().exitCode; code != want {
+            t.Errorf("%s: want exit code %d, got %d", name, want, code)
+        }
+    }
+
+    if !handled {
+        t.Error("want unregistered name routed to handler")
+    }
+}
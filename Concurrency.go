@@ -0,0 +1,81 @@
+package pgo
+
+import (
+    "net/http"
+    "strconv"
+    "sync/atomic"
+    "time"
+)
+
+// set max concurrent requests, 0 for unlimited
+func (s *Server) SetMaxConcurrent(n int) {
+    if n < 0 {
+        panic("Server: maxConcurrent must not be negative")
+    }
+
+    s.maxConcurrent, s.slots = n, nil
+    if n > 0 {
+        s.slots = make(chan struct{}, n)
+    }
+}
+
+// set max time of request queued for a slot, 0 to shed immediately
+func (s *Server) SetConcurrentWait(wait string) {
+    s.concurrentWait, _ = time.ParseDuration(wait)
+}
+
+// set Retry-After of shed request
+func (s *Server) SetShedRetryAfter(v string) {
+    s.shedRetryAfter, _ = time.ParseDuration(v)
+}
+
+// get number of requests being served, including queued ones
+func (s *Server) GetInFlight() int64 {
+    return atomic.LoadInt64(&s.inFlight)
+}
+
+// get number of requests shed since server start
+func (s *Server) GetShedCount() uint64 {
+    return atomic.LoadUint64(&s.numShed)
+}
+
+// acquire a slot of concurrent requests, the slot channel is returned
+// to release, false if the request is shed and 503 is sent
+func (s *Server) acquire(w http.ResponseWriter, r *http.Request) (chan struct{}, bool) {
+    slots := s.slots
+    if slots == nil || s.isDrainExempt(r.URL.Path) {
+        return nil, true
+    }
+
+    select {
+    case slots <- struct{}{}:
+        return slots, true
+    default:
+    }
+
+    if s.concurrentWait > 0 {
+        timer := time.NewTimer(s.concurrentWait)
+        defer timer.Stop()
+
+        select {
+        case slots <- struct{}{}:
+            return slots, true
+        case <-timer.C:
+        case <-r.Context().Done():
+        }
+    }
+
+    atomic.AddUint64(&s.numShed, 1)
+    if retryAfter := s.shedRetryAfter; retryAfter > 0 {
+        w.Header().Set("Retry-After", strconv.Itoa(int((retryAfter+time.Second-1)/time.Second)))
+    }
+
+    http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+    return nil, false
+}
+
+func (s *Server) release(slots chan struct{}) {
+    if slots != nil {
+        <-slots
+    }
+}
@@ -0,0 +1,69 @@
+package pgo
+
+import (
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+func TestServerMaxConcurrent(t *testing.T) {
+    s := App.GetServer()
+    s.SetMaxConcurrent(1)
+    s.SetShedRetryAfter("1500ms")
+    defer s.SetMaxConcurrent(0)
+    defer s.SetShedRetryAfter("1s")
+    defer s.SetConcurrentWait("0s")
+
+    // unique path so rule of the previous run(-count) is not matched
+    slow := fmt.Sprintf("/concurrent/slow%d", time.Now().UnixNano())
+    started, release := make(chan struct{}), make(chan struct{})
+    App.GetRouter().AddHandler("^"+slow+"$", func(ctx *Context) {
+        started <- struct{}{}
+        <-release
+        ctx.End(http.StatusOK, []byte("slow"))
+    }, nil)
+    App.GetRouter().AddHandler("^/concurrent/fast$", func(ctx *Context) {
+        ctx.End(http.StatusOK, []byte("fast"))
+    }, nil)
+
+    serve := func(path string) *httptest.ResponseRecorder {
+        w := httptest.NewRecorder()
+        s.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+        return w
+    }
+
+    done := make(chan int)
+    go func() { done <- serve(slow).Code }()
+    <-started
+
+    shed := s.GetShedCount()
+    w := serve("/concurrent/fast")
+    if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "2" {
+        t.Errorf("slot busy: want 503 with Retry-After 2, got %d %q", w.Code, w.Header().Get("Retry-After"))
+    }
+
+    if n := s.GetShedCount(); n != shed+1 {
+        t.Errorf("want shed count %d, got %d", shed+1, n)
+    }
+
+    if w := serve(App.GetHealth().GetPath()); w.Code == http.StatusServiceUnavailable {
+        t.Error("health path: want exempt from concurrency limit, got 503")
+    }
+
+    // queued request gets the slot once it's released
+    s.SetConcurrentWait("1s")
+    go func() {
+        time.Sleep(20 * time.Millisecond)
+        close(release)
+    }()
+
+    if w := serve("/concurrent/fast"); w.Code != http.StatusOK {
+        t.Errorf("queued request: want 200 after slot released, got %d", w.Code)
+    }
+
+    if code := <-done; code != http.StatusOK {
+        t.Errorf("slow request: want 200, got %d", code)
+    }
+}
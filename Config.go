@@ -2,36 +2,173 @@ package pgo
 
 import (
     "encoding/json"
+    "fmt"
+    "io/fs"
     "io/ioutil"
     "os"
+    "path"
     "path/filepath"
+    "sort"
+    "strconv"
     "strings"
     "sync"
 
     "github.com/pinguo/pgo/Util"
 )
 
+const (
+    ConfigErrorDirMissing = 1 // config directory not found
+    ConfigErrorParse      = 2 // config file can not be parsed
+    ConfigErrorEmpty      = 3 // config file is empty
+    ConfigErrorSource     = 4 // config source failed to load
+    ConfigErrorSecret     = 5 // secret reference can not be resolved
+
+    ConfigAdded    = 1 // key is added, Old is nil
+    ConfigDeleted  = 2 // key is deleted, New is nil
+    ConfigModified = 3 // value of key is changed
+
+    SecretScheme   = "secret://"
+    SecretRedacted = "******"
+)
+
+// error raised when loading config, strict mode panics with it,
+// permissive mode records it and skips the offending path
+type ConfigError struct {
+    Kind    int
+    Path    string
+    Message string
+}
+
+func (c *ConfigError) Error() string {
+    switch c.Kind {
+    case ConfigErrorDirMissing:
+        return fmt.Sprintf("Config: directory missing: %s, %s", c.Path, c.Message)
+    case ConfigErrorParse:
+        return fmt.Sprintf("Config: failed to parse file: %s, %s", c.Path, c.Message)
+    case ConfigErrorEmpty:
+        return fmt.Sprintf("Config: empty file: %s, %s", c.Path, c.Message)
+    case ConfigErrorSource:
+        return fmt.Sprintf("Config: failed to load source: %s, %s", c.Path, c.Message)
+    case ConfigErrorSecret:
+        return fmt.Sprintf("Config: failed to resolve secret: %s, %s", c.Path, c.Message)
+    default:
+        return fmt.Sprintf("Config: %s, %s", c.Path, c.Message)
+    }
+}
+
+// config component, loads config files from @app/conf, @app/conf/{env}
+// and @app/conf/{dimension}/{name} of each profile in order, in strict mode(default) any config error
+// panics with *ConfigError, in permissive mode(--permissive)
+// errors are recorded, see GetErrors(), and loading goes on.
+//
+// overrides set by --set flags(eg. --set app.server.addr=:9000, repeatable)
+// and env(eg. PGO_app__server__addr=:9000, "__" for ".") are applied after
+// files of the name are loaded and merged, flags take precedence over env,
+// value is parsed as json if valid(number, bool, array, object), or string.
+//
+// string value "secret://<ref>"(eg. "secret://db/password") is resolved
+// by resolver set by SetSecretResolver when loaded, failure panics with
+// *ConfigError even in permissive mode, Dump redacts secret values.
+//
+// AddSource layers config of a source(eg. etcd or consul) under or over
+// files, changes notified by source's Watch reload config, see Reload.
+//
+// SetFS loads files under conf path from fs.FS instead of disk, eg.
+// embed.FS for single binary, overlays and env expansion work the same,
+// missing conf directory is reported when server starts, so the binary
+// can run without conf directory if fs is set at the start of main.
 type Config struct {
-    parsers map[string]IConfigParser
-    data    map[string]interface{}
-    paths   []string
-    lock    sync.RWMutex
+    parsers    map[string]IConfigParser
+    data       map[string]interface{}
+    paths      []string
+    confPath   string
+    fsys       fs.FS
+    dirError   *ConfigError
+    permissive bool
+    errors     []*ConfigError
+    overrides  [][2]interface{} // key and value in applying order
+    sets       [][2]interface{} // key and value set by Set
+    sources    []*configSource
+    resolver   ISecretResolver
+    secrets    map[string]bool // keys of secret references
+    callbacks  []func(changes []ConfigChange)
+    reloadLock sync.Mutex
+    lock       sync.RWMutex
 }
 
-func (c *Config) Construct() {
+func (c *Config) Construct(permissive ...bool) {
     c.parsers = make(map[string]IConfigParser)
     c.data = make(map[string]interface{})
+    c.secrets = make(map[string]bool)
     c.paths = make([]string, 0)
+    c.errors = make([]*ConfigError, 0)
+    c.permissive = len(permissive) > 0 && permissive[0]
 
     confPath := filepath.Join(App.GetBasePath(), "conf")
-    if f, e := os.Stat(confPath); os.IsNotExist(e) || !f.IsDir() {
-        panic("invalid config path, " + confPath)
+    if f, e := os.Stat(confPath); os.IsNotExist(e) || (e == nil && !f.IsDir()) {
+        msg := "expected directory at <base>/conf, check --base flag or binary location"
+        c.dirError = &ConfigError{ConfigErrorDirMissing, confPath, msg}
+        if c.permissive {
+            c.addError(c.dirError)
+        }
     }
 
+    c.confPath = confPath
+
     c.AddPath(confPath)
     c.AddPath(filepath.Join(confPath, App.GetEnv()))
+    for _, p := range App.GetProfiles() {
+        c.AddPath(filepath.Join(confPath, p[0], p[1]))
+    }
 
     c.AddParser("json", &JsonConfigParser{})
+    c.AddParser("json5", &JsonConfigParser{})
+}
+
+// set file system to load config files, root of fs is conf path,
+// loaded config is cleared and app config is applied again, so call
+// it at the start of main before using components, nil for disk
+func (c *Config) SetFS(fsys fs.FS) {
+    c.lock.Lock()
+    c.fsys, c.data = fsys, make(map[string]interface{})
+    if c.dirError != nil && fsys != nil {
+        errors := c.errors[:0]
+        for _, e := range c.errors {
+            if e != c.dirError {
+                errors = append(errors, e)
+            }
+        }
+        c.errors, c.dirError = errors, nil
+    }
+    c.lock.Unlock()
+
+    App.applyConfig(true)
+}
+
+// panic with missing conf directory in strict mode
+func (c *Config) checkDir() {
+    if c.dirError != nil && !c.permissive {
+        panic(c.dirError)
+    }
+}
+
+// check whether config is loaded in permissive mode
+func (c *Config) IsPermissive() bool {
+    return c.permissive
+}
+
+// get errors recorded in permissive mode
+func (c *Config) GetErrors() []*ConfigError {
+    return c.errors
+}
+
+// panic in strict mode, record error in permissive mode
+func (c *Config) addError(e *ConfigError) {
+    if !c.permissive {
+        panic(e)
+    }
+
+    c.errors = append(c.errors, e)
 }
 
 // add parser for file with ext extension
@@ -51,9 +188,21 @@ func (c *Config) AddPath(path string) {
     c.paths = append(paths, path)
 }
 
+// get bool config, accept bool, number(0 is false) and string of
+// true/false, 1/0, yes/no, on/off(case insensitive), dft for others
 func (c *Config) GetBool(key string, dft bool) bool {
-    if v := c.Get(key); v != nil {
-        return Util.ToBool(v)
+    switch v := c.Get(key).(type) {
+    case bool:
+        return v
+    case float32, float64, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+        return Util.ToFloat(v) != 0
+    case string:
+        switch strings.ToLower(strings.TrimSpace(v)) {
+        case "true", "1", "yes", "on", "y", "t":
+            return true
+        case "false", "0", "no", "off", "n", "f":
+            return false
+        }
     }
 
     return dft
@@ -67,9 +216,15 @@ func (c *Config) GetInt(key string, dft int) int {
     return dft
 }
 
+// get float config, accept number and numeric string, dft for others
 func (c *Config) GetFloat(key string, dft float64) float64 {
-    if v := c.Get(key); v != nil {
+    switch v := c.Get(key).(type) {
+    case float32, float64, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
         return Util.ToFloat(v)
+    case string:
+        if f, e := strconv.ParseFloat(strings.TrimSpace(v), 64); e == nil {
+            return f
+        }
     }
 
     return dft
@@ -96,12 +251,105 @@ func (c *Config) Get(key string) interface{} {
     return Util.MapGet(c.data, key)
 }
 
-// set config by dot separated key, empty key for root, nil val for clear
+// get sorted child keys of dot separated prefix, empty prefix for
+// loaded top-level names, nil if prefix not exists or is not a map, eg.
+// GetKeys("app.components") => ["cache", "http", "log", ...]
+func (c *Config) GetKeys(prefix string) []string {
+    m, _ := c.Get(prefix).(map[string]interface{})
+
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+
+    var keys []string
+    for k := range m {
+        keys = append(keys, k)
+    }
+
+    sort.Strings(keys)
+    return keys
+}
+
+// get copy of config subtree under dot separated prefix, empty map if
+// prefix not exists or is not a map, flat for dot separated keys, eg.
+// GetAll("app.server", true) => {"addr": ..., "http.readTimeout": ...}
+func (c *Config) GetAll(prefix string, flat ...bool) map[string]interface{} {
+    m, _ := c.Get(prefix).(map[string]interface{})
+
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+
+    if len(flat) > 0 && flat[0] {
+        return Util.MapFlatten(m)
+    }
+
+    return Util.MapCopy(m)
+}
+
+// set config by dot separated key, empty key for root, nil val for clear,
+// it's applied again when config is reloaded
 func (c *Config) Set(key string, val interface{}) {
     c.lock.Lock()
     defer c.lock.Unlock()
 
+    if len(key) > 0 {
+        val = resolveValue(key, val, c.resolver, c.secrets)
+    }
+
     Util.MapSet(c.data, key, val)
+
+    sets := c.sets[:0]
+    for _, o := range c.sets {
+        if k := o[0].(string); len(key) > 0 && k != key && !strings.HasPrefix(k, key+".") {
+            sets = append(sets, o)
+        }
+    }
+    c.sets = append(sets, [2]interface{}{key, val})
+}
+
+// override config by dot separated key, it's applied after loading files,
+// so it takes precedence over files, later override wins
+func (c *Config) Override(key string, val interface{}) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+
+    c.overrides = append(c.overrides, [2]interface{}{key, val})
+    if _, ok := c.data[strings.Split(key, ".")[0]]; ok {
+        Util.MapSet(c.data, key, val)
+    }
+}
+
+// get overrides from env with prefix and --set flags, value is json or string
+func parseOverrides(environ []string, prefix string, sets []string) [][2]interface{} {
+    var envs, overrides [][2]interface{}
+    for _, kv := range environ {
+        if pos := strings.IndexByte(kv, '='); pos > len(prefix) && strings.HasPrefix(kv, prefix) {
+            key := strings.ReplaceAll(kv[len(prefix):pos], "__", ".")
+            envs = append(envs, [2]interface{}{key, parseOverrideValue(kv[pos+1:])})
+        }
+    }
+
+    // sort env by key for stable order, flags are kept in given order
+    sort.Slice(envs, func(i, j int) bool { return envs[i][0].(string) < envs[j][0].(string) })
+    overrides = append(overrides, envs...)
+
+    for _, kv := range sets {
+        pos := strings.IndexByte(kv, '=')
+        if pos <= 0 {
+            panic("Config: invalid --set, expect key=value, " + kv)
+        }
+        overrides = append(overrides, [2]interface{}{kv[:pos], parseOverrideValue(kv[pos+1:])})
+    }
+
+    return overrides
+}
+
+func parseOverrideValue(v string) interface{} {
+    var val interface{}
+    if e := json.Unmarshal([]byte(v), &val); e == nil && val != nil {
+        return val
+    }
+
+    return v
 }
 
 // load config file
@@ -115,22 +363,130 @@ func (c *Config) Load(name string) {
         return
     }
 
-    for _, path := range c.paths {
-        files, _ := filepath.Glob(filepath.Join(path, name+".*"))
+    c.loadInto(c.data, name)
+    c.resolveSecrets(c.data, name, c.resolver, c.secrets)
+}
+
+// load config of name into data, sources under files, files, sources
+// over files, overrides and values set by Set are merged in order,
+// secret references are kept for caller to resolve
+func (c *Config) loadInto(data map[string]interface{}, name string) {
+    c.mergeSources(data, name, false)
+
+    for _, dir := range c.paths {
+        if rel, ok := c.fsPath(dir); ok {
+            files, _ := fs.Glob(c.fsys, path.Join(rel, name+".*"))
+            for _, f := range files {
+                if parser, ok := c.parsers[strings.ToLower(path.Ext(f))[1:]]; ok {
+                    mergeConfig(data, name, c.parseFsFile(parser, f))
+                }
+            }
+            continue
+        }
+
+        files, _ := filepath.Glob(filepath.Join(dir, name+".*"))
         for _, f := range files {
             ext := strings.ToLower(filepath.Ext(f))
             if parser, ok := c.parsers[ext[1:]]; ok {
-                if conf := parser.Parse(f); conf != nil {
-                    Util.MapMerge(c.data, map[string]interface{}{name: conf})
-                } else {
-                    panic("Config: failed to parse file: " + f)
-                }
+                mergeConfig(data, name, c.parseFile(parser, f))
             }
         }
     }
+
+    c.mergeSources(data, name, true)
+
+    for _, list := range [][][2]interface{}{c.overrides, c.sets} {
+        for _, o := range list {
+            if key := o[0].(string); strings.Split(key, ".")[0] == name {
+                Util.MapSet(data, key, o[1])
+            }
+        }
+    }
+}
+
+func mergeConfig(data map[string]interface{}, name string, conf map[string]interface{}) {
+    if conf != nil {
+        Util.MapMerge(data, map[string]interface{}{name: conf})
+    }
 }
 
-// parser for json config
+// get path in fs of config dir, false if fs is not set
+// or dir is out of conf path, eg. added by AddPath
+func (c *Config) fsPath(dir string) (string, bool) {
+    if c.fsys == nil {
+        return "", false
+    }
+
+    rel, e := filepath.Rel(c.confPath, dir)
+    if e != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+        return "", false
+    }
+
+    return filepath.ToSlash(rel), true
+}
+
+// parse config file in fs, parser must implement IConfigContentParser
+func (c *Config) parseFsFile(parser IConfigParser, name string) (conf map[string]interface{}) {
+    content, e := fs.ReadFile(c.fsys, name)
+    if e != nil {
+        c.addError(&ConfigError{ConfigErrorParse, name, e.Error()})
+        return nil
+    } else if len(content) == 0 {
+        c.addError(&ConfigError{ConfigErrorEmpty, name, "file has no content"})
+        return nil
+    }
+
+    cp, ok := parser.(IConfigContentParser)
+    if !ok {
+        c.addError(&ConfigError{ConfigErrorParse, name, "parser does not support fs"})
+        return nil
+    }
+
+    defer func() {
+        if v := recover(); v != nil {
+            if e, ok := v.(*ConfigError); ok {
+                c.addError(e)
+            } else {
+                c.addError(&ConfigError{ConfigErrorParse, name, Util.ToString(v)})
+            }
+            conf = nil
+        }
+    }()
+
+    if conf = cp.ParseContent(name, content); conf == nil {
+        c.addError(&ConfigError{ConfigErrorEmpty, name, "parser returns no data"})
+    }
+
+    return conf
+}
+
+// parse config file, nil is returned if failed in permissive mode
+func (c *Config) parseFile(parser IConfigParser, path string) (conf map[string]interface{}) {
+    if info, e := os.Stat(path); e == nil && info.Size() == 0 {
+        c.addError(&ConfigError{ConfigErrorEmpty, path, "file has no content"})
+        return nil
+    }
+
+    defer func() {
+        if v := recover(); v != nil {
+            if e, ok := v.(*ConfigError); ok {
+                c.addError(e)
+            } else {
+                c.addError(&ConfigError{ConfigErrorParse, path, Util.ToString(v)})
+            }
+            conf = nil
+        }
+    }()
+
+    if conf = parser.Parse(path); conf == nil {
+        c.addError(&ConfigError{ConfigErrorEmpty, path, "parser returns no data"})
+    }
+
+    return conf
+}
+
+// parser for json config, comments(// and /* */) and trailing
+// commas are allowed(JSONC), for both .json and .json5 files
 type JsonConfigParser struct {
 }
 
@@ -147,12 +503,16 @@ func (j *JsonConfigParser) Parse(path string) map[string]interface{} {
         panic("JsonConfigParser: failed to read file: " + path)
     }
 
-    // expand env: ${env||default}
-    content = Util.ExpandEnv(content)
+    return j.ParseContent(path, content)
+}
+
+func (j *JsonConfigParser) ParseContent(path string, content []byte) map[string]interface{} {
+    // strip comments and trailing commas, then expand env: ${env||default}
+    content = Util.ExpandEnv(Util.StripJsonComments(content))
 
     var data map[string]interface{}
     if e := json.Unmarshal(content, &data); e != nil {
-        panic("jsonConfigParser: failed to parse file: " + path)
+        panic(&ConfigError{ConfigErrorParse, path, e.Error()})
     }
 
     return data
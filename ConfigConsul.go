@@ -0,0 +1,247 @@
+package pgo
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "net/url"
+    "reflect"
+    "strconv"
+    "strings"
+    "time"
+)
+
+const (
+    defaultConsulAddr    = "http://127.0.0.1:8500"
+    defaultConsulWait    = 5 * time.Minute
+    defaultConsulRetry   = time.Second
+    defaultConsulTimeout = 10 * time.Second
+    maxConsulRetry       = time.Minute
+)
+
+// consul kv config source, implements IConfigSource, configuration:
+// source := pgo.CreateObject(map[string]interface{}{
+//     "class": "@pgo/ConsulSource",
+//     "addr": "http://127.0.0.1:8500",
+//     "prefix": "config/myapp",
+//     "token": "${CONSUL_TOKEN}",
+//     "wait": "5m",
+//     "retry": "1s",
+//     "timeout": "10s",
+// }).(pgo.IConfigSource)
+// pgo.App.GetConfig().AddSource(source, true)
+//
+// keys under prefix are mapped to config by path, eg. "config/myapp/app/db/dsn"
+// is "app.db.dsn", json value is decoded, otherwise it's used as string, so
+// "config/myapp/app" may hold the whole app config in json. changes are
+// watched by blocking query up to wait, non-blocking query(eg. Load) is
+// bounded by timeout, connection loss keeps last-known config and retries
+// with retry interval doubled each time(max 1m).
+type ConsulSource struct {
+    addr    string
+    prefix  string
+    token   string
+    wait    time.Duration
+    retry   time.Duration
+    timeout time.Duration
+    client  *http.Client
+    index   uint64
+    last    map[string]interface{}
+}
+
+func (s *ConsulSource) Construct() {
+    s.addr = defaultConsulAddr
+    s.wait = defaultConsulWait
+    s.retry = defaultConsulRetry
+    s.timeout = defaultConsulTimeout
+}
+
+func (s *ConsulSource) Init() {
+    // blocking query may take wait plus jitter of wait/16
+    s.client = &http.Client{Timeout: s.wait + s.wait/16 + 10*time.Second}
+}
+
+func (s *ConsulSource) SetAddr(addr string) {
+    s.addr = strings.TrimRight(addr, "/")
+}
+
+func (s *ConsulSource) SetPrefix(prefix string) {
+    s.prefix = strings.Trim(prefix, "/")
+}
+
+func (s *ConsulSource) SetToken(token string) {
+    s.token = token
+}
+
+func (s *ConsulSource) SetWait(v string) {
+    if wait, e := time.ParseDuration(v); e != nil || wait <= 0 {
+        panic("ConsulSource: invalid wait, " + v)
+    } else {
+        s.wait = wait
+    }
+}
+
+func (s *ConsulSource) SetRetry(v string) {
+    if retry, e := time.ParseDuration(v); e != nil || retry <= 0 {
+        panic("ConsulSource: invalid retry, " + v)
+    } else {
+        s.retry = retry
+    }
+}
+
+func (s *ConsulSource) SetTimeout(v string) {
+    if timeout, e := time.ParseDuration(v); e != nil || timeout <= 0 {
+        panic("ConsulSource: invalid timeout, " + v)
+    } else {
+        s.timeout = timeout
+    }
+}
+
+// load all keys under prefix
+func (s *ConsulSource) Load() (map[string]interface{}, error) {
+    data, index, e := s.query(context.Background(), 0)
+    if e == nil {
+        s.index, s.last = index, data
+    }
+
+    return data, e
+}
+
+// watch changes until app is shutdown, cb is called only if config changed
+func (s *ConsulSource) Watch(cb func(data map[string]interface{})) {
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+
+    Go(func() {
+        <-App.GetDone()
+        cancel()
+    })
+
+    delay := s.retry
+    for ctx.Err() == nil {
+        data, index, e := s.query(ctx, s.index)
+        if e != nil {
+            if ctx.Err() != nil {
+                return
+            }
+
+            GLogger().Warn("ConsulSource: failed to watch %s, retry in %s, %s", s.prefix, delay, e)
+            select {
+            case <-ctx.Done():
+                return
+            case <-time.After(delay):
+            }
+
+            if delay *= 2; delay > maxConsulRetry {
+                delay = maxConsulRetry
+            }
+            continue
+        }
+
+        delay = s.retry
+        if index == s.index {
+            continue // wait timeout without change
+        }
+
+        // index going backwards means consul state is reset
+        if index < s.index {
+            index = 0
+        }
+
+        s.index = index
+        if s.last == nil || !reflect.DeepEqual(s.last, data) {
+            s.last = data
+            cb(data)
+        }
+    }
+}
+
+// query keys under prefix, blocking until index changes if index > 0,
+// otherwise it returns at once, so it's bounded by timeout instead of
+// the long-poll timeout of client
+func (s *ConsulSource) query(ctx context.Context, index uint64) (map[string]interface{}, uint64, error) {
+    if index == 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, s.timeout)
+        defer cancel()
+    }
+
+    prefix := s.prefix
+    if len(prefix) > 0 {
+        prefix += "/" // exclude keys of sibling prefix, eg. "myapp2"
+    }
+
+    addr := s.addr + "/v1/kv/" + prefix + "?recurse=true"
+    if index > 0 {
+        addr += "&index=" + strconv.FormatUint(index, 10) + "&wait=" + url.QueryEscape(s.wait.String())
+    }
+
+    req, e := http.NewRequest(http.MethodGet, addr, nil)
+    if e != nil {
+        return nil, 0, e
+    }
+
+    if len(s.token) > 0 {
+        req.Header.Set("X-Consul-Token", s.token)
+    }
+
+    client := s.client
+    if client == nil {
+        client = http.DefaultClient
+    }
+
+    res, e := client.Do(req.WithContext(ctx))
+    if e != nil {
+        return nil, 0, e
+    }
+    defer res.Body.Close()
+
+    body, e := ioutil.ReadAll(res.Body)
+    if e != nil {
+        return nil, 0, e
+    }
+
+    newIndex, _ := strconv.ParseUint(res.Header.Get("X-Consul-Index"), 10, 64)
+    data := make(map[string]interface{})
+    if res.StatusCode == http.StatusNotFound {
+        return data, newIndex, nil // no key under prefix
+    } else if res.StatusCode != http.StatusOK {
+        return nil, 0, fmt.Errorf("status %d, %s", res.StatusCode, body)
+    }
+
+    var pairs []struct {
+        Key   string
+        Value []byte
+    }
+
+    if e := json.Unmarshal(body, &pairs); e != nil {
+        return nil, 0, e
+    }
+
+    for _, pair := range pairs {
+        key := strings.Trim(strings.TrimPrefix(pair.Key, prefix), "/")
+        if len(key) == 0 || strings.HasSuffix(pair.Key, "/") && pair.Value == nil {
+            continue // folder
+        }
+
+        setPath(data, strings.Split(key, "/"), parseOverrideValue(string(pair.Value)))
+    }
+
+    return data, newIndex, nil
+}
+
+// set value of path in nested map, non-map value on the path is replaced
+func setPath(data map[string]interface{}, path []string, val interface{}) {
+    for _, k := range path[:len(path)-1] {
+        child, ok := data[k].(map[string]interface{})
+        if !ok {
+            child = make(map[string]interface{})
+            data[k] = child
+        }
+        data = child
+    }
+
+    data[path[len(path)-1]] = val
+}
@@ -0,0 +1,69 @@
+package pgo
+
+import (
+    "encoding/base64"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+func newTestConsulSource(addr string) *ConsulSource {
+    s := &ConsulSource{}
+    s.Construct()
+    s.SetAddr(addr)
+    s.SetPrefix("config/myapp")
+    s.Init()
+    return s
+}
+
+func TestConsulSourceLoad(t *testing.T) {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path != "/v1/kv/config/myapp/" || r.URL.Query().Get("index") != "" {
+            t.Errorf("unexpected query %s", r.URL)
+        }
+
+        value := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+        w.Header().Set("X-Consul-Index", "7")
+        fmt.Fprintf(w, `[{"Key":"config/myapp/"},{"Key":"config/myapp/app/db/dsn","Value":"%s"},{"Key":"config/myapp/app/db/pool","Value":"%s"}]`,
+            value("mysql://db"), value("10"))
+    }))
+    defer server.Close()
+
+    s := newTestConsulSource(server.URL)
+    data, e := s.Load()
+    if e != nil {
+        t.Fatal(e)
+    }
+
+    want := map[string]interface{}{"app": map[string]interface{}{
+        "db": map[string]interface{}{"dsn": "mysql://db", "pool": parseOverrideValue("10")},
+    }}
+    if !reflect.DeepEqual(data, want) || s.index != 7 {
+        t.Errorf("want %v at index 7, got %v at index %d", want, data, s.index)
+    }
+}
+
+func TestConsulSourceLoadTimeout(t *testing.T) {
+    done := make(chan struct{})
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        <-done
+    }))
+    defer server.Close()
+    defer close(done)
+
+    s := newTestConsulSource(server.URL)
+    s.SetTimeout("50ms")
+
+    start := time.Now()
+    if _, e := s.Load(); e == nil || !strings.Contains(e.Error(), "deadline") {
+        t.Errorf("want deadline error, got %v", e)
+    }
+
+    if elapsed := time.Since(start); elapsed > time.Second {
+        t.Errorf("want Load bounded by timeout, took %s", elapsed)
+    }
+}
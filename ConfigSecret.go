@@ -0,0 +1,107 @@
+package pgo
+
+import (
+    "encoding/json"
+    "strconv"
+    "strings"
+)
+
+// set resolver of secret references, loaded config is resolved at once,
+// so set it at the start of main before using components, eg.
+// pgo.App.GetConfig().SetSecretResolver(vaultResolver)
+func (c *Config) SetSecretResolver(resolver ISecretResolver) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+
+    c.resolver = resolver
+    for name := range c.data {
+        c.resolveSecrets(c.data, name, resolver, c.secrets)
+    }
+}
+
+// dump loaded config as indented json, secret values are redacted
+// unless unredacted is true
+func (c *Config) Dump(unredacted ...bool) []byte {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+
+    data := make(map[string]interface{}, len(c.data))
+    for name, v := range c.data {
+        data[name] = c.redact(name, v, len(unredacted) > 0 && unredacted[0])
+    }
+
+    output, _ := json.MarshalIndent(data, "", "    ")
+    return output
+}
+
+// replace secret references of name in data with values of resolver, the
+// tree is copied so overrides and sources keep the references, keys of
+// secrets are recorded in secrets, references are kept if resolver is nil
+func (c *Config) resolveSecrets(data map[string]interface{}, name string, resolver ISecretResolver, secrets map[string]bool) {
+    if v, ok := data[name]; ok {
+        data[name] = resolveValue(name, v, resolver, secrets)
+    }
+}
+
+func resolveValue(key string, v interface{}, resolver ISecretResolver, secrets map[string]bool) interface{} {
+    switch val := v.(type) {
+    case map[string]interface{}:
+        m := make(map[string]interface{}, len(val))
+        for k, item := range val {
+            m[k] = resolveValue(key+"."+k, item, resolver, secrets)
+        }
+        return m
+
+    case []interface{}:
+        s := make([]interface{}, len(val))
+        for i, item := range val {
+            s[i] = resolveValue(key+"."+strconv.Itoa(i), item, resolver, secrets)
+        }
+        return s
+
+    case string:
+        if !strings.HasPrefix(val, SecretScheme) {
+            return val
+        }
+
+        // keep reference until resolver is set
+        secrets[key] = true
+        if resolver == nil {
+            return val
+        }
+
+        secret, e := resolver.Resolve(val[len(SecretScheme):])
+        if e != nil {
+            panic(&ConfigError{ConfigErrorSecret, key, val + ", " + e.Error()})
+        }
+
+        return secret
+    }
+
+    return v
+}
+
+// copy value with secrets replaced by SecretRedacted
+func (c *Config) redact(key string, v interface{}, unredacted bool) interface{} {
+    if c.secrets[key] && !unredacted {
+        return SecretRedacted
+    }
+
+    switch val := v.(type) {
+    case map[string]interface{}:
+        m := make(map[string]interface{}, len(val))
+        for k, item := range val {
+            m[k] = c.redact(key+"."+k, item, unredacted)
+        }
+        return m
+
+    case []interface{}:
+        s := make([]interface{}, len(val))
+        for i, item := range val {
+            s[i] = c.redact(key+"."+strconv.Itoa(i), item, unredacted)
+        }
+        return s
+    }
+
+    return v
+}
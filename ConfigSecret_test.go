@@ -0,0 +1,98 @@
+package pgo
+
+import (
+    "encoding/json"
+    "errors"
+    "sync"
+    "sync/atomic"
+    "testing"
+)
+
+type testSecretResolver struct {
+    calls int32
+}
+
+func (r *testSecretResolver) Resolve(ref string) (string, error) {
+    atomic.AddInt32(&r.calls, 1)
+    if ref == "missing" {
+        return "", errors.New("not found")
+    }
+
+    return "resolved-" + ref, nil
+}
+
+func newTestConfig() *Config {
+    c := &Config{}
+    c.Construct(true)
+    return c
+}
+
+func TestConfigSecretResolve(t *testing.T) {
+    c := newTestConfig()
+    c.Set("db", map[string]interface{}{"user": "app", "password": SecretScheme + "db/password"})
+
+    if v := c.GetString("db.password", ""); v != SecretScheme+"db/password" {
+        t.Errorf("without resolver: want reference kept, got %q", v)
+    }
+
+    c.SetSecretResolver(&testSecretResolver{})
+    if v := c.GetString("db.password", ""); v != "resolved-db/password" {
+        t.Errorf("with resolver: want resolved-db/password, got %q", v)
+    }
+
+    var dump map[string]map[string]interface{}
+    if e := json.Unmarshal(c.Dump(), &dump); e != nil {
+        t.Fatal(e)
+    }
+
+    if dump["db"]["password"] != SecretRedacted || dump["db"]["user"] != "app" {
+        t.Errorf("want password redacted in dump, got %v", dump["db"])
+    }
+
+    if e := json.Unmarshal(c.Dump(true), &dump); e != nil || dump["db"]["password"] != "resolved-db/password" {
+        t.Errorf("unredacted dump: want resolved value, got %v", dump["db"])
+    }
+}
+
+func TestConfigSecretResolveError(t *testing.T) {
+    c := newTestConfig()
+    c.SetSecretResolver(&testSecretResolver{})
+
+    defer func() {
+        e, ok := recover().(*ConfigError)
+        if !ok || e.Kind != ConfigErrorSecret || e.Path != "db.password" {
+            t.Errorf("want secret ConfigError of db.password, got %v", e)
+        }
+    }()
+
+    c.Set("db.password", SecretScheme+"missing")
+}
+
+func TestConfigSecretReloadConcurrentDump(t *testing.T) {
+    c := newTestConfig()
+    resolver := &testSecretResolver{}
+    c.SetSecretResolver(resolver)
+    c.Set("api", map[string]interface{}{"key": SecretScheme + "api/key"})
+
+    wg := sync.WaitGroup{}
+    for i := 0; i < 4; i++ {
+        wg.Add(2)
+        go func() {
+            defer wg.Done()
+            if e := c.Reload(); e != nil {
+                t.Error(e)
+            }
+        }()
+        go func() {
+            defer wg.Done()
+            c.Dump()
+        }()
+    }
+    wg.Wait()
+
+    var dump map[string]map[string]interface{}
+    json.Unmarshal(c.Dump(), &dump)
+    if dump["api"]["key"] != SecretRedacted {
+        t.Errorf("after reload: want key redacted, got %v", dump["api"])
+    }
+}
@@ -0,0 +1,178 @@
+package pgo
+
+import (
+    "fmt"
+    "reflect"
+    "sort"
+
+    "github.com/pinguo/pgo/Util"
+)
+
+// config source added by Config.AddSource
+type configSource struct {
+    source    IConfigSource
+    data      map[string]interface{}
+    overFiles bool
+}
+
+// add config source, overFiles is true to take precedence over files,
+// false to provide defaults under files, sources on the same side are
+// layered in adding order(later wins), overrides of flags and env are
+// always on top, initial config is loaded at once, then source is
+// watched in a new goroutine, every change reloads config, eg.
+// pgo.App.GetConfig().AddSource(etcdSource, true)
+func (c *Config) AddSource(source IConfigSource, overFiles bool) {
+    data, e := source.Load()
+    if e != nil {
+        c.addError(&ConfigError{ConfigErrorSource, fmt.Sprintf("%T", source), e.Error()})
+    }
+
+    s := &configSource{source: source, data: data, overFiles: overFiles}
+    c.lock.Lock()
+    c.sources = append(c.sources, s)
+    c.lock.Unlock()
+
+    if e := c.Reload(); e != nil {
+        panic(e)
+    }
+
+    Go(func() {
+        source.Watch(func(data map[string]interface{}) {
+            c.lock.Lock()
+            s.data = data
+            c.lock.Unlock()
+
+            if e := c.Reload(); e != nil {
+                GLogger().Error("Config: failed to reload on source change, %s", e)
+            }
+        })
+    })
+}
+
+// change of a config key, key is dotted path, eg. "app.db.dsn",
+// kind is one of ConfigAdded, ConfigDeleted and ConfigModified
+type ConfigChange struct {
+    Kind int
+    Key  string
+    Old  interface{}
+    New  interface{}
+}
+
+// add callback called with changes sorted by key after config is reloaded,
+// eg. by change of source, it's not called if nothing changed, changes are
+// of the deepest keys, added or deleted map, value changed between map and
+// non-map, and array are reported as a whole at that key, eg.
+// pgo.App.GetConfig().OnChange(func(changes []pgo.ConfigChange) {
+//     for _, change := range changes {
+//         if change.Key == "app.db.dsn" {
+//             reconnect()
+//         }
+//     }
+// })
+func (c *Config) OnChange(fn func(changes []ConfigChange)) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+
+    c.callbacks = append(c.callbacks, fn)
+}
+
+// reload loaded config from files and sources, new config is built aside
+// and swapped in at once, so readers see either old or new config, old
+// config is kept if reloading fails, secrets are resolved without lock,
+// callbacks of OnChange run after swap.
+func (c *Config) Reload() (err error) {
+    c.reloadLock.Lock()
+    defer c.reloadLock.Unlock()
+
+    var data map[string]interface{}
+    var resolver ISecretResolver
+    secrets := make(map[string]bool)
+    built := func() bool {
+        defer func() {
+            if v := recover(); v != nil {
+                if e, ok := v.(error); ok {
+                    err = e
+                } else {
+                    err = fmt.Errorf("Config: %s", Util.ToString(v))
+                }
+            }
+        }()
+
+        func() {
+            c.lock.RLock()
+            defer c.lock.RUnlock()
+
+            data, resolver = make(map[string]interface{}, len(c.data)), c.resolver
+            for name := range c.data {
+                c.loadInto(data, name)
+            }
+        }()
+
+        // resolver may be remote, so it runs without lock
+        for name := range data {
+            c.resolveSecrets(data, name, resolver, secrets)
+        }
+
+        return true
+    }()
+
+    if !built {
+        return err
+    }
+
+    c.lock.Lock()
+    old := c.data
+    c.data = data
+    for key := range secrets {
+        c.secrets[key] = true
+    }
+    callbacks := c.callbacks
+    c.lock.Unlock()
+
+    if len(callbacks) > 0 {
+        changes := diffConfig(nil, "", old, data)
+        sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+        for i := 0; i < len(callbacks) && len(changes) > 0; i++ {
+            callbacks[i](changes)
+        }
+    }
+
+    return nil
+}
+
+// append changes from old to cur of prefix
+func diffConfig(changes []ConfigChange, prefix string, old, cur map[string]interface{}) []ConfigChange {
+    for k, ov := range old {
+        key := prefix + k
+        nv, ok := cur[k]
+        if !ok {
+            changes = append(changes, ConfigChange{ConfigDeleted, key, ov, nil})
+            continue
+        }
+
+        om, ok1 := ov.(map[string]interface{})
+        nm, ok2 := nv.(map[string]interface{})
+        if ok1 && ok2 {
+            changes = diffConfig(changes, key+".", om, nm)
+        } else if !reflect.DeepEqual(ov, nv) {
+            changes = append(changes, ConfigChange{ConfigModified, key, ov, nv})
+        }
+    }
+
+    for k, nv := range cur {
+        if _, ok := old[k]; !ok {
+            changes = append(changes, ConfigChange{ConfigAdded, prefix + k, nil, nv})
+        }
+    }
+
+    return changes
+}
+
+// merge config of name from sources on the side of files
+func (c *Config) mergeSources(data map[string]interface{}, name string, overFiles bool) {
+    for _, s := range c.sources {
+        if conf, ok := s.data[name].(map[string]interface{}); ok && s.overFiles == overFiles {
+            mergeConfig(data, name, Util.MapCopy(conf))
+        }
+    }
+}
@@ -0,0 +1,167 @@
+package pgo
+
+import (
+    "errors"
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+    "time"
+)
+
+// source delivers data sent to updates until it's closed
+type testConfigSource struct {
+    data    map[string]interface{}
+    updates chan map[string]interface{}
+}
+
+func (s *testConfigSource) Load() (map[string]interface{}, error) {
+    return s.data, nil
+}
+
+func (s *testConfigSource) Watch(cb func(data map[string]interface{})) {
+    for data := range s.updates {
+        cb(data)
+    }
+}
+
+func TestConfigOnChange(t *testing.T) {
+    c := newTestConfig()
+    source := &testConfigSource{
+        data: map[string]interface{}{
+            "db": map[string]interface{}{
+                "dsn":   "mysql://old",
+                "pool":  10,
+                "debug": true,
+            },
+        },
+        updates: make(chan map[string]interface{}),
+    }
+    defer close(source.updates)
+
+    result := make(chan []ConfigChange, 1)
+    c.OnChange(func(changes []ConfigChange) { result <- changes })
+    c.AddSource(source, true)
+
+    // config is loaded on first access, then rebuilt on every change
+    if v := c.GetString("db.dsn", ""); v != "mysql://old" {
+        t.Fatalf("want mysql://old, got %q", v)
+    }
+
+    source.updates <- map[string]interface{}{
+        "db": map[string]interface{}{
+            "dsn":     "mysql://new",
+            "pool":    10,
+            "timeout": "3s",
+        },
+    }
+
+    want := []ConfigChange{
+        {ConfigDeleted, "db.debug", true, nil},
+        {ConfigModified, "db.dsn", "mysql://old", "mysql://new"},
+        {ConfigAdded, "db.timeout", nil, "3s"},
+    }
+
+    select {
+    case changes := <-result:
+        if !reflect.DeepEqual(changes, want) {
+            t.Errorf("want %+v, got %+v", want, changes)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("source change: callback is not called")
+    }
+}
+
+func TestConfigOnChangeNothingChanged(t *testing.T) {
+    c := newTestConfig()
+    c.Set("app.name", "demo")
+
+    called := false
+    c.OnChange(func(changes []ConfigChange) { called = true })
+    if e := c.Reload(); e != nil {
+        t.Fatal(e)
+    }
+
+    if called {
+        t.Error("want callback skipped if nothing changed")
+    }
+}
+
+type failConfigSource struct{}
+
+func (s *failConfigSource) Load() (map[string]interface{}, error) {
+    return nil, errors.New("connection refused")
+}
+
+func (s *failConfigSource) Watch(cb func(data map[string]interface{})) {}
+
+func TestConfigSourceLayering(t *testing.T) {
+    dir := t.TempDir()
+    os.WriteFile(filepath.Join(dir, "layer.json"), []byte(`{"a": "file", "b": "file", "c": "file"}`), 0644)
+
+    c := newTestConfig()
+    c.paths = nil
+    c.AddPath(dir)
+
+    source := func(data map[string]interface{}) *testConfigSource {
+        return &testConfigSource{data: map[string]interface{}{"layer": data}, updates: make(chan map[string]interface{})}
+    }
+
+    under := source(map[string]interface{}{"a": "under", "d": "under"})
+    over1 := source(map[string]interface{}{"b": "over1", "c": "over1"})
+    over2 := source(map[string]interface{}{"c": "over2", "e": "over2"})
+    defer close(under.updates)
+    defer close(over1.updates)
+    defer close(over2.updates)
+
+    c.AddSource(over1, true)
+    c.AddSource(under, false)
+    c.AddSource(over2, true)
+
+    // set after loaded, Set on unloaded name skips its files
+    c.Get("layer")
+    c.Set("layer.e", "set")
+
+    tests := map[string]string{
+        "layer.a": "file",  // files over under source
+        "layer.b": "over1", // over source over files
+        "layer.c": "over2", // later source on the same side wins
+        "layer.d": "under", // under source gives defaults
+        "layer.e": "set",   // values set by Set are on top
+    }
+
+    for key, want := range tests {
+        if got := c.GetString(key, ""); got != want {
+            t.Errorf("%s: want %q, got %q", key, want, got)
+        }
+    }
+
+    // changed source reloads config, other layers are kept
+    result := make(chan []ConfigChange, 1)
+    c.OnChange(func(changes []ConfigChange) { result <- changes })
+    over1.updates <- map[string]interface{}{"layer": map[string]interface{}{"b": "over1-new"}}
+
+    select {
+    case <-result:
+    case <-time.After(time.Second):
+        t.Fatal("source change: config is not reloaded")
+    }
+
+    if v := c.GetString("layer.b", ""); v != "over1-new" {
+        t.Errorf("after change: want over1-new, got %q", v)
+    }
+
+    if v := c.GetString("layer.c", ""); v != "over2" {
+        t.Errorf("after change: want other source kept, got %q", v)
+    }
+}
+
+func TestConfigSourceLoadError(t *testing.T) {
+    c := newTestConfig()
+    c.AddSource(&failConfigSource{}, true)
+
+    errs := c.GetErrors()
+    if len(errs) == 0 || errs[len(errs)-1].Kind != ConfigErrorSource || errs[len(errs)-1].Message != "connection refused" {
+        t.Errorf("want source error recorded, got %v", errs)
+    }
+}
@@ -0,0 +1,168 @@
+package pgo
+
+import (
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+    "testing/fstest"
+
+    "github.com/pinguo/pgo/Util"
+)
+
+func TestParseProfiles(t *testing.T) {
+    got := parseProfiles(" region=eu, tier=premium ,,region=us")
+    want := [][2]string{{"region", "us"}, {"tier", "premium"}}
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("want %v, got %v", want, got)
+    }
+
+    for _, s := range []string{"region", "region=", "=eu", "region=../eu", "re.gion=eu"} {
+        func() {
+            defer func() {
+                if recover() == nil {
+                    t.Errorf("%q: want panic of invalid profile", s)
+                }
+            }()
+            parseProfiles(s)
+        }()
+    }
+}
+
+func TestConfigProfilePaths(t *testing.T) {
+    profiles := App.profiles
+    App.profiles = [][2]string{{"region", "eu"}, {"tier", "premium"}}
+    defer func() { App.profiles = profiles }()
+
+    c := newTestConfig()
+    conf := filepath.Join(App.GetBasePath(), "conf")
+    want := []string{
+        conf,
+        filepath.Join(conf, App.GetEnv()),
+        filepath.Join(conf, "region", "eu"),
+        filepath.Join(conf, "tier", "premium"),
+    }
+
+    if !reflect.DeepEqual(c.paths, want) {
+        t.Errorf("want paths %v, got %v", want, c.paths)
+    }
+}
+
+func TestConfigProfileLayering(t *testing.T) {
+    dir := t.TempDir()
+    files := map[string]string{
+        "app.json":               `{"name": "demo", "db": {"host": "base", "port": 3306}}`,
+        "region/eu/app.json":     `{"db": {"host": "eu"}, "cdn": "eu.cdn"}`,
+        "tier/premium/app.json5": `{"db": {"pool": 50,}, /* jsonc */}`,
+    }
+
+    for name, content := range files {
+        path := filepath.Join(dir, name)
+        os.MkdirAll(filepath.Dir(path), 0755)
+        os.WriteFile(path, []byte(content), 0644)
+    }
+
+    c := newTestConfig()
+    c.paths = nil
+    for _, p := range []string{"", "region/eu", "tier/premium"} {
+        c.AddPath(filepath.Join(dir, p))
+    }
+
+    tests := map[string]interface{}{
+        "app.name":    "demo",
+        "app.db.host": "eu",
+        "app.db.port": 3306,
+        "app.db.pool": 50,
+        "app.cdn":     "eu.cdn",
+    }
+
+    for key, want := range tests {
+        if got := c.Get(key); Util.ToString(got) != Util.ToString(want) {
+            t.Errorf("%s: want %v, got %v", key, want, got)
+        }
+    }
+}
+
+func TestConfigFS(t *testing.T) {
+    os.Setenv("PGO_TEST_FS_HOST", "env-host")
+    defer os.Unsetenv("PGO_TEST_FS_HOST")
+
+    c := newTestConfig()
+    c.SetFS(fstest.MapFS{
+        "fsTest.json":                  {Data: []byte(`{"host": "${PGO_TEST_FS_HOST}", "port": 80, "name": "base"}`)},
+        App.GetEnv() + "/fsTest.json5": {Data: []byte(`{"port": 8080, // env overlay` + "\n}")},
+        "empty.json":                   {Data: nil},
+    })
+
+    tests := map[string]interface{}{
+        "fsTest.host": "env-host",
+        "fsTest.port": 8080,
+        "fsTest.name": "base",
+    }
+
+    for key, want := range tests {
+        if got := c.Get(key); Util.ToString(got) != Util.ToString(want) {
+            t.Errorf("%s: want %v, got %v", key, want, got)
+        }
+    }
+
+    c.Get("empty.key")
+    if errs := c.GetErrors(); len(errs) == 0 || errs[len(errs)-1].Kind != ConfigErrorEmpty {
+        t.Errorf("empty file in fs: want empty error, got %v", errs)
+    }
+
+    // dir out of conf path is still read from disk
+    dir := t.TempDir()
+    os.WriteFile(filepath.Join(dir, "fsTest.json"), []byte(`{"name": "disk"}`), 0644)
+    c.AddPath(dir)
+    c.SetFS(c.fsys)
+    if v := c.GetString("fsTest.name", ""); v != "disk" {
+        t.Errorf("path out of conf: want disk, got %q", v)
+    }
+}
+
+func TestConfigGetKeysAndAll(t *testing.T) {
+    c := newTestConfig()
+    c.Set("app", map[string]interface{}{
+        "name": "demo",
+        "components": map[string]interface{}{
+            "redis": map[string]interface{}{"class": "@pgo/Client/Redis/Client", "pool": map[string]interface{}{"size": 10}},
+            "db":    map[string]interface{}{"class": "@pgo/Client/Db/Client"},
+        },
+    })
+
+    if keys := c.GetKeys("app.components"); !reflect.DeepEqual(keys, []string{"db", "redis"}) {
+        t.Errorf("want sorted component ids, got %v", keys)
+    }
+
+    nested := c.GetAll("app.components")
+    if redis, _ := nested["redis"].(map[string]interface{}); redis == nil || redis["class"] != "@pgo/Client/Redis/Client" {
+        t.Errorf("nested: want subtree of components, got %v", nested)
+    }
+
+    // returned map is a copy
+    nested["redis"].(map[string]interface{})["class"] = "changed"
+    if v := c.GetString("app.components.redis.class", ""); v != "@pgo/Client/Redis/Client" {
+        t.Errorf("want config unchanged by modifying result, got %q", v)
+    }
+
+    flat := c.GetAll("app.components", true)
+    want := map[string]interface{}{"db.class": "@pgo/Client/Db/Client", "redis.class": "@pgo/Client/Redis/Client", "redis.pool.size": 10}
+    if !reflect.DeepEqual(flat, want) {
+        t.Errorf("flat: want %v, got %v", want, flat)
+    }
+
+    for _, prefix := range []string{"app.missing", "app.name", "app.components.redis.class"} {
+        if keys := c.GetKeys(prefix); len(keys) != 0 {
+            t.Errorf("%s: want no keys, got %v", prefix, keys)
+        }
+
+        if all := c.GetAll(prefix); all == nil || len(all) != 0 {
+            t.Errorf("%s: want empty map, got %v", prefix, all)
+        }
+
+        if all := c.GetAll(prefix, true); all == nil || len(all) != 0 {
+            t.Errorf("%s flat: want empty map, got %v", prefix, all)
+        }
+    }
+}
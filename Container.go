@@ -36,6 +36,29 @@ func (c *Container) Bind(i interface{}) {
         name = name[VendorLength:]
     }
 
+    c.BindName(name, i)
+}
+
+// bind reflect.Type to the specified class name, so object can be
+// created by this name, eg. "class": "myapp.FooController",
+// param i must be a pointer or a reflect.Type
+func (c *Container) BindName(name string, i interface{}) {
+    var iv reflect.Value
+    if rt, ok := i.(reflect.Type); ok {
+        if rt.Kind() == reflect.Ptr {
+            rt = rt.Elem()
+        }
+        iv = reflect.New(rt)
+        i = iv.Interface()
+    } else if iv = reflect.ValueOf(i); iv.Kind() != reflect.Ptr {
+        panic("Container: invalid type, need pointer or reflect.Type")
+    }
+
+    if len(name) == 0 {
+        panic("Container: class name cannot be empty")
+    }
+
+    rt := iv.Elem().Type()
     item := bindItem{rt, nil, -1, -1}
 
     // get extra bind info
@@ -64,6 +87,14 @@ func (c *Container) Has(name string) bool {
     return ok
 }
 
+// get bind info of the class name, nil if not bound
+func (c *Container) GetInfo(name string) interface{} {
+    if item, ok := c.items[name]; ok {
+        return item.info
+    }
+    return nil
+}
+
 // get new object of the class name
 func (c *Container) Get(name string, config map[string]interface{}, params ...interface{}) interface{} {
     if v, _ := c.GetValue(name, config, params...); v.IsValid() {
@@ -76,7 +107,7 @@ func (c *Container) Get(name string, config map[string]interface{}, params ...in
 func (c *Container) GetValue(name string, config map[string]interface{}, params ...interface{}) (reflect.Value, interface{}) {
     item, ok := c.items[name]
     if !ok {
-        panic("Container: class not found, " + name)
+        panic("Container: class not found, " + name + ", forgot to bind it or import its package?")
     }
 
     // construct new object
@@ -3,26 +3,62 @@ package pgo
 import (
     "bytes"
     "compress/gzip"
+    "context"
+    "crypto/hmac"
+    "encoding/base64"
     "encoding/json"
+    "encoding/xml"
     "errors"
     "flag"
     "fmt"
+    "io"
+    "io/ioutil"
+    "mime"
+    "mime/multipart"
+    "net"
     "net/http"
     "os"
+    "path/filepath"
+    "strconv"
     "strings"
     "time"
 
     "github.com/pinguo/pgo/Util"
 )
 
+// duration of a named phase for Server-Timing header
+type timing struct {
+    name string
+    dur  time.Duration
+}
+
 type Context struct {
     input        *http.Request
     output       http.ResponseWriter
+    writer       *sentWriter
     startTime    time.Time
+    deadline     time.Time
     logId        string
     controllerId string
     actionId     string
     userData     map[string]interface{}
+    principal    interface{}
+    status       int
+    rawBody      []byte
+    plugins      []IPlugin
+    index        int
+    rule         *routeRule
+    ruleParams   []string
+    ruleMatched  bool
+    missHandler  func(ctx *Context) // handler of unroutable request
+    done         <-chan struct{}
+    stdCtx       context.Context
+    stdCancel    context.CancelFunc
+    timings      []timing
+    routeTime    time.Time
+    flashIn      map[string][]string
+    flashOut     map[string][]string
+    view         string
     *Profiler
     *Logger
 }
@@ -33,11 +69,38 @@ func (c *Context) Init() {
 
     if App.GetMode() == ModeCmd {
         c.Logger = GLogger()
+    } else if c.input != nil {
+        // logs of web request are buffered if enabled
+        c.Logger = App.GetLog().GetBufferedLogger(App.name, c.GetLogId())
     } else {
         c.Logger = App.GetLog().GetLogger(App.name, c.GetLogId())
     }
 }
 
+// run the next plugin in the chain, plugin must call it to
+// continue the chain, otherwise the rest plugins and the
+// controller action are skipped
+func (c *Context) Next() {
+    if c.index < len(c.plugins) {
+        plugin := c.plugins[c.index]
+        c.index++
+        plugin.HandleRequest(c)
+    }
+}
+
+func (c *Context) setRule(rule *routeRule, params []string, miss func(ctx *Context)) {
+    c.rule, c.ruleParams, c.ruleMatched, c.missHandler = rule, params, true, miss
+}
+
+func (c *Context) getRule() (*routeRule, []string, func(ctx *Context), bool) {
+    return c.rule, c.ruleParams, c.missHandler, c.ruleMatched
+}
+
+func (c *Context) setPlugins(plugins []IPlugin) {
+    c.plugins = plugins
+    c.index = 0
+}
+
 func (c *Context) SetInput(r *http.Request) {
     c.input = r
 }
@@ -54,6 +117,154 @@ func (c *Context) GetOutput() http.ResponseWriter {
     return c.output
 }
 
+// get request deadline, ok is false if no deadline, the earlier
+// of server write timeout and deadline of request context is used
+func (c *Context) GetDeadline() (deadline time.Time, ok bool) {
+    deadline, ok = c.deadline, !c.deadline.IsZero()
+    if c.input != nil {
+        if d, has := c.input.Context().Deadline(); has && (!ok || d.Before(deadline)) {
+            deadline, ok = d, true
+        }
+    }
+
+    return
+}
+
+func (c *Context) setDeadline(deadline time.Time) {
+    c.deadline = deadline
+}
+
+// get channel closed when the context is done, for web request it's
+// closed when request ends, for background job and cmd it's closed
+// on app shutdown
+func (c *Context) GetDone() <-chan struct{} {
+    if c.done == nil && c.input != nil {
+        return c.input.Context().Done()
+    } else if c.done == nil {
+        return App.GetDone()
+    }
+
+    return c.done
+}
+
+// run fn in a new goroutine with a background context, which carries
+// the log id of c and is not done by request end but by app shutdown,
+// shutdown waits running jobs up to server's stopTimeout, see App.Stop,
+// panic in fn is recovered and logged, eg.
+// ctx.Go(func(bg *pgo.Context) {
+//     sendMail(bg, user)
+// })
+func (c *Context) Go(fn func(bg *Context)) {
+    bg := &Context{logId: c.GetLogId(), done: App.GetDone()}
+    bg.Init()
+
+    App.addJob(1)
+    go func() {
+        defer func() {
+            if v := recover(); v != nil {
+                reportGoPanic(bg, v)
+            }
+
+            bg.cleanup()
+            App.addJob(-1)
+        }()
+
+        fn(bg)
+    }()
+}
+
+// run fn with a new background context carrying logId(new id if empty),
+// for work outside requests, eg. queue jobs, GetStdContext is not canceled
+// by app shutdown so the work can be drained, GetDone still reports it,
+// the context is cleaned up after fn returns, panic is not recovered.
+func RunContext(logId string, fn func(ctx *Context)) {
+    ctx := &Context{logId: logId}
+    ctx.Init()
+    defer ctx.cleanup()
+
+    fn(ctx)
+}
+
+// get context.Context of c for libraries, eg. database/sql, it's done
+// as GetDone and has deadline of GetDeadline, it's canceled at the end
+// of request or background job, so don't use it after that.
+func (c *Context) GetStdContext() context.Context {
+    if c.stdCtx != nil {
+        return c.stdCtx
+    }
+
+    parent := context.Background()
+    if c.input != nil {
+        parent = c.input.Context()
+    }
+
+    var ctx context.Context
+    var cancel context.CancelFunc
+    if deadline, ok := c.GetDeadline(); ok {
+        ctx, cancel = context.WithDeadline(parent, deadline)
+    } else {
+        ctx, cancel = context.WithCancel(parent)
+    }
+
+    // done channel other than request's, eg. app shutdown for background job
+    if c.done != nil {
+        done := c.done
+        go func() {
+            select {
+            case <-done:
+                cancel()
+            case <-ctx.Done():
+            }
+        }()
+    }
+
+    c.stdCtx, c.stdCancel = ctx, cancel
+    return ctx
+}
+
+// run fn once for concurrent calls of the same key across requests,
+// callers share the result of the running call, result is not kept
+// after the call, caller stops waiting with context error when request
+// is done while fn goes on for others, eg. on cache miss:
+// v, e := ctx.Do("user:"+id, func() (interface{}, error) { return loadUser(id) })
+func (c *Context) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+    select {
+    case res := <-flight.DoChan(key, fn):
+        return res.Value, res.Err
+    case <-c.GetDone():
+        if c.done == nil && c.input != nil {
+            return nil, c.input.Context().Err()
+        }
+        return nil, context.Canceled
+    }
+}
+
+// start timing of name for Server-Timing header, call the returned
+// function to stop, it's nop if serverTiming of server is disabled and
+// no profile tap is set, eg.
+// defer ctx.Timing("db")()
+func (c *Context) Timing(name string) func() {
+    if !App.GetServer().serverTiming && (c.Profiler == nil || c.profileTap == nil) {
+        return func() {}
+    }
+
+    start := time.Now()
+    return func() {
+        c.AddTiming(name, time.Since(start))
+    }
+}
+
+// add duration of name for Server-Timing header, and to profile tap
+func (c *Context) AddTiming(name string, d time.Duration) {
+    if App.GetServer().serverTiming {
+        c.timings = append(c.timings, timing{name, d})
+    }
+
+    if c.Profiler != nil && c.profileTap != nil {
+        c.profileTap(name, d)
+    }
+}
+
 func (c *Context) GetElapseMs() int {
     elapse := time.Now().Sub(c.startTime)
     return int(elapse.Nanoseconds() / 1e6)
@@ -61,7 +272,7 @@ func (c *Context) GetElapseMs() int {
 
 func (c *Context) GetLogId() string {
     if len(c.logId) == 0 {
-        c.logId = c.GetHeader("X-Log-Id", "")
+        c.logId = c.GetHeader(LogIdHeader, "")
         if len(c.logId) == 0 {
             c.logId = Util.GenUniqueId()
         }
@@ -104,6 +315,16 @@ func (c *Context) GetUserData(key string, dft interface{}) interface{} {
     return dft
 }
 
+// set authenticated principal, it's set by auth component
+func (c *Context) SetPrincipal(principal interface{}) {
+    c.principal = principal
+}
+
+// get authenticated principal, nil if not authenticated
+func (c *Context) GetPrincipal() interface{} {
+    return c.principal
+}
+
 // get request method
 func (c *Context) GetMethod() string {
     if c.input != nil {
@@ -142,6 +363,64 @@ func (c *Context) GetQueryAll() map[string]string {
     return m
 }
 
+// get first url query value by name as int, dft is returned if missing or invalid
+func (c *Context) GetQueryInt(name string, dft int) int {
+    if v := c.GetQuery(name, ""); len(v) > 0 {
+        if i, ok := Util.ParseInt(v); ok {
+            return i
+        }
+    }
+
+    return dft
+}
+
+// get first url query value by name as float, dft is returned if missing or invalid
+func (c *Context) GetQueryFloat(name string, dft float64) float64 {
+    if v := c.GetQuery(name, ""); len(v) > 0 {
+        if f, ok := Util.ParseFloat(v); ok {
+            return f
+        }
+    }
+
+    return dft
+}
+
+// get first url query value by name as bool, true/false, 1/0, yes/no, on/off
+// are accepted, dft is returned if missing or invalid
+func (c *Context) GetQueryBool(name string, dft bool) bool {
+    if v := c.GetQuery(name, ""); len(v) > 0 {
+        if b, ok := Util.ParseBool(v); ok {
+            return b
+        }
+    }
+
+    return dft
+}
+
+// get all url query values by name, eg. ids=1&ids=2 => []string{"1", "2"}
+func (c *Context) GetQueryArray(name string) []string {
+    if c.input != nil {
+        if v, ok := c.input.URL.Query()[name]; ok {
+            return v
+        }
+    }
+
+    return []string{}
+}
+
+// bind url queries into struct pointed by ptr, query name is taken from
+// `query` tag or the field name with lower first letter, missing or
+// invalid value is skipped, eg.
+// type Req struct {
+//     Page int   `query:"page"`
+//     Ids  []int `query:"ids"`
+// }
+func (c *Context) GetQueryStruct(ptr interface{}) {
+    if c.input != nil {
+        Util.STBindValues(ptr, c.input.URL.Query(), "query")
+    }
+}
+
 // get first post value by name
 func (c *Context) GetPost(name, dft string) string {
     if c.input != nil {
@@ -184,6 +463,19 @@ func (c *Context) GetParam(name, dft string) string {
     return dft
 }
 
+// get named group captured by route rule, eg. "path" of "^/files/{*path}$"
+func (c *Context) GetRouteParam(name string) string {
+    if c.rule != nil {
+        for i, n := range c.rule.rePat.SubexpNames() {
+            if n == name && i > 0 && i-1 < len(c.ruleParams) {
+                return c.ruleParams[i-1]
+            }
+        }
+    }
+
+    return ""
+}
+
 // get first value of all params, post take precedence over get
 func (c *Context) GetParamAll() map[string]string {
     m := make(map[string]string)
@@ -279,8 +571,15 @@ func (c *Context) GetPath() string {
     return "/"
 }
 
-// get client ip
+// get client ip, if trustedProxies or proxyProtocol of server is set,
+// it's GetTrustedClientIp, otherwise X-Forwarded-For, X-Client-Ip and
+// X-Real-Ip are honoured from any peer for compatibility, so don't use
+// it for access control or rate limiting without trustedProxies.
 func (c *Context) GetClientIp() string {
+    if server := App.GetServer(); len(server.trustedProxies) > 0 || server.proxyProtocol {
+        return c.GetTrustedClientIp()
+    }
+
     if xff := c.GetHeader("X-Forwarded-For", ""); len(xff) > 0 {
         if pos := strings.IndexByte(xff, ','); pos > 0 {
             return strings.TrimSpace(xff[:pos])
@@ -297,16 +596,56 @@ func (c *Context) GetClientIp() string {
         return ip
     }
 
-    if c.input != nil && len(c.input.RemoteAddr) > 0 {
-        pos := strings.LastIndexByte(c.input.RemoteAddr, ':')
-        if pos > 0 {
-            return c.input.RemoteAddr[:pos]
-        } else {
-            return c.input.RemoteAddr
+    return c.GetRemoteIp()
+}
+
+// get client ip resolved through trusted proxies of server, forwarded
+// headers are honoured only if the peer is a trusted proxy, X-Forwarded-For
+// is walked from right to left and the first untrusted ip is the client,
+// it's the peer if no trusted proxy is set, which is the client address
+// recovered by PROXY protocol if proxyProtocol is enabled.
+func (c *Context) GetTrustedClientIp() string {
+    server, ip := App.GetServer(), c.GetRemoteIp()
+    if !server.IsTrustedProxy(ip) {
+        return ip
+    }
+
+    if xff := c.GetHeader("X-Forwarded-For", ""); len(xff) > 0 {
+        hops := strings.Split(xff, ",")
+        for i := len(hops) - 1; i >= 0; i-- {
+            hop := strings.TrimSpace(hops[i])
+            if net.ParseIP(hop) == nil {
+                break // malformed hop, stop at the last trusted one
+            }
+
+            if ip = hop; !server.IsTrustedProxy(hop) {
+                break
+            }
         }
+
+        return ip
     }
 
-    return ""
+    for _, name := range []string{"X-Client-Ip", "X-Real-Ip"} {
+        if v := strings.TrimSpace(c.GetHeader(name, "")); net.ParseIP(v) != nil {
+            return v
+        }
+    }
+
+    return ip
+}
+
+// get ip of the connection peer, headers are ignored
+func (c *Context) GetRemoteIp() string {
+    if c.input == nil || len(c.input.RemoteAddr) == 0 {
+        return ""
+    }
+
+    if host, _, e := net.SplitHostPort(c.input.RemoteAddr); e == nil {
+        return host
+    }
+
+    return c.input.RemoteAddr
 }
 
 // get json decoded body
@@ -316,18 +655,182 @@ func (c *Context) GetJsonBody(target interface{}) error {
         return errors.New("invalid content-type: " + ct)
     }
 
-    return json.NewDecoder(c.input.Body).Decode(target)
+    return json.Unmarshal(c.GetRawBody(), target)
 }
 
-// get raw body bytes
+// get xml decoded body, content-type must be application/xml or text/xml,
+// an exception with status 400 is returned if body is malformed
+func (c *Context) GetXmlBody(target interface{}) error {
+    ct := c.GetHeader("Content-Type", "")
+    if !strings.HasPrefix(ct, "application/xml") && !strings.HasPrefix(ct, "text/xml") {
+        return NewException(http.StatusBadRequest, "invalid content-type: %s", ct)
+    }
+
+    if e := xml.Unmarshal(c.GetRawBody(), target); e != nil {
+        return NewException(http.StatusBadRequest, "malformed xml body, %s", e)
+    }
+
+    return nil
+}
+
+// get raw body bytes, body is buffered on first call and the request
+// body is reset to the buffer, so it can be read again by the next
+// reader(plugin, action etc.), panic with 413 if body is larger than
+// server's maxBodyBytes, DefaultBodyBytes is used if it's not positive.
 func (c *Context) GetRawBody() []byte {
-    if c.input == nil {
+    if c.input == nil || c.input.Body == nil {
         return nil
     }
 
-    buf := &bytes.Buffer{}
-    buf.ReadFrom(c.input.Body)
-    return buf.Bytes()
+    if c.rawBody == nil {
+        maxBytes := int64(App.GetServer().MaxBodyBytes)
+        if maxBytes <= 0 {
+            maxBytes = DefaultBodyBytes
+        }
+
+        buf := &bytes.Buffer{}
+        n, e := buf.ReadFrom(io.LimitReader(c.input.Body, maxBytes+1))
+        c.input.Body.Close()
+
+        if n > maxBytes {
+            panic(NewException(http.StatusRequestEntityTooLarge, "request body too large, limit %d bytes", maxBytes))
+        } else if e != nil {
+            panic(NewException(http.StatusBadRequest, "failed to read request body, %s", e))
+        }
+
+        c.rawBody = buf.Bytes()
+    }
+
+    c.input.Body = ioutil.NopCloser(bytes.NewReader(c.rawBody))
+    return c.rawBody
+}
+
+// get uploaded file by form field name, multipart form is parsed on
+// first call, parts beyond server's maxMemoryBytes are spilled to temp
+// files, which are removed at the end of request.
+func (c *Context) GetFile(name string) (multipart.File, *multipart.FileHeader, error) {
+    if c.input == nil {
+        return nil, nil, http.ErrNotMultipart
+    }
+
+    if c.input.MultipartForm == nil {
+        if e := c.input.ParseMultipartForm(int64(App.GetServer().MaxMemoryBytes)); e != nil {
+            return nil, nil, e
+        }
+    }
+
+    return c.input.FormFile(name)
+}
+
+// get reader to iterate multipart parts without buffering, so large
+// upload can be written to disk or object storage directly, eg.
+// reader, e := ctx.GetMultipartReader()
+// for part, e := reader.NextPart(); e == nil; part, e = reader.NextPart() {
+//     if part.FileName() != "" {
+//         io.Copy(dst, part)
+//     }
+// }
+// request body is consumed by the reader, so it can't be used
+// together with GetFile, GetPost etc. on the same request.
+func (c *Context) GetMultipartReader() (*multipart.Reader, error) {
+    if c.input == nil {
+        return nil, http.ErrNotMultipart
+    }
+
+    return c.input.MultipartReader()
+}
+
+// release resources at the end of request
+func (c *Context) cleanup() {
+    if c.input != nil && c.input.MultipartForm != nil {
+        c.input.MultipartForm.RemoveAll()
+    }
+
+    if c.stdCancel != nil {
+        c.stdCancel()
+    }
+
+    if c.Logger != nil {
+        c.FlushBuffer(c.status >= http.StatusInternalServerError)
+    }
+}
+
+// end request with error in standard json format:
+// {"status": 404, "message": "...", "data": {}, "details": ..., "logId": "..."}
+// error is mapped to exception by Status component, status is the code
+// of exception if set, otherwise http status, message is localized by
+// Status component, unknown error is 500 and its message is hidden in
+// prod env; 5xx is logged as error, others as warning.
+func (c *Context) EndError(err error) {
+    status, output := c.errorOutput(err)
+    c.SetHeader("Content-Type", "application/json; charset=utf-8")
+    c.End(status, output)
+}
+
+// end request with data rendered in the format negotiated from Accept
+// header by Render component, json is used when Accept is absent or */*
+// (see defaultType), text/html renders view if given, otherwise the view
+// mapped to the current route, 406 is responded if no format acceptable.
+// eg. ctx.Render(http.StatusOK, user, "user/profile")
+func (c *Context) Render(status int, data interface{}, view ...string) {
+    if len(view) > 0 {
+        c.view = view[0]
+    }
+
+    c.SetHeader("Vary", "Accept")
+    stop := c.Timing("render")
+    contentType, output, ok := App.GetRender().Render(c, c.GetHeader("Accept", ""), data)
+    stop()
+
+    if !ok {
+        c.PushLog("status", http.StatusNotAcceptable)
+        c.SetHeader("Content-Type", "text/plain; charset=utf-8")
+        c.End(http.StatusNotAcceptable, []byte(http.StatusText(http.StatusNotAcceptable)))
+        return
+    }
+
+    c.PushLog("status", status)
+    c.SetHeader("Content-Type", contentType)
+    c.End(status, output)
+}
+
+func (c *Context) errorOutput(err error) (int, []byte) {
+    e, known := App.GetStatus().GetException(err)
+    status, code, msg := e.GetStatus(), e.GetCode(), e.GetMessage()
+    if code == 0 {
+        code = status
+    }
+
+    if !known && App.GetEnv() == DefaultEnv || len(msg) == 0 {
+        msg = http.StatusText(status)
+    }
+
+    body := map[string]interface{}{
+        "status":  code,
+        "message": App.GetStatus().GetText(code, c, msg),
+        "data":    EmptyObject,
+        "logId":   c.GetLogId(),
+    }
+
+    if details := e.GetDetails(); details != nil {
+        body["details"] = details
+    }
+
+    output, e2 := json.Marshal(body)
+    if e2 != nil {
+        panic(fmt.Sprintf("failed to marshal json, %s", e2))
+    }
+
+    c.PushLog("status", code)
+    if !App.GetServer().IsErrorLogOff(status) {
+        if status >= http.StatusInternalServerError {
+            c.Error("%s", err)
+        } else {
+            c.Warn("%s", err)
+        }
+    }
+
+    return status, output
 }
 
 // validate query param, return string validator
@@ -359,6 +862,261 @@ func (c *Context) SetCookie(cookie *http.Cookie) {
     }
 }
 
+// set cookie signed by the first of server cookieKeys, value is
+// readable but can't be forged, options like SameSite, Secure,
+// HttpOnly and Expires are kept, signature binds name and sign time
+func (c *Context) SetSignedCookie(cookie *http.Cookie) {
+    payload := c.cookiePayload(cookie.Value)
+    key := Util.DeriveKey(App.GetServer().GetCookieKeys()[0], "pgo-cookie-sign")
+    sig := Util.HmacSha256(key, []byte(cookie.Name+"|"+payload))
+
+    signed := *cookie
+    signed.Value = base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(sig)
+    c.SetCookie(&signed)
+}
+
+// get value of signed cookie, dft is returned if cookie is missing,
+// forged, or signed earlier than maxAge if specified, all cookieKeys
+// are tried so cookies signed by rotated key are still accepted, dft
+// is returned as well if cookieKeys is not configured
+func (c *Context) GetSignedCookie(name, dft string, maxAge ...time.Duration) string {
+    value := c.GetCookie(name, "")
+    pos := strings.IndexByte(value, '.')
+    if pos == -1 {
+        return dft
+    }
+
+    payload, e1 := base64.RawURLEncoding.DecodeString(value[:pos])
+    sig, e2 := base64.RawURLEncoding.DecodeString(value[pos+1:])
+    if e1 != nil || e2 != nil {
+        return dft
+    }
+
+    for _, key := range App.GetServer().cookieKeys {
+        key = Util.DeriveKey(key, "pgo-cookie-sign")
+        if hmac.Equal(sig, Util.HmacSha256(key, []byte(name+"|"+string(payload)))) {
+            return c.parseCookiePayload(string(payload), dft, maxAge)
+        }
+    }
+
+    c.Debug("Context: invalid signature of cookie %s", name)
+    return dft
+}
+
+// set cookie encrypted by aes-gcm with the first of server cookieKeys,
+// value can't be read or forged by client
+func (c *Context) SetSecureCookie(cookie *http.Cookie) {
+    key := Util.DeriveKey(App.GetServer().GetCookieKeys()[0], "pgo-cookie-encrypt")
+    data, e := Util.AesGcmEncrypt(key, []byte(c.cookiePayload(cookie.Value)), []byte(cookie.Name))
+    if e != nil {
+        panic("Context: encrypt cookie failed, " + e.Error())
+    }
+
+    encrypted := *cookie
+    encrypted.Value = base64.RawURLEncoding.EncodeToString(data)
+    c.SetCookie(&encrypted)
+}
+
+// get value of encrypted cookie, same as GetSignedCookie otherwise
+func (c *Context) GetSecureCookie(name, dft string, maxAge ...time.Duration) string {
+    data, e := base64.RawURLEncoding.DecodeString(c.GetCookie(name, ""))
+    if e != nil || len(data) == 0 {
+        return dft
+    }
+
+    for _, key := range App.GetServer().cookieKeys {
+        key = Util.DeriveKey(key, "pgo-cookie-encrypt")
+        if plain, e := Util.AesGcmDecrypt(key, data, []byte(name)); e == nil {
+            return c.parseCookiePayload(string(plain), dft, maxAge)
+        }
+    }
+
+    c.Debug("Context: decrypt cookie %s failed", name)
+    return dft
+}
+
+// payload of signed or encrypted cookie: unix time|value
+func (c *Context) cookiePayload(value string) string {
+    return strconv.FormatInt(time.Now().Unix(), 10) + "|" + value
+}
+
+func (c *Context) parseCookiePayload(payload, dft string, maxAge []time.Duration) string {
+    pos := strings.IndexByte(payload, '|')
+    if pos == -1 {
+        return dft
+    }
+
+    ts, e := strconv.ParseInt(payload[:pos], 10, 64)
+    if e != nil {
+        return dft
+    }
+
+    if len(maxAge) > 0 && maxAge[0] > 0 && time.Since(time.Unix(ts, 0)) > maxAge[0] {
+        return dft
+    }
+
+    return payload[pos+1:]
+}
+
+// add one-time message of category, the message survives one
+// redirect and is consumed when read by the next request, eg.
+// ctx.Flash("success", "Saved") then ctx.GetFlashes("success")
+func (c *Context) Flash(category, message string) {
+    c.readFlash()
+    if c.flashOut == nil {
+        c.flashOut = make(map[string][]string)
+    }
+
+    c.flashOut[category] = append(c.flashOut[category], message)
+    c.writeFlash()
+}
+
+// get and consume flash messages of category, messages of all
+// categories are returned if category is empty, messages of other
+// categories are kept in cookie until they are read
+func (c *Context) GetFlashes(category string) []string {
+    c.readFlash()
+    if len(category) > 0 {
+        messages, ok := c.flashIn[category]
+        if ok {
+            delete(c.flashIn, category)
+            c.writeFlash()
+        }
+        return messages
+    }
+
+    messages := make([]string, 0)
+    for _, v := range c.GetFlashAll() {
+        messages = append(messages, v...)
+    }
+
+    return messages
+}
+
+// get and consume flash messages of all categories
+func (c *Context) GetFlashAll() map[string][]string {
+    c.readFlash()
+    messages := c.flashIn
+    c.flashIn = make(map[string][]string)
+    if len(messages) > 0 {
+        c.writeFlash()
+    }
+    return messages
+}
+
+// load flash messages from cookie, invalid cookie is deleted
+func (c *Context) readFlash() {
+    if c.flashIn != nil {
+        return
+    }
+
+    c.flashIn = make(map[string][]string)
+    if value := c.GetCookie(FlashCookieName, ""); len(value) > 0 {
+        data, e := base64.RawURLEncoding.DecodeString(value)
+        if e == nil {
+            e = json.Unmarshal(data, &c.flashIn)
+        }
+
+        if e != nil {
+            c.flashIn = make(map[string][]string)
+            c.writeFlash()
+        }
+    }
+}
+
+// write unread messages and messages added in this request to cookie,
+// replace the flash cookie previously set, delete cookie if empty
+func (c *Context) writeFlash() {
+    if c.output == nil {
+        return
+    }
+
+    header, prefix := c.output.Header(), FlashCookieName+"="
+    cookies := header["Set-Cookie"][:0]
+    for _, v := range header["Set-Cookie"] {
+        if !strings.HasPrefix(v, prefix) {
+            cookies = append(cookies, v)
+        }
+    }
+    header["Set-Cookie"] = cookies
+
+    flashes := make(map[string][]string, len(c.flashIn)+len(c.flashOut))
+    for category, v := range c.flashIn {
+        flashes[category] = append(flashes[category], v...)
+    }
+    for category, v := range c.flashOut {
+        flashes[category] = append(flashes[category], v...)
+    }
+
+    cookie := &http.Cookie{Name: FlashCookieName, Path: "/", HttpOnly: true, MaxAge: -1}
+    if len(flashes) > 0 {
+        data, _ := json.Marshal(flashes)
+        cookie.Value, cookie.MaxAge = base64.RawURLEncoding.EncodeToString(data), 0
+    }
+
+    c.SetCookie(cookie)
+}
+
+// stream reader to response as an attachment without buffering,
+// contentType defaults to application/octet-stream if empty, range
+// requests(206, multipart/byteranges, 416, If-Range) are supported
+// if reader is an io.ReadSeeker
+func (c *Context) Download(reader io.Reader, filename string, contentType string) {
+    if len(contentType) == 0 {
+        contentType = "application/octet-stream"
+    }
+
+    c.SetHeader("Content-Type", contentType)
+    c.SetHeader("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+
+    if c.output != nil {
+        c.SetHeader(LogIdHeader, c.GetLogId())
+        if rs, ok := reader.(io.ReadSeeker); ok && c.input != nil {
+            http.ServeContent(c.output, c.input, filename, time.Time{}, rs)
+            return
+        }
+
+        c.output.WriteHeader(http.StatusOK)
+        if _, e := io.Copy(c.output, reader); e != nil {
+            c.Warn("download %s interrupted, %s", filename, e)
+        }
+    } else {
+        io.Copy(os.Stdout, reader)
+    }
+}
+
+// stream file(path alias supported) to response as an attachment,
+// Content-Length, Last-Modified and range requests(206, multipart/byteranges,
+// 416, If-Range) are supported, filename defaults to base name of path
+func (c *Context) DownloadFile(path string, filename ...string) {
+    path = GetAlias(path)
+    h, e := os.Open(path)
+    if e != nil {
+        panic(NewException(http.StatusNotFound, "file not found, %s", filepath.Base(path)))
+    }
+
+    defer h.Close()
+
+    info, e := h.Stat()
+    if e != nil || info.IsDir() {
+        panic(NewException(http.StatusNotFound, "file not found, %s", filepath.Base(path)))
+    }
+
+    name := info.Name()
+    if len(filename) > 0 && len(filename[0]) > 0 {
+        name = filename[0]
+    }
+
+    c.SetHeader("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+
+    if c.output != nil {
+        c.SetHeader(LogIdHeader, c.GetLogId())
+        http.ServeContent(c.output, c.input, name, info.ModTime(), h)
+    } else {
+        io.Copy(os.Stdout, h)
+    }
+}
+
 // send http response, gzip data if possible
 func (c *Context) End(status int, data []byte) {
     if c.output != nil {
@@ -366,9 +1124,19 @@ func (c *Context) End(status int, data []byte) {
             status = http.StatusOK
         }
 
-        c.SetHeader("X-Log-Id", c.GetLogId())
+        c.status = status
+        c.SetHeader(LogIdHeader, c.GetLogId())
         c.SetHeader("X-Cost-Time", fmt.Sprintf("%dms", c.GetElapseMs()))
 
+        if len(c.timings) > 0 {
+            metrics := make([]string, 0, len(c.timings))
+            for _, t := range c.timings {
+                metrics = append(metrics, fmt.Sprintf("%s;dur=%.3f", t.name, float64(t.dur)/float64(time.Millisecond)))
+            }
+
+            c.SetHeader("Server-Timing", strings.Join(metrics, ", "))
+        }
+
         svr := App.GetServer()
         if svr.GzipEnable && len(data) > svr.GzipMinBytes {
             ae := c.GetHeader("Accept-Encoding", "")
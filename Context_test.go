@@ -0,0 +1,450 @@
+package pgo
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "io"
+    "mime/multipart"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+func newTestContext(r *http.Request) (*Context, *httptest.ResponseRecorder) {
+    w := httptest.NewRecorder()
+    ctx := &Context{}
+    ctx.SetInput(r)
+    ctx.SetOutput(w)
+    ctx.Init()
+
+    return ctx, w
+}
+
+func newRequestContext(method, url string, body io.Reader) (*Context, *httptest.ResponseRecorder) {
+    return newTestContext(httptest.NewRequest(method, url, body))
+}
+
+func newFlashContext(cookies []*http.Cookie) (*Context, *httptest.ResponseRecorder) {
+    r := httptest.NewRequest("GET", "/flash", nil)
+    for _, cookie := range cookies {
+        r.AddCookie(cookie)
+    }
+
+    return newTestContext(r)
+}
+
+// flash cookies set by response, passed to the next request
+func nextFlashCookies(w *httptest.ResponseRecorder) []*http.Cookie {
+    cookies := make([]*http.Cookie, 0)
+    for _, cookie := range w.Result().Cookies() {
+        if cookie.Name == FlashCookieName && cookie.MaxAge >= 0 {
+            cookies = append(cookies, cookie)
+        }
+    }
+
+    return cookies
+}
+
+func TestFlashNextRequest(t *testing.T) {
+    ctx, w := newFlashContext(nil)
+    ctx.Flash("success", "Saved")
+    ctx.Flash("success", "Mailed")
+
+    ctx, _ = newFlashContext(nextFlashCookies(w))
+    if got := ctx.GetFlashes("success"); !reflect.DeepEqual(got, []string{"Saved", "Mailed"}) {
+        t.Errorf("want [Saved Mailed], got %v", got)
+    }
+
+    if got := ctx.GetFlashes("success"); len(got) != 0 {
+        t.Errorf("read again in same request: want consumed, got %v", got)
+    }
+}
+
+func TestFlashKeepUnreadCategories(t *testing.T) {
+    ctx, w := newFlashContext(nil)
+    ctx.Flash("success", "Saved")
+    ctx.Flash("warning", "Quota almost used")
+
+    ctx, w = newFlashContext(nextFlashCookies(w))
+    if got := ctx.GetFlashes("success"); !reflect.DeepEqual(got, []string{"Saved"}) {
+        t.Errorf("want [Saved], got %v", got)
+    }
+
+    ctx, w = newFlashContext(nextFlashCookies(w))
+    all := ctx.GetFlashAll()
+    if !reflect.DeepEqual(all, map[string][]string{"warning": {"Quota almost used"}}) {
+        t.Errorf("next request: want unread warning only, got %v", all)
+    }
+
+    if cookies := nextFlashCookies(w); len(cookies) != 0 {
+        t.Errorf("all read: want flash cookie deleted, got %v", cookies)
+    }
+}
+
+func TestFlashInvalidCookie(t *testing.T) {
+    ctx, w := newFlashContext([]*http.Cookie{{Name: FlashCookieName, Value: "not-base64!"}})
+    if got := ctx.GetFlashAll(); len(got) != 0 {
+        t.Errorf("invalid cookie: want no flash, got %v", got)
+    }
+
+    deleted := false
+    for _, cookie := range w.Result().Cookies() {
+        deleted = deleted || cookie.Name == FlashCookieName && cookie.MaxAge < 0
+    }
+
+    if !deleted {
+        t.Error("invalid cookie: want flash cookie deleted")
+    }
+}
+
+// set signed or encrypted cookie by keys, return cookie of response
+func setKeyedCookie(secure bool, keys []interface{}, value string) *http.Cookie {
+    App.GetServer().SetCookieKeys(keys)
+    ctx, w := newRequestContext("GET", "/cookie", nil)
+    cookie := &http.Cookie{Name: "sess", Value: value, HttpOnly: true}
+    if secure {
+        ctx.SetSecureCookie(cookie)
+    } else {
+        ctx.SetSignedCookie(cookie)
+    }
+
+    return w.Result().Cookies()[0]
+}
+
+// get value of signed or encrypted cookie by keys, "dft" if rejected
+func getKeyedCookie(secure bool, keys []interface{}, cookie *http.Cookie, maxAge time.Duration) string {
+    App.GetServer().SetCookieKeys(keys)
+    r := httptest.NewRequest("GET", "/cookie", nil)
+    r.AddCookie(cookie)
+    ctx, _ := newTestContext(r)
+    if secure {
+        return ctx.GetSecureCookie("sess", "dft", maxAge)
+    }
+
+    return ctx.GetSignedCookie("sess", "dft", maxAge)
+}
+
+func TestContextKeyedCookie(t *testing.T) {
+    defer App.GetServer().SetCookieKeys(nil)
+
+    old, rotated := []interface{}{"old-key"}, []interface{}{"new-key", "old-key"}
+    tamper := func(c *http.Cookie) {
+        if c.Value[0] == 'A' {
+            c.Value = "B" + c.Value[1:]
+        } else {
+            c.Value = "A" + c.Value[1:]
+        }
+    }
+
+    tests := []struct {
+        name    string
+        getKeys []interface{}
+        modify  func(c *http.Cookie)
+        maxAge  time.Duration
+        want    string
+    }{
+        {"round trip", old, nil, 0, "uid=1"},
+        {"within max age", old, nil, time.Hour, "uid=1"},
+        {"tampered", old, tamper, 0, "dft"},
+        {"rotated key", rotated, nil, 0, "uid=1"},
+        {"removed key", []interface{}{"new-key"}, nil, 0, "dft"},
+        {"no keys", nil, nil, 0, "dft"},
+        // signed at the second, so it's older than 1ns when read
+        {"expired", old, nil, time.Nanosecond, "dft"},
+    }
+
+    for _, secure := range []bool{false, true} {
+        for _, test := range tests {
+            cookie := setKeyedCookie(secure, old, "uid=1")
+            if cookie.Value == "uid=1" || !cookie.HttpOnly {
+                t.Errorf("secure=%v %s: want keyed value with options kept, got %v", secure, test.name, cookie)
+            }
+
+            if test.modify != nil {
+                test.modify(cookie)
+            }
+
+            if got := getKeyedCookie(secure, test.getKeys, cookie, test.maxAge); got != test.want {
+                t.Errorf("secure=%v %s: want %q, got %q", secure, test.name, test.want, got)
+            }
+        }
+    }
+}
+
+func TestContextQueryGetters(t *testing.T) {
+    ctx, _ := newRequestContext("GET", "/q?page=2&hex=0x10&price=9.5&on=yes&off=0&bad=abc&ids=1&ids=2", nil)
+
+    if v := ctx.GetQueryInt("page", 1); v != 2 {
+        t.Errorf("page: want 2, got %d", v)
+    }
+
+    if v := ctx.GetQueryInt("hex", 0); v != 16 {
+        t.Errorf("hex: want 16, got %d", v)
+    }
+
+    if v := ctx.GetQueryInt("bad", 7); v != 7 {
+        t.Errorf("invalid int: want default 7, got %d", v)
+    }
+
+    if v := ctx.GetQueryInt("missing", 3); v != 3 {
+        t.Errorf("missing int: want default 3, got %d", v)
+    }
+
+    if v := ctx.GetQueryFloat("price", 0); v != 9.5 {
+        t.Errorf("price: want 9.5, got %v", v)
+    }
+
+    if v := ctx.GetQueryFloat("bad", 1.5); v != 1.5 {
+        t.Errorf("invalid float: want default 1.5, got %v", v)
+    }
+
+    if !ctx.GetQueryBool("on", false) || ctx.GetQueryBool("off", true) {
+        t.Error("bool: want on=yes true and off=0 false")
+    }
+
+    if !ctx.GetQueryBool("bad", true) {
+        t.Error("invalid bool: want default true")
+    }
+
+    if v := ctx.GetQueryArray("ids"); !reflect.DeepEqual(v, []string{"1", "2"}) {
+        t.Errorf("ids: want [1 2], got %v", v)
+    }
+
+    if v := ctx.GetQueryArray("missing"); v == nil || len(v) != 0 {
+        t.Errorf("missing array: want empty slice, got %#v", v)
+    }
+}
+
+func TestContextGetQueryStruct(t *testing.T) {
+    type Sorting struct {
+        Sort string `query:"sort"`
+    }
+
+    type request struct {
+        Sorting
+        Page    int     `query:"page"`
+        Ids     []int   `query:"ids"`
+        Keyword string
+        Price   float64 `query:"price"`
+        Size    int     `query:"size"`
+    }
+
+    ctx, _ := newRequestContext("GET", "/q?page=3&ids=1&ids=x&ids=3&keyword=go&sort=name&price=abc", nil)
+    req := request{Size: 20, Price: 1.5}
+    ctx.GetQueryStruct(&req)
+
+    want := request{Sorting{"name"}, 3, []int{1, 3}, "go", 1.5, 20}
+    if !reflect.DeepEqual(req, want) {
+        t.Errorf("want %+v, got %+v", want, req)
+    }
+}
+
+func TestContextGetRawBody(t *testing.T) {
+    server := App.GetServer()
+    defer func(n int) { server.MaxBodyBytes = n }(server.MaxBodyBytes)
+
+    for limit, want := range map[int]int{4: http.StatusRequestEntityTooLarge, 5: 0, 0: 0, -1: 0} {
+        server.MaxBodyBytes = limit
+        ctx, _ := newRequestContext("POST", "/body", strings.NewReader("hello"))
+
+        status := 0
+        func() {
+            defer func() {
+                if e, ok := AsException(recover()); ok {
+                    status = e.GetStatus()
+                }
+            }()
+
+            if body := ctx.GetRawBody(); string(body) != "hello" || string(ctx.GetRawBody()) != "hello" {
+                t.Errorf("limit %d: want body read twice, got %q", limit, body)
+            }
+        }()
+
+        if status != want {
+            t.Errorf("limit %d: want status %d, got %d", limit, want, status)
+        }
+    }
+}
+
+func TestContextGetFileCleanup(t *testing.T) {
+    server := App.GetServer()
+    defer func(n int) { server.MaxMemoryBytes = n }(server.MaxMemoryBytes)
+    server.MaxMemoryBytes = 16
+
+    var spilled string
+    path := fmt.Sprintf("/upload/file%d", time.Now().UnixNano())
+    App.GetRouter().AddHandler("^"+path+"$", func(ctx *Context) {
+        file, header, e := ctx.GetFile("upload")
+        if e != nil {
+            t.Errorf("want uploaded file, got %v", e)
+            return
+        }
+
+        if f, ok := file.(*os.File); ok {
+            spilled = f.Name()
+        }
+
+        data, _ := io.ReadAll(file)
+        ctx.End(http.StatusOK, []byte(fmt.Sprintf("%s %d", header.Filename, len(data))))
+    }, nil)
+
+    body := &bytes.Buffer{}
+    mw := multipart.NewWriter(body)
+    part, _ := mw.CreateFormFile("upload", "big.bin")
+    part.Write(bytes.Repeat([]byte("x"), 1024))
+    mw.Close()
+
+    r := httptest.NewRequest("POST", path, body)
+    r.Header.Set("Content-Type", mw.FormDataContentType())
+    w := httptest.NewRecorder()
+    server.ServeHTTP(w, r)
+
+    if w.Body.String() != "big.bin 1024" || len(spilled) == 0 {
+        t.Fatalf("want file beyond maxMemoryBytes spilled to disk, got %q %q", w.Body.String(), spilled)
+    }
+
+    if _, e := os.Stat(spilled); !os.IsNotExist(e) {
+        t.Errorf("want temp file %s removed at the end of request, got %v", spilled, e)
+    }
+}
+
+func TestContextDownload(t *testing.T) {
+    ctx, w := newRequestContext("GET", "/download", nil)
+    ctx.Download(io.MultiReader(strings.NewReader("hello "), strings.NewReader("world")), "report 1.txt", "")
+
+    if w.Code != http.StatusOK || w.Body.String() != "hello world" {
+        t.Errorf("want 200 hello world, got %d %q", w.Code, w.Body.String())
+    }
+
+    if ct := w.Header().Get("Content-Type"); ct != "application/octet-stream" {
+        t.Errorf("want default content type, got %q", ct)
+    }
+
+    if cd := w.Header().Get("Content-Disposition"); cd != `attachment; filename="report 1.txt"` {
+        t.Errorf("want attachment with quoted filename, got %q", cd)
+    }
+
+    if w.Header().Get(LogIdHeader) != ctx.GetLogId() {
+        t.Error("want log id header")
+    }
+}
+
+func TestContextDownloadFile(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "data.csv")
+    if e := os.WriteFile(path, []byte("id,name\n1,foo\n"), 0644); e != nil {
+        t.Fatal(e)
+    }
+
+    ctx, w := newRequestContext("GET", "/download", nil)
+    ctx.DownloadFile(path, "export.csv")
+
+    if w.Code != http.StatusOK || w.Body.String() != "id,name\n1,foo\n" {
+        t.Errorf("want 200 with file content, got %d %q", w.Code, w.Body.String())
+    }
+
+    if w.Header().Get("Content-Length") != "14" || len(w.Header().Get("Last-Modified")) == 0 {
+        t.Errorf("want Content-Length and Last-Modified, got %v", w.Header())
+    }
+
+    if cd := w.Header().Get("Content-Disposition"); cd != `attachment; filename=export.csv` {
+        t.Errorf("want export.csv attachment, got %q", cd)
+    }
+
+    defer func() {
+        if e, ok := recover().(*Exception); !ok || e.GetStatus() != http.StatusNotFound {
+            t.Errorf("directory: want 404 exception, got %v", e)
+        }
+    }()
+
+    ctx, _ = newRequestContext("GET", "/download", nil)
+    ctx.DownloadFile(filepath.Dir(path))
+}
+
+func TestContextDownloadRange(t *testing.T) {
+    download := func(header map[string]string) *httptest.ResponseRecorder {
+        ctx, w := newRequestContext("GET", "/download", nil)
+        for k, v := range header {
+            ctx.GetInput().Header.Set(k, v)
+        }
+
+        ctx.Download(strings.NewReader("0123456789"), "digits.txt", "text/plain")
+        return w
+    }
+
+    if w := download(map[string]string{"Range": "bytes=2-5"}); w.Code != http.StatusPartialContent || w.Body.String() != "2345" {
+        t.Errorf("single range: want 206 2345, got %d %q", w.Code, w.Body.String())
+    } else if cr := w.Header().Get("Content-Range"); cr != "bytes 2-5/10" {
+        t.Errorf("single range: want Content-Range bytes 2-5/10, got %q", cr)
+    }
+
+    w := download(map[string]string{"Range": "bytes=0-1,8-9"})
+    if w.Code != http.StatusPartialContent || !strings.HasPrefix(w.Header().Get("Content-Type"), "multipart/byteranges") {
+        t.Errorf("multiple ranges: want 206 multipart/byteranges, got %d %s", w.Code, w.Header().Get("Content-Type"))
+    }
+
+    if w := download(map[string]string{"Range": "bytes=20-30"}); w.Code != http.StatusRequestedRangeNotSatisfiable {
+        t.Errorf("unsatisfiable range: want 416, got %d", w.Code)
+    }
+
+    // without modification time If-Range date never matches, full content is sent
+    w = download(map[string]string{"Range": "bytes=2-5", "If-Range": "Mon, 02 Jan 2006 15:04:05 GMT"})
+    if w.Code != http.StatusOK || w.Body.String() != "0123456789" {
+        t.Errorf("stale If-Range: want 200 full content, got %d %q", w.Code, w.Body.String())
+    }
+}
+
+func TestContextGo(t *testing.T) {
+    r := httptest.NewRequest("GET", "/go", nil)
+    reqCtx, cancel := context.WithCancel(r.Context())
+    ctx, _ := newTestContext(r.WithContext(reqCtx))
+
+    result := make(chan string, 1)
+    release := make(chan struct{})
+    ctx.Go(func(bg *Context) {
+        <-release
+        select {
+        case <-bg.GetDone():
+            result <- "done"
+        default:
+            result <- bg.GetLogId()
+        }
+    })
+
+    // request end doesn't cancel background job
+    cancel()
+    select {
+    case <-ctx.GetDone():
+    case <-time.After(time.Second):
+        t.Fatal("want request context done after cancel")
+    }
+
+    close(release)
+    if v := <-result; v != ctx.GetLogId() {
+        t.Errorf("want job running with log id of request, got %q", v)
+    }
+}
+
+func TestContextGoPanic(t *testing.T) {
+    ctx, _ := newRequestContext("GET", "/go", nil)
+    before := App.GetJobCount()
+    finished := make(chan struct{})
+    ctx.Go(func(bg *Context) {
+        defer close(finished)
+        panic("job failed")
+    })
+
+    <-finished
+    for i := 0; i < 100 && App.GetJobCount() != before; i++ {
+        time.Sleep(time.Millisecond)
+    }
+
+    if n := App.GetJobCount(); n != before {
+        t.Errorf("want panicked job recovered and uncounted, got %d jobs", n)
+    }
+}
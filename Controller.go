@@ -69,12 +69,26 @@ func (c *Controller) FinishAction(action string) {
 // process unhandled action panic
 func (c *Controller) HandlePanic(v interface{}) {
     status := http.StatusInternalServerError
-    switch e := v.(type) {
-    case *Exception:
+    if e, ok := AsException(v); ok {
         status = e.GetStatus()
-        c.OutputJson(EmptyObject, status, e.GetMessage())
-    default:
+    }
+
+    if handler := App.GetServer().GetErrorHandler(); handler != nil && status >= http.StatusInternalServerError {
+        c.Status, c.Output = 0, nil
+        handler(c.GetContext(), status, v)
+    } else if e, ok := AsException(v); !ok {
         c.OutputJson(EmptyObject, status)
+    } else if e.GetCode() == 0 {
+        c.OutputJson(EmptyObject, status, e.GetMessage())
+    } else {
+        // code is output as json status, status as http status
+        msg := e.GetMessage()
+        if len(msg) == 0 {
+            msg = http.StatusText(status)
+        }
+
+        c.OutputJson(EmptyObject, e.GetCode(), msg)
+        c.Status = status
     }
 
     if !App.GetServer().IsErrorLogOff(status) {
@@ -113,6 +127,12 @@ func (c *Controller) OutputJson(data interface{}, status int, msg ...string) {
     c.GetContext().SetHeader("Content-Type", "application/json; charset=utf-8")
 }
 
+// output error in standard json format, see Context.EndError
+func (c *Controller) OutputError(err error) {
+    c.Status, c.Output = c.GetContext().errorOutput(err)
+    c.GetContext().SetHeader("Content-Type", "application/json; charset=utf-8")
+}
+
 // output jsonp response
 func (c *Controller) OutputJsonp(callback string, data interface{}, status int, msg ...string) {
     message := App.GetStatus().GetText(status, c.GetContext(), msg...)
@@ -138,10 +158,61 @@ func (c *Controller) OutputJsonp(callback string, data interface{}, status int,
     c.GetContext().SetHeader("Content-Type", "text/javascript; charset=utf-8")
 }
 
+// output xml response, data can be a struct with xml tags,
+// map or slice, eg. <response><status>200</status>...</response>
+func (c *Controller) OutputXml(data interface{}, status int, msg ...string) {
+    message := App.GetStatus().GetText(status, c.GetContext(), msg...)
+    renderer := &XmlRenderer{}
+
+    c.Status = http.StatusOK
+    c.Output = renderer.Render(c.GetContext(), map[string]interface{}{
+        "status":  status,
+        "message": message,
+        "data":    data,
+    })
+
+    c.GetContext().PushLog("status", status)
+    c.GetContext().SetHeader("Content-Type", renderer.ContentType())
+}
+
+// output response in the format negotiated from Accept header,
+// json, xml and html(view mapped in render component) are supported
+// by default, 406 is responded if no acceptable format found.
+func (c *Controller) OutputRender(data interface{}, status int, msg ...string) {
+    ctx := c.GetContext()
+    message := App.GetStatus().GetText(status, ctx, msg...)
+    accept := ctx.GetHeader("Accept", "")
+    ctx.SetHeader("Vary", "Accept")
+
+    stop := ctx.Timing("render")
+    contentType, output, ok := App.GetRender().Render(ctx, accept, map[string]interface{}{
+        "status":  status,
+        "message": message,
+        "data":    data,
+    })
+    stop()
+
+    if !ok {
+        c.Status = http.StatusNotAcceptable
+        c.Output = []byte(http.StatusText(c.Status))
+        ctx.PushLog("status", c.Status)
+        ctx.SetHeader("Content-Type", "text/plain; charset=utf-8")
+        return
+    }
+
+    c.Status = http.StatusOK
+    c.Output = output
+
+    ctx.PushLog("status", status)
+    ctx.SetHeader("Content-Type", contentType)
+}
+
 // output rendered view
 func (c *Controller) OutputView(view string, data interface{}) {
+    stop := c.GetContext().Timing("render")
     c.Status = http.StatusOK
     c.Output = App.GetView().Render(view, data)
+    stop()
     c.GetContext().PushLog("status", c.Status)
     c.GetContext().SetHeader("Content-Type", "text/html; charset=utf-8")
 }
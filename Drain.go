@@ -0,0 +1,92 @@
+package pgo
+
+import (
+    "crypto/subtle"
+    "errors"
+    "net/http"
+    "regexp"
+    "strings"
+    "sync/atomic"
+)
+
+var errDraining = errors.New("server is draining")
+
+// enter drain mode, readiness fails so load balancer removes this
+// instance, in-flight requests go on, new requests except readiness
+// and admin endpoints get 503 with connection closed
+func (s *Server) Drain() {
+    if !atomic.CompareAndSwapInt32(&s.draining, 0, 1) {
+        return
+    }
+
+    GLogger().Notice("server enters drain mode")
+    s.http.SetKeepAlivesEnabled(false)
+    App.GetHealth().AddCheck("drain", func() error { return errDraining })
+}
+
+// check if server is in drain mode
+func (s *Server) IsDraining() bool {
+    return atomic.LoadInt32(&s.draining) == 1
+}
+
+// complete shutdown as SIGTERM, server stops gracefully within stopTimeout
+func (s *Server) Shutdown() {
+    select {
+    case s.stopChan <- true:
+    default: // shutdown already pending
+    }
+}
+
+// register admin endpoints, they are disabled if admin token is empty
+func (s *Server) addAdminHandlers() {
+    if len(s.adminPath) == 0 || len(s.adminToken) == 0 {
+        return
+    }
+
+    router, prefix := App.GetRouter(), "^"+regexp.QuoteMeta(s.adminPath)
+    router.AddHandler(prefix+"/drain$", s.serveDrain, map[string]interface{}{
+        "method":  http.MethodPost,
+        "summary": "enter drain mode",
+    })
+    router.AddHandler(prefix+"/shutdown$", s.serveShutdown, map[string]interface{}{
+        "method":  http.MethodPost,
+        "summary": "complete graceful shutdown",
+    })
+}
+
+// check if request bypasses drain rejection and host check, eg. probes
+func (s *Server) isDrainExempt(path string) bool {
+    if len(s.adminPath) > 0 && strings.HasPrefix(path, s.adminPath+"/") {
+        return true
+    }
+
+    return path == App.GetHealth().GetPath()
+}
+
+// check admin token in "Authorization: Bearer <token>" header
+func (s *Server) checkAdminToken(ctx *Context) bool {
+    token := strings.TrimPrefix(ctx.GetHeader("Authorization", ""), "Bearer ")
+    if subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1 {
+        return true
+    }
+
+    ctx.Warn("Server: invalid admin token from %s", ctx.GetClientIp())
+    ctx.EndError(NewException(http.StatusUnauthorized, "invalid admin token"))
+    return false
+}
+
+func (s *Server) serveDrain(ctx *Context) {
+    if s.checkAdminToken(ctx) {
+        s.Drain()
+        ctx.End(http.StatusAccepted, []byte("draining"))
+    }
+}
+
+func (s *Server) serveShutdown(ctx *Context) {
+    if s.checkAdminToken(ctx) {
+        GLogger().Notice("shutdown requested by %s", ctx.GetClientIp())
+        s.Drain()
+        s.Shutdown()
+        ctx.End(http.StatusAccepted, []byte("shutting down"))
+    }
+}
@@ -1,6 +1,7 @@
 package pgo
 
 import (
+    "errors"
     "fmt"
 )
 
@@ -12,23 +13,99 @@ func NewException(status int, msg ...interface{}) *Exception {
         message = fmt.Sprintf(msg[0].(string), msg[1:]...)
     }
 
-    return &Exception{status, message}
+    return &Exception{status: status, message: message}
+}
+
+// new exception with application code, status is http status
+// of the response, code is the status field of json output
+func NewCodeException(status, code int, msg ...interface{}) *Exception {
+    e := NewException(status, msg...)
+    e.code = code
+    return e
+}
+
+// wrap error as exception, message defaults to error string,
+// the wrapped error can be retrieved by errors.Unwrap/Is/As
+func WrapException(err error, status int, msg ...interface{}) *Exception {
+    e := NewException(status, msg...)
+    if e.cause = err; len(e.message) == 0 && err != nil {
+        e.message = err.Error()
+    }
+
+    return e
+}
+
+// get exception from panic value, v can be an exception
+// or an error wrapping exception
+func AsException(v interface{}) (*Exception, bool) {
+    if err, ok := v.(error); ok {
+        var e *Exception
+        if errors.As(err, &e) {
+            return e, true
+        }
+    }
+
+    return nil, false
 }
 
 type Exception struct {
     status  int
+    code    int
     message string
+    details interface{}
+    cause   error
 }
 
 func (e *Exception) GetStatus() int {
     return e.status
 }
 
+// get application code, 0 if not set
+func (e *Exception) GetCode() int {
+    return e.code
+}
+
 func (e *Exception) GetMessage() string {
     return e.message
 }
 
+// get error details, nil if not set
+func (e *Exception) GetDetails() interface{} {
+    return e.details
+}
+
+// set error details, eg. invalid fields, output as "details" by ctx.EndError
+func (e *Exception) WithDetails(details interface{}) *Exception {
+    e.details = details
+    return e
+}
+
+// get wrapped error, nil if not set
+func (e *Exception) GetCause() error {
+    return e.cause
+}
+
+// set application code
+func (e *Exception) WithCode(code int) *Exception {
+    e.code = code
+    return e
+}
+
+// implement errors.Unwrap interface
+func (e *Exception) Unwrap() error {
+    return e.cause
+}
+
 // implement error interface
 func (e *Exception) Error() string {
-    return fmt.Sprintf("exception: %d, message: %s", e.status, e.message)
+    msg := fmt.Sprintf("exception: %d, message: %s", e.status, e.message)
+    if e.code != 0 {
+        msg = fmt.Sprintf("exception: %d, code: %d, message: %s", e.status, e.code, e.message)
+    }
+
+    if e.cause != nil {
+        msg += ", cause: " + e.cause.Error()
+    }
+
+    return msg
 }
@@ -0,0 +1,80 @@
+package pgo
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "testing"
+)
+
+func TestExceptionMessage(t *testing.T) {
+    if e := NewException(http.StatusNotFound, "user %d not found", 12); e.GetMessage() != "user 12 not found" {
+        t.Errorf("want formatted message, got %q", e.GetMessage())
+    }
+
+    e := NewCodeException(http.StatusBadRequest, 10001, "invalid name")
+    if e.GetStatus() != http.StatusBadRequest || e.GetCode() != 10001 {
+        t.Errorf("want status 400 and code 10001, got %d %d", e.GetStatus(), e.GetCode())
+    }
+
+    if s := e.Error(); s != "exception: 400, code: 10001, message: invalid name" {
+        t.Errorf("unexpected error string %q", s)
+    }
+
+    if d := e.WithDetails([]string{"name"}).GetDetails(); fmt.Sprint(d) != "[name]" {
+        t.Errorf("want details kept, got %v", d)
+    }
+}
+
+func TestExceptionWrap(t *testing.T) {
+    e := WrapException(io.ErrUnexpectedEOF, http.StatusBadGateway)
+    if e.GetMessage() != io.ErrUnexpectedEOF.Error() || e.GetCause() != io.ErrUnexpectedEOF {
+        t.Errorf("want message and cause of wrapped error, got %q %v", e.GetMessage(), e.GetCause())
+    }
+
+    if !errors.Is(e, io.ErrUnexpectedEOF) {
+        t.Error("want errors.Is to find wrapped error")
+    }
+
+    if s := e.Error(); s != "exception: 502, message: unexpected EOF, cause: unexpected EOF" {
+        t.Errorf("unexpected error string %q", s)
+    }
+
+    wrapped := fmt.Errorf("load user: %w", e.WithCode(20001))
+    if ex, ok := AsException(wrapped); !ok || ex.GetCode() != 20001 {
+        t.Errorf("want exception found in error chain, got %v %v", ex, ok)
+    }
+
+    if _, ok := AsException("boom"); ok {
+        t.Error("non-error panic value: want no exception")
+    }
+}
+
+func TestControllerHandlePanicCode(t *testing.T) {
+    handle := func(v interface{}) (*Controller, map[string]interface{}) {
+        ctx, _ := newRequestContext("GET", "/panic", nil)
+        c := &Controller{}
+        c.SetContext(ctx)
+        c.HandlePanic(v)
+
+        var res map[string]interface{}
+        json.Unmarshal(c.Output, &res)
+        return c, res
+    }
+
+    c, res := handle(fmt.Errorf("wrapped: %w", NewCodeException(http.StatusConflict, 30001, "duplicated")))
+    if c.Status != http.StatusConflict || res["status"] != float64(30001) || res["message"] != "duplicated" {
+        t.Errorf("code exception: want http 409 with json status 30001, got %d %v", c.Status, res)
+    }
+
+    c, res = handle(NewException(http.StatusForbidden, "denied"))
+    if res["status"] != float64(http.StatusForbidden) || res["message"] != "denied" {
+        t.Errorf("exception: want json status 403, got %d %v", c.Status, res)
+    }
+
+    if _, res = handle("boom"); res["status"] != float64(http.StatusInternalServerError) {
+        t.Errorf("unknown panic: want json status 500, got %v", res)
+    }
+}
@@ -0,0 +1,14 @@
+//go:build go1.24
+
+package pgo
+
+import "net/http"
+
+// serve http/2 without tls(prior knowledge) besides http/1.1
+func enableH2c(svr *http.Server, enable bool) {
+    protocols := new(http.Protocols)
+    protocols.SetHTTP1(true)
+    protocols.SetHTTP2(true)
+    protocols.SetUnencryptedHTTP2(enable)
+    svr.Protocols = protocols
+}
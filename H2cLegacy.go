@@ -0,0 +1,12 @@
+//go:build !go1.24
+
+package pgo
+
+import "net/http"
+
+// unencrypted http/2 of net/http requires go1.24
+func enableH2c(svr *http.Server, enable bool) {
+    if enable {
+        panic("Server: h2c requires go1.24 or later")
+    }
+}
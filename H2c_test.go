@@ -0,0 +1,66 @@
+//go:build go1.24
+
+package pgo
+
+import (
+    "io"
+    "net"
+    "net/http"
+    "strings"
+    "testing"
+)
+
+func TestServerGrpcSharesListener(t *testing.T) {
+    s := App.GetServer()
+    s.SetGrpc(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "application/grpc")
+        w.Write([]byte("grpc " + r.URL.Path))
+    }), nil)
+    defer s.SetGrpc(nil, nil)
+
+    App.GetRouter().AddHandler("^/h2c/ping$", func(ctx *Context) {
+        ctx.End(http.StatusOK, []byte("http "+ctx.GetInput().Proto))
+    }, nil)
+
+    ln, e := net.Listen("tcp", "127.0.0.1:0")
+    if e != nil {
+        t.Fatal(e)
+    }
+
+    svr := &http.Server{Handler: s}
+    enableH2c(svr, true)
+    go svr.Serve(ln)
+    defer svr.Close()
+
+    h2c := new(http.Protocols)
+    h2c.SetUnencryptedHTTP2(true)
+    clients := map[string]*http.Client{
+        "http1": {},
+        "h2c":   {Transport: &http.Transport{Protocols: h2c}},
+    }
+
+    send := func(client, contentType, path string) string {
+        r, _ := http.NewRequest("POST", "http://"+ln.Addr().String()+path, strings.NewReader("body"))
+        r.Header.Set("Content-Type", contentType)
+        resp, e := clients[client].Do(r)
+        if e != nil {
+            t.Fatalf("%s %s: %s", client, path, e)
+        }
+        defer resp.Body.Close()
+
+        body, _ := io.ReadAll(resp.Body)
+        return string(body)
+    }
+
+    if v := send("h2c", "application/grpc", "/pkg.Greeter/Hello"); v != "grpc /pkg.Greeter/Hello" {
+        t.Errorf("grpc over h2c: want grpc handler, got %q", v)
+    }
+
+    if v := send("h2c", "application/json", "/h2c/ping"); v != "http HTTP/2.0" {
+        t.Errorf("plain h2c request: want router, got %q", v)
+    }
+
+    if v := send("http1", "application/grpc", "/h2c/ping"); v != "http HTTP/1.1" {
+        t.Errorf("http/1.1 on same listener: want router, got %q", v)
+    }
+}
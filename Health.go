@@ -0,0 +1,157 @@
+package pgo
+
+import (
+    "encoding/json"
+    "net/http"
+    "regexp"
+    "sync"
+    "time"
+
+    "github.com/pinguo/pgo/Util"
+)
+
+// health check function, return error if not healthy
+type HealthCheck func() error
+
+// warmup hook run before server accepts traffic
+type warmup struct {
+    name     string
+    fn       func() error
+    required bool
+}
+
+// health component, components register checks by AddCheck,
+// readiness endpoint responds 200 if all checks pass, 503 otherwise,
+// configuration:
+// "health": {
+//     "path": "/_ready"
+// }
+//
+// warmup hooks added by AddWarmup run in order before server listens,
+// eg. preload caches, compile templates, failure of required hook is
+// kept as a failed check, so readiness stays 503 and lb sends no traffic.
+type Health struct {
+    lock    sync.RWMutex
+    path    string
+    checks  map[string]HealthCheck
+    warmups []warmup
+}
+
+func (h *Health) Construct() {
+    h.path = DefaultHealthPath
+    h.checks = make(map[string]HealthCheck)
+}
+
+func (h *Health) Init() {
+    if len(h.path) > 0 && ModeWeb == App.GetMode() {
+        App.GetRouter().AddHandler("^"+regexp.QuoteMeta(h.path)+"$", h.serveReady, map[string]interface{}{
+            "summary": "readiness check",
+        })
+    }
+}
+
+// set path of readiness endpoint, empty to disable
+func (h *Health) SetPath(path string) {
+    if h.path = ""; len(path) > 0 {
+        h.path = Util.CleanPath(path)
+    }
+}
+
+// get path of readiness endpoint
+func (h *Health) GetPath() string {
+    return h.path
+}
+
+// add or replace health check by name
+func (h *Health) AddCheck(name string, check HealthCheck) {
+    h.lock.Lock()
+    defer h.lock.Unlock()
+
+    h.checks[name] = check
+}
+
+// add warmup hook by name, error of required hook blocks readiness
+func (h *Health) AddWarmup(name string, fn func() error, required bool) {
+    h.lock.Lock()
+    defer h.lock.Unlock()
+
+    h.warmups = append(h.warmups, warmup{name, fn, required})
+}
+
+// run warmup hooks in order, return errors of failed hooks,
+// it's called by server before listening
+func (h *Health) Warmup() map[string]error {
+    h.lock.RLock()
+    warmups := h.warmups
+    h.lock.RUnlock()
+
+    errors := make(map[string]error)
+    for _, w := range warmups {
+        start := time.Now()
+        e := h.runCheck(w.fn)
+        if e == nil {
+            GLogger().Info("warmup %s done in %dms", w.name, time.Since(start)/time.Millisecond)
+            continue
+        }
+
+        errors[w.name] = e
+        if !w.required {
+            GLogger().Warn("warmup %s failed, %s", w.name, e)
+            continue
+        }
+
+        GLogger().Error("warmup %s failed, readiness blocked, %s", w.name, e)
+        h.AddCheck("warmup:"+w.name, func() error { return e })
+    }
+
+    return errors
+}
+
+// remove health check by name
+func (h *Health) DelCheck(name string) {
+    h.lock.Lock()
+    defer h.lock.Unlock()
+
+    delete(h.checks, name)
+}
+
+// run all checks, return errors of failed checks
+func (h *Health) Check() map[string]error {
+    h.lock.RLock()
+    checks := make(map[string]HealthCheck, len(h.checks))
+    for name, check := range h.checks {
+        checks[name] = check
+    }
+    h.lock.RUnlock()
+
+    errors := make(map[string]error)
+    for name, check := range checks {
+        if e := h.runCheck(check); e != nil {
+            errors[name] = e
+        }
+    }
+
+    return errors
+}
+
+func (h *Health) runCheck(check HealthCheck) (e error) {
+    defer func() {
+        if v := recover(); v != nil {
+            e = NewException(http.StatusServiceUnavailable, Util.ToString(v))
+        }
+    }()
+
+    return check()
+}
+
+func (h *Health) serveReady(ctx *Context) {
+    status, failed := http.StatusOK, make(map[string]string)
+    for name, e := range h.Check() {
+        failed[name] = e.Error()
+        status = http.StatusServiceUnavailable
+    }
+
+    output, _ := json.Marshal(map[string]interface{}{"ready": len(failed) == 0, "failed": failed})
+    ctx.SetHeader("Content-Type", "application/json; charset=utf-8")
+    ctx.End(status, output)
+}
@@ -3,6 +3,8 @@ package pgo
 import (
     "fmt"
     "strings"
+    "sync"
+    "time"
 
     "github.com/pinguo/pgo/Util"
 )
@@ -14,16 +16,40 @@ import (
 // configuration:
 // "i18n": {
 //     "sourceLang": "en",
-//     "targetLang": [ "en", "zh-CN", "zh-TW"]
+//     "targetLang": [ "en", "zh-CN", "zh-TW"],
+//     "source": {"class": "@app/Lib/DbMessageSource"},
+//     "refreshInterval": "60s"
 // }
+//
+// messages are loaded from source on first use of each lang and
+// cached, source defaults to lang files(FileMessageSource), if
+// refreshInterval is set, updates since last load are merged into
+// cache periodically, cache is kept if source fails, lang failed
+// on first load is not cached, so it's loaded again on next use.
 type I18n struct {
-    sourceLang string
-    targetLang map[string]bool
+    sourceLang      string
+    targetLang      map[string]bool
+    source          IMessageSource
+    refreshInterval time.Duration
+
+    messages    map[string]map[string]string // cached messages of each lang
+    updated     map[string]time.Time         // last update time of each lang
+    refreshOnce sync.Once
+    lock        sync.RWMutex
 }
 
 func (i *I18n) Construct() {
     i.sourceLang = "en"
     i.targetLang = make(map[string]bool)
+    i.messages = make(map[string]map[string]string)
+    i.updated = make(map[string]time.Time)
+}
+
+func (i *I18n) Init() {
+    if i.source == nil {
+        i.source = &FileMessageSource{}
+        App.GetConfig().OnChange(i.onConfigChange)
+    }
 }
 
 func (i *I18n) SetSourceLang(lang string) {
@@ -37,6 +63,25 @@ func (i *I18n) SetTargetLang(targets []interface{}) {
     }
 }
 
+// set message source, v is source object or its configuration
+func (i *I18n) SetSource(v interface{}) {
+    if source, ok := v.(IMessageSource); ok {
+        i.source = source
+    } else if source, ok := CreateObject(v).(IMessageSource); ok {
+        i.source = source
+    } else {
+        panic("I18n: invalid message source, " + Util.ToString(v))
+    }
+}
+
+func (i *I18n) SetRefreshInterval(v string) {
+    if d, e := time.ParseDuration(v); e != nil {
+        panic("I18n: invalid refreshInterval, " + e.Error())
+    } else {
+        i.refreshInterval = d
+    }
+}
+
 // translate message to target lang, lang format is one of the following:
 // 1. accept-language header value: zh-CN,zh;q=0.9,en;q=0.8,zh-TW;q=0.7
 // 2. ll-CC: lower case lang code and upper case area code, zh-CN
@@ -78,12 +123,131 @@ func (i *I18n) detectLang(lang string) string {
     return i.sourceLang
 }
 
-// load message from lang file i18n_{lang}.json
+// load message of lang from cache, load from source on cache miss
 func (i *I18n) loadMessage(message, lang string) string {
     if !i.targetLang[lang] {
         return message
     }
 
-    key := fmt.Sprintf("i18n_%s.%s", lang, message)
-    return App.GetConfig().GetString(key, message)
+    i.lock.RLock()
+    messages, ok := i.messages[lang]
+    i.lock.RUnlock()
+
+    if !ok {
+        messages = i.loadLang(lang)
+    }
+
+    if translation, ok := messages[message]; ok {
+        return translation
+    }
+
+    return message
+}
+
+// load all messages of lang from source
+func (i *I18n) loadLang(lang string) map[string]string {
+    if i.refreshInterval > 0 {
+        i.refreshOnce.Do(func() { Go(i.refresh) })
+    }
+
+    i.lock.Lock()
+    defer i.lock.Unlock()
+
+    if messages, ok := i.messages[lang]; ok {
+        return messages
+    }
+
+    messages, updated, e := i.source.LoadMessages(lang, time.Time{})
+    if e != nil {
+        GLogger().Error("I18n: failed to load messages of %s, %s", lang, e)
+        return nil
+    }
+
+    if messages == nil {
+        messages = make(map[string]string)
+    }
+
+    i.messages[lang], i.updated[lang] = messages, updated
+    return messages
+}
+
+// goroutine to merge updated messages into cache periodically
+func (i *I18n) refresh() {
+    for range time.Tick(i.refreshInterval) {
+        i.lock.RLock()
+        langs := make(map[string]time.Time, len(i.updated))
+        for lang, updated := range i.updated {
+            langs[lang] = updated
+        }
+        i.lock.RUnlock()
+
+        for lang, since := range langs {
+            updates, updated, e := i.source.LoadMessages(lang, since)
+            if e != nil {
+                GLogger().Warn("I18n: failed to refresh messages of %s, %s", lang, e)
+                continue
+            } else if len(updates) == 0 {
+                continue
+            }
+
+            // copy on write, readers hold the old map without lock
+            i.lock.Lock()
+            messages := make(map[string]string, len(i.messages[lang])+len(updates))
+            for k, v := range i.messages[lang] {
+                messages[k] = v
+            }
+            for k, v := range updates {
+                messages[k] = v
+            }
+            i.messages[lang], i.updated[lang] = messages, updated
+            i.lock.Unlock()
+        }
+    }
+}
+
+// drop cached messages of lang files changed by config reload,
+// so they are loaded again on next use
+func (i *I18n) onConfigChange(changes []ConfigChange) {
+    i.lock.Lock()
+    defer i.lock.Unlock()
+
+    for _, change := range changes {
+        if lang := strings.TrimPrefix(change.Key, "i18n_"); lang != change.Key {
+            if pos := strings.IndexByte(lang, '.'); pos > 0 {
+                lang = lang[:pos]
+            }
+
+            delete(i.messages, lang)
+            delete(i.updated, lang)
+        }
+    }
+}
+
+// message source of lang files i18n_{lang}.json in conf directory,
+// nested keys are flattened with dot, there is no incremental update,
+// I18n loads lang file again after it's changed by config reload.
+type FileMessageSource struct {
+}
+
+func (f *FileMessageSource) LoadMessages(lang string, since time.Time) (map[string]string, time.Time, error) {
+    if !since.IsZero() {
+        return nil, since, nil
+    }
+
+    messages := make(map[string]string)
+    if data, ok := App.GetConfig().Get("i18n_" + lang).(map[string]interface{}); ok {
+        f.flatten(messages, "", data)
+    }
+
+    return messages, time.Now(), nil
+}
+
+func (f *FileMessageSource) flatten(messages map[string]string, prefix string, data map[string]interface{}) {
+    for k, v := range data {
+        if m, ok := v.(map[string]interface{}); ok {
+            f.flatten(messages, prefix+k+".", m)
+        } else {
+            messages[prefix+k] = Util.ToString(v)
+        }
+    }
 }
@@ -0,0 +1,135 @@
+package pgo
+
+import (
+    "errors"
+    "sync"
+    "testing"
+    "time"
+)
+
+// source returns messages of lang, and updates if since is not zero
+type testMessageSource struct {
+    lock     sync.Mutex
+    messages map[string]string
+    updates  map[string]string
+    err      error
+    loads    int
+}
+
+func (s *testMessageSource) LoadMessages(lang string, since time.Time) (map[string]string, time.Time, error) {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    s.loads++
+    if s.err != nil {
+        return nil, since, s.err
+    } else if !since.IsZero() {
+        return s.updates, time.Now(), nil
+    }
+
+    return s.messages, time.Now(), nil
+}
+
+func (s *testMessageSource) set(updates map[string]string, err error) {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    s.updates, s.err = updates, err
+}
+
+func (s *testMessageSource) getLoads() int {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    return s.loads
+}
+
+func newTestI18n(source IMessageSource) *I18n {
+    i := &I18n{}
+    i.Construct()
+    i.SetTargetLang([]interface{}{"zh-CN"})
+    if source != nil {
+        i.SetSource(source)
+    }
+
+    i.Init()
+    return i
+}
+
+func TestI18nCache(t *testing.T) {
+    source := &testMessageSource{messages: map[string]string{"hello": "你好"}}
+    i := newTestI18n(source)
+
+    for n := 0; n < 3; n++ {
+        if v := i.Translate("hello", "zh-CN,zh;q=0.9"); v != "你好" {
+            t.Errorf("want 你好, got %q", v)
+        }
+    }
+
+    if v := i.Translate("hello", "en"); v != "hello" {
+        t.Errorf("source lang: want message itself, got %q", v)
+    }
+
+    if n := source.getLoads(); n != 1 {
+        t.Errorf("want lang loaded once, got %d", n)
+    }
+}
+
+func TestI18nLoadError(t *testing.T) {
+    source := &testMessageSource{messages: map[string]string{"hello": "你好"}, err: errors.New("db down")}
+    i := newTestI18n(source)
+
+    if v := i.Translate("hello", "zh-CN"); v != "hello" {
+        t.Errorf("source failed: want message itself, got %q", v)
+    }
+
+    source.set(nil, nil)
+    if v := i.Translate("hello", "zh-CN"); v != "你好" || source.getLoads() != 2 {
+        t.Errorf("source recovered: want loaded again, got %q after %d loads", v, source.getLoads())
+    }
+}
+
+func TestI18nRefresh(t *testing.T) {
+    source := &testMessageSource{messages: map[string]string{"hello": "你好", "bye": "再见"}}
+    i := newTestI18n(source)
+    i.SetRefreshInterval("10ms")
+
+    i.Translate("hello", "zh-CN")
+    source.set(map[string]string{"hello": "您好"}, nil)
+
+    deadline := time.Now().Add(time.Second)
+    for i.Translate("hello", "zh-CN") != "您好" && time.Now().Before(deadline) {
+        time.Sleep(5 * time.Millisecond)
+    }
+
+    if v := i.Translate("hello", "zh-CN"); v != "您好" {
+        t.Fatalf("want update merged, got %q", v)
+    }
+
+    // cache is kept if refresh fails
+    source.set(nil, errors.New("db down"))
+    defer source.set(nil, nil)
+    time.Sleep(30 * time.Millisecond)
+    if v := i.Translate("bye", "zh-CN"); v != "再见" {
+        t.Errorf("refresh failed: want cache kept, got %q", v)
+    }
+}
+
+func TestI18nFileReload(t *testing.T) {
+    i := newTestI18n(nil)
+    App.GetConfig().Set("i18n_zh-CN", map[string]interface{}{"user": map[string]interface{}{"name": "姓名"}})
+
+    if v := i.Translate("user.name", "zh-CN"); v != "姓名" {
+        t.Fatalf("want nested key of lang file, got %q", v)
+    }
+
+    App.GetConfig().Set("i18n_zh-CN.user.name", "名字")
+    if v := i.Translate("user.name", "zh-CN"); v != "姓名" {
+        t.Errorf("before reload: want cached, got %q", v)
+    }
+
+    i.onConfigChange([]ConfigChange{{ConfigModified, "i18n_zh-CN.user.name", "姓名", "名字"}})
+    if v := i.Translate("user.name", "zh-CN"); v != "名字" {
+        t.Errorf("after reload: want lang file loaded again, got %q", v)
+    }
+}
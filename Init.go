@@ -2,8 +2,10 @@ package pgo
 
 import (
     "fmt"
+    "os"
     "reflect"
     "regexp"
+    "runtime/debug"
     "strings"
     "time"
 
@@ -11,6 +13,7 @@ import (
 )
 
 const (
+    FrameworkVersion   = "v0.1.0"
     ModeWeb            = 1
     ModeCmd            = 2
     DefaultEnv         = "prod"
@@ -19,6 +22,17 @@ const (
     DefaultServerAddr  = "0.0.0.0:8000"
     DefaultTimeout     = 30 * time.Second
     DefaultHeaderBytes = 1 << 20
+    DefaultBodyBytes   = 10 << 20
+    DefaultMemoryBytes = 32 << 20
+    DefaultHealthPath  = "/_ready"
+    DefaultAdminPath   = "/_admin"
+    DefaultInitWarn    = time.Second
+    DefaultStopTimeout = 5 * time.Second
+    DefaultStatsRoutes = 200
+    FlashCookieName    = "pgo_flash"
+    LogIdHeader        = "X-Log-Id"
+    ConfigEnvPrefix    = "PGO_"
+    CommandHelp        = "help"
     ControllerWeb      = "Controller"
     ControllerCmd      = "Command"
     ConstructMethod    = "Construct"
@@ -28,12 +42,23 @@ const (
     ActionPrefix       = "Action"
     ActionLength       = 6
     TraceMaxDepth      = 10
+
+    RpcParseError     = -32700
+    RpcInvalidRequest = -32600
+    RpcMethodNotFound = -32601
+    RpcInvalidParams  = -32602
+    RpcInternalError  = -32603
+
+    TrailingSlashStrict   = "strict"
+    TrailingSlashIgnore   = "ignore"
+    TrailingSlashRedirect = "redirect"
 )
 
 var (
     aliases map[string]string
     aliasRe *regexp.Regexp
     logger  *Logger
+    flight  *Util.SingleFlight
 
     App         *Application
     EmptyObject struct{}
@@ -46,6 +71,7 @@ func init() {
     // global initialization
     aliases = make(map[string]string)
     aliasRe = regexp.MustCompile(`^@[^\\/]+`)
+    flight = Util.NewSingleFlight()
 
     // new app instance
     App = &Application{}
@@ -58,12 +84,52 @@ func init() {
     App.container.Bind(&FileTarget{})
     App.container.Bind(&Status{})
     App.container.Bind(&I18n{})
+    App.container.Bind(&FileMessageSource{})
     App.container.Bind(&View{})
+    App.container.Bind(&Render{})
+    App.container.Bind(&Health{})
+    App.container.Bind(&Auth{})
+    App.container.Bind(&Jwt{})
+    App.container.Bind(&ConsulSource{})
+    App.container.Bind(&JsonSerializer{})
+    App.container.Bind(&GobSerializer{})
 }
 
 // run application
 func Run() {
     App.GetServer().Serve()
+
+    // exit with code of registered command
+    if code := App.GetServer().exitCode; code != 0 {
+        os.Exit(code)
+    }
+}
+
+// run fn in a new goroutine, panic is recovered and logged instead of
+// crashing the process, then reported to handlers added by Server.OnPanic,
+// eg. for alerting, use it for fire-and-forget tasks and framework loops.
+func Go(fn func()) {
+    go func() {
+        defer func() {
+            if v := recover(); v != nil {
+                reportGoPanic(nil, v)
+            }
+        }()
+
+        fn()
+    }()
+}
+
+// log panic recovered in goroutine and call panic handlers
+func reportGoPanic(ctx *Context, v interface{}) {
+    stack := debug.Stack()
+    if ctx == nil {
+        ctx = &Context{}
+        ctx.Init()
+    }
+
+    ctx.Error("goroutine panic, %s, trace[%s]", Util.ToString(v), Util.PanicTrace(TraceMaxDepth, false))
+    App.GetServer().reportPanic(ctx, v, stack)
 }
 
 // get global logger
@@ -109,6 +175,14 @@ func GetAlias(alias string) string {
     return ""
 }
 
+// register class name for type of i, i must be a pointer or a reflect.Type,
+// CreateObject resolves the name to the type registered, eg.
+// pgo.Register("myapp.FooController", &FooController{})
+// pgo.CreateObject("myapp.FooController")
+func Register(name string, i interface{}) {
+    App.GetContainer().BindName(name, i)
+}
+
 // create object using the given configuration
 func CreateObject(class interface{}, params ...interface{}) interface{} {
     var className string
@@ -122,17 +196,23 @@ func CreateObject(class interface{}, params ...interface{}) interface{} {
             panic(`CreateObject: object configuration require "class" element`)
         }
 
-        className = v["class"].(string)
+        className, _ = v["class"].(string)
         config = v
     default:
         panic(fmt.Sprintf("CreateObject: unsupported class type: %T", class))
     }
 
-    if name := GetAlias(className); len(name) > 0 {
-        return App.GetContainer().Get(name, config, params...)
+    name := GetAlias(className)
+    if len(name) == 0 {
+        panic("CreateObject: unknown class alias: " + className)
+    }
+
+    if !App.GetContainer().Has(name) {
+        panic(fmt.Sprintf("CreateObject: class not found: %s(resolved from %s), "+
+            "bind it by Container.Bind() or pgo.Register()", name, className))
     }
 
-    panic("unknown class: " + className)
+    return App.GetContainer().Get(name, config, params...)
 }
 
 // configure object using the given configuration
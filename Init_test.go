@@ -0,0 +1,14 @@
+package pgo
+
+import (
+    "flag"
+    "os"
+    "testing"
+)
+
+// command line is parsed by App at init, before test flags are
+// registered, parse again so -run, -bench etc. take effect
+func TestMain(m *testing.M) {
+    flag.CommandLine.Parse(os.Args[1:])
+    os.Exit(m.Run())
+}
@@ -23,6 +23,11 @@ type IPlugin interface {
     HandleRequest(ctx *Context)
 }
 
+type IAuthStrategy interface {
+    Authenticate(ctx *Context) (principal interface{}, e error)
+    Challenge(realm string) string
+}
+
 type IFilter interface {
     HandleFilter(ctx *Context)
 }
@@ -36,19 +41,66 @@ type ITarget interface {
     Flush(final bool)
 }
 
+type IRenderer interface {
+    ContentType() string
+    Render(ctx *Context, data interface{}) []byte
+}
+
+type IReopener interface {
+    Reopen()
+}
+
+type IStopper interface {
+    Stop() error
+}
+
+type ISerializer interface {
+    Serialize(v interface{}) ([]byte, error)
+    Unserialize(data []byte, ptr interface{}) error
+}
+
 type IConfigParser interface {
     Parse(path string) map[string]interface{}
 }
 
+// parser supports config files in fs.FS
+type IConfigContentParser interface {
+    ParseContent(path string, content []byte) map[string]interface{}
+}
+
+// source of config layered with files, eg. etcd or consul, Load gets
+// all config keyed by top-level name, eg. {"app": {...}}, Watch blocks
+// and calls cb with new config of every change, it returns immediately
+// if source does not support watching.
+type IConfigSource interface {
+    Load() (map[string]interface{}, error)
+    Watch(cb func(data map[string]interface{}))
+}
+
+// resolver of secret reference in config, ref is the part after
+// "secret://", eg. "db/password", implemented by user, eg. for vault
+type ISecretResolver interface {
+    Resolve(ref string) (string, error)
+}
+
+type IMessageSource interface {
+    LoadMessages(lang string, since time.Time) (map[string]string, time.Time, error)
+}
+
+// cache of memory, memcache and redis clients, GetMulti, SetMulti
+// and Has are the same as MGet, MSet and Exists
 type ICache interface {
     Get(key string) *Value
     MGet(keys []string) map[string]*Value
+    GetMulti(keys []string) map[string]*Value
     Set(key string, value interface{}, expire ...time.Duration) bool
     MSet(items map[string]interface{}, expire ...time.Duration) bool
+    SetMulti(items map[string]interface{}, expire ...time.Duration) bool
     Add(key string, value interface{}, expire ...time.Duration) bool
     MAdd(items map[string]interface{}, expire ...time.Duration) bool
     Del(key string) bool
     MDel(keys []string) bool
     Exists(key string) bool
+    Has(key string) bool
     Incr(key string, delta int) int
 }
@@ -0,0 +1,251 @@
+package pgo
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "io/ioutil"
+    "net/http"
+    "regexp"
+    "sort"
+    "sync"
+
+    "github.com/pinguo/pgo/Util"
+)
+
+// handler of json-rpc method, params is the raw "params" member,
+// returned error is converted to error object, *RpcError is kept
+// as is, exception of 400 or 422 is invalid params, others are
+// internal error, panic is recovered as internal error.
+type RpcHandler func(ctx *Context, params json.RawMessage) (interface{}, error)
+
+// error object of json-rpc response
+type RpcError struct {
+    Code    int         `json:"code"`
+    Message string      `json:"message"`
+    Data    interface{} `json:"data,omitempty"`
+}
+
+func NewRpcError(code int, message string, data ...interface{}) *RpcError {
+    e := &RpcError{Code: code, Message: message}
+    if len(data) > 0 {
+        e.Data = data[0]
+    }
+
+    return e
+}
+
+// implement error interface
+func (e *RpcError) Error() string {
+    return "json-rpc error: " + Util.ToString(e.Code) + ", " + e.Message
+}
+
+type rpcRequest struct {
+    Version string          `json:"jsonrpc"`
+    Method  string          `json:"method"`
+    Params  json.RawMessage `json:"params"`
+    Id      json.RawMessage `json:"id"`
+}
+
+type rpcResponse struct {
+    Version string           `json:"jsonrpc"`
+    Result  *json.RawMessage `json:"result,omitempty"`
+    Error   *RpcError        `json:"error,omitempty"`
+    Id      json.RawMessage  `json:"id"`
+}
+
+// json-rpc 2.0 over http, single and batch requests are supported,
+// notification(request without id) runs without response, usage:
+// rpc := pgo.NewJsonRpc()
+// rpc.Register("user.get", func(ctx *pgo.Context, params json.RawMessage) (interface{}, error) {
+//     var args struct{ Id int `json:"id"` }
+//     if e := json.Unmarshal(params, &args); e != nil {
+//         return nil, pgo.NewRpcError(pgo.RpcInvalidParams, e.Error())
+//     }
+//     return getUser(args.Id), nil
+// })
+// rpc.Mount("/rpc")
+//
+// it's a router handler, so server plugins run before it, batch calls
+// run in order with the same context, response is always 200 except
+// 204 for notifications only.
+type JsonRpc struct {
+    handlers map[string]RpcHandler
+    lock     sync.RWMutex
+}
+
+func NewJsonRpc() *JsonRpc {
+    return &JsonRpc{handlers: make(map[string]RpcHandler)}
+}
+
+// register handler of method, existing handler is replaced
+func (r *JsonRpc) Register(method string, handler RpcHandler) {
+    if len(method) == 0 || handler == nil {
+        panic("JsonRpc: method and handler cannot be empty")
+    }
+
+    r.lock.Lock()
+    defer r.lock.Unlock()
+
+    r.handlers[method] = handler
+}
+
+// get sorted methods registered
+func (r *JsonRpc) Methods() []string {
+    r.lock.RLock()
+    defer r.lock.RUnlock()
+
+    methods := make([]string, 0, len(r.handlers))
+    for method := range r.handlers {
+        methods = append(methods, method)
+    }
+
+    sort.Strings(methods)
+    return methods
+}
+
+// add POST handler of path to router
+func (r *JsonRpc) Mount(path string) {
+    path = Util.CleanPath(path)
+    App.GetRouter().AddHandler("^"+regexp.QuoteMeta(path)+"$", r.Handle, map[string]interface{}{
+        "method":  http.MethodPost,
+        "summary": "json-rpc 2.0 endpoint",
+    })
+}
+
+// handle json-rpc request of context
+func (r *JsonRpc) Handle(ctx *Context) {
+    body, e := ioutil.ReadAll(ctx.GetInput().Body)
+    if e != nil {
+        r.output(ctx, newRpcErrorResponse(nil, NewRpcError(RpcParseError, "Parse error")))
+        return
+    }
+
+    body = bytes.TrimSpace(body)
+    if len(body) == 0 || body[0] != '[' {
+        if resp := r.call(ctx, body); resp != nil {
+            r.output(ctx, resp)
+        } else {
+            ctx.End(http.StatusNoContent, nil)
+        }
+        return
+    }
+
+    var batch []json.RawMessage
+    if e := json.Unmarshal(body, &batch); e != nil {
+        r.output(ctx, newRpcErrorResponse(nil, NewRpcError(RpcParseError, "Parse error")))
+        return
+    } else if len(batch) == 0 {
+        r.output(ctx, newRpcErrorResponse(nil, NewRpcError(RpcInvalidRequest, "Invalid Request")))
+        return
+    }
+
+    responses := make([]*rpcResponse, 0, len(batch))
+    for _, raw := range batch {
+        if resp := r.call(ctx, raw); resp != nil {
+            responses = append(responses, resp)
+        }
+    }
+
+    if len(responses) == 0 {
+        ctx.End(http.StatusNoContent, nil)
+    } else {
+        r.output(ctx, responses)
+    }
+}
+
+// call method of a single request, nil for notification
+func (r *JsonRpc) call(ctx *Context, raw []byte) (resp *rpcResponse) {
+    var req rpcRequest
+    if e := json.Unmarshal(raw, &req); e != nil {
+        if _, ok := e.(*json.SyntaxError); ok {
+            return newRpcErrorResponse(nil, NewRpcError(RpcParseError, "Parse error"))
+        }
+        return newRpcErrorResponse(nil, NewRpcError(RpcInvalidRequest, "Invalid Request"))
+    }
+
+    if req.Version != "2.0" || len(req.Method) == 0 || !isRpcParams(req.Params) {
+        return newRpcErrorResponse(req.Id, NewRpcError(RpcInvalidRequest, "Invalid Request"))
+    }
+
+    r.lock.RLock()
+    handler := r.handlers[req.Method]
+    r.lock.RUnlock()
+
+    notify := len(req.Id) == 0
+    if handler == nil {
+        if notify {
+            return nil
+        }
+        return newRpcErrorResponse(req.Id, NewRpcError(RpcMethodNotFound, "Method not found"))
+    }
+
+    defer func() {
+        if v := recover(); v != nil {
+            ctx.Error("JsonRpc: %s panic, %s, trace[%s]", req.Method, Util.ToString(v), Util.PanicTrace(TraceMaxDepth, false))
+            if resp = nil; !notify {
+                err, _ := v.(error)
+                resp = newRpcErrorResponse(req.Id, rpcErrorOf(err))
+            }
+        }
+    }()
+
+    ctx.PushLog("rpc", req.Method)
+    result, e := handler(ctx, req.Params)
+    if notify {
+        return nil
+    } else if e != nil {
+        if _, ok := e.(*RpcError); !ok {
+            ctx.Warn("JsonRpc: %s failed, %s", req.Method, e)
+        }
+        return newRpcErrorResponse(req.Id, rpcErrorOf(e))
+    }
+
+    output, e := json.Marshal(result)
+    if e != nil {
+        ctx.Error("JsonRpc: failed to marshal result of %s, %s", req.Method, e)
+        return newRpcErrorResponse(req.Id, NewRpcError(RpcInternalError, "Internal error"))
+    }
+
+    message := json.RawMessage(output)
+    return &rpcResponse{Version: "2.0", Result: &message, Id: req.Id}
+}
+
+func (r *JsonRpc) output(ctx *Context, v interface{}) {
+    output, _ := json.Marshal(v)
+    ctx.SetHeader("Content-Type", "application/json; charset=utf-8")
+    ctx.End(http.StatusOK, output)
+}
+
+func newRpcErrorResponse(id json.RawMessage, e *RpcError) *rpcResponse {
+    if len(id) == 0 {
+        id = json.RawMessage("null")
+    }
+
+    return &rpcResponse{Version: "2.0", Error: e, Id: id}
+}
+
+// convert error of handler to error object
+func rpcErrorOf(err error) *RpcError {
+    var re *RpcError
+    if errors.As(err, &re) {
+        return re
+    }
+
+    if e, ok := AsException(err); ok {
+        switch e.GetStatus() {
+        case http.StatusBadRequest, http.StatusUnprocessableEntity:
+            return NewRpcError(RpcInvalidParams, e.GetMessage(), e.GetDetails())
+        default:
+            return NewRpcError(RpcInternalError, e.GetMessage(), e.GetDetails())
+        }
+    }
+
+    return NewRpcError(RpcInternalError, "Internal error")
+}
+
+// params must be omitted, array or object
+func isRpcParams(params json.RawMessage) bool {
+    params = bytes.TrimSpace(params)
+    return len(params) == 0 || params[0] == '[' || params[0] == '{'
+}
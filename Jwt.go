@@ -0,0 +1,204 @@
+package pgo
+
+import (
+    "crypto/rsa"
+    "crypto/x509"
+    "encoding/pem"
+    "io/ioutil"
+    "time"
+
+    "github.com/pinguo/pgo/Util"
+)
+
+// jwt component, sign and verify tokens with keys from config,
+// configuration:
+// "components": {
+//     "jwt": {
+//         "class": "@pgo/Jwt",
+//         "alg": "HS256",
+//         "secret": "${JWT_SECRET}",
+//         "privateKey": "@app/conf/jwt.key",
+//         "publicKey": "@app/conf/jwt.pub",
+//         "ttl": "2h",
+//         "skew": "30s",
+//         "issuer": "",
+//         "audience": ""
+//     }
+// }
+//
+// HS256 uses secret, RS256 signs with privateKey and verifies with
+// publicKey(PEM files, publicKey can be omitted if privateKey is set),
+// ttl sets exp of token without exp, skew is the clock skew tolerance
+// of exp and nbf, usage with bearer strategy:
+// jwt := pgo.App.Get("jwt").(*pgo.Jwt)
+// pgo.App.GetAuth().AddStrategy("jwt", pgo.NewBearerAuth(jwt.Verifier()))
+type Jwt struct {
+    alg        string
+    secret     []byte
+    privateKey *rsa.PrivateKey
+    publicKey  *rsa.PublicKey
+    ttl        time.Duration
+    skew       time.Duration
+    issuer     string
+    audience   string
+}
+
+func (j *Jwt) Construct() {
+    j.alg = "HS256"
+}
+
+func (j *Jwt) Init() {
+    switch j.alg {
+    case "HS256":
+        if len(j.secret) == 0 {
+            panic("Jwt: secret is required for HS256")
+        }
+    case "RS256":
+        if j.publicKey == nil && j.privateKey != nil {
+            j.publicKey = &j.privateKey.PublicKey
+        }
+        if j.publicKey == nil {
+            panic("Jwt: publicKey or privateKey is required for RS256")
+        }
+    default:
+        panic("Jwt: unsupported alg, " + j.alg)
+    }
+}
+
+func (j *Jwt) SetAlg(alg string) {
+    j.alg = alg
+}
+
+func (j *Jwt) SetSecret(secret string) {
+    j.secret = []byte(secret)
+}
+
+// set path of PEM private key, PKCS#1 or PKCS#8
+func (j *Jwt) SetPrivateKey(path string) {
+    block := j.readPem(path)
+    if key, e := x509.ParsePKCS1PrivateKey(block.Bytes); e == nil {
+        j.privateKey = key
+    } else if key, e := x509.ParsePKCS8PrivateKey(block.Bytes); e == nil {
+        if rsaKey, ok := key.(*rsa.PrivateKey); ok {
+            j.privateKey = rsaKey
+            return
+        }
+        panic("Jwt: private key is not rsa, " + path)
+    } else {
+        panic("Jwt: invalid private key, " + e.Error())
+    }
+}
+
+// set path of PEM public key, PKIX or PKCS#1
+func (j *Jwt) SetPublicKey(path string) {
+    block := j.readPem(path)
+    if key, e := x509.ParsePKIXPublicKey(block.Bytes); e == nil {
+        if rsaKey, ok := key.(*rsa.PublicKey); ok {
+            j.publicKey = rsaKey
+            return
+        }
+        panic("Jwt: public key is not rsa, " + path)
+    } else if key, e := x509.ParsePKCS1PublicKey(block.Bytes); e == nil {
+        j.publicKey = key
+    } else {
+        panic("Jwt: invalid public key, " + e.Error())
+    }
+}
+
+func (j *Jwt) SetTtl(v string) {
+    j.ttl = j.parseDuration("ttl", v)
+}
+
+func (j *Jwt) SetSkew(v string) {
+    j.skew = j.parseDuration("skew", v)
+}
+
+func (j *Jwt) SetIssuer(issuer string) {
+    j.issuer = issuer
+}
+
+func (j *Jwt) SetAudience(audience string) {
+    j.audience = audience
+}
+
+// sign claims to token, iat is set, exp and iss are set if configured
+// and not in claims, claims is not modified
+func (j *Jwt) Sign(claims map[string]interface{}) (string, error) {
+    now := time.Now()
+    payload := make(map[string]interface{}, len(claims)+3)
+    payload["iat"] = now.Unix()
+    if j.ttl > 0 {
+        payload["exp"] = now.Add(j.ttl).Unix()
+    }
+
+    if len(j.issuer) > 0 {
+        payload["iss"] = j.issuer
+    }
+
+    if len(j.audience) > 0 {
+        payload["aud"] = j.audience
+    }
+
+    for k, v := range claims {
+        payload[k] = v
+    }
+
+    if j.alg == "RS256" {
+        if j.privateKey == nil {
+            panic("Jwt: privateKey is required to sign")
+        }
+        return Util.JwtSign(payload, j.alg, j.privateKey)
+    }
+
+    return Util.JwtSign(payload, j.alg, j.secret)
+}
+
+// parse and verify token, return its claims, token signed by
+// other alg than configured is rejected
+func (j *Jwt) Parse(token string) (map[string]interface{}, error) {
+    return Util.JwtParse(token, j.keyOf, &Util.JwtOptions{Skew: j.skew, Issuer: j.issuer, Audience: j.audience})
+}
+
+// get verifier for bearer strategy, principal is claims of token
+func (j *Jwt) Verifier() TokenVerifier {
+    return func(token string) (interface{}, error) {
+        claims, e := j.Parse(token)
+        if e != nil {
+            return nil, e
+        }
+        return claims, nil
+    }
+}
+
+func (j *Jwt) keyOf(alg string, header map[string]interface{}) (interface{}, error) {
+    if alg != j.alg {
+        return nil, Util.ErrJwtAlgorithm
+    } else if alg == "RS256" {
+        return j.publicKey, nil
+    }
+
+    return j.secret, nil
+}
+
+func (j *Jwt) readPem(path string) *pem.Block {
+    data, e := ioutil.ReadFile(GetAlias(path))
+    if e != nil {
+        panic("Jwt: read key failed, " + e.Error())
+    }
+
+    block, _ := pem.Decode(data)
+    if block == nil {
+        panic("Jwt: invalid PEM file, " + path)
+    }
+
+    return block
+}
+
+func (j *Jwt) parseDuration(name, v string) time.Duration {
+    d, e := time.ParseDuration(v)
+    if e != nil {
+        panic("Jwt: invalid " + name + ", " + e.Error())
+    }
+
+    return d
+}
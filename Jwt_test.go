@@ -0,0 +1,97 @@
+package pgo
+
+import (
+    "crypto/rand"
+    "crypto/rsa"
+    "crypto/x509"
+    "encoding/pem"
+    "io/ioutil"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "github.com/pinguo/pgo/Util"
+)
+
+func newTestJwt(alg string) *Jwt {
+    j := &Jwt{}
+    j.Construct()
+    j.SetAlg(alg)
+    return j
+}
+
+func TestJwtSignAndParse(t *testing.T) {
+    j := newTestJwt("HS256")
+    j.SetSecret("secret")
+    j.SetTtl("1h")
+    j.SetIssuer("pgo")
+    j.Init()
+
+    token, e := j.Sign(map[string]interface{}{"sub": "u1"})
+    if e != nil {
+        t.Fatalf("sign: %s", e)
+    }
+
+    claims, e := j.Parse(token)
+    if e != nil || claims["sub"] != "u1" || claims["iss"] != "pgo" {
+        t.Fatalf("want claims of u1 issued by pgo, got %v %v", claims, e)
+    }
+
+    if exp, _ := claims["exp"].(float64); int64(exp)-time.Now().Unix() < 3500 {
+        t.Errorf("want exp of ttl, got %v", claims["exp"])
+    }
+
+    // claims wins over configured values
+    token, _ = j.Sign(map[string]interface{}{"iss": "other"})
+    if _, e := j.Parse(token); e != Util.ErrJwtIssuer {
+        t.Errorf("other issuer: want ErrJwtIssuer, got %v", e)
+    }
+
+    principal, e := j.Verifier()("bad token")
+    if e == nil || principal != nil {
+        t.Errorf("verifier: want error of bad token, got %v", principal)
+    }
+}
+
+func TestJwtRS256KeyFile(t *testing.T) {
+    key, e := rsa.GenerateKey(rand.Reader, 2048)
+    if e != nil {
+        t.Fatal(e)
+    }
+
+    der, _ := x509.MarshalPKCS8PrivateKey(key)
+    path := filepath.Join(t.TempDir(), "jwt.key")
+    ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+
+    j := newTestJwt("RS256")
+    j.SetPrivateKey(path)
+    j.Init()
+
+    token, e := j.Sign(map[string]interface{}{"sub": "u1"})
+    if e != nil {
+        t.Fatalf("sign: %s", e)
+    }
+
+    if claims, e := j.Parse(token); e != nil || claims["sub"] != "u1" {
+        t.Errorf("want sub u1, got %v %v", claims, e)
+    }
+
+    // token of other alg is rejected even if key would match
+    hs, _ := Util.JwtSign(map[string]interface{}{"sub": "admin"}, "HS256", []byte("secret"))
+    if _, e := j.Parse(hs); e != Util.ErrJwtAlgorithm {
+        t.Errorf("hs256 token: want ErrJwtAlgorithm, got %v", e)
+    }
+}
+
+func TestJwtInitInvalid(t *testing.T) {
+    for _, alg := range []string{"HS256", "RS256", "ES256"} {
+        func() {
+            defer func() {
+                if recover() == nil {
+                    t.Errorf("%s without key: want panic", alg)
+                }
+            }()
+            newTestJwt(alg).Init()
+        }()
+    }
+}
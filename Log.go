@@ -4,10 +4,15 @@ import (
     "bytes"
     "fmt"
     "os"
+    "os/signal"
     "path/filepath"
+    "reflect"
+    "regexp"
     "runtime"
     "strings"
     "sync"
+    "sync/atomic"
+    "syscall"
     "time"
 
     "github.com/pinguo/pgo/Util"
@@ -26,8 +31,22 @@ const (
     rotateNone   = 0
     rotateHourly = 1
     rotateDaily  = 2
+
+    bufferNone  = 0
+    bufferAll   = 1
+    bufferError = 2
+
+    defaultDedupWindow = 60 * time.Second
+    maxDedupEntries    = 10000
 )
 
+// variable parts of message ignored by dedup fingerprint: uuid, hex id, number
+var defaultDedupPatterns = []string{
+    `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+    `\b[0-9a-fA-F]{16,}\b`,
+    `\d+`,
+}
+
 func LevelToString(level int) string {
     switch level {
     case LevelNone:
@@ -95,14 +114,21 @@ type LogItem struct {
     LogId   string
     Trace   string
     Message string
+    batch   []*LogItem // buffered items of a request, dispatched together
 }
 
 // log component, configuration:
 // "log": {
 //     "levels": "ALL",
-//     "traceLevels": "DEBUG"
+//     "traceLevels": "DEBUG",
+//     "goroutineLevels": "NONE",
 //     "chanLen": 1000,
 //     "flushInterval": "60s",
+//     "reopenSignal": "SIGHUP",
+//     "buffer": "none",
+//     "dedupLevels": "ERROR,FATAL",
+//     "dedupWindow": "60s",
+//     "dedupPatterns": ["\\d+", "user=\\S+"],
 //     "targets": {
 //         "info": {
 //             "class": "@pgo/FileTarget",
@@ -118,14 +144,35 @@ type LogItem struct {
 //         }
 //     }
 // }
+//
+// reopenSignal(SIGHUP, SIGUSR1, SIGUSR2) makes targets reopen their
+// files on signal for external logrotate, use "rotate": "none" then.
+// traceLevels adds file:line of the call site(framework frames are
+// skipped), goroutineLevels adds goroutine id, both have runtime cost,
+// they can be changed per logger by Logger.SetTraceLevels and
+// Logger.SetGoroutineLevels.
+// buffer makes logs of a web request accumulate on the context and
+// dispatched together at request end, so lines of a request stay
+// adjacent with the access log, "all" keeps all entries, "error" drops
+// debug entries unless the request errored(error log or 5xx status).
+// dedupLevels collapses repeated messages of the levels within
+// dedupWindow, the first one is logged at once, the rest are counted
+// and logged as one "... (repeated N times in 60s)" line at the end of
+// window, messages are grouped with parts matching dedupPatterns
+// ignored, default patterns ignore numbers and ids, default levels none.
 type Dispatcher struct {
-    levels        int
-    chanLen       int
-    traceLevels   int
-    flushInterval time.Duration
-    targets       map[string]ITarget
-    msgChan       chan *LogItem
-    wg            sync.WaitGroup
+    levels          int
+    chanLen         int
+    traceLevels     int
+    goroutineLevels int
+    flushInterval   time.Duration
+    reopenSignal    os.Signal
+    buffer          int
+    dedup           *logDedup
+    targets         map[string]ITarget
+    msgChan         chan *LogItem
+    reopenChan      chan bool
+    wg              sync.WaitGroup
 }
 
 func (d *Dispatcher) Construct() {
@@ -133,10 +180,12 @@ func (d *Dispatcher) Construct() {
     d.chanLen = 1000
     d.traceLevels = LevelDebug
     d.flushInterval = 60 * time.Second
+    d.dedup = newLogDedup()
 }
 
 func (d *Dispatcher) Init() {
     d.msgChan = make(chan *LogItem, d.chanLen)
+    d.reopenChan = make(chan bool, 1)
 
     if len(d.targets) == 0 {
         // use console target as default
@@ -144,6 +193,10 @@ func (d *Dispatcher) Init() {
         d.targets["console"] = CreateObject("@pgo/ConsoleTarget").(ITarget)
     }
 
+    if d.reopenSignal != nil {
+        Go(d.watchSignal)
+    }
+
     // start loop
     d.wg.Add(1)
     go d.loop()
@@ -176,6 +229,17 @@ func (d *Dispatcher) SetTraceLevels(v interface{}) {
     }
 }
 
+// set log levels to add goroutine id, default none
+func (d *Dispatcher) SetGoroutineLevels(v interface{}) {
+    if _, ok := v.(string); ok {
+        d.goroutineLevels = parseLevels(v.(string))
+    } else if _, ok := v.(int); ok {
+        d.goroutineLevels = v.(int)
+    } else {
+        panic(fmt.Sprintf("Dispatcher: invalid goroutine levels: %v", v))
+    }
+}
+
 // set interval to flush log, default 60s
 func (d *Dispatcher) SetFlushInterval(v string) {
     if flushInterval, err := time.ParseDuration(v); err != nil {
@@ -185,6 +249,64 @@ func (d *Dispatcher) SetFlushInterval(v string) {
     }
 }
 
+// set signal to reopen targets, eg. SIGHUP, default none
+func (d *Dispatcher) SetReopenSignal(v string) {
+    switch strings.ToUpper(v) {
+    case "", "NONE":
+        d.reopenSignal = nil
+    case "SIGHUP", "HUP":
+        d.reopenSignal = syscall.SIGHUP
+    case "SIGUSR1", "USR1":
+        d.reopenSignal = syscall.SIGUSR1
+    case "SIGUSR2", "USR2":
+        d.reopenSignal = syscall.SIGUSR2
+    default:
+        panic("Dispatcher: invalid reopen signal: " + v)
+    }
+}
+
+// set buffer mode of request logs: none, all, error, default none
+func (d *Dispatcher) SetBuffer(v string) {
+    switch strings.ToLower(v) {
+    case "", "none":
+        d.buffer = bufferNone
+    case "all":
+        d.buffer = bufferAll
+    case "error":
+        d.buffer = bufferError
+    default:
+        panic("Dispatcher: invalid buffer mode: " + v)
+    }
+}
+
+// set log levels to dedup, default none
+func (d *Dispatcher) SetDedupLevels(v interface{}) {
+    if _, ok := v.(string); ok {
+        d.dedup.levels = parseLevels(v.(string))
+    } else if _, ok := v.(int); ok {
+        d.dedup.levels = v.(int)
+    } else {
+        panic(fmt.Sprintf("Dispatcher: invalid dedup levels: %v", v))
+    }
+}
+
+// set window to collapse repeated messages, default 60s
+func (d *Dispatcher) SetDedupWindow(v string) {
+    if window, err := time.ParseDuration(v); err != nil || window <= 0 {
+        panic(fmt.Sprintf("Dispatcher: invalid dedupWindow: %s", v))
+    } else {
+        d.dedup.window = window
+    }
+}
+
+// set regexps of variable parts ignored by dedup, default patterns are replaced
+func (d *Dispatcher) SetDedupPatterns(patterns []interface{}) {
+    d.dedup.patterns = make([]*regexp.Regexp, 0, len(patterns))
+    for _, v := range patterns {
+        d.dedup.patterns = append(d.dedup.patterns, regexp.MustCompile(Util.ToString(v)))
+    }
+}
+
 // set output target, default ConsoleTarget
 func (d *Dispatcher) SetTargets(targets map[string]interface{}) {
     d.targets = make(map[string]ITarget)
@@ -202,7 +324,18 @@ func (d *Dispatcher) SetTargets(targets map[string]interface{}) {
 
 // get a new logger with name and logId specified
 func (d *Dispatcher) GetLogger(name, logId string) *Logger {
-    return &Logger{name, logId, d}
+    return &Logger{name: name, logId: logId, dispatcher: d, traceLevels: d.traceLevels, goroutineLevels: d.goroutineLevels}
+}
+
+// get a new logger buffering items until FlushBuffer,
+// it's same as GetLogger if buffer mode is none
+func (d *Dispatcher) GetBufferedLogger(name, logId string) *Logger {
+    l := d.GetLogger(name, logId)
+    if d.buffer != bufferNone {
+        l.buffer = &logBuffer{}
+    }
+
+    return l
 }
 
 // get a new profiler
@@ -216,45 +349,80 @@ func (d *Dispatcher) Flush() {
     d.wg.Wait()
 }
 
-func (d *Dispatcher) isHandling(level int) bool {
-    return level&d.levels != 0
+// ask targets to reopen their files, reopen is done in the loop
+// goroutine, so it's serialized with log processing
+func (d *Dispatcher) Reopen() {
+    select {
+    case d.reopenChan <- true:
+    default: // reopen already pending
+    }
 }
 
-func (d *Dispatcher) addItem(item *LogItem) {
-    if d.traceLevels&item.Level != 0 {
-        if _, file, line, ok := runtime.Caller(3); ok {
-            if pos := strings.LastIndex(file, "src/"); pos > 0 {
-                file = file[pos+4:]
-            }
+func (d *Dispatcher) watchSignal() {
+    sig := make(chan os.Signal, 1)
+    signal.Notify(sig, d.reopenSignal)
 
-            item.Trace = fmt.Sprintf("[%s:%d]", file, line)
-        }
+    for range sig {
+        d.Reopen()
     }
+}
 
+func (d *Dispatcher) isHandling(level int) bool {
+    return level&d.levels != 0
+}
+
+func (d *Dispatcher) addItem(item *LogItem) {
     d.msgChan <- item
 }
 
+// dispatch loop, it's restarted on panic of target, the panic is
+// written to stderr because dispatcher itself may be broken
 func (d *Dispatcher) loop() {
+    defer func() {
+        if v := recover(); v != nil {
+            fmt.Fprintf(os.Stderr, "log dispatcher panic, %s, trace[%s]\n", Util.ToString(v), Util.PanicTrace(TraceMaxDepth, false))
+            go d.loop()
+        }
+    }()
+
     flushTimer := time.Tick(d.flushInterval)
 
+    var dedupTimer <-chan time.Time
+    if d.dedup.levels != LevelNone {
+        dedupTimer = time.Tick(d.dedup.window / 2)
+    }
+
     for {
         select {
         case item, ok := <-d.msgChan:
-            for _, target := range d.targets {
-                if ok {
-                    target.Process(item)
-                } else {
+            if !ok {
+                d.expireDedup(time.Now(), true)
+
+                for _, target := range d.targets {
                     target.Flush(true)
                 }
+                goto end
             }
 
-            if !ok {
-                goto end
+            if item.batch != nil {
+                for _, v := range item.batch {
+                    d.process(v)
+                }
+            } else {
+                d.process(item)
             }
+        case now := <-dedupTimer:
+            d.expireDedup(now, false)
         case <-flushTimer:
             for _, target := range d.targets {
                 target.Flush(false)
             }
+        case <-d.reopenChan:
+            for _, target := range d.targets {
+                if reopener, ok := target.(IReopener); ok {
+                    reopener.Reopen()
+                }
+            }
         }
     }
 
@@ -262,11 +430,64 @@ end:
     d.wg.Done()
 }
 
+// pass item to targets unless it's suppressed by dedup
+func (d *Dispatcher) process(item *LogItem) {
+    pass, summary := d.dedup.filter(item)
+    if summary != nil {
+        d.output(summary)
+    }
+
+    if pass {
+        d.output(item)
+    }
+}
+
+// write summaries of expired dedup windows, they are not filtered
+// again, so a summary is never counted as a repeat
+func (d *Dispatcher) expireDedup(now time.Time, force bool) {
+    for _, summary := range d.dedup.expire(now, force) {
+        d.output(summary)
+    }
+}
+
+// pass item to targets directly, eg. summary of dedup
+func (d *Dispatcher) output(item *LogItem) {
+    for _, target := range d.targets {
+        target.Process(item)
+    }
+}
+
 // logger component
 type Logger struct {
-    name       string
-    logId      string
-    dispatcher *Dispatcher
+    name            string
+    logId           string
+    dispatcher      *Dispatcher
+    traceLevels     int
+    goroutineLevels int
+    tap             atomic.Value // func(item *LogItem), set while logging from other goroutines
+    buffer          *logBuffer
+}
+
+// buffered items of a request, items logged after flush are
+// dispatched directly, eg. by background job of the request
+type logBuffer struct {
+    lock    sync.Mutex
+    items   []*LogItem
+    errored bool
+    flushed bool
+}
+
+func (b *logBuffer) add(item *LogItem) bool {
+    b.lock.Lock()
+    defer b.lock.Unlock()
+
+    if b.flushed {
+        return false
+    }
+
+    b.items = append(b.items, item)
+    b.errored = b.errored || item.Level >= LevelError
+    return true
 }
 
 func (l *Logger) log(level int, format string, v ...interface{}) {
@@ -287,9 +508,68 @@ func (l *Logger) log(level int, format string, v ...interface{}) {
         item.Message = fmt.Sprintf(format, v...)
     }
 
+    if l.traceLevels&level != 0 {
+        item.Trace = callerTrace()
+    }
+
+    if l.goroutineLevels&level != 0 {
+        item.Trace += fmt.Sprintf("[g:%d]", goroutineId())
+    }
+
+    if tap, _ := l.tap.Load().(func(item *LogItem)); tap != nil {
+        tap(item)
+    }
+
+    if l.buffer != nil && l.buffer.add(item) {
+        return
+    }
+
     l.dispatcher.addItem(item)
 }
 
+// dispatch buffered items together, errored forces debug items
+// to be kept in error buffer mode, no effect if not buffered
+func (l *Logger) FlushBuffer(errored bool) {
+    if l.buffer == nil {
+        return
+    }
+
+    l.buffer.lock.Lock()
+    items, keepDebug := l.buffer.items, errored || l.buffer.errored
+    l.buffer.items, l.buffer.flushed = nil, true
+    l.buffer.lock.Unlock()
+
+    if l.dispatcher.buffer == bufferError && !keepDebug {
+        kept := items[:0]
+        for _, item := range items {
+            if item.Level != LevelDebug {
+                kept = append(kept, item)
+            }
+        }
+        items = kept
+    }
+
+    if len(items) > 0 {
+        l.dispatcher.addItem(&LogItem{batch: items})
+    }
+}
+
+// set function to receive log items of this logger
+// besides the targets, eg. collect logs of a request
+func (l *Logger) SetTap(fn func(item *LogItem)) {
+    l.tap.Store(fn)
+}
+
+// set log levels to add file:line of call site for this logger
+func (l *Logger) SetTraceLevels(levels int) {
+    l.traceLevels = levels
+}
+
+// set log levels to add goroutine id for this logger
+func (l *Logger) SetGoroutineLevels(levels int) {
+    l.goroutineLevels = levels
+}
+
 func (l *Logger) GetName() string {
     return l.name
 }
@@ -322,12 +602,86 @@ func (l *Logger) Fatal(format string, v ...interface{}) {
     l.log(LevelFatal, format, v...)
 }
 
+// get file:line of the first caller outside framework, the
+// direct caller of logger is used if all frames are framework's
+func callerTrace() string {
+    pcs := make([]uintptr, 32)
+    n := runtime.Callers(4, pcs) // skip Callers, callerTrace, log, Debug...
+    frames := runtime.CallersFrames(pcs[:n])
+
+    var first *runtime.Frame
+    for {
+        frame, more := frames.Next()
+        if first == nil {
+            first = &frame
+        }
+
+        if !isFrameworkFunc(frame.Function) {
+            return formatFrame(frame)
+        }
+
+        if !more {
+            break
+        }
+    }
+
+    if first != nil {
+        return formatFrame(*first)
+    }
+
+    return ""
+}
+
+// package path of framework without vendor prefix
+var frameworkPkg = trimVendor(reflect.TypeOf(Logger{}).PkgPath())
+
+func trimVendor(name string) string {
+    if pos := strings.Index(name, VendorPrefix); pos != -1 {
+        name = name[pos+VendorLength:]
+    }
+    return name
+}
+
+func isFrameworkFunc(name string) bool {
+    name = trimVendor(name)
+
+    return strings.HasPrefix(name, frameworkPkg+".") || strings.HasPrefix(name, frameworkPkg+"/") ||
+        strings.HasPrefix(name, "runtime.") || strings.HasPrefix(name, "reflect.")
+}
+
+func formatFrame(frame runtime.Frame) string {
+    file := frame.File
+    if pos := strings.LastIndex(file, "src/"); pos > 0 {
+        file = file[pos+4:]
+    }
+
+    return fmt.Sprintf("[%s:%d]", file, frame.Line)
+}
+
+// parse goroutine id from stack header, eg. "goroutine 18 [running]:"
+func goroutineId() uint64 {
+    buf := make([]byte, 64)
+    buf = buf[:runtime.Stack(buf, false)]
+    buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+
+    id := uint64(0)
+    for _, c := range buf {
+        if c < '0' || c > '9' {
+            break
+        }
+        id = id*10 + uint64(c-'0')
+    }
+
+    return id
+}
+
 // profiler component
 type Profiler struct {
     pushLog      []string
     counting     map[string][2]int
     profile      map[string][2]int
     profileStack map[string]time.Time
+    profileTap   func(key string, elapse time.Duration)
 }
 
 func (p *Profiler) Reset() {
@@ -381,6 +735,10 @@ func (p *Profiler) ProfileStop(key string) {
 }
 
 func (p *Profiler) ProfileAdd(key string, elapse time.Duration) {
+    if p.profileTap != nil {
+        p.profileTap(key, elapse)
+    }
+
     if p.profile == nil {
         p.profile = make(map[string][2]int)
     }
@@ -392,6 +750,13 @@ func (p *Profiler) ProfileAdd(key string, elapse time.Duration) {
     p.profile[key] = v
 }
 
+// set tap to receive each profiled segment, eg. Db.Query and url of
+// http client, and timings added by Context.Timing, nil to remove,
+// tap may be called concurrently, it's kept by Reset.
+func (p *Profiler) SetProfileTap(tap func(key string, elapse time.Duration)) {
+    p.profileTap = tap
+}
+
 func (p *Profiler) GetPushLogString() string {
     if len(p.pushLog) == 0 {
         return ""
@@ -516,6 +881,7 @@ type FileTarget struct {
     rotate        int
 
     buffer        bytes.Buffer
+    handle        *os.File
     lastRotate    time.Time
     curBufferLine int
 }
@@ -531,18 +897,18 @@ func (f *FileTarget) Construct() {
 
 func (f *FileTarget) Init() {
     f.filePath = GetAlias(f.filePath)
-    h, e := os.OpenFile(f.filePath, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0644)
+    h, e := f.openFile()
     if e != nil {
         panic(fmt.Sprintf("FileTarget: failed to open file: %s, e: %s", f.filePath, e))
     }
 
-    defer h.Close()
-
     stat, e := h.Stat()
     if e != nil {
+        h.Close()
         panic(fmt.Sprintf("FileTarget: failed to stat file: %s, e: %s", f.filePath, e))
     }
 
+    f.handle = h
     f.curBufferLine = 0
     f.lastRotate = stat.ModTime()
     f.buffer.Grow(f.maxBufferByte)
@@ -603,23 +969,48 @@ func (f *FileTarget) Process(item *LogItem) {
 }
 
 func (f *FileTarget) Flush(final bool) {
-    // nothing to flush
-    if f.curBufferLine == 0 {
-        return
+    if f.curBufferLine > 0 {
+        // file is opened again if logs come after final flush
+        if f.handle == nil {
+            if h, e := f.openFile(); e != nil {
+                os.Stderr.WriteString(fmt.Sprintf("FileTarget: failed to open file: %s, e: %s\n", f.filePath, e))
+            } else {
+                f.handle = h
+            }
+        }
+
+        // write log buffer to file
+        if f.handle != nil {
+            f.buffer.WriteTo(f.handle)
+        }
+
+        f.buffer.Reset()
+        f.curBufferLine = 0
     }
 
-    // open log file to write
-    h, e := os.OpenFile(f.filePath, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0644)
-    if e != nil {
-        panic(fmt.Sprintf("FileTarget: failed to open file: %s, e: %s", f.filePath, e))
+    // close file after final flush
+    if final && f.handle != nil {
+        f.handle.Close()
+        f.handle = nil
     }
+}
 
-    defer h.Close()
+func (f *FileTarget) openFile() (*os.File, error) {
+    return os.OpenFile(f.filePath, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0644)
+}
 
-    // write log buffer to file
-    f.buffer.WriteTo(h)
-    f.buffer.Reset()
-    f.curBufferLine = 0
+// reopen file by path after it's moved by external logrotate,
+// buffered logs are flushed to the old file first, the old file
+// is kept if failed to open the new one
+func (f *FileTarget) Reopen() {
+    h, e := f.openFile()
+    if e != nil {
+        os.Stderr.WriteString(fmt.Sprintf("FileTarget: failed to reopen file: %s, e: %s\n", f.filePath, e))
+        return
+    }
+
+    f.Flush(true)
+    f.handle = h
 }
 
 func (f *FileTarget) shouldRotate(now time.Time) bool {
@@ -652,6 +1043,14 @@ func (f *FileTarget) rotateLog(now time.Time) {
     newPath := fmt.Sprintf("%s.%s", f.filePath, suffix)
     os.Rename(f.filePath, newPath)
 
+    // open new file to write
+    h, e := f.openFile()
+    if e != nil {
+        panic(fmt.Sprintf("FileTarget: failed to open file: %s, e: %s", f.filePath, e))
+    }
+
+    f.handle = h
+
     // update last rotate time
     f.lastRotate = now
 
@@ -0,0 +1,102 @@
+package pgo
+
+import (
+    "fmt"
+    "regexp"
+    "time"
+)
+
+// entry of a fingerprint in the current window
+type dedupEntry struct {
+    start time.Time
+    last  *LogItem
+    count int // repeats suppressed since start
+}
+
+// collapse repeated log items of dedup levels within window, items are
+// grouped by fingerprint: level, logger name and message with parts
+// matching patterns replaced, the first item of a window is passed
+// through, repeats are counted and reported by one summary item when
+// the window ends. it's only used by the dispatch loop, so no lock.
+type logDedup struct {
+    levels   int
+    window   time.Duration
+    patterns []*regexp.Regexp
+    entries  map[string]*dedupEntry
+}
+
+func newLogDedup() *logDedup {
+    d := &logDedup{window: defaultDedupWindow, entries: make(map[string]*dedupEntry)}
+    for _, p := range defaultDedupPatterns {
+        d.patterns = append(d.patterns, regexp.MustCompile(p))
+    }
+
+    return d
+}
+
+func (d *logDedup) fingerprint(item *LogItem) string {
+    msg := item.Message
+    for _, re := range d.patterns {
+        msg = re.ReplaceAllString(msg, "?")
+    }
+
+    return fmt.Sprintf("%d|%s|%s", item.Level, item.Name, msg)
+}
+
+// filter item, return false if it's a repeat to suppress, summary of
+// the expired window of the same fingerprint is returned if any
+func (d *logDedup) filter(item *LogItem) (pass bool, summary *LogItem) {
+    if item.Level&d.levels == 0 {
+        return true, nil
+    }
+
+    key := d.fingerprint(item)
+    if entry, ok := d.entries[key]; ok {
+        if item.When.Sub(entry.start) < d.window {
+            entry.count++
+            entry.last = item
+            return false, nil
+        }
+
+        summary = entry.summary(d.window)
+        delete(d.entries, key)
+    }
+
+    if len(d.entries) < maxDedupEntries {
+        d.entries[key] = &dedupEntry{start: item.When, last: item}
+    }
+
+    return true, summary
+}
+
+// remove entries of expired windows, return their summaries,
+// all entries are removed if force is true, eg. on flush
+func (d *logDedup) expire(now time.Time, force bool) []*LogItem {
+    summaries := make([]*LogItem, 0)
+    for key, entry := range d.entries {
+        if force || now.Sub(entry.start) >= d.window {
+            if summary := entry.summary(d.window); summary != nil {
+                summaries = append(summaries, summary)
+            }
+            delete(d.entries, key)
+        }
+    }
+
+    return summaries
+}
+
+// summary item of suppressed repeats, nil if no repeat
+func (e *dedupEntry) summary(window time.Duration) *LogItem {
+    if e.count == 0 {
+        return nil
+    }
+
+    item := *e.last
+    item.When = time.Now()
+    if elapsed := item.When.Sub(e.start); elapsed < window {
+        window = elapsed
+    }
+
+    item.Message = fmt.Sprintf("%s (repeated %d times in %s)", e.last.Message, e.count, window.Round(time.Second))
+    return &item
+}
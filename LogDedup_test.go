@@ -0,0 +1,88 @@
+package pgo
+
+import (
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+type recordTarget struct {
+    lock  sync.Mutex
+    items []*LogItem
+}
+
+func (t *recordTarget) Process(item *LogItem) {
+    t.lock.Lock()
+    defer t.lock.Unlock()
+
+    t.items = append(t.items, item)
+}
+
+func (t *recordTarget) Flush(final bool) {}
+
+func (t *recordTarget) messages() []string {
+    t.lock.Lock()
+    defer t.lock.Unlock()
+
+    msgs := make([]string, 0, len(t.items))
+    for _, item := range t.items {
+        msgs = append(msgs, item.Message)
+    }
+
+    return msgs
+}
+
+func TestLogDedupFilter(t *testing.T) {
+    d := newLogDedup()
+    d.levels = LevelError
+
+    now := time.Now()
+    item := func(msg string, when time.Time) *LogItem {
+        return &LogItem{When: when, Level: LevelError, Name: "app", Message: msg}
+    }
+
+    if pass, _ := d.filter(item("user 1 not found", now)); !pass {
+        t.Error("first item: want pass")
+    }
+
+    if pass, _ := d.filter(item("user 2 not found", now.Add(time.Second))); pass {
+        t.Error("repeat with different number: want suppressed")
+    }
+
+    if pass, _ := d.filter(&LogItem{When: now, Level: LevelInfo, Message: "user 1 not found"}); !pass {
+        t.Error("level not deduped: want pass")
+    }
+
+    pass, summary := d.filter(item("user 3 not found", now.Add(d.window+time.Second)))
+    if !pass || summary == nil || !strings.Contains(summary.Message, "repeated 1 times") {
+        t.Errorf("item of next window: want pass with summary, got %v %v", pass, summary)
+    }
+}
+
+func TestLogDedupSummaryNotFiltered(t *testing.T) {
+    target := &recordTarget{}
+    d := &Dispatcher{}
+    d.Construct()
+    d.SetDedupLevels(LevelError)
+    d.SetDedupPatterns([]interface{}{`\d+`})
+    d.targets = map[string]ITarget{"record": target}
+
+    now := time.Now()
+    for i := 0; i < 3; i++ {
+        d.process(&LogItem{When: now, Level: LevelError, Name: "app", Message: "db timeout"})
+    }
+
+    // an item collapsing with the summary must not suppress it
+    d.process(&LogItem{When: now, Level: LevelError, Name: "app", Message: "db timeout (repeated 9 times in 9s)"})
+    d.expireDedup(now.Add(d.dedup.window), false)
+
+    msgs := target.messages()
+    if len(msgs) != 3 || !strings.Contains(msgs[2], "db timeout (repeated 2 times") {
+        t.Errorf("want first items and summary, got %v", msgs)
+    }
+
+    if len(d.dedup.entries) != 0 {
+        t.Errorf("want no dedup entry after expire, got %d", len(d.dedup.entries))
+    }
+}
@@ -0,0 +1,85 @@
+package pgo
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+func newTestFileTarget(t *testing.T) *FileTarget {
+    f := &FileTarget{}
+    f.Construct()
+    f.SetFilePath(filepath.Join(t.TempDir(), "app.log"))
+    f.SetRotate("none")
+    f.Init()
+
+    return f
+}
+
+func readLog(t *testing.T, path string) string {
+    data, e := ioutil.ReadFile(path)
+    if e != nil {
+        t.Fatal(e)
+    }
+
+    return string(data)
+}
+
+func TestFileTargetFlushFinalReopensLazily(t *testing.T) {
+    f := newTestFileTarget(t)
+    f.Process(&LogItem{When: time.Now(), Level: LevelInfo, Message: "before final flush"})
+    f.Flush(true)
+
+    if f.handle != nil {
+        t.Fatal("after final flush: want handle closed and cleared")
+    }
+
+    // a second final flush on closed file is fine
+    f.Flush(true)
+
+    f.Process(&LogItem{When: time.Now(), Level: LevelInfo, Message: "after final flush"})
+    f.Flush(false)
+
+    content := readLog(t, f.filePath)
+    if !strings.Contains(content, "before final flush") || !strings.Contains(content, "after final flush") {
+        t.Errorf("want both logs in file, got %q", content)
+    }
+}
+
+func TestFileTargetReopen(t *testing.T) {
+    f := newTestFileTarget(t)
+    f.Process(&LogItem{When: time.Now(), Level: LevelInfo, Message: "old file"})
+
+    // external logrotate moves the file away
+    moved := f.filePath + ".1"
+    if e := os.Rename(f.filePath, moved); e != nil {
+        t.Fatal(e)
+    }
+
+    f.Reopen()
+    f.Process(&LogItem{When: time.Now(), Level: LevelInfo, Message: "new file"})
+    f.Flush(true)
+
+    if content := readLog(t, moved); !strings.Contains(content, "old file") || strings.Contains(content, "new file") {
+        t.Errorf("moved file: want only buffered old log, got %q", content)
+    }
+
+    if content := readLog(t, f.filePath); !strings.Contains(content, "new file") {
+        t.Errorf("reopened file: want new log, got %q", content)
+    }
+}
+
+func TestFileTargetLevels(t *testing.T) {
+    f := newTestFileTarget(t)
+    f.SetLevels("ERROR")
+    f.Process(&LogItem{When: time.Now(), Level: LevelInfo, Message: "info"})
+    f.Process(&LogItem{When: time.Now(), Level: LevelError, Message: "error"})
+    f.Flush(true)
+
+    if content := readLog(t, f.filePath); strings.Contains(content, "info") || !strings.Contains(content, "error") {
+        t.Errorf("want only error log, got %q", content)
+    }
+}
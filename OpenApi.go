@@ -0,0 +1,441 @@
+package pgo
+
+import (
+    "bytes"
+    "encoding/json"
+    "net/http"
+    "reflect"
+    "regexp"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/pinguo/pgo/Util"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// path param of pattern, index is position in captured groups
+type openApiParam struct {
+    name  string
+    index int
+    body  string // regexp of the group
+}
+
+// build OpenAPI 3 document of routes, each route is an operation of
+// its path and method(GET if no method), path params are converted from
+// groups of pattern, eg. "^/api/item/(?P<id>\d+)$" is "/api/item/{id}",
+// unnamed group is named by position, eg. "{param1}". metadata "summary",
+// "description" and "tags" annotate the operation, "request" and
+// "response" are struct values(or reflect.Type) to describe parameters
+// and response, eg.
+// router.AddHandler("^/api/item$", createItem, map[string]interface{}{
+//     "method":   "POST",
+//     "summary":  "create item",
+//     "tags":     []string{"item"},
+//     "request":  CreateItemForm{},
+//     "response": Item{},
+// })
+//
+// struct param of controller action is used as request if no "request",
+// fields of request are query params by tag "param" for GET, HEAD and
+// DELETE, otherwise they are request body of json(by tag "json") or form.
+func (r *Router) OpenApi() map[string]interface{} {
+    version := App.GetBuildInfo().Version
+    if len(version) == 0 {
+        version = "0.0.0"
+    }
+
+    paths := make(map[string]interface{})
+    rules := make(map[string]*routeRule)
+    for _, rule := range r.rules {
+        rules[rule.method+" "+rule.pattern] = rule
+    }
+
+    for _, info := range r.Routes() {
+        rule := rules[info.Method+" "+info.Pattern]
+        path, params := openApiPath(info.Pattern)
+        item, _ := paths[path].(map[string]interface{})
+        if item == nil {
+            item = make(map[string]interface{})
+            paths[path] = item
+        }
+
+        method := strings.ToLower(info.Method)
+        if len(method) == 0 {
+            method = "get"
+        }
+
+        if _, ok := item[method]; !ok {
+            item[method] = r.openApiOperation(info, rule, params)
+        }
+    }
+
+    return map[string]interface{}{
+        "openapi": "3.0.3",
+        "info":    map[string]interface{}{"title": App.GetName(), "version": version},
+        "paths":   paths,
+    }
+}
+
+// set path to serve OpenAPI document, eg. "/_openapi", json by
+// default, yaml if query param format is yaml, disabled if empty
+func (r *Router) SetOpenApiPath(path string) {
+    if len(path) > 0 {
+        path = Util.CleanPath(path)
+        r.AddHandler("^"+regexp.QuoteMeta(path)+"$", r.serveOpenApi, map[string]interface{}{
+            "method":  http.MethodGet,
+            "summary": "OpenAPI document of routes",
+        })
+    }
+}
+
+func (r *Router) serveOpenApi(ctx *Context) {
+    output, _ := json.Marshal(r.OpenApi())
+    if ctx.GetQuery("format", "") == "yaml" {
+        var doc interface{}
+        json.Unmarshal(output, &doc)
+
+        buf := &bytes.Buffer{}
+        writeYaml(buf, doc, 0)
+        ctx.SetHeader("Content-Type", "application/yaml; charset=utf-8")
+        ctx.End(http.StatusOK, buf.Bytes())
+        return
+    }
+
+    ctx.SetHeader("Content-Type", "application/json; charset=utf-8")
+    ctx.End(http.StatusOK, output)
+}
+
+// build operation of route
+func (r *Router) openApiOperation(info RouteInfo, rule *routeRule, params []openApiParam) map[string]interface{} {
+    var request, response reflect.Type
+    var args []reflect.Type // scalar params of action in order
+    if v, ok := info.Meta["request"]; ok {
+        request = typeOfMeta(v)
+    }
+
+    if v, ok := info.Meta["response"]; ok {
+        response = typeOfMeta(v)
+    }
+
+    if rule == nil || rule.handler == nil {
+        if at := actionTypeOf(info.Handler, info.Method); at != nil {
+            for i := 1; i < at.NumIn(); i++ {
+                if st := structParam(at.In(i)); st != nil {
+                    if request == nil {
+                        request = st
+                    }
+                } else {
+                    args = append(args, at.In(i))
+                }
+            }
+        }
+    }
+
+    op := map[string]interface{}{}
+    for _, key := range []string{"summary", "description"} {
+        if v, ok := info.Meta[key]; ok {
+            op[key] = Util.ToString(v)
+        }
+    }
+
+    switch tags := info.Meta["tags"].(type) {
+    case []string:
+        op["tags"] = tags
+    case []interface{}:
+        names := make([]string, 0, len(tags))
+        for _, tag := range tags {
+            names = append(names, Util.ToString(tag))
+        }
+        op["tags"] = names
+    }
+
+    parameters := make([]interface{}, 0)
+    inPath := make(map[string]bool)
+    for _, p := range params {
+        schema := map[string]interface{}{"type": "string"}
+        if p.index < len(args) {
+            schema = openApiSchema(args[p.index], "param", nil)
+        } else if p.body == `\d+` || p.body == "[0-9]+" {
+            schema = map[string]interface{}{"type": "integer"}
+        }
+
+        inPath[p.name] = true
+        parameters = append(parameters, map[string]interface{}{"name": p.name, "in": "path", "required": true, "schema": schema})
+    }
+
+    method := strings.ToUpper(info.Method)
+    if request != nil && request.Kind() == reflect.Struct {
+        if len(method) == 0 || method == http.MethodGet || method == http.MethodHead || method == http.MethodDelete {
+            for _, field := range openApiFields(request, "param") {
+                if !inPath[field.name] {
+                    parameters = append(parameters, map[string]interface{}{"name": field.name, "in": "query", "schema": openApiSchema(field.rt, "param", nil)})
+                }
+            }
+        } else {
+            op["requestBody"] = map[string]interface{}{
+                "content": map[string]interface{}{
+                    "application/json":                  map[string]interface{}{"schema": openApiSchema(request, "json", nil)},
+                    "application/x-www-form-urlencoded": map[string]interface{}{"schema": openApiSchema(request, "param", nil)},
+                },
+            }
+        }
+    }
+
+    if len(parameters) > 0 {
+        op["parameters"] = parameters
+    }
+
+    ok := map[string]interface{}{"description": "OK"}
+    if response != nil {
+        ok["content"] = map[string]interface{}{
+            "application/json": map[string]interface{}{"schema": openApiSchema(response, "json", nil)},
+        }
+    }
+
+    op["responses"] = map[string]interface{}{"200": ok}
+    return op
+}
+
+// get method type of controller action of route, nil if not found
+func actionTypeOf(route, method string) reflect.Type {
+    server := App.GetServer()
+    controllerId, actionId := server.findAction(route, strings.ToUpper(method))
+    if len(controllerId) == 0 {
+        return nil
+    }
+
+    item := App.GetContainer().items[server.getControllerName(controllerId)]
+    idx, ok := item.info.(map[string]int)[actionId]
+    if !ok {
+        return nil
+    }
+
+    return reflect.PtrTo(item.rt).Method(idx).Type
+}
+
+// get type of request or response metadata
+func typeOfMeta(v interface{}) reflect.Type {
+    rt, ok := v.(reflect.Type)
+    if !ok {
+        rt = reflect.TypeOf(v)
+    }
+
+    for rt != nil && rt.Kind() == reflect.Ptr {
+        rt = rt.Elem()
+    }
+
+    return rt
+}
+
+// convert pattern to OpenAPI path and get path params of it
+func openApiPath(pattern string) (string, []openApiParam) {
+    pattern = strings.TrimPrefix(pattern, "(?i)")
+    pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "^"), "$")
+    pattern = catchAllRe.ReplaceAllString(pattern, "/(?P<$1>.*)")
+
+    buf := make([]byte, 0, len(pattern))
+    params := make([]openApiParam, 0)
+    for i, captured := 0, 0; i < len(pattern); i++ {
+        c := pattern[i]
+        if c == '\\' && i+1 < len(pattern) {
+            i++
+            buf = append(buf, pattern[i])
+            continue
+        } else if c != '(' {
+            buf = append(buf, c)
+            continue
+        }
+
+        // find end of group, count capturing groups in it
+        start, depth, count := i, 0, 0
+        for ; i < len(pattern); i++ {
+            if pattern[i] == '\\' {
+                i++
+            } else if pattern[i] == '(' {
+                depth++
+                if !strings.HasPrefix(pattern[i:], "(?") || strings.HasPrefix(pattern[i:], "(?P<") {
+                    count++
+                }
+            } else if pattern[i] == ')' {
+                if depth--; depth == 0 {
+                    break
+                }
+            }
+        }
+
+        end := i + 1
+        if end > len(pattern) {
+            end = len(pattern)
+        }
+
+        // optional group is still a path param
+        group, index := pattern[start:end], captured
+        if i+1 < len(pattern) && pattern[i+1] == '?' {
+            i++
+        }
+
+        if captured += count; strings.HasPrefix(group, "(?") && !strings.HasPrefix(group, "(?P<") {
+            continue // non-capturing group is dropped
+        }
+
+        p := openApiParam{name: "param" + Util.ToString(index+1), index: index, body: strings.TrimSuffix(group[1:], ")")}
+        if strings.HasPrefix(group, "(?P<") {
+            pos := strings.IndexByte(group, '>')
+            p.name, p.body = group[4:pos], strings.TrimSuffix(group[pos+1:], ")")
+        }
+
+        params = append(params, p)
+        buf = append(buf, '{')
+        buf = append(buf, p.name...)
+        buf = append(buf, '}')
+    }
+
+    return string(buf), params
+}
+
+// field of struct schema
+type openApiField struct {
+    name string
+    rt   reflect.Type
+}
+
+// get exported fields of struct named by tag, embedded struct is flattened
+func openApiFields(rt reflect.Type, tag string) []openApiField {
+    fields := make([]openApiField, 0, rt.NumField())
+    for i := 0; i < rt.NumField(); i++ {
+        sf := rt.Field(i)
+        if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+            fields = append(fields, openApiFields(sf.Type, tag)...)
+            continue
+        } else if len(sf.PkgPath) > 0 {
+            continue // unexported
+        }
+
+        name := sf.Tag.Get(tag)
+        if pos := strings.IndexByte(name, ','); pos != -1 {
+            name = name[:pos]
+        }
+
+        if name == "-" {
+            continue
+        } else if len(name) > 0 {
+        } else if tag == "json" {
+            name = sf.Name
+        } else {
+            name = strings.ToLower(sf.Name[:1]) + sf.Name[1:]
+        }
+
+        fields = append(fields, openApiField{name, sf.Type})
+    }
+
+    return fields
+}
+
+// build json schema of type, field names of struct are from tag,
+// seen types are described as object to stop recursion
+func openApiSchema(rt reflect.Type, tag string, seen map[reflect.Type]bool) map[string]interface{} {
+    for rt.Kind() == reflect.Ptr {
+        rt = rt.Elem()
+    }
+
+    switch rt.Kind() {
+    case reflect.Bool:
+        return map[string]interface{}{"type": "boolean"}
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+        return map[string]interface{}{"type": "integer"}
+    case reflect.Int64:
+        return map[string]interface{}{"type": "integer", "format": "int64"}
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        return map[string]interface{}{"type": "integer", "minimum": 0}
+    case reflect.Float32, reflect.Float64:
+        return map[string]interface{}{"type": "number"}
+    case reflect.String:
+        return map[string]interface{}{"type": "string"}
+    case reflect.Slice, reflect.Array:
+        if rt.Elem().Kind() == reflect.Uint8 {
+            return map[string]interface{}{"type": "string", "format": "byte"}
+        }
+        return map[string]interface{}{"type": "array", "items": openApiSchema(rt.Elem(), tag, seen)}
+    case reflect.Map:
+        return map[string]interface{}{"type": "object", "additionalProperties": openApiSchema(rt.Elem(), tag, seen)}
+    case reflect.Struct:
+        if rt == timeType {
+            return map[string]interface{}{"type": "string", "format": "date-time"}
+        } else if seen[rt] {
+            return map[string]interface{}{"type": "object"}
+        }
+
+        if seen == nil {
+            seen = make(map[reflect.Type]bool)
+        }
+
+        seen[rt] = true
+        defer delete(seen, rt)
+
+        props := make(map[string]interface{})
+        for _, field := range openApiFields(rt, tag) {
+            props[field.name] = openApiSchema(field.rt, tag, seen)
+        }
+
+        return map[string]interface{}{"type": "object", "properties": props}
+    }
+
+    return map[string]interface{}{}
+}
+
+// write decoded json value as yaml, key:value of map is in order of key
+func writeYaml(buf *bytes.Buffer, v interface{}, indent int) {
+    pad := strings.Repeat("  ", indent)
+    switch val := v.(type) {
+    case map[string]interface{}:
+        keys := make([]string, 0, len(val))
+        for k := range val {
+            keys = append(keys, k)
+        }
+        sort.Strings(keys)
+
+        for _, k := range keys {
+            key, _ := json.Marshal(k)
+            buf.WriteString(pad)
+            buf.Write(key)
+            buf.WriteByte(':')
+            writeYamlValue(buf, val[k], indent+1)
+        }
+    case []interface{}:
+        for _, item := range val {
+            buf.WriteString(pad)
+            buf.WriteByte('-')
+            writeYamlValue(buf, item, indent+1)
+        }
+    default:
+        writeYamlValue(buf, v, indent)
+    }
+}
+
+// write value after key or dash, nested collection starts a new line
+func writeYamlValue(buf *bytes.Buffer, v interface{}, indent int) {
+    switch val := v.(type) {
+    case map[string]interface{}:
+        if len(val) == 0 {
+            buf.WriteString(" {}\n")
+            return
+        }
+    case []interface{}:
+        if len(val) == 0 {
+            buf.WriteString(" []\n")
+            return
+        }
+    default:
+        // json scalar is valid yaml flow scalar
+        output, _ := json.Marshal(val)
+        buf.WriteByte(' ')
+        buf.Write(output)
+        buf.WriteByte('\n')
+        return
+    }
+
+    buf.WriteByte('\n')
+    writeYaml(buf, v, indent)
+}
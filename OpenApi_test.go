@@ -0,0 +1,166 @@
+package pgo
+
+import (
+    "encoding/json"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+type openApiTestItem struct {
+    Id       int64     `json:"id"`
+    Name     string    `json:"name" param:"name"`
+    Tags     []string  `json:"tags,omitempty"`
+    Data     []byte    `json:"data"`
+    Created  time.Time `json:"created"`
+    Parent   *openApiTestItem
+    internal int
+    Skip     string `json:"-" param:"-"`
+}
+
+type OpenApiQuery struct {
+    Page int    `param:"page"`
+    Sort string `param:"sort"`
+}
+
+type OpenApiTestController struct {
+    Controller
+}
+
+func (c *OpenApiTestController) ActionView(id int, q OpenApiQuery) {}
+
+func TestOpenApiPath(t *testing.T) {
+    tests := []struct {
+        pattern, path string
+        names         []string
+    }{
+        {`^/api/item/(?P<id>\d+)$`, "/api/item/{id}", []string{"id"}},
+        {`^/user/(\d+)/post/(\w+)$`, "/user/{param1}/post/{param2}", []string{"param1", "param2"}},
+        {`^/api(?:/v1)?/user/(\d+)$`, "/api/user/{param1}", []string{"param1"}},
+        {`^/file/(\w+)\.json$`, "/file/{param1}.json", []string{"param1"}},
+        {`^/page(/\d+)?$`, "/page{param1}", []string{"param1"}},
+        {`(?i)^/Static$`, "/Static", nil},
+    }
+
+    for _, test := range tests {
+        path, params := openApiPath(test.pattern)
+        names := make([]string, 0)
+        for _, p := range params {
+            names = append(names, p.name)
+        }
+
+        if path != test.path || strings.Join(names, ",") != strings.Join(test.names, ",") {
+            t.Errorf("%s: want %s %v, got %s %v", test.pattern, test.path, test.names, path, names)
+        }
+    }
+}
+
+func TestOpenApiSchema(t *testing.T) {
+    schema := openApiSchema(reflect.TypeOf(&openApiTestItem{}), "json", nil)
+    props := schema["properties"].(map[string]interface{})
+
+    want := map[string]interface{}{
+        "id":      map[string]interface{}{"type": "integer", "format": "int64"},
+        "name":    map[string]interface{}{"type": "string"},
+        "tags":    map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+        "data":    map[string]interface{}{"type": "string", "format": "byte"},
+        "created": map[string]interface{}{"type": "string", "format": "date-time"},
+    }
+
+    for name, v := range want {
+        if !reflect.DeepEqual(props[name], v) {
+            t.Errorf("%s: want %v, got %v", name, v, props[name])
+        }
+    }
+
+    // recursive type stops at seen type
+    if parent := props["Parent"]; !reflect.DeepEqual(parent, map[string]interface{}{"type": "object"}) {
+        t.Errorf("want recursion described as object, got %v", parent)
+    }
+
+    for _, name := range []string{"internal", "Skip", "-"} {
+        if _, ok := props[name]; ok {
+            t.Errorf("want %s skipped", name)
+        }
+    }
+}
+
+func TestOpenApiDocument(t *testing.T) {
+    App.GetContainer().BindName("Controller/OpenApiTestController", &OpenApiTestController{})
+    defer delete(App.GetContainer().items, "Controller/OpenApiTestController")
+
+    r := newTestRouter()
+    r.AddHandler("^/oa/item$", func(ctx *Context) {}, map[string]interface{}{
+        "method":   "POST",
+        "summary":  "create item",
+        "tags":     []interface{}{"item"},
+        "request":  openApiTestItem{},
+        "response": &openApiTestItem{},
+    })
+    r.AddHandler("^/oa/item$", func(ctx *Context) {}, map[string]interface{}{
+        "method":  "GET",
+        "request": reflect.TypeOf(OpenApiQuery{}),
+    })
+    r.AddRoute(`^/oa/item/(\d+)$`, "/open-api-test/view", map[string]interface{}{"method": "GET"})
+
+    data, _ := json.Marshal(r.OpenApi())
+    var doc struct {
+        Openapi string
+        Paths   map[string]map[string]struct {
+            Summary     string
+            Tags        []string
+            Parameters  []map[string]interface{}
+            RequestBody map[string]interface{}
+            Responses   map[string]map[string]interface{}
+        }
+    }
+    json.Unmarshal(data, &doc)
+
+    create := doc.Paths["/oa/item"]["post"]
+    if doc.Openapi != "3.0.3" || create.Summary != "create item" || len(create.Tags) != 1 || create.RequestBody == nil {
+        t.Errorf("post: want operation with json body, got %+v", create)
+    }
+
+    if _, ok := create.Responses["200"]["content"]; !ok {
+        t.Errorf("post: want response schema, got %v", create.Responses)
+    }
+
+    list := doc.Paths["/oa/item"]["get"]
+    if len(list.Parameters) != 2 || list.Parameters[0]["name"] != "page" || list.Parameters[0]["in"] != "query" || list.RequestBody != nil {
+        t.Errorf("get: want query params of request, got %+v", list)
+    }
+
+    view, ok := doc.Paths["/oa/item/{param1}"]["get"]
+    if !ok || len(view.Parameters) != 3 {
+        t.Fatalf("controller action: want path and query params, got %+v", doc.Paths)
+    }
+
+    id := view.Parameters[0]
+    if id["in"] != "path" || id["required"] != true || id["schema"].(map[string]interface{})["type"] != "integer" {
+        t.Errorf("controller action: want integer path param of action arg, got %v", id)
+    }
+
+    if view.Parameters[1]["name"] != "page" || view.Parameters[2]["name"] != "sort" {
+        t.Errorf("controller action: want struct param as query, got %v", view.Parameters)
+    }
+}
+
+func TestOpenApiServeYaml(t *testing.T) {
+    r := newTestRouter()
+    r.AddHandler("^/oa/ping$", func(ctx *Context) {}, map[string]interface{}{"method": "GET", "tags": []string{}})
+
+    ctx, w := newRequestContext("GET", "/_openapi?format=yaml", nil)
+    r.serveOpenApi(ctx)
+
+    out := w.Body.String()
+    for _, line := range []string{`"openapi": "3.0.3"`, `  "/oa/ping":`, `    "get":`, `        "description": "OK"`, `      "tags": []`} {
+        if !strings.Contains(out, line+"\n") {
+            t.Errorf("want line %q in yaml, got\n%s", line, out)
+        }
+    }
+
+    if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/yaml") {
+        t.Errorf("want yaml content type, got %q", ct)
+    }
+}
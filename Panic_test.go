@@ -0,0 +1,86 @@
+package pgo
+
+import (
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "net/http/httptrace"
+    "sync"
+    "testing"
+)
+
+func TestServerPanicKeepAlive(t *testing.T) {
+    s := App.GetServer()
+    defer func(handlers []PanicHandler) { s.panicHandlers = handlers }(s.panicHandlers)
+
+    var lock sync.Mutex
+    var reported []interface{}
+    stacked := true
+    s.OnPanic(func(ctx *Context, v interface{}, stack []byte) { panic("broken panic handler") })
+    s.OnPanic(func(ctx *Context, v interface{}, stack []byte) {
+        lock.Lock()
+        defer lock.Unlock()
+        reported = append(reported, v)
+        stacked = stacked && len(stack) > 0
+    })
+
+    router := App.GetRouter()
+    router.AddHandler("^/panic/before$", func(ctx *Context) {
+        ctx.SetHeader("Content-Length", "100")
+        panic("before response")
+    }, nil)
+    router.AddHandler("^/panic/after$", func(ctx *Context) {
+        ctx.GetOutput().WriteHeader(http.StatusOK)
+        ctx.GetOutput().Write([]byte("partial"))
+        ctx.GetOutput().(http.Flusher).Flush()
+        panic("after response")
+    }, nil)
+    router.AddHandler("^/panic/ok$", func(ctx *Context) {
+        ctx.End(http.StatusOK, []byte("ok"))
+    }, nil)
+
+    svr := httptest.NewServer(s)
+    defer svr.Close()
+
+    get := func(path string) (int, bool, error) {
+        reused := false
+        trace := &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) { reused = info.Reused }}
+        r, _ := http.NewRequest("GET", svr.URL+path, nil)
+        resp, e := svr.Client().Do(r.WithContext(httptrace.WithClientTrace(r.Context(), trace)))
+        if e != nil {
+            return 0, reused, e
+        }
+        defer resp.Body.Close()
+
+        _, e = io.ReadAll(resp.Body)
+        return resp.StatusCode, reused, e
+    }
+
+    get("/panic/ok")
+    if code, reused, e := get("/panic/before"); code != http.StatusInternalServerError || !reused || e != nil {
+        t.Errorf("panic before response: want 500 on kept connection, got %d reused=%v %v", code, reused, e)
+    }
+
+    if code, reused, e := get("/panic/ok"); code != http.StatusOK || !reused || e != nil {
+        t.Errorf("after recovered panic: want connection kept alive, got %d reused=%v %v", code, reused, e)
+    }
+
+    if _, _, e := get("/panic/after"); e == nil {
+        t.Error("panic after response: want broken response")
+    }
+
+    if code, reused, _ := get("/panic/ok"); code != http.StatusOK || reused {
+        t.Errorf("after aborted response: want new connection, got %d reused=%v", code, reused)
+    }
+
+    lock.Lock()
+    defer lock.Unlock()
+
+    if len(reported) != 2 {
+        t.Fatalf("want panic reported once per request despite broken handler, got %v", reported)
+    }
+
+    if reported[0] != "before response" || reported[1] != "after response" || !stacked {
+        t.Errorf("want recovered values with stack, got %v, stacked=%v", reported, stacked)
+    }
+}
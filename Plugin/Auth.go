@@ -0,0 +1,56 @@
+package Plugin
+
+import (
+    "net/http"
+
+    "github.com/pinguo/pgo"
+    "github.com/pinguo/pgo/Util"
+)
+
+// Auth plugin, authenticates request by strategies registered to
+// auth component, usually attached to routes by "plugins" of rule,
+// configuration:
+// "plugins": [{
+//     "class": "@pgo/Plugin/Auth",
+//     "strategies": ["token", "key"],
+//     "optional": false
+// }]
+//
+// all registered strategies are tried if strategies is empty, failed
+// request gets 401 with WWW-Authenticate header of the strategies,
+// optional request without credentials continues with nil principal.
+type Auth struct {
+    strategies []string
+    optional   bool
+}
+
+func (a *Auth) SetStrategies(strategies []interface{}) {
+    a.strategies = make([]string, 0, len(strategies))
+    for _, v := range strategies {
+        a.strategies = append(a.strategies, Util.ToString(v))
+    }
+}
+
+func (a *Auth) SetOptional(v bool) {
+    a.optional = v
+}
+
+func (a *Auth) HandleRequest(ctx *pgo.Context) {
+    auth := pgo.App.GetAuth()
+    if _, e := auth.Authenticate(ctx, a.strategies...); e != nil {
+        if e == pgo.ErrNoCredentials {
+            if a.optional {
+                ctx.Next()
+                return
+            }
+            e = pgo.NewException(http.StatusUnauthorized, "authentication required")
+        }
+
+        ctx.PushLog("auth", "fail")
+        auth.Challenge(ctx, a.strategies...)
+        ctx.EndError(e)
+        return
+    }
+
+    ctx.Next()
+}
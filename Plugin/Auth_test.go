@@ -0,0 +1,44 @@
+package Plugin
+
+import (
+    "net/http"
+    "testing"
+
+    "github.com/pinguo/pgo"
+    "github.com/pinguo/pgo/Test"
+)
+
+func TestAuthHandleRequest(t *testing.T) {
+    pgo.App.GetAuth().AddStrategy("pluginTestToken", pgo.NewBearerAuth(pgo.StaticTokens(map[string]interface{}{"t1": "user1"})))
+
+    serve := func(optional bool, token string) (*Test.ResponseRecorder, interface{}) {
+        a := &Auth{}
+        a.SetStrategies([]interface{}{"pluginTestToken"})
+        a.SetOptional(optional)
+
+        ctx, w := Test.NewTestContext("GET", "/auth", nil)
+        if len(token) > 0 {
+            ctx.GetInput().Header.Set("Authorization", "Bearer "+token)
+        }
+
+        a.HandleRequest(ctx)
+        return w, ctx.GetPrincipal()
+    }
+
+    if w, principal := serve(false, "t1"); w.GetStatus() != http.StatusOK || principal != "user1" {
+        t.Errorf("valid token: want 200 with principal, got %d %v", w.GetStatus(), principal)
+    }
+
+    w, _ := serve(false, "")
+    if w.GetStatus() != http.StatusUnauthorized || len(w.GetHeader("WWW-Authenticate")) == 0 {
+        t.Errorf("no token: want 401 with challenge, got %d %q", w.GetStatus(), w.GetHeader("WWW-Authenticate"))
+    }
+
+    if w, principal := serve(true, ""); w.GetStatus() != http.StatusOK || principal != nil {
+        t.Errorf("optional without token: want 200 with nil principal, got %d %v", w.GetStatus(), principal)
+    }
+
+    if w, _ := serve(true, "wrong"); w.GetStatus() != http.StatusUnauthorized {
+        t.Errorf("optional with invalid token: want 401, got %d", w.GetStatus())
+    }
+}
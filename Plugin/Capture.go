@@ -0,0 +1,267 @@
+package Plugin
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "math/rand"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/pinguo/pgo"
+    "github.com/pinguo/pgo/Util"
+)
+
+// captured request record, a json line of capture file
+type CaptureRecord struct {
+    Time      int64               `json:"time"`
+    LogId     string              `json:"logId"`
+    Method    string              `json:"method"`
+    Uri       string              `json:"uri"`
+    Host      string              `json:"host"`
+    Header    map[string][]string `json:"header"`
+    Body      []byte              `json:"body"`
+    Truncated bool                `json:"truncated,omitempty"`
+}
+
+// Capture plugin, write sampled requests to file for replaying, configuration:
+// "plugins": [{
+//     "class": "@pgo/Plugin/Capture",
+//     "rate": 0.01,
+//     "file": "@runtime/capture.jsonl",
+//     "maxBody": 65536,
+//     "maxFileBytes": 104857600,
+//     "redactHeaders": ["Authorization", "Cookie", "X-Api-Key"]
+// }]
+//
+// rate is the sampling ratio in [0, 1], body is captured up to maxBody
+// bytes and marked truncated beyond that, capturing stops once file
+// reaches maxFileBytes, values of redactHeaders(merged with defaults)
+// are replaced with [REDACTED] and not sent by replay, captured file
+// is replayed by the replay command, eg.
+// ./bin/app --cmd replay --target http://127.0.0.1:8000 runtime/capture.jsonl
+type Capture struct {
+    rate          float64
+    file          string
+    maxBody       int
+    maxFileBytes  int64
+    redactHeaders map[string]bool
+
+    lock sync.Mutex
+    fd   *os.File
+    size int64
+}
+
+func (c *Capture) Construct() {
+    c.rate = defaultCaptureRate
+    c.file = pgo.GetAlias(defaultCaptureFile)
+    c.maxBody = defaultCaptureMaxBody
+    c.maxFileBytes = defaultCaptureMaxFile
+    c.redactHeaders = make(map[string]bool)
+    for _, h := range captureRedactHeaders {
+        c.redactHeaders[h] = true
+    }
+}
+
+func (c *Capture) SetRate(rate float64) {
+    if rate < 0 || rate > 1 {
+        panic(fmt.Sprintf("Capture: invalid rate %v", rate))
+    }
+
+    c.rate = rate
+}
+
+func (c *Capture) SetFile(file string) {
+    c.file = pgo.GetAlias(file)
+}
+
+func (c *Capture) SetMaxBody(maxBody int) {
+    if maxBody >= 0 {
+        c.maxBody = maxBody
+    }
+}
+
+func (c *Capture) SetMaxFileBytes(maxFileBytes int64) {
+    if maxFileBytes > 0 {
+        c.maxFileBytes = maxFileBytes
+    }
+}
+
+func (c *Capture) SetRedactHeaders(headers []interface{}) {
+    for _, v := range headers {
+        c.redactHeaders[http.CanonicalHeaderKey(Util.ToString(v))] = true
+    }
+}
+
+func (c *Capture) HandleRequest(ctx *pgo.Context) {
+    if rand.Float64() < c.rate && !c.isFull() {
+        c.write(c.record(ctx))
+    }
+
+    ctx.Next()
+}
+
+// build record of request, body is read up to maxBody and
+// put back before request body, so handlers see full body
+func (c *Capture) record(ctx *pgo.Context) *CaptureRecord {
+    r := ctx.GetInput()
+    rec := &CaptureRecord{
+        Time:   time.Now().Unix(),
+        LogId:  ctx.GetLogId(),
+        Method: r.Method,
+        Uri:    r.URL.RequestURI(),
+        Host:   r.Host,
+        Header: make(map[string][]string, len(r.Header)),
+    }
+
+    for k, v := range r.Header {
+        if c.redactHeaders[k] {
+            rec.Header[k] = []string{captureRedacted}
+        } else {
+            rec.Header[k] = v
+        }
+    }
+
+    if r.Body != nil && r.Body != http.NoBody {
+        body, _ := ioutil.ReadAll(io.LimitReader(r.Body, int64(c.maxBody)+1))
+        r.Body = &captureBody{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+        if len(body) > c.maxBody {
+            rec.Body, rec.Truncated = body[:c.maxBody], true
+        } else {
+            rec.Body = body
+        }
+    }
+
+    return rec
+}
+
+func (c *Capture) isFull() bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+
+    return c.size >= c.maxFileBytes
+}
+
+func (c *Capture) write(rec *CaptureRecord) {
+    line, _ := json.Marshal(rec)
+    line = append(line, '\n')
+
+    c.lock.Lock()
+    defer c.lock.Unlock()
+
+    if c.fd == nil {
+        os.MkdirAll(filepath.Dir(c.file), 0755)
+        fd, e := os.OpenFile(c.file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+        if e != nil {
+            pgo.GLogger().Error("Capture: failed to open file, %s", e)
+            return
+        }
+
+        info, _ := fd.Stat()
+        c.fd, c.size = fd, info.Size()
+    }
+
+    if c.size >= c.maxFileBytes {
+        return
+    }
+
+    n, e := c.fd.Write(line)
+    if c.size += int64(n); e != nil {
+        pgo.GLogger().Error("Capture: failed to write record, %s", e)
+    }
+}
+
+// restored request body, read captured prefix then the rest
+type captureBody struct {
+    io.Reader
+    io.Closer
+}
+
+// replay records of capture file against target, each record is sent
+// with its method, uri, headers and body, redacted headers are dropped,
+// result of each request is written to out, return count of requests
+// failed to send.
+func Replay(file, target string, out io.Writer) (int, error) {
+    fd, e := os.Open(file)
+    if e != nil {
+        return 0, e
+    }
+    defer fd.Close()
+
+    target = strings.TrimRight(target, "/")
+    client := &http.Client{Timeout: defaultReplayTimeout}
+    scanner := bufio.NewScanner(fd)
+    scanner.Buffer(make([]byte, 0, 64*1024), maxCaptureLine)
+
+    failed := 0
+    for scanner.Scan() {
+        if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+            continue
+        }
+
+        rec := &CaptureRecord{}
+        if e := json.Unmarshal(scanner.Bytes(), rec); e != nil {
+            return failed, fmt.Errorf("invalid record, %s", e)
+        }
+
+        req, e := http.NewRequest(rec.Method, target+rec.Uri, bytes.NewReader(rec.Body))
+        if e != nil {
+            return failed, e
+        }
+
+        for k, v := range rec.Header {
+            // content length is set by body, which may be truncated
+            if k == "Content-Length" || len(v) == 1 && v[0] == captureRedacted {
+                continue
+            }
+            req.Header[k] = v
+        }
+
+        if rec.Truncated {
+            req.Header.Set("X-Replay-Truncated", "1")
+        }
+        req.Header.Set("X-Replay-Log-Id", rec.LogId)
+
+        res, e := client.Do(req)
+        if e != nil {
+            failed++
+            fmt.Fprintf(out, "%s %s error: %s\n", rec.Method, rec.Uri, e)
+            continue
+        }
+
+        io.Copy(ioutil.Discard, res.Body)
+        res.Body.Close()
+        fmt.Fprintf(out, "%s %s %d\n", rec.Method, rec.Uri, res.StatusCode)
+    }
+
+    return failed, scanner.Err()
+}
+
+func replayCommand(ctx *pgo.CmdContext) int {
+    fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+    target := fs.String("target", defaultReplayTarget, "base url of target server")
+    if e := fs.Parse(ctx.Args); e != nil {
+        return 2
+    } else if fs.NArg() != 1 {
+        fmt.Fprintln(os.Stderr, "usage: --cmd replay [--target url] file")
+        return 2
+    }
+
+    failed, e := Replay(fs.Arg(0), *target, os.Stdout)
+    if e != nil {
+        fmt.Fprintf(os.Stderr, "replay: %s\n", e)
+        return 1
+    } else if failed > 0 {
+        return 1
+    }
+
+    return 0
+}
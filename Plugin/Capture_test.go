@@ -0,0 +1,147 @@
+package Plugin
+
+import (
+    "bytes"
+    "encoding/json"
+    "io/ioutil"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/pinguo/pgo"
+    "github.com/pinguo/pgo/Test"
+)
+
+func newCapture(t *testing.T) *Capture {
+    c := &Capture{}
+    c.Construct()
+    c.SetRate(1)
+    c.SetFile(filepath.Join(t.TempDir(), "capture.jsonl"))
+    t.Cleanup(func() {
+        if c.fd != nil {
+            c.fd.Close()
+        }
+    })
+
+    return c
+}
+
+func captureRequest(c *Capture, body string) string {
+    r := Test.NewRequest("POST", "/user/edit?id=1", strings.NewReader(body))
+    r.Header.Set("Authorization", "Bearer secret")
+    r.Header.Set("X-Custom", "1")
+    r.Header.Set(pgo.LogIdHeader, "capture-id")
+    ctx, _ := Test.NewRequestContext(r)
+    c.HandleRequest(ctx)
+
+    read, _ := ioutil.ReadAll(ctx.GetInput().Body)
+    return string(read)
+}
+
+func readCaptures(t *testing.T, c *Capture) []CaptureRecord {
+    data, e := ioutil.ReadFile(c.file)
+    if e != nil {
+        t.Fatal(e)
+    }
+
+    var records []CaptureRecord
+    for _, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+        rec := CaptureRecord{}
+        if e := json.Unmarshal(line, &rec); e != nil {
+            t.Fatal(e)
+        }
+        records = append(records, rec)
+    }
+
+    return records
+}
+
+func TestCaptureRecord(t *testing.T) {
+    c := newCapture(t)
+    c.SetMaxBody(5)
+
+    if body := captureRequest(c, "hello world"); body != "hello world" {
+        t.Errorf("want full body for handler, got %q", body)
+    }
+
+    records := readCaptures(t, c)
+    if len(records) != 1 {
+        t.Fatalf("want 1 record, got %d", len(records))
+    }
+
+    rec := records[0]
+    if rec.Method != "POST" || rec.Uri != "/user/edit?id=1" || rec.LogId != "capture-id" {
+        t.Errorf("want request line and log id, got %+v", rec)
+    }
+
+    if string(rec.Body) != "hello" || !rec.Truncated {
+        t.Errorf("want body truncated to maxBody, got %q %v", rec.Body, rec.Truncated)
+    }
+
+    if rec.Header["Authorization"][0] != captureRedacted || rec.Header["X-Custom"][0] != "1" {
+        t.Errorf("want sensitive header redacted only, got %v", rec.Header)
+    }
+}
+
+func TestCaptureMaxFileBytes(t *testing.T) {
+    c := newCapture(t)
+    c.SetMaxFileBytes(1)
+    captureRequest(c, "first")
+    captureRequest(c, "second")
+
+    if records := readCaptures(t, c); len(records) != 1 {
+        t.Errorf("file full: want capturing stopped, got %d records", len(records))
+    }
+
+    c = newCapture(t)
+    c.SetRate(0)
+    captureRequest(c, "body")
+    if c.fd != nil {
+        t.Error("rate 0: want nothing captured")
+    }
+}
+
+func TestCaptureReplay(t *testing.T) {
+    c := newCapture(t)
+    c.SetMaxBody(5)
+    captureRequest(c, "hello world")
+    captureRequest(c, "ok")
+
+    var got []*http.Request
+    var bodies []string
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        body, _ := ioutil.ReadAll(r.Body)
+        got, bodies = append(got, r), append(bodies, string(body))
+        w.WriteHeader(http.StatusAccepted)
+    }))
+    defer srv.Close()
+
+    out := &bytes.Buffer{}
+    failed, e := Replay(c.file, srv.URL+"/", out)
+    if e != nil || failed != 0 || len(got) != 2 {
+        t.Fatalf("want 2 requests replayed, got %d, failed %d, %v", len(got), failed, e)
+    }
+
+    r := got[0]
+    if r.Method != "POST" || r.URL.RequestURI() != "/user/edit?id=1" || bodies[0] != "hello" {
+        t.Errorf("want captured request, got %s %s %q", r.Method, r.URL.RequestURI(), bodies[0])
+    }
+
+    if len(r.Header.Get("Authorization")) > 0 || r.Header.Get("X-Custom") != "1" {
+        t.Errorf("want redacted header dropped, got %v", r.Header)
+    }
+
+    if r.Header.Get("X-Replay-Truncated") != "1" || r.Header.Get("X-Replay-Log-Id") != "capture-id" {
+        t.Errorf("want replay headers, got %v", r.Header)
+    }
+
+    if len(got[1].Header.Get("X-Replay-Truncated")) > 0 || bodies[1] != "ok" {
+        t.Errorf("untruncated record: want full body without mark, got %q", bodies[1])
+    }
+
+    if want := "POST /user/edit?id=1 202\n"; !strings.HasPrefix(out.String(), want) {
+        t.Errorf("want result lines, got %q", out.String())
+    }
+}
@@ -0,0 +1,247 @@
+package Plugin
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "html"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/pinguo/pgo"
+    "github.com/pinguo/pgo/Util"
+)
+
+// DebugToolbar plugin for development, collects route, duration, log
+// lines and timed segments of the request, segments are profiled by
+// instrumented components, eg. Db.Query and url of http client, and
+// Context.Timing, eg. routing, action and render, html response gets a
+// collapsible toolbar injected before </body>, json response gets
+// X-Debug-* headers, and a "_debug" field in the json object if
+// jsonField is true,
+// configuration:
+// "plugins": [{
+//     "class": "@pgo/Plugin/DebugToolbar",
+//     "envs": ["dev"],
+//     "jsonField": false
+// }]
+//
+// the plugin is inert if app env is not in envs(["dev"] by default),
+// response is not compressed while it's active, html and json responses
+// are buffered, others and responses flushed by handler, eg. streaming,
+// are passed through unchanged.
+type DebugToolbar struct {
+    envs      map[string]bool
+    jsonField bool
+    enabled   bool
+}
+
+func (d *DebugToolbar) Construct() {
+    d.envs = map[string]bool{"dev": true}
+}
+
+func (d *DebugToolbar) Init() {
+    d.enabled = d.envs[pgo.App.GetEnv()]
+}
+
+func (d *DebugToolbar) SetEnvs(envs []interface{}) {
+    d.envs = make(map[string]bool)
+    for _, v := range envs {
+        d.envs[Util.ToString(v)] = true
+    }
+}
+
+func (d *DebugToolbar) SetJsonField(v bool) {
+    d.jsonField = v
+}
+
+// check if toolbar is active in current env
+func (d *DebugToolbar) IsEnabled() bool {
+    return d.enabled
+}
+
+func (d *DebugToolbar) HandleRequest(ctx *pgo.Context) {
+    if !d.enabled || ctx.GetInput() == nil {
+        ctx.Next()
+        return
+    }
+
+    logs, lock := make([]string, 0), sync.Mutex{}
+    ctx.SetTap(func(item *pgo.LogItem) {
+        lock.Lock()
+        if len(logs) < maxDebugLogs {
+            logs = append(logs, fmt.Sprintf("%s [%s] %s", item.When.Format("15:04:05.000"), pgo.LevelToString(item.Level), item.Message))
+        }
+        lock.Unlock()
+    })
+
+    segments, index := make([]*debugSegment, 0), make(map[string]int)
+    ctx.SetProfileTap(func(key string, elapse time.Duration) {
+        lock.Lock()
+        if i, ok := index[key]; ok {
+            segments[i].Ms += float64(elapse) / float64(time.Millisecond)
+            segments[i].Count++
+        } else if len(segments) < maxDebugLogs {
+            index[key] = len(segments)
+            segments = append(segments, &debugSegment{key, float64(elapse) / float64(time.Millisecond), 1})
+        }
+        lock.Unlock()
+    })
+
+    // response is modified, so disable gzip of ctx.End
+    ctx.GetInput().Header.Del("Accept-Encoding")
+
+    w := &debugWriter{ResponseWriter: ctx.GetOutput()}
+    ctx.SetOutput(w)
+    defer func() {
+        ctx.SetOutput(w.ResponseWriter)
+        ctx.SetTap(nil)
+        ctx.SetProfileTap(nil)
+
+        lock.Lock()
+        defer lock.Unlock()
+        d.flush(ctx, w, logs, segments)
+    }()
+
+    ctx.Next()
+}
+
+func (d *DebugToolbar) flush(ctx *pgo.Context, w *debugWriter, logs []string, segments []*debugSegment) {
+    if !w.written || w.passthrough {
+        return
+    }
+
+    route := "-"
+    if id := ctx.GetControllerId(); len(id) > 0 {
+        route = id + "/" + ctx.GetActionId()
+    }
+
+    duration := strconv.Itoa(ctx.GetElapseMs()) + "ms"
+    body, ct := w.buf.Bytes(), w.Header().Get("Content-Type")
+
+    if strings.HasPrefix(ct, "text/html") {
+        body = d.injectToolbar(body, ctx, w.status, route, duration, logs, segments)
+    } else if strings.HasPrefix(ct, "application/json") {
+        w.Header().Set("X-Debug-Route", route)
+        w.Header().Set("X-Debug-Duration", duration)
+        w.Header().Set("X-Debug-Logs", strconv.Itoa(len(logs)))
+        if len(segments) > 0 {
+            w.Header().Set("X-Debug-Timing", segmentString(segments))
+        }
+
+        if d.jsonField {
+            var data map[string]interface{}
+            if e := json.Unmarshal(body, &data); e == nil && data != nil {
+                data["_debug"] = map[string]interface{}{"route": route, "duration": duration, "logs": logs, "timings": segments}
+                if output, e := json.Marshal(data); e == nil {
+                    body = output
+                }
+            }
+        }
+    }
+
+    w.Header().Del("Content-Length")
+    w.ResponseWriter.WriteHeader(w.status)
+    w.ResponseWriter.Write(body)
+}
+
+func (d *DebugToolbar) injectToolbar(body []byte, ctx *pgo.Context, status int, route, duration string, logs []string, segments []*debugSegment) []byte {
+    buf := &bytes.Buffer{}
+    buf.WriteString(`<div id="pgo-debug" style="position:fixed;bottom:0;left:0;right:0;z-index:99999;` +
+        `max-height:50%;overflow:auto;background:#222;color:#eee;font:12px monospace;padding:4px 8px">`)
+    fmt.Fprintf(buf, `<details><summary>%s %s | %s | %d %s | %d logs</summary>`,
+        html.EscapeString(ctx.GetMethod()), html.EscapeString(ctx.GetPath()),
+        html.EscapeString(route), status, duration, len(logs))
+    buf.WriteString(`<pre style="white-space:pre-wrap;margin:4px 0">`)
+    for _, seg := range segments {
+        fmt.Fprintf(buf, "%-40s %10.2fms x%d\n", html.EscapeString(seg.Name), seg.Ms, seg.Count)
+    }
+    if len(segments) > 0 {
+        buf.WriteByte('\n')
+    }
+    for _, line := range logs {
+        buf.WriteString(html.EscapeString(line))
+        buf.WriteByte('\n')
+    }
+    buf.WriteString(`</pre></details></div>`)
+
+    pos := bytes.LastIndex(bytes.ToLower(body), []byte("</body>"))
+    if pos == -1 {
+        return append(body, buf.Bytes()...)
+    }
+
+    output := make([]byte, 0, len(body)+buf.Len())
+    output = append(output, body[:pos]...)
+    output = append(output, buf.Bytes()...)
+    return append(output, body[pos:]...)
+}
+
+// timed segment of request, aggregated by name
+type debugSegment struct {
+    Name  string  `json:"name"`
+    Ms    float64 `json:"ms"`
+    Count int     `json:"count"`
+}
+
+// format segments as name=ms/count, eg. Db.Query=2.10ms/3 action=5.02ms/1
+func segmentString(segments []*debugSegment) string {
+    parts := make([]string, 0, len(segments))
+    for _, seg := range segments {
+        parts = append(parts, fmt.Sprintf("%s=%.2fms/%d", seg.Name, seg.Ms, seg.Count))
+    }
+
+    return strings.Join(parts, " ")
+}
+
+// response writer buffers html and json output until the request ends,
+// other content types and flushed output are passed through
+type debugWriter struct {
+    http.ResponseWriter
+    status      int
+    written     bool
+    passthrough bool
+    buf         bytes.Buffer
+}
+
+func (w *debugWriter) WriteHeader(status int) {
+    if w.written {
+        return
+    }
+
+    w.status, w.written = status, true
+    if ct := w.Header().Get("Content-Type"); len(ct) > 0 && !strings.HasPrefix(ct, "text/html") && !strings.HasPrefix(ct, "application/json") {
+        w.passthrough = true
+    }
+
+    if w.passthrough {
+        w.ResponseWriter.WriteHeader(status)
+    }
+}
+
+func (w *debugWriter) Write(b []byte) (int, error) {
+    w.WriteHeader(http.StatusOK)
+    if w.passthrough {
+        return w.ResponseWriter.Write(b)
+    }
+
+    return w.buf.Write(b)
+}
+
+// switch to passthrough, output buffered so far is sent first
+func (w *debugWriter) Flush() {
+    if !w.passthrough {
+        w.passthrough = true
+        if w.written {
+            w.ResponseWriter.WriteHeader(w.status)
+            w.ResponseWriter.Write(w.buf.Bytes())
+            w.buf.Reset()
+        }
+    }
+
+    if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+        flusher.Flush()
+    }
+}
@@ -0,0 +1,85 @@
+package Plugin
+
+import (
+    "net/http"
+    "strings"
+    "testing"
+
+    "github.com/pinguo/pgo"
+    "github.com/pinguo/pgo/Test"
+)
+
+func init() {
+    d := &DebugToolbar{}
+    d.Construct()
+    d.enabled = true
+    pgo.App.GetServer().Use("debugToolbar", d)
+
+    router := pgo.App.GetRouter()
+    router.AddHandler("^/debug/html$", func(ctx *pgo.Context) {
+        ctx.SetHeader("Content-Type", "text/html; charset=utf-8")
+        ctx.End(http.StatusOK, []byte("<html><body>page</body></html>"))
+    })
+
+    router.AddHandler("^/debug/stream$", func(ctx *pgo.Context) {
+        w := ctx.GetOutput()
+        w.Header().Set("Content-Type", "text/event-stream")
+        w.Write([]byte("data: 1\n\n"))
+        w.(http.Flusher).Flush()
+        w.Write([]byte("data: 2\n\n"))
+    })
+
+    router.AddHandler("^/debug/flushed$", func(ctx *pgo.Context) {
+        w := ctx.GetOutput()
+        w.Header().Set("Content-Type", "text/html")
+        w.Write([]byte("<html><body>chunk"))
+        w.(http.Flusher).Flush()
+        w.Write([]byte("</body></html>"))
+    })
+}
+
+func TestDebugToolbarInjectHtml(t *testing.T) {
+    w := Test.Serve(Test.NewRequest("GET", "/debug/html", nil))
+    body := w.GetString()
+    if !strings.Contains(body, `id="pgo-debug"`) || !strings.HasSuffix(body, "</body></html>") {
+        t.Errorf("html: want toolbar injected before </body>, got %q", body)
+    }
+}
+
+func TestDebugToolbarPassStream(t *testing.T) {
+    w := Test.Serve(Test.NewRequest("GET", "/debug/stream", nil))
+    if body := w.GetString(); body != "data: 1\n\ndata: 2\n\n" || !w.Flushed {
+        t.Errorf("event stream: want passed through and flushed, got %q flushed=%v", body, w.Flushed)
+    }
+}
+
+func TestDebugToolbarPassFlushed(t *testing.T) {
+    w := Test.Serve(Test.NewRequest("GET", "/debug/flushed", nil))
+    if body := w.GetString(); body != "<html><body>chunk</body></html>" || !w.Flushed {
+        t.Errorf("flushed html: want passed through unchanged, got %q flushed=%v", body, w.Flushed)
+    }
+}
+
+func TestDebugToolbarDisabledInProd(t *testing.T) {
+    if env := pgo.App.GetEnv(); env != "prod" {
+        t.Skipf("want prod env, got %s", env)
+    }
+
+    if d := pgo.CreateObject("@pgo/Plugin/DebugToolbar").(*DebugToolbar); d.IsEnabled() {
+        t.Error("want toolbar disabled by Init in prod")
+    }
+
+    // toolbar of server is forced on for other tests, use one built by Init
+    pgo.App.GetRouter().AddHandler("^/debug/prod$", func(ctx *pgo.Context) {
+        ctx.SetHeader("Content-Type", "text/html; charset=utf-8")
+        ctx.End(http.StatusOK, []byte("<html><body>page</body></html>"))
+    }, map[string]interface{}{
+        "skipPlugins": []interface{}{"debugToolbar"},
+        "plugins":     []interface{}{map[string]interface{}{"class": "@pgo/Plugin/DebugToolbar"}},
+    })
+
+    w := Test.Serve(Test.NewRequest("GET", "/debug/prod", nil))
+    if body := w.GetString(); body != "<html><body>page</body></html>" {
+        t.Errorf("prod: want page without toolbar, got %q", body)
+    }
+}
@@ -0,0 +1,220 @@
+package Plugin
+
+import (
+    "bytes"
+    "net/http"
+    "time"
+
+    "github.com/pinguo/pgo"
+    "github.com/pinguo/pgo/Util"
+)
+
+// stored response of idempotent request, exported for encoding
+type idempotentResponse struct {
+    Fingerprint string
+    Status      int
+    Header      http.Header
+    Body        []byte
+}
+
+// Idempotency plugin, the first response of request with Idempotency-Key
+// header is stored and replayed for retries with the same key and
+// request fingerprint(method, url and body), configuration:
+// "plugins": [{
+//     "class": "@pgo/Plugin/Idempotency",
+//     "ttl": "24h",
+//     "methods": ["POST", "PUT", "PATCH", "DELETE"],
+//     "header": "Idempotency-Key",
+//     "waitTimeout": "10s",
+//     "maxBytes": 1048576,
+//     "storage": "cache"
+// }]
+//
+// reusing a key with different fingerprint gets 409, duplicate arriving
+// while the first is in flight waits for its response up to waitTimeout,
+// then gets 409, 5xx response is not stored so the request can be retried,
+// storage is id of an ICache component shared by all server instances.
+// keys are scoped by client, the principal if authenticated(put Auth plugin
+// before this one), otherwise the trusted client ip, so clients reusing the
+// same key never get each other's response.
+type Idempotency struct {
+    ttl         time.Duration
+    methods     map[string]bool
+    header      string
+    waitTimeout time.Duration
+    maxBytes    int
+    storage     string
+}
+
+func (i *Idempotency) Construct() {
+    i.ttl = defaultIdempotentTtl
+    i.methods = map[string]bool{http.MethodPost: true, http.MethodPut: true, http.MethodPatch: true, http.MethodDelete: true}
+    i.header = "Idempotency-Key"
+    i.waitTimeout = defaultIdempotentWait
+    i.maxBytes = defaultCacheMaxBytes
+    i.storage = defaultCacheStorage
+}
+
+func (i *Idempotency) SetTtl(v string) {
+    if ttl, e := time.ParseDuration(v); e != nil {
+        panic("Idempotency: invalid ttl, " + e.Error())
+    } else {
+        i.ttl = ttl
+    }
+}
+
+func (i *Idempotency) SetMethods(methods []interface{}) {
+    i.methods = make(map[string]bool)
+    for _, v := range methods {
+        i.methods[Util.ToString(v)] = true
+    }
+}
+
+func (i *Idempotency) SetHeader(header string) {
+    i.header = header
+}
+
+func (i *Idempotency) SetWaitTimeout(v string) {
+    if d, e := time.ParseDuration(v); e != nil {
+        panic("Idempotency: invalid waitTimeout, " + e.Error())
+    } else {
+        i.waitTimeout = d
+    }
+}
+
+func (i *Idempotency) SetMaxBytes(maxBytes int) {
+    i.maxBytes = maxBytes
+}
+
+func (i *Idempotency) SetStorage(storage string) {
+    i.storage = storage
+}
+
+func (i *Idempotency) HandleRequest(ctx *pgo.Context) {
+    idemKey := ctx.GetHeader(i.header, "")
+    if len(idemKey) == 0 || !i.methods[ctx.GetMethod()] {
+        ctx.Next()
+        return
+    }
+
+    buf := &bytes.Buffer{}
+    buf.WriteString(ctx.GetMethod())
+    buf.WriteByte(' ')
+    buf.WriteString(ctx.GetInput().URL.RequestURI())
+    buf.WriteByte('\n')
+    buf.Write(ctx.GetRawBody())
+
+    cache := pgo.App.Get(i.storage).(pgo.ICache)
+    fp, key := Util.Md5String(buf.Bytes()), "pgo_idem_"+Util.Md5String(i.getScope(ctx)+"\n"+idemKey)
+    lockKey := key + "_lock"
+
+    if res := i.load(cache, key); res != nil {
+        i.replay(ctx, res, fp)
+        return
+    }
+
+    // acquire lock, duplicates wait for the first one
+    if !cache.Add(lockKey, fp, i.waitTimeout+pgo.App.GetServer().GetHttp().WriteTimeout) {
+        if v := cache.Get(lockKey); v != nil && v.Valid() && v.String() != fp {
+            i.conflict(ctx, "idempotency key is used by a different request")
+            return
+        }
+
+        if res := i.wait(ctx, cache, key); res != nil {
+            i.replay(ctx, res, fp)
+        } else {
+            i.conflict(ctx, "request with the same idempotency key is in progress")
+        }
+        return
+    }
+
+    defer cache.Del(lockKey)
+
+    w := &cacheWriter{ResponseWriter: ctx.GetOutput(), status: http.StatusOK, maxBytes: i.maxBytes}
+    ctx.SetOutput(w)
+    defer ctx.SetOutput(w.ResponseWriter)
+
+    ctx.Next()
+
+    if w.overflow || w.status >= http.StatusInternalServerError {
+        return
+    }
+
+    header := make(http.Header)
+    for k, v := range w.Header() {
+        switch k {
+        case pgo.LogIdHeader, "X-Cost-Time", "Set-Cookie":
+            continue
+        }
+        header[k] = v
+    }
+
+    cache.Set(key, pgo.Encode(&idempotentResponse{fp, w.status, header, w.buf.Bytes()}), i.ttl)
+}
+
+// get client scope of key, principal of authenticated request or client ip
+func (i *Idempotency) getScope(ctx *pgo.Context) string {
+    if principal := ctx.GetPrincipal(); principal != nil {
+        return "principal:" + Util.ToString(principal)
+    }
+
+    return "ip:" + ctx.GetTrustedClientIp()
+}
+
+func (i *Idempotency) load(cache pgo.ICache, key string) *idempotentResponse {
+    v := cache.Get(key)
+    if v == nil || !v.Valid() {
+        return nil
+    }
+
+    res := &idempotentResponse{}
+    if e := v.TryDecode(res); e != nil {
+        return nil
+    }
+
+    return res
+}
+
+// poll stored response until wait timeout or request is done
+func (i *Idempotency) wait(ctx *pgo.Context, cache pgo.ICache, key string) *idempotentResponse {
+    timer := time.NewTimer(i.waitTimeout)
+    ticker := time.NewTicker(defaultIdempotentPoll)
+    defer timer.Stop()
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ticker.C:
+            if res := i.load(cache, key); res != nil {
+                return res
+            }
+        case <-timer.C:
+            return nil
+        case <-ctx.GetDone():
+            return nil
+        }
+    }
+}
+
+func (i *Idempotency) replay(ctx *pgo.Context, res *idempotentResponse, fp string) {
+    if res.Fingerprint != fp {
+        i.conflict(ctx, "idempotency key is used by a different request")
+        return
+    }
+
+    w := ctx.GetOutput()
+    for k, v := range res.Header {
+        w.Header()[k] = v
+    }
+
+    ctx.PushLog("idempotent", "replay")
+    ctx.SetHeader("Idempotent-Replayed", "true")
+    ctx.SetHeader(pgo.LogIdHeader, ctx.GetLogId())
+    w.WriteHeader(res.Status)
+    w.Write(res.Body)
+}
+
+func (i *Idempotency) conflict(ctx *pgo.Context, msg string) {
+    ctx.PushLog("idempotent", "conflict")
+    ctx.EndError(pgo.NewException(http.StatusConflict, msg))
+}
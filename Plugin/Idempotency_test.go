@@ -0,0 +1,131 @@
+package Plugin
+
+import (
+    "fmt"
+    "net/http"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "github.com/pinguo/pgo"
+    "github.com/pinguo/pgo/Test"
+)
+
+// add route with Idempotency plugin, handler counts executions
+func newIdempotentRoute(handler func(ctx *pgo.Context)) (string, *int32) {
+    path := fmt.Sprintf("/idempotent/order%d", time.Now().UnixNano())
+    count := new(int32)
+    pgo.App.GetRouter().AddHandler("^"+path+"$", func(ctx *pgo.Context) {
+        n := atomic.AddInt32(count, 1)
+        if handler != nil {
+            handler(ctx)
+        }
+        ctx.End(http.StatusCreated, []byte(fmt.Sprintf("order %d", n)))
+    }, map[string]interface{}{
+        "plugins": []interface{}{map[string]interface{}{"class": "@pgo/Plugin/Idempotency", "waitTimeout": "2s"}},
+    })
+
+    return path, count
+}
+
+// key is prefixed by path, so stored responses of other tests are not hit
+func serveIdempotent(path, key, body, remoteAddr string) *Test.ResponseRecorder {
+    r := Test.NewRequest("POST", path, strings.NewReader(body))
+    r.Header.Set("Idempotency-Key", path+key)
+    if len(remoteAddr) > 0 {
+        r.RemoteAddr = remoteAddr
+    }
+
+    return Test.Serve(r)
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+    path, count := newIdempotentRoute(nil)
+
+    first := serveIdempotent(path, "k1", `{"amount":1}`, "")
+    if first.GetStatus() != http.StatusCreated || first.GetString() != "order 1" {
+        t.Fatalf("first: want 201 order 1, got %d %q", first.GetStatus(), first.GetString())
+    }
+
+    retry := serveIdempotent(path, "k1", `{"amount":1}`, "")
+    if retry.GetStatus() != http.StatusCreated || retry.GetString() != "order 1" || retry.GetHeader("Idempotent-Replayed") != "true" {
+        t.Errorf("retry: want stored response replayed, got %d %q %v", retry.GetStatus(), retry.GetString(), retry.Header())
+    }
+
+    if w := serveIdempotent(path, "k2", `{"amount":1}`, ""); w.GetString() != "order 2" || len(w.GetHeader("Idempotent-Replayed")) > 0 {
+        t.Errorf("other key: want executed, got %q", w.GetString())
+    }
+
+    if n := atomic.LoadInt32(count); n != 2 {
+        t.Errorf("want handler executed twice, got %d", n)
+    }
+}
+
+func TestIdempotencyConflict(t *testing.T) {
+    path, count := newIdempotentRoute(nil)
+
+    serveIdempotent(path, "k1", `{"amount":1}`, "")
+    if w := serveIdempotent(path, "k1", `{"amount":2}`, ""); w.GetStatus() != http.StatusConflict {
+        t.Errorf("different body: want 409, got %d %q", w.GetStatus(), w.GetString())
+    }
+
+    if n := atomic.LoadInt32(count); n != 1 {
+        t.Errorf("want conflicting request not executed, got %d executions", n)
+    }
+}
+
+func TestIdempotencyScopedByClient(t *testing.T) {
+    path, count := newIdempotentRoute(nil)
+
+    a := serveIdempotent(path, "k1", `{"amount":1}`, "198.51.100.1:4000")
+    b := serveIdempotent(path, "k1", `{"amount":1}`, "198.51.100.2:4000")
+    if a.GetString() != "order 1" || b.GetString() != "order 2" || len(b.GetHeader("Idempotent-Replayed")) > 0 {
+        t.Errorf("same key of other client: want executed, got %q %q", a.GetString(), b.GetString())
+    }
+
+    if n := atomic.LoadInt32(count); n != 2 {
+        t.Errorf("want handler executed for each client, got %d", n)
+    }
+}
+
+func TestIdempotencyConcurrentWait(t *testing.T) {
+    started, release := make(chan bool), make(chan bool)
+    path, count := newIdempotentRoute(func(ctx *pgo.Context) {
+        started <- true
+        <-release
+    })
+
+    var wg sync.WaitGroup
+    res := make([]*Test.ResponseRecorder, 2)
+    wg.Add(2)
+    go func() {
+        defer wg.Done()
+        res[0] = serveIdempotent(path, "k1", `{"amount":1}`, "")
+    }()
+
+    select {
+    case <-started:
+    case <-time.After(time.Second):
+        t.Fatal("first request: want handler executed")
+    }
+
+    go func() {
+        defer wg.Done()
+        res[1] = serveIdempotent(path, "k1", `{"amount":1}`, "")
+    }()
+
+    // let the duplicate wait on the lock before the first one finishes
+    time.Sleep(2 * defaultIdempotentPoll)
+    close(release)
+    wg.Wait()
+
+    if n := atomic.LoadInt32(count); n != 1 {
+        t.Fatalf("want handler executed once, got %d", n)
+    }
+
+    if res[1].GetStatus() != http.StatusCreated || res[1].GetString() != "order 1" || res[1].GetHeader("Idempotent-Replayed") != "true" {
+        t.Errorf("duplicate: want response of the first request, got %d %q", res[1].GetStatus(), res[1].GetString())
+    }
+}
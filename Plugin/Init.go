@@ -1,5 +1,57 @@
 package Plugin
 
+import (
+    "time"
+
+    "github.com/pinguo/pgo"
+    _ "github.com/pinguo/pgo/Client/Memory"
+)
+
+const (
+    defaultCacheTtl      = 60 * time.Second
+    defaultCacheMaxBytes = 1 << 20
+    defaultCacheStorage  = "cache"
+
+    defaultRetryAfter     = 300 * time.Second
+    defaultMaintenanceMsg = "Service under maintenance, please try again later"
+
+    defaultLimitRate = 100
+    maxLimitBuckets  = 100000
+
+    maxDebugLogs = 200
+
+    defaultIdempotentTtl  = 24 * time.Hour
+    defaultIdempotentWait = 10 * time.Second
+    defaultIdempotentPoll = 50 * time.Millisecond
+
+    defaultCaptureRate    = 0.01
+    defaultCaptureFile    = "@runtime/capture.jsonl"
+    defaultCaptureMaxBody = 64 << 10
+    defaultCaptureMaxFile = 100 << 20
+    defaultReplayTarget   = "http://127.0.0.1:8000"
+    defaultReplayTimeout  = 30 * time.Second
+    maxCaptureLine        = 16 << 20
+    captureRedacted       = "[REDACTED]"
+
+    defaultHsts = "max-age=31536000; includeSubDomains"
+)
+
+var (
+    captureRedactHeaders  = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+    defaultTrustedProxies = []interface{}{"127.0.0.0/8", "::1", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"}
+)
+
 func init() {
+    container := pgo.App.GetContainer()
+
+    container.Bind(&ResponseCache{})
+    container.Bind(&Maintenance{})
+    container.Bind(&RateLimit{})
+    container.Bind(&DebugToolbar{})
+    container.Bind(&Idempotency{})
+    container.Bind(&Auth{})
+    container.Bind(&Capture{})
+    container.Bind(&SecurityHeaders{})
 
+    pgo.App.RegisterCommand("replay", replayCommand, "replay requests captured by Capture plugin")
 }
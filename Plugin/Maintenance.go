@@ -0,0 +1,228 @@
+package Plugin
+
+import (
+    "net"
+    "net/http"
+    "os"
+    "regexp"
+    "strconv"
+    "sync/atomic"
+    "time"
+
+    "github.com/pinguo/pgo"
+    "github.com/pinguo/pgo/Util"
+)
+
+// Maintenance plugin, respond 503 to requests except allowed paths
+// and ips when maintenance mode is on, configuration:
+// "plugins": [{
+//     "class": "@pgo/Plugin/Maintenance",
+//     "enable": false,
+//     "file": "@runtime/maintenance",
+//     "env": "MAINTENANCE",
+//     "allowPaths": ["^/status$"],
+//     "allowIps": ["127.0.0.1", "10.0.0.0/8"],
+//     "retryAfter": "300s",
+//     "message": "Service under maintenance, please try again later",
+//     "view": "",
+//     "adminPath": "/_maintenance"
+// }]
+//
+// maintenance mode is on if enable is true or the file exists, so
+// it can be toggled by `touch` and `rm` without redeploy, env names
+// a variable read at startup, mode is on if it's 1 or true, so a
+// deploy can start instances in maintenance mode, readiness
+// endpoint of Health component is always allowed, client ip is
+// resolved by ctx.GetTrustedClientIp, so forwarded headers are only
+// honoured from trustedProxies of server, view is rendered
+// instead of message if set, adminPath accepts POST from allowIps
+// with enable=1 or enable=0 to toggle at runtime, empty to disable.
+type Maintenance struct {
+    enabled    int32
+    env        string
+    file       string
+    fileOn     int32
+    fileCheck  int64
+    allowPaths []*regexp.Regexp
+    allowIps   []*net.IPNet
+    retryAfter time.Duration
+    message    string
+    view       string
+    adminPath  string
+}
+
+func (m *Maintenance) Construct() {
+    m.allowPaths = make([]*regexp.Regexp, 0)
+    m.allowIps = make([]*net.IPNet, 0)
+    m.retryAfter = defaultRetryAfter
+    m.message = defaultMaintenanceMsg
+}
+
+func (m *Maintenance) Init() {
+    if len(m.env) == 0 {
+        return
+    }
+
+    if on, e := strconv.ParseBool(os.Getenv(m.env)); e == nil && on {
+        m.Enable()
+    }
+}
+
+func (m *Maintenance) SetEnable(enable bool) {
+    if enable {
+        m.Enable()
+    } else {
+        m.Disable()
+    }
+}
+
+func (m *Maintenance) SetFile(file string) {
+    m.file = pgo.GetAlias(file)
+}
+
+// set env variable to enable maintenance mode at startup
+func (m *Maintenance) SetEnv(name string) {
+    m.env = name
+}
+
+func (m *Maintenance) SetAllowPaths(paths []interface{}) {
+    for _, v := range paths {
+        m.allowPaths = append(m.allowPaths, regexp.MustCompile(Util.ToString(v)))
+    }
+}
+
+func (m *Maintenance) SetAllowIps(ips []interface{}) {
+    m.allowIps = append(m.allowIps, parseIpNets("Maintenance: invalid allow ip, ", ips)...)
+}
+
+func (m *Maintenance) SetRetryAfter(v string) {
+    if retryAfter, e := time.ParseDuration(v); e != nil {
+        panic("Maintenance: invalid retryAfter, " + e.Error())
+    } else {
+        m.retryAfter = retryAfter
+    }
+}
+
+func (m *Maintenance) SetMessage(message string) {
+    m.message = message
+}
+
+func (m *Maintenance) SetView(view string) {
+    m.view = view
+}
+
+func (m *Maintenance) SetAdminPath(path string) {
+    m.adminPath = Util.CleanPath(path)
+}
+
+// turn on maintenance mode
+func (m *Maintenance) Enable() {
+    atomic.StoreInt32(&m.enabled, 1)
+}
+
+// turn off maintenance mode, mode is still on if the file exists
+func (m *Maintenance) Disable() {
+    atomic.StoreInt32(&m.enabled, 0)
+}
+
+// check if maintenance mode is on
+func (m *Maintenance) IsEnabled() bool {
+    return atomic.LoadInt32(&m.enabled) == 1 || m.checkFile()
+}
+
+func (m *Maintenance) HandleRequest(ctx *pgo.Context) {
+    path := ctx.GetPath()
+    if len(m.adminPath) > 0 && path == m.adminPath {
+        m.handleAdmin(ctx)
+        return
+    }
+
+    if !m.IsEnabled() || m.isAllowed(ctx, path) {
+        ctx.Next()
+        return
+    }
+
+    output, contentType := []byte(m.message), "text/plain; charset=utf-8"
+    if len(m.view) > 0 {
+        output, contentType = pgo.App.GetView().Render(m.view, nil), "text/html; charset=utf-8"
+    }
+
+    ctx.PushLog("maintenance", 1)
+    ctx.SetHeader("Content-Type", contentType)
+    ctx.SetHeader("Retry-After", strconv.Itoa(int(m.retryAfter/time.Second)))
+    ctx.End(http.StatusServiceUnavailable, output)
+}
+
+func (m *Maintenance) handleAdmin(ctx *pgo.Context) {
+    if ctx.GetMethod() != http.MethodPost || !m.isAllowedIp(remoteIp(ctx)) {
+        ctx.End(http.StatusForbidden, []byte(http.StatusText(http.StatusForbidden)))
+        return
+    }
+
+    m.SetEnable(Util.ToBool(ctx.GetParam("enable", "0")))
+    ctx.End(http.StatusOK, []byte("maintenance: "+strconv.FormatBool(m.IsEnabled())))
+}
+
+func (m *Maintenance) isAllowed(ctx *pgo.Context, path string) bool {
+    if path == pgo.App.GetHealth().GetPath() {
+        return true
+    }
+
+    for _, re := range m.allowPaths {
+        if re.MatchString(path) {
+            return true
+        }
+    }
+
+    return m.isAllowedIp(ctx.GetTrustedClientIp())
+}
+
+func (m *Maintenance) isAllowedIp(ip string) bool {
+    if parsed := net.ParseIP(ip); parsed != nil {
+        for _, ipNet := range m.allowIps {
+            if ipNet.Contains(parsed) {
+                return true
+            }
+        }
+    }
+
+    return false
+}
+
+// check file presence at most once per second
+func (m *Maintenance) checkFile() bool {
+    if len(m.file) == 0 {
+        return false
+    }
+
+    now, last := time.Now().Unix(), atomic.LoadInt64(&m.fileCheck)
+    if now != last && atomic.CompareAndSwapInt64(&m.fileCheck, last, now) {
+        if _, e := os.Stat(m.file); e == nil {
+            atomic.StoreInt32(&m.fileOn, 1)
+        } else {
+            atomic.StoreInt32(&m.fileOn, 0)
+        }
+    }
+
+    return atomic.LoadInt32(&m.fileOn) == 1
+}
+
+// parse ips or cidrs, single ip is converted to cidr of itself
+func parseIpNets(errPrefix string, ips []interface{}) []*net.IPNet {
+    ipNets := make([]*net.IPNet, 0, len(ips))
+    for _, v := range ips {
+        ipNet, e := Util.ParseIpNet(Util.ToString(v))
+        if e != nil {
+            panic(errPrefix + e.Error())
+        }
+
+        ipNets = append(ipNets, ipNet)
+    }
+
+    return ipNets
+}
+
+// ip of the connection peer, headers are ignored
+func remoteIp(ctx *pgo.Context) string {
+    return ctx.GetRemoteIp()
+}
@@ -0,0 +1,124 @@
+package Plugin
+
+import (
+    "io/ioutil"
+    "net/http"
+    "os"
+    "path/filepath"
+    "testing"
+
+    "github.com/pinguo/pgo"
+    "github.com/pinguo/pgo/Test"
+)
+
+func newMaintenance() *Maintenance {
+    m := &Maintenance{}
+    m.Construct()
+    return m
+}
+
+func serveMaintenance(m *Maintenance, method, target, remoteAddr string) *Test.ResponseRecorder {
+    r := Test.NewRequest(method, target, nil)
+    r.RemoteAddr = remoteAddr
+    ctx, w := Test.NewRequestContext(r)
+    m.HandleRequest(ctx)
+    return w
+}
+
+func TestMaintenanceEnv(t *testing.T) {
+    defer os.Unsetenv("PGO_TEST_MAINTENANCE")
+    for value, want := range map[string]bool{"1": true, "true": true, "TRUE": true, "0": false, "false": false, "on": false, "": false} {
+        os.Setenv("PGO_TEST_MAINTENANCE", value)
+        m := newMaintenance()
+        m.SetEnv("PGO_TEST_MAINTENANCE")
+        m.Init()
+
+        if got := m.IsEnabled(); got != want {
+            t.Errorf("env %q: want enabled %v, got %v", value, want, got)
+        }
+    }
+
+    // env is read at startup only, runtime toggle still works
+    os.Setenv("PGO_TEST_MAINTENANCE", "1")
+    m := newMaintenance()
+    m.SetEnv("PGO_TEST_MAINTENANCE")
+    m.Init()
+    m.Disable()
+    if m.IsEnabled() {
+        t.Error("disabled after env enabled: want off")
+    }
+}
+
+func TestMaintenanceFile(t *testing.T) {
+    m := newMaintenance()
+    m.SetFile(filepath.Join(t.TempDir(), "maintenance"))
+    if m.IsEnabled() {
+        t.Fatal("without file: want off")
+    }
+
+    ioutil.WriteFile(m.file, nil, 0644)
+    m.fileCheck = 0 // skip the once per second cache
+    if !m.IsEnabled() {
+        t.Error("file exists: want on")
+    }
+
+    os.Remove(m.file)
+    m.fileCheck = 0
+    if m.IsEnabled() {
+        t.Error("file removed: want off")
+    }
+}
+
+func TestMaintenanceHandleRequest(t *testing.T) {
+    m := newMaintenance()
+    m.SetAllowPaths([]interface{}{"^/status$"})
+    m.SetAllowIps([]interface{}{"10.0.0.0/8"})
+    m.SetRetryAfter("60s")
+    m.SetMessage("down")
+
+    if w := serveMaintenance(m, "GET", "/user", "203.0.113.9:5000"); w.GetStatus() != http.StatusOK {
+        t.Errorf("mode off: want request passed, got %d", w.GetStatus())
+    }
+
+    m.Enable()
+    w := serveMaintenance(m, "GET", "/user", "203.0.113.9:5000")
+    if w.GetStatus() != http.StatusServiceUnavailable || w.GetHeader("Retry-After") != "60" || w.GetString() != "down" {
+        t.Errorf("mode on: want 503 with Retry-After and message, got %d %q %q", w.GetStatus(), w.GetHeader("Retry-After"), w.GetString())
+    }
+
+    allowed := map[string]string{
+        "/status":                    "203.0.113.9:5000",
+        pgo.App.GetHealth().GetPath(): "203.0.113.9:5000",
+        "/user":                      "10.1.2.3:5000",
+    }
+
+    for path, remoteAddr := range allowed {
+        if w := serveMaintenance(m, "GET", path, remoteAddr); w.GetStatus() != http.StatusOK {
+            t.Errorf("%s from %s: want allowed, got %d", path, remoteAddr, w.GetStatus())
+        }
+    }
+}
+
+func TestMaintenanceAdmin(t *testing.T) {
+    m := newMaintenance()
+    m.SetAllowIps([]interface{}{"127.0.0.1"})
+    m.SetAdminPath("/_maintenance")
+
+    if w := serveMaintenance(m, "POST", "/_maintenance?enable=1", "203.0.113.9:5000"); w.GetStatus() != http.StatusForbidden || m.IsEnabled() {
+        t.Errorf("other ip: want 403, got %d", w.GetStatus())
+    }
+
+    if w := serveMaintenance(m, "GET", "/_maintenance?enable=1", "127.0.0.1:5000"); w.GetStatus() != http.StatusForbidden || m.IsEnabled() {
+        t.Errorf("GET: want 403, got %d", w.GetStatus())
+    }
+
+    w := serveMaintenance(m, "POST", "/_maintenance?enable=1", "127.0.0.1:5000")
+    if w.GetStatus() != http.StatusOK || w.GetString() != "maintenance: true" || !m.IsEnabled() {
+        t.Errorf("enable: want mode on, got %d %q", w.GetStatus(), w.GetString())
+    }
+
+    // admin path is served in maintenance mode
+    if w := serveMaintenance(m, "POST", "/_maintenance?enable=0", "127.0.0.1:5000"); w.GetString() != "maintenance: false" || m.IsEnabled() {
+        t.Errorf("disable: want mode off, got %d %q", w.GetStatus(), w.GetString())
+    }
+}
@@ -0,0 +1,232 @@
+package Plugin
+
+import (
+    "container/list"
+    "fmt"
+    "math"
+    "net/http"
+    "regexp"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/pinguo/pgo"
+    "github.com/pinguo/pgo/Util"
+)
+
+// RateLimit plugin, limit requests per client key and respond 429
+// if limit exceeded, configuration:
+// "plugins": [{
+//     "class": "@pgo/Plugin/RateLimit",
+//     "rate": 100,
+//     "window": "1s",
+//     "burst": 100,
+//     "keyBy": "ip",
+//     "routes": ["^/api/"],
+//     "store": "",
+//     "onStoreError": "local"
+// }]
+//
+// rate is max requests per window, keyBy is "ip" or "header:<name>",
+// ip is resolved by ctx.GetTrustedClientIp, so forwarded headers are
+// only honoured from trustedProxies of server, empty routes limits all
+// paths. without store each instance limits by local token bucket,
+// the least recently used bucket is evicted beyond 100000 keys, store is id of a shared ICache component(eg.
+// a Redis Client), then limit is enforced cluster-wide by sliding
+// window counter, onStoreError decides what to do if store fails,
+// "local" uses local token bucket, "allow" or "deny" the request.
+type RateLimit struct {
+    rate         int
+    window       time.Duration
+    burst        int
+    keyBy        string
+    routes       []*regexp.Regexp
+    store        string
+    onStoreError string
+
+    lock    sync.Mutex
+    buckets map[string]*list.Element // element of lru
+    lru     *list.List               // *limitBucket, most recent first
+}
+
+// local token bucket of key
+type limitBucket struct {
+    key    string
+    bucket *Util.TokenBucket
+}
+
+func (r *RateLimit) Construct() {
+    r.rate = defaultLimitRate
+    r.window = time.Second
+    r.keyBy = "ip"
+    r.routes = make([]*regexp.Regexp, 0)
+    r.onStoreError = "local"
+    r.buckets = make(map[string]*list.Element)
+    r.lru = list.New()
+}
+
+func (r *RateLimit) SetRate(rate int) {
+    if rate <= 0 {
+        panic(fmt.Sprintf("RateLimit: invalid rate, %d", rate))
+    }
+
+    r.rate = rate
+}
+
+func (r *RateLimit) SetWindow(v string) {
+    if window, e := time.ParseDuration(v); e != nil || window < time.Second {
+        panic(fmt.Sprintf("RateLimit: invalid window, %s", v))
+    } else {
+        r.window = window
+    }
+}
+
+func (r *RateLimit) SetBurst(burst int) {
+    r.burst = burst
+}
+
+func (r *RateLimit) SetKeyBy(keyBy string) {
+    if keyBy != "ip" && !strings.HasPrefix(keyBy, "header:") {
+        panic("RateLimit: invalid keyBy, " + keyBy)
+    }
+
+    r.keyBy = keyBy
+}
+
+func (r *RateLimit) SetRoutes(routes []interface{}) {
+    for _, v := range routes {
+        r.routes = append(r.routes, regexp.MustCompile(Util.ToString(v)))
+    }
+}
+
+func (r *RateLimit) SetStore(store string) {
+    r.store = store
+}
+
+func (r *RateLimit) SetOnStoreError(v string) {
+    if v != "local" && v != "allow" && v != "deny" {
+        panic("RateLimit: invalid onStoreError, " + v)
+    }
+
+    r.onStoreError = v
+}
+
+func (r *RateLimit) HandleRequest(ctx *pgo.Context) {
+    if !r.matchRoute(ctx.GetPath()) {
+        ctx.Next()
+        return
+    }
+
+    if ok, wait := r.Allow(r.getKey(ctx)); !ok {
+        ctx.PushLog("rateLimit", 1)
+        ctx.SetHeader("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+        ctx.End(http.StatusTooManyRequests, []byte(http.StatusText(http.StatusTooManyRequests)))
+        return
+    }
+
+    ctx.Next()
+}
+
+// check if request of key is allowed, wait is the suggested
+// duration before retry if not allowed
+func (r *RateLimit) Allow(key string) (ok bool, wait time.Duration) {
+    if len(r.store) == 0 {
+        return r.allowLocal(key)
+    }
+
+    defer func() {
+        if v := recover(); v != nil {
+            pgo.GLogger().Warn("RateLimit: store %s failed, %s", r.store, Util.ToString(v))
+            switch r.onStoreError {
+            case "allow":
+                ok, wait = true, 0
+            case "deny":
+                ok, wait = false, r.window
+            default:
+                ok, wait = r.allowLocal(key)
+            }
+        }
+    }()
+
+    return r.allowStore(key)
+}
+
+// local token bucket, refill rate per window
+func (r *RateLimit) allowLocal(key string) (bool, time.Duration) {
+    r.lock.Lock()
+    var bucket *Util.TokenBucket
+    if elem, ok := r.buckets[key]; ok {
+        r.lru.MoveToFront(elem)
+        bucket = elem.Value.(*limitBucket).bucket
+    } else {
+        if r.lru.Len() >= maxLimitBuckets {
+            oldest := r.lru.Back()
+            r.lru.Remove(oldest)
+            delete(r.buckets, oldest.Value.(*limitBucket).key)
+        }
+
+        burst := r.burst
+        if burst <= 0 {
+            burst = r.rate
+        }
+
+        bucket = Util.NewTokenBucket(float64(r.rate)/r.window.Seconds(), burst)
+        r.buckets[key] = r.lru.PushFront(&limitBucket{key, bucket})
+    }
+    r.lock.Unlock()
+
+    if bucket.Allow() {
+        return true, 0
+    }
+
+    return false, time.Duration(float64(time.Second) * r.window.Seconds() / float64(r.rate))
+}
+
+// sliding window counter in store, the previous window
+// is weighted by its overlap with the sliding window
+func (r *RateLimit) allowStore(key string) (bool, time.Duration) {
+    cache := pgo.App.Get(r.store).(pgo.ICache)
+    now := time.Now()
+    index := now.UnixNano() / int64(r.window)
+    elapsed := float64(now.UnixNano()%int64(r.window)) / float64(r.window)
+
+    prefix := "pgo_rl_" + Util.Md5String(key) + "_"
+    curKey, prevKey := prefix+strconv.FormatInt(index, 10), prefix+strconv.FormatInt(index-1, 10)
+
+    prev := 0
+    if v := cache.Get(prevKey); v != nil && v.Valid() {
+        prev = v.Int()
+    }
+
+    cache.Add(curKey, 0, 2*r.window)
+    cur := cache.Incr(curKey, 1)
+
+    if float64(prev)*(1-elapsed)+float64(cur) > float64(r.rate) {
+        return false, time.Duration((1 - elapsed) * float64(r.window))
+    }
+
+    return true, 0
+}
+
+func (r *RateLimit) getKey(ctx *pgo.Context) string {
+    if strings.HasPrefix(r.keyBy, "header:") {
+        return r.keyBy + ":" + ctx.GetHeader(r.keyBy[7:], "")
+    }
+
+    return "ip:" + ctx.GetTrustedClientIp()
+}
+
+func (r *RateLimit) matchRoute(path string) bool {
+    if len(r.routes) == 0 {
+        return true
+    }
+
+    for _, re := range r.routes {
+        if re.MatchString(path) {
+            return true
+        }
+    }
+
+    return false
+}
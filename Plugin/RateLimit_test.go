@@ -0,0 +1,127 @@
+package Plugin
+
+import (
+    "net/http"
+    "strconv"
+    "testing"
+
+    _ "github.com/pinguo/pgo/Client/Memory"
+    "github.com/pinguo/pgo/Test"
+)
+
+func newRateLimit(rate int, store string) *RateLimit {
+    r := &RateLimit{}
+    r.Construct()
+    r.SetRate(rate)
+    r.SetStore(store)
+    return r
+}
+
+func TestRateLimitLocal(t *testing.T) {
+    r := newRateLimit(2, "")
+    for i := 0; i < 2; i++ {
+        if ok, _ := r.Allow("a"); !ok {
+            t.Fatalf("request %d of a: want allowed", i)
+        }
+    }
+
+    if ok, wait := r.Allow("a"); ok || wait <= 0 {
+        t.Errorf("exceeded: want denied with wait, got %v %s", ok, wait)
+    }
+
+    if ok, _ := r.Allow("b"); !ok {
+        t.Error("other key: want own bucket")
+    }
+}
+
+func TestRateLimitEvictLru(t *testing.T) {
+    r := newRateLimit(1, "")
+    r.Allow("hot")
+    for i := 1; i < maxLimitBuckets; i++ {
+        r.Allow("cold" + strconv.Itoa(i))
+        if i == maxLimitBuckets/2 {
+            r.Allow("hot") // used recently, so it's not evicted
+        }
+    }
+
+    r.Allow("new")
+    if _, ok := r.buckets["hot"]; !ok || r.lru.Len() != maxLimitBuckets {
+        t.Errorf("want least recently used bucket evicted only, got hot=%v len=%d", ok, r.lru.Len())
+    }
+
+    if _, ok := r.buckets["cold1"]; ok {
+        t.Error("want the least recently used bucket evicted")
+    }
+}
+
+func TestRateLimitStore(t *testing.T) {
+    // instances sharing store limit together
+    r1, r2 := newRateLimit(3, "cache"), newRateLimit(3, "cache")
+    key := "store"
+
+    allowed := 0
+    for i := 0; i < 4; i++ {
+        for _, r := range []*RateLimit{r1, r2} {
+            if ok, _ := r.Allow(key); ok {
+                allowed++
+            }
+        }
+    }
+
+    // fewer only if the loop crosses a window and the previous counts
+    if allowed > 3 || allowed == 0 {
+        t.Errorf("want at most 3 allowed cluster-wide, got %d", allowed)
+    }
+}
+
+func TestRateLimitStoreError(t *testing.T) {
+    for onError, want := range map[string]bool{"allow": true, "deny": false, "local": true} {
+        r := newRateLimit(1, "missingStore")
+        r.SetOnStoreError(onError)
+        if ok, _ := r.Allow("key"); ok != want {
+            t.Errorf("%s: want %v, got %v", onError, want, ok)
+        }
+    }
+}
+
+func TestRateLimitHandleRequest(t *testing.T) {
+    r := newRateLimit(1, "")
+    r.SetKeyBy("header:X-Api-Key")
+    r.SetRoutes([]interface{}{"^/api/"})
+
+    serve := func(path, apiKey string) *Test.ResponseRecorder {
+        ctx, w := Test.NewTestContext("GET", path, nil)
+        ctx.GetInput().Header.Set("X-Api-Key", apiKey)
+        r.HandleRequest(ctx)
+        return w
+    }
+
+    serve("/api/user", "k1")
+    if w := serve("/api/user", "k1"); w.GetStatus() != http.StatusTooManyRequests || w.GetHeader("Retry-After") != "1" {
+        t.Errorf("exceeded: want 429 with Retry-After 1, got %d %q", w.GetStatus(), w.GetHeader("Retry-After"))
+    }
+
+    if w := serve("/api/user", "k2"); w.GetStatus() != http.StatusOK {
+        t.Errorf("other api key: want 200, got %d", w.GetStatus())
+    }
+
+    if w := serve("/page", "k1"); w.GetStatus() != http.StatusOK {
+        t.Errorf("unmatched route: want 200, got %d", w.GetStatus())
+    }
+}
+
+func TestRateLimitIgnoreSpoofedIp(t *testing.T) {
+    r := newRateLimit(1, "")
+    serve := func(forwarded string) int {
+        ctx, w := Test.NewTestContext("GET", "/api/user", nil)
+        ctx.GetInput().RemoteAddr = "203.0.113.9:5000"
+        ctx.GetInput().Header.Set("X-Forwarded-For", forwarded)
+        r.HandleRequest(ctx)
+        return w.GetStatus()
+    }
+
+    serve("10.0.0.1")
+    if code := serve("10.0.0.2"); code != http.StatusTooManyRequests {
+        t.Errorf("rotated X-Forwarded-For from untrusted peer: want 429, got %d", code)
+    }
+}
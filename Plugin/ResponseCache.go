@@ -0,0 +1,217 @@
+package Plugin
+
+import (
+    "bytes"
+    "net/http"
+    "regexp"
+    "strings"
+    "time"
+
+    "github.com/pinguo/pgo"
+    "github.com/pinguo/pgo/Util"
+)
+
+// cached response, exported for encoding
+type cachedResponse struct {
+    Status int
+    Header http.Header
+    Body   []byte
+}
+
+// ResponseCache plugin, cache full response(status, headers, body)
+// of GET/HEAD requests keyed by method, url and vary headers,
+// hits are served without running the controller action,
+// configuration:
+// "plugins": [{
+//     "class": "@pgo/Plugin/ResponseCache",
+//     "ttl": "60s",
+//     "routes": ["^/api/hot/"],
+//     "varyHeaders": ["Accept", "Accept-Encoding"],
+//     "statuses": [200],
+//     "maxBytes": 1048576,
+//     "storage": "cache"
+// }]
+//
+// empty routes caches all paths, request with no-cache header skips
+// cache lookup, response with no-store or private is not stored,
+// storage is id of an ICache component, "cache" core component by default.
+type ResponseCache struct {
+    ttl         time.Duration
+    routes      []*regexp.Regexp
+    varyHeaders []string
+    statuses    map[int]bool
+    maxBytes    int
+    storage     string
+}
+
+func (r *ResponseCache) Construct() {
+    r.ttl = defaultCacheTtl
+    r.routes = make([]*regexp.Regexp, 0)
+    r.varyHeaders = []string{"Accept", "Accept-Encoding"}
+    r.statuses = map[int]bool{http.StatusOK: true}
+    r.maxBytes = defaultCacheMaxBytes
+    r.storage = defaultCacheStorage
+}
+
+func (r *ResponseCache) SetTtl(v string) {
+    if ttl, e := time.ParseDuration(v); e != nil {
+        panic("ResponseCache: invalid ttl, " + e.Error())
+    } else {
+        r.ttl = ttl
+    }
+}
+
+func (r *ResponseCache) SetRoutes(routes []interface{}) {
+    for _, v := range routes {
+        r.routes = append(r.routes, regexp.MustCompile(Util.ToString(v)))
+    }
+}
+
+func (r *ResponseCache) SetVaryHeaders(headers []interface{}) {
+    r.varyHeaders = make([]string, 0, len(headers))
+    for _, v := range headers {
+        r.varyHeaders = append(r.varyHeaders, http.CanonicalHeaderKey(Util.ToString(v)))
+    }
+}
+
+func (r *ResponseCache) SetStatuses(statuses []interface{}) {
+    r.statuses = make(map[int]bool)
+    for _, v := range statuses {
+        r.statuses[Util.ToInt(v)] = true
+    }
+}
+
+func (r *ResponseCache) SetMaxBytes(maxBytes int) {
+    r.maxBytes = maxBytes
+}
+
+func (r *ResponseCache) SetStorage(storage string) {
+    r.storage = storage
+}
+
+func (r *ResponseCache) HandleRequest(ctx *pgo.Context) {
+    method := ctx.GetMethod()
+    if (method != http.MethodGet && method != http.MethodHead) || !r.matchRoute(ctx.GetPath()) {
+        ctx.Next()
+        return
+    }
+
+    key := r.buildKey(ctx)
+    if !hasNoCache(ctx.GetHeader("Cache-Control", "")) && !hasNoCache(ctx.GetHeader("Pragma", "")) {
+        if res := r.load(key); res != nil {
+            w := ctx.GetOutput()
+            for k, v := range res.Header {
+                w.Header()[k] = v
+            }
+
+            ctx.PushLog("cache", "hit")
+            ctx.SetHeader("X-Cache", "HIT")
+            ctx.SetHeader(pgo.LogIdHeader, ctx.GetLogId())
+            w.WriteHeader(res.Status)
+            w.Write(res.Body)
+            return
+        }
+    }
+
+    ctx.SetHeader("X-Cache", "MISS")
+    w := &cacheWriter{ResponseWriter: ctx.GetOutput(), status: http.StatusOK, maxBytes: r.maxBytes}
+    ctx.SetOutput(w)
+    defer ctx.SetOutput(w.ResponseWriter)
+
+    ctx.Next()
+
+    cc := strings.ToLower(w.Header().Get("Cache-Control"))
+    if w.overflow || !r.statuses[w.status] || strings.Contains(cc, "no-store") || strings.Contains(cc, "private") {
+        return
+    }
+
+    header := make(http.Header)
+    for k, v := range w.Header() {
+        switch k {
+        case pgo.LogIdHeader, "X-Cost-Time", "X-Cache", "Set-Cookie":
+            continue
+        }
+        header[k] = v
+    }
+
+    r.save(key, &cachedResponse{w.status, header, w.buf.Bytes()})
+}
+
+func (r *ResponseCache) matchRoute(path string) bool {
+    if len(r.routes) == 0 {
+        return true
+    }
+
+    for _, re := range r.routes {
+        if re.MatchString(path) {
+            return true
+        }
+    }
+
+    return false
+}
+
+func (r *ResponseCache) buildKey(ctx *pgo.Context) string {
+    buf := &bytes.Buffer{}
+    buf.WriteString(ctx.GetMethod())
+    buf.WriteByte(' ')
+    buf.WriteString(ctx.GetInput().URL.RequestURI())
+
+    for _, h := range r.varyHeaders {
+        buf.WriteByte('\n')
+        buf.WriteString(h)
+        buf.WriteByte(':')
+        buf.WriteString(ctx.GetHeader(h, ""))
+    }
+
+    return "pgo_rc_" + Util.Md5String(buf.Bytes())
+}
+
+func (r *ResponseCache) load(key string) *cachedResponse {
+    v := pgo.App.Get(r.storage).(pgo.ICache).Get(key)
+    if v == nil || !v.Valid() {
+        return nil
+    }
+
+    res := &cachedResponse{}
+    if e := v.TryDecode(res); e != nil {
+        return nil
+    }
+
+    return res
+}
+
+func (r *ResponseCache) save(key string, res *cachedResponse) {
+    pgo.App.Get(r.storage).(pgo.ICache).Set(key, pgo.Encode(res), r.ttl)
+}
+
+func hasNoCache(v string) bool {
+    return strings.Contains(strings.ToLower(v), "no-cache")
+}
+
+// response writer copies body for caching
+type cacheWriter struct {
+    http.ResponseWriter
+    status   int
+    maxBytes int
+    overflow bool
+    buf      bytes.Buffer
+}
+
+func (w *cacheWriter) WriteHeader(status int) {
+    w.status = status
+    w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cacheWriter) Write(b []byte) (int, error) {
+    if !w.overflow {
+        if w.buf.Len()+len(b) > w.maxBytes {
+            w.overflow = true
+            w.buf.Reset()
+        } else {
+            w.buf.Write(b)
+        }
+    }
+
+    return w.ResponseWriter.Write(b)
+}
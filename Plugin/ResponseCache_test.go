@@ -0,0 +1,85 @@
+package Plugin
+
+import (
+    "fmt"
+    "net/http"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "github.com/pinguo/pgo"
+    "github.com/pinguo/pgo/Test"
+)
+
+// add route with ResponseCache plugin, body is the execution count
+func newCachedRoute(ttl string) (string, *int32) {
+    path := fmt.Sprintf("/cached/item%d", time.Now().UnixNano())
+    count := new(int32)
+    pgo.App.GetRouter().AddHandler("^"+path+"$", func(ctx *pgo.Context) {
+        n := atomic.AddInt32(count, 1)
+        ctx.SetHeader("X-Item", "1")
+        ctx.End(http.StatusOK, []byte(fmt.Sprintf("item %d", n)))
+    }, map[string]interface{}{
+        "plugins": []interface{}{map[string]interface{}{"class": "@pgo/Plugin/ResponseCache", "ttl": ttl}},
+    })
+
+    return path, count
+}
+
+func serveCached(path, cacheControl string) *Test.ResponseRecorder {
+    r := Test.NewRequest("GET", path, nil)
+    if len(cacheControl) > 0 {
+        r.Header.Set("Cache-Control", cacheControl)
+    }
+
+    return Test.Serve(r)
+}
+
+func TestResponseCacheHit(t *testing.T) {
+    path, count := newCachedRoute("60s")
+
+    if w := serveCached(path, ""); w.GetString() != "item 1" || w.GetHeader("X-Cache") != "MISS" {
+        t.Errorf("first: want MISS item 1, got %s %q", w.GetHeader("X-Cache"), w.GetString())
+    }
+
+    w := serveCached(path, "")
+    if w.GetStatus() != http.StatusOK || w.GetString() != "item 1" || w.GetHeader("X-Cache") != "HIT" || w.GetHeader("X-Item") != "1" {
+        t.Errorf("second: want HIT with stored body and header, got %d %s %q %v", w.GetStatus(), w.GetHeader("X-Cache"), w.GetString(), w.Header())
+    }
+
+    if w := serveCached(path+"?page=2", ""); w.GetString() != "item 2" || w.GetHeader("X-Cache") != "MISS" {
+        t.Errorf("other url: want MISS item 2, got %s %q", w.GetHeader("X-Cache"), w.GetString())
+    }
+
+    if n := atomic.LoadInt32(count); n != 2 {
+        t.Errorf("want handler executed twice, got %d", n)
+    }
+}
+
+func TestResponseCacheExpire(t *testing.T) {
+    path, _ := newCachedRoute("50ms")
+
+    serveCached(path, "")
+    if w := serveCached(path, ""); w.GetHeader("X-Cache") != "HIT" {
+        t.Fatalf("within ttl: want HIT, got %s", w.GetHeader("X-Cache"))
+    }
+
+    time.Sleep(80 * time.Millisecond)
+    if w := serveCached(path, ""); w.GetString() != "item 2" || w.GetHeader("X-Cache") != "MISS" {
+        t.Errorf("after ttl: want MISS item 2, got %s %q", w.GetHeader("X-Cache"), w.GetString())
+    }
+}
+
+func TestResponseCacheNoCache(t *testing.T) {
+    path, _ := newCachedRoute("60s")
+
+    serveCached(path, "")
+    if w := serveCached(path, "no-cache"); w.GetString() != "item 2" || w.GetHeader("X-Cache") != "MISS" {
+        t.Errorf("no-cache: want lookup bypassed with MISS item 2, got %s %q", w.GetHeader("X-Cache"), w.GetString())
+    }
+
+    // bypassed response refreshes the stored one
+    if w := serveCached(path, ""); w.GetString() != "item 2" || w.GetHeader("X-Cache") != "HIT" {
+        t.Errorf("after no-cache: want HIT item 2, got %s %q", w.GetHeader("X-Cache"), w.GetString())
+    }
+}
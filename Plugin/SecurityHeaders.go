@@ -0,0 +1,119 @@
+package Plugin
+
+import (
+    "net"
+    "strings"
+
+    "github.com/pinguo/pgo"
+)
+
+// SecurityHeaders plugin, set security headers of response, configuration:
+// "plugins": [{
+//     "class": "@pgo/Plugin/SecurityHeaders",
+//     "hsts": "max-age=31536000; includeSubDomains",
+//     "contentTypeOptions": "nosniff",
+//     "frameOptions": "SAMEORIGIN",
+//     "contentSecurityPolicy": "default-src 'self'",
+//     "referrerPolicy": "strict-origin-when-cross-origin",
+//     "trustedProxies": ["127.0.0.1", "10.0.0.0/8"]
+// }]
+//
+// values above are defaults except contentSecurityPolicy(empty), empty
+// value disables the header, headers are set before the action, so an
+// action can override them for its response. Strict-Transport-Security
+// is only sent over https, or X-Forwarded-Proto is https from proxy of
+// trustedProxies(loopback and private networks by default).
+type SecurityHeaders struct {
+    hsts           string
+    headers        [][2]string
+    trustedProxies []*net.IPNet
+}
+
+func (s *SecurityHeaders) Construct() {
+    s.hsts = defaultHsts
+    s.headers = [][2]string{
+        {"X-Content-Type-Options", "nosniff"},
+        {"X-Frame-Options", "SAMEORIGIN"},
+        {"Content-Security-Policy", ""},
+        {"Referrer-Policy", "strict-origin-when-cross-origin"},
+    }
+    s.trustedProxies = parseIpNets("SecurityHeaders: invalid trusted proxy, ", defaultTrustedProxies)
+}
+
+func (s *SecurityHeaders) SetHsts(v string) {
+    s.hsts = v
+}
+
+func (s *SecurityHeaders) SetContentTypeOptions(v string) {
+    s.setHeader("X-Content-Type-Options", v)
+}
+
+func (s *SecurityHeaders) SetFrameOptions(v string) {
+    s.setHeader("X-Frame-Options", v)
+}
+
+func (s *SecurityHeaders) SetContentSecurityPolicy(v string) {
+    s.setHeader("Content-Security-Policy", v)
+}
+
+func (s *SecurityHeaders) SetReferrerPolicy(v string) {
+    s.setHeader("Referrer-Policy", v)
+}
+
+func (s *SecurityHeaders) SetTrustedProxies(ips []interface{}) {
+    s.trustedProxies = parseIpNets("SecurityHeaders: invalid trusted proxy, ", ips)
+}
+
+func (s *SecurityHeaders) HandleRequest(ctx *pgo.Context) {
+    for _, h := range s.headers {
+        if len(h[1]) > 0 {
+            ctx.SetHeader(h[0], h[1])
+        }
+    }
+
+    if len(s.hsts) > 0 && s.isHttps(ctx) {
+        ctx.SetHeader("Strict-Transport-Security", s.hsts)
+    }
+
+    ctx.Next()
+}
+
+func (s *SecurityHeaders) setHeader(name, value string) {
+    for i := range s.headers {
+        if s.headers[i][0] == name {
+            s.headers[i][1] = value
+        }
+    }
+}
+
+// check if request is https, X-Forwarded-Proto is only trusted from proxies
+func (s *SecurityHeaders) isHttps(ctx *pgo.Context) bool {
+    r := ctx.GetInput()
+    if r.TLS != nil {
+        return true
+    }
+
+    proto := r.Header.Get("X-Forwarded-Proto")
+    if len(proto) == 0 {
+        return false
+    }
+
+    // the first proto is set by the proxy facing client
+    if pos := strings.IndexByte(proto, ','); pos != -1 {
+        proto = proto[:pos]
+    }
+
+    if !strings.EqualFold(strings.TrimSpace(proto), "https") {
+        return false
+    }
+
+    if ip := net.ParseIP(remoteIp(ctx)); ip != nil {
+        for _, ipNet := range s.trustedProxies {
+            if ipNet.Contains(ip) {
+                return true
+            }
+        }
+    }
+
+    return false
+}
@@ -0,0 +1,102 @@
+package Plugin
+
+import (
+    "crypto/tls"
+    "testing"
+
+    "github.com/pinguo/pgo/Test"
+)
+
+func newSecurityHeaders() *SecurityHeaders {
+    s := &SecurityHeaders{}
+    s.Construct()
+    return s
+}
+
+func serveSecurityHeaders(s *SecurityHeaders, remoteAddr, proto string, useTls bool) *Test.ResponseRecorder {
+    r := Test.NewRequest("GET", "/", nil)
+    r.RemoteAddr = remoteAddr
+    if len(proto) > 0 {
+        r.Header.Set("X-Forwarded-Proto", proto)
+    }
+    if useTls {
+        r.TLS = &tls.ConnectionState{}
+    }
+
+    ctx, w := Test.NewRequestContext(r)
+    s.HandleRequest(ctx)
+    return w
+}
+
+func TestSecurityHeadersDefaults(t *testing.T) {
+    w := serveSecurityHeaders(newSecurityHeaders(), "203.0.113.9:5000", "", false)
+    want := map[string]string{
+        "X-Content-Type-Options":    "nosniff",
+        "X-Frame-Options":           "SAMEORIGIN",
+        "Referrer-Policy":           "strict-origin-when-cross-origin",
+        "Content-Security-Policy":   "",
+        "Strict-Transport-Security": "",
+    }
+
+    for name, value := range want {
+        if got := w.GetHeader(name); got != value {
+            t.Errorf("%s: want %q, got %q", name, value, got)
+        }
+    }
+}
+
+func TestSecurityHeadersConfigured(t *testing.T) {
+    s := newSecurityHeaders()
+    s.SetFrameOptions("")
+    s.SetContentSecurityPolicy("default-src 'self'")
+    s.SetHsts("max-age=60")
+
+    w := serveSecurityHeaders(s, "203.0.113.9:5000", "", true)
+    if v := w.GetHeader("X-Frame-Options"); len(v) > 0 {
+        t.Errorf("empty value: want header disabled, got %q", v)
+    }
+
+    if v := w.GetHeader("Content-Security-Policy"); v != "default-src 'self'" {
+        t.Errorf("want configured csp, got %q", v)
+    }
+
+    if v := w.GetHeader("Strict-Transport-Security"); v != "max-age=60" {
+        t.Errorf("tls: want configured hsts, got %q", v)
+    }
+
+    s.SetHsts("")
+    if v := serveSecurityHeaders(s, "203.0.113.9:5000", "", true).GetHeader("Strict-Transport-Security"); len(v) > 0 {
+        t.Errorf("empty hsts: want disabled, got %q", v)
+    }
+}
+
+func TestSecurityHeadersForwardedProto(t *testing.T) {
+    s := newSecurityHeaders()
+    tests := []struct {
+        remoteAddr, proto string
+        want              bool
+    }{
+        {"10.0.0.1:5000", "https", true},
+        {"127.0.0.1:5000", "HTTPS", true},
+        {"10.0.0.1:5000", "https, http", true},
+        {"10.0.0.1:5000", "http, https", false},
+        {"10.0.0.1:5000", "http", false},
+        {"203.0.113.9:5000", "https", false},
+    }
+
+    for _, test := range tests {
+        w := serveSecurityHeaders(s, test.remoteAddr, test.proto, false)
+        if got := len(w.GetHeader("Strict-Transport-Security")) > 0; got != test.want {
+            t.Errorf("%s %q: want hsts %v, got %v", test.remoteAddr, test.proto, test.want, got)
+        }
+    }
+
+    s.SetTrustedProxies([]interface{}{"203.0.113.0/24"})
+    if w := serveSecurityHeaders(s, "10.0.0.1:5000", "https", false); len(w.GetHeader("Strict-Transport-Security")) > 0 {
+        t.Error("untrusted proxy after SetTrustedProxies: want no hsts")
+    }
+
+    if w := serveSecurityHeaders(s, "203.0.113.9:5000", "https", false); len(w.GetHeader("Strict-Transport-Security")) == 0 {
+        t.Error("configured trusted proxy: want hsts")
+    }
+}
@@ -0,0 +1,169 @@
+package pgo
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/binary"
+    "errors"
+    "io"
+    "net"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+var (
+    proxyV1Prefix = []byte("PROXY ")
+    proxyV2Sig    = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+    errProxyHeader = errors.New("invalid proxy protocol header")
+    errProxyPeer   = errors.New("proxy protocol from untrusted peer")
+)
+
+// listener to accept connections wrapped in PROXY protocol(v1/v2),
+// header is parsed lazily in connection's goroutine, so a slow
+// client will not block the accept loop, header is accepted from
+// any peer if trusted is nil.
+type proxyListener struct {
+    net.Listener
+    timeout time.Duration
+    trusted func(ip string) bool
+}
+
+func (l *proxyListener) Accept() (net.Conn, error) {
+    conn, e := l.Listener.Accept()
+    if e != nil {
+        return nil, e
+    }
+
+    return &proxyConn{Conn: conn, reader: bufio.NewReader(conn), timeout: l.timeout, trusted: l.trusted}, nil
+}
+
+// connection with remote address recovered from PROXY protocol header,
+// malformed header closes the connection.
+type proxyConn struct {
+    net.Conn
+    reader  *bufio.Reader
+    timeout time.Duration
+    trusted func(ip string) bool
+    remote  net.Addr
+    err     error
+    once    sync.Once
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+    c.once.Do(c.readHeader)
+    if c.err != nil {
+        return 0, c.err
+    }
+
+    return c.reader.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+    c.once.Do(c.readHeader)
+    if c.remote != nil {
+        return c.remote
+    }
+
+    return c.Conn.RemoteAddr()
+}
+
+func (c *proxyConn) readHeader() {
+    if c.timeout > 0 {
+        c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+        defer c.Conn.SetReadDeadline(time.Time{})
+    }
+
+    if ip, _, _ := net.SplitHostPort(c.Conn.RemoteAddr().String()); c.trusted != nil && !c.trusted(ip) {
+        c.err = errProxyPeer
+    } else if peek, e := c.reader.Peek(len(proxyV1Prefix)); e == nil && bytes.Equal(peek, proxyV1Prefix) {
+        c.remote, c.err = c.readV1()
+    } else if peek, e := c.reader.Peek(len(proxyV2Sig)); e == nil && bytes.Equal(peek, proxyV2Sig) {
+        c.remote, c.err = c.readV2()
+    } else {
+        c.err = errProxyHeader
+    }
+
+    if c.err != nil {
+        GLogger().Warn("Server: proxy protocol failed from %s, %s", c.Conn.RemoteAddr(), c.err)
+        c.Conn.Close()
+    }
+}
+
+// v1: PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n
+func (c *proxyConn) readV1() (net.Addr, error) {
+    // max length of v1 header is 107 bytes
+    line := make([]byte, 0, 108)
+    for len(line) < 107 {
+        b, e := c.reader.ReadByte()
+        if e != nil {
+            return nil, e
+        }
+
+        if line = append(line, b); b == '\n' {
+            break
+        }
+    }
+
+    if !bytes.HasSuffix(line, []byte("\r\n")) {
+        return nil, errProxyHeader
+    }
+
+    fields := strings.Fields(string(line[:len(line)-2]))
+    if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+        return nil, nil
+    }
+
+    if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+        return nil, errProxyHeader
+    }
+
+    ip := net.ParseIP(fields[2])
+    port, e := strconv.Atoi(fields[4])
+    if ip == nil || e != nil || port < 0 || port > 65535 {
+        return nil, errProxyHeader
+    }
+
+    return &net.TCPAddr{IP: ip, Port: port}, nil
+}
+
+// v2: 12 bytes signature, version/command, family, length, addresses
+func (c *proxyConn) readV2() (net.Addr, error) {
+    header := make([]byte, 16)
+    if _, e := io.ReadFull(c.reader, header); e != nil {
+        return nil, e
+    }
+
+    if header[12]>>4 != 2 {
+        return nil, errProxyHeader
+    }
+
+    body := make([]byte, binary.BigEndian.Uint16(header[14:]))
+    if _, e := io.ReadFull(c.reader, body); e != nil {
+        return nil, e
+    }
+
+    // LOCAL command, connection from proxy itself
+    if header[12]&0x0f == 0 {
+        return nil, nil
+    } else if header[12]&0x0f != 1 {
+        return nil, errProxyHeader
+    }
+
+    switch header[13] >> 4 {
+    case 1: // AF_INET
+        if len(body) < 12 {
+            return nil, errProxyHeader
+        }
+        return &net.TCPAddr{IP: net.IP(body[:4]), Port: int(binary.BigEndian.Uint16(body[8:]))}, nil
+    case 2: // AF_INET6
+        if len(body) < 36 {
+            return nil, errProxyHeader
+        }
+        return &net.TCPAddr{IP: net.IP(body[:16]), Port: int(binary.BigEndian.Uint16(body[32:]))}, nil
+    default: // AF_UNSPEC or AF_UNIX, keep original address
+        return nil, nil
+    }
+}
@@ -0,0 +1,119 @@
+package pgo
+
+import (
+    "bufio"
+    "encoding/binary"
+    "io"
+    "net"
+    "testing"
+    "time"
+)
+
+// pipe connection with address of the peer
+type testPeerConn struct {
+    net.Conn
+    peer net.Addr
+}
+
+func (c *testPeerConn) RemoteAddr() net.Addr {
+    return c.peer
+}
+
+// proxy connection of server side, data is written by peer and then closed
+func newTestProxyConn(data []byte, trusted func(ip string) bool) *proxyConn {
+    client, server := net.Pipe()
+    go func() {
+        client.Write(data)
+        client.Close()
+    }()
+
+    conn := &testPeerConn{server, &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 40000}}
+    return &proxyConn{Conn: conn, reader: bufio.NewReader(conn), timeout: time.Second, trusted: trusted}
+}
+
+// v2 header of command with address block
+func proxyV2Header(command, family byte, addrs []byte) []byte {
+    header := append([]byte{}, proxyV2Sig...)
+    header = append(header, 0x20|command, family<<4|1, 0, 0)
+    binary.BigEndian.PutUint16(header[14:], uint16(len(addrs)))
+    return append(header, addrs...)
+}
+
+func TestProxyConn(t *testing.T) {
+    inet := []byte{192, 0, 2, 1, 198, 51, 100, 1, 0xdb, 0xc4, 0x01, 0xbb}
+    inet6 := append(append(net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")...), 0xdb, 0xc4, 0x01, 0xbb)
+    trusted := func(ip string) bool { return ip == "10.0.0.1" }
+    untrusted := func(ip string) bool { return false }
+
+    tests := []struct {
+        name    string
+        data    []byte
+        trusted func(ip string) bool
+        remote  string // empty if rejected
+    }{
+        {"v1 tcp4", []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56260 443\r\n"), nil, "192.0.2.1:56260"},
+        {"v1 tcp6", []byte("PROXY TCP6 2001:db8::1 2001:db8::2 56260 443\r\n"), nil, "[2001:db8::1]:56260"},
+        {"v1 unknown", []byte("PROXY UNKNOWN\r\n"), nil, "10.0.0.1:40000"},
+        {"v2 proxy inet", proxyV2Header(1, 1, inet), nil, "192.0.2.1:56260"},
+        {"v2 proxy inet6", proxyV2Header(1, 2, inet6), nil, "[2001:db8::1]:56260"},
+        {"v2 local", proxyV2Header(0, 0, nil), nil, "10.0.0.1:40000"},
+        {"trusted peer", []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56260 443\r\n"), trusted, "192.0.2.1:56260"},
+        {"untrusted peer", []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56260 443\r\n"), untrusted, ""},
+        {"v1 truncated", []byte("PROXY TCP4 192.0.2.1 198.51"), nil, ""},
+        {"v1 bad address", []byte("PROXY TCP4 192.0.2.300 198.51.100.1 56260 443\r\n"), nil, ""},
+        {"v1 bad port", []byte("PROXY TCP4 192.0.2.1 198.51.100.1 65536 443\r\n"), nil, ""},
+        {"v2 truncated", proxyV2Header(1, 1, inet)[:14], nil, ""},
+        {"v2 short address", proxyV2Header(1, 1, inet[:8]), nil, ""},
+        {"v2 bad version", append(proxyV2Sig[:12:12], 0x11, 0x11, 0, 0), nil, ""},
+        {"garbage", []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"), nil, ""},
+    }
+
+    for _, test := range tests {
+        conn := newTestProxyConn(append(test.data, "body"...), test.trusted)
+        if len(test.remote) == 0 {
+            if n, e := conn.Read(make([]byte, 16)); e == nil || n > 0 {
+                t.Errorf("%s: want rejected, got %d bytes %v", test.name, n, e)
+            }
+            continue
+        }
+
+        data, e := io.ReadAll(conn)
+
+        if e != nil || string(data) != "body" {
+            t.Errorf("%s: want body after header, got %q %v", test.name, data, e)
+        }
+
+        if remote := conn.RemoteAddr().String(); remote != test.remote {
+            t.Errorf("%s: want remote %s, got %s", test.name, test.remote, remote)
+        }
+    }
+}
+
+func TestProxyListenerTrusted(t *testing.T) {
+    ln, e := net.Listen("tcp", "127.0.0.1:0")
+    if e != nil {
+        t.Fatal(e)
+    }
+    defer ln.Close()
+
+    pl := &proxyListener{Listener: ln, timeout: time.Second, trusted: func(ip string) bool { return false }}
+    go func() {
+        if conn, e := net.Dial("tcp", ln.Addr().String()); e == nil {
+            conn.Write([]byte("PROXY TCP4 192.0.2.1 198.51.100.1 56260 443\r\n"))
+            conn.Close()
+        }
+    }()
+
+    conn, e := pl.Accept()
+    if e != nil {
+        t.Fatal(e)
+    }
+
+    if _, e := conn.Read(make([]byte, 1)); e != errProxyPeer {
+        t.Errorf("want untrusted peer rejected, got %v", e)
+    }
+
+    if remote := conn.RemoteAddr().(*net.TCPAddr); !remote.IP.IsLoopback() {
+        t.Errorf("want address of peer kept, got %s", remote)
+    }
+}
@@ -0,0 +1,293 @@
+package pgo
+
+import (
+    "bytes"
+    "encoding/json"
+    "encoding/xml"
+    "fmt"
+    "reflect"
+    "sort"
+    "strconv"
+    "strings"
+
+    "github.com/pinguo/pgo/Util"
+)
+
+type acceptItem struct {
+    mediaType string
+    q         float64
+}
+
+// parse accept header to media types sorted by q weight,
+// eg. `text/html;q=0.9, application/json` => [application/json text/html]
+func parseAccept(accept string) []acceptItem {
+    items := make([]acceptItem, 0)
+    for _, part := range strings.Split(accept, ",") {
+        params := strings.Split(part, ";")
+        mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+        if len(mediaType) == 0 {
+            continue
+        }
+
+        q := 1.0
+        for _, param := range params[1:] {
+            param = strings.TrimSpace(param)
+            if strings.HasPrefix(param, "q=") {
+                if f, e := strconv.ParseFloat(param[2:], 64); e == nil {
+                    q = f
+                }
+            }
+        }
+
+        if q > 0 {
+            items = append(items, acceptItem{mediaType, q})
+        }
+    }
+
+    sort.SliceStable(items, func(i, j int) bool { return items[i].q > items[j].q })
+    return items
+}
+
+// render component, render response by the best media
+// type negotiated from Accept header, configuration:
+// "render": {
+//     "defaultType": "application/json",
+//     "views": {
+//         "/welcome/index": "welcome/index"
+//     },
+//     "renderers": {
+//         "application/msgpack": "@app/Lib/MsgpackRenderer"
+//     }
+// }
+//
+// views maps route(controllerId/actionId) to view for text/html,
+// route without view is not acceptable for text/html.
+type Render struct {
+    defaultType string
+    views       map[string]string
+    types       []string
+    renderers   map[string]IRenderer
+}
+
+func (r *Render) Construct() {
+    r.defaultType = "application/json"
+    r.views = make(map[string]string)
+    r.types = make([]string, 0)
+    r.renderers = make(map[string]IRenderer)
+
+    r.AddRenderer("application/json", &JsonRenderer{})
+    r.AddRenderer("application/xml", &XmlRenderer{})
+    r.AddRenderer("text/xml", &XmlRenderer{})
+    r.AddRenderer("text/html", &HtmlRenderer{})
+}
+
+// set media type used when Accept header is absent or */*
+func (r *Render) SetDefaultType(mediaType string) {
+    r.defaultType = strings.ToLower(mediaType)
+}
+
+// set route to view mapping for text/html
+func (r *Render) SetViews(views map[string]interface{}) {
+    for route, view := range views {
+        r.views[strings.ToLower(route)] = Util.ToString(view)
+    }
+}
+
+// set custom renderers, media type => renderer class
+func (r *Render) SetRenderers(renderers map[string]interface{}) {
+    for mediaType, class := range renderers {
+        r.AddRenderer(mediaType, CreateObject(class).(IRenderer))
+    }
+}
+
+// add or replace renderer for media type
+func (r *Render) AddRenderer(mediaType string, renderer IRenderer) {
+    mediaType = strings.ToLower(mediaType)
+    if _, ok := r.renderers[mediaType]; !ok {
+        r.types = append(r.types, mediaType)
+    }
+
+    r.renderers[mediaType] = renderer
+}
+
+// get view given to Context.Render or mapped to the current
+// route, empty string if not mapped
+func (r *Render) GetView(ctx *Context) string {
+    if len(ctx.view) > 0 {
+        return ctx.view
+    }
+
+    route := ctx.GetControllerId() + "/" + ctx.GetActionId()
+    return r.views[strings.ToLower(route)]
+}
+
+// render data by the media type negotiated from accept header,
+// return content type and output, ok is false if not acceptable
+func (r *Render) Render(ctx *Context, accept string, data interface{}) (contentType string, output []byte, ok bool) {
+    for _, mediaType := range r.Negotiate(accept) {
+        if output = r.renderers[mediaType].Render(ctx, data); output != nil {
+            return r.renderers[mediaType].ContentType(), output, true
+        }
+    }
+
+    return "", nil, false
+}
+
+// get acceptable media types in preference order
+func (r *Render) Negotiate(accept string) []string {
+    items := parseAccept(accept)
+    if len(items) == 0 {
+        items = append(items, acceptItem{"*/*", 1})
+    }
+
+    types, seen := make([]string, 0), make(map[string]bool)
+    add := func(mediaType string) {
+        if _, ok := r.renderers[mediaType]; ok && !seen[mediaType] {
+            seen[mediaType] = true
+            types = append(types, mediaType)
+        }
+    }
+
+    for _, item := range items {
+        if item.mediaType == "*/*" {
+            add(r.defaultType)
+            for _, mediaType := range r.types {
+                add(mediaType)
+            }
+        } else if strings.HasSuffix(item.mediaType, "/*") {
+            prefix := item.mediaType[:len(item.mediaType)-1]
+            if strings.HasPrefix(r.defaultType, prefix) {
+                add(r.defaultType)
+            }
+
+            for _, mediaType := range r.types {
+                if strings.HasPrefix(mediaType, prefix) {
+                    add(mediaType)
+                }
+            }
+        } else {
+            add(item.mediaType)
+        }
+    }
+
+    return types
+}
+
+// json renderer
+type JsonRenderer struct {
+}
+
+func (j *JsonRenderer) ContentType() string {
+    return "application/json; charset=utf-8"
+}
+
+func (j *JsonRenderer) Render(ctx *Context, data interface{}) []byte {
+    output, e := json.Marshal(data)
+    if e != nil {
+        panic(fmt.Sprintf("failed to marshal json, %s", e))
+    }
+
+    return output
+}
+
+// xml renderer, map and slice are supported besides
+// types encoding/xml can marshal, eg.
+// {"status":200,"data":[1,2]} => <response><data><item>1</item><item>2</item></data><status>200</status></response>
+type XmlRenderer struct {
+}
+
+func (x *XmlRenderer) ContentType() string {
+    return "application/xml; charset=utf-8"
+}
+
+func (x *XmlRenderer) Render(ctx *Context, data interface{}) []byte {
+    buf := &bytes.Buffer{}
+    buf.WriteString(xml.Header)
+
+    start := xml.StartElement{Name: xml.Name{Local: "response"}}
+    if e := xml.NewEncoder(buf).EncodeElement(xmlNode{data}, start); e != nil {
+        panic(fmt.Sprintf("failed to marshal xml, %s", e))
+    }
+
+    return buf.Bytes()
+}
+
+// html renderer, render view mapped to the current route
+type HtmlRenderer struct {
+}
+
+func (h *HtmlRenderer) ContentType() string {
+    return "text/html; charset=utf-8"
+}
+
+func (h *HtmlRenderer) Render(ctx *Context, data interface{}) []byte {
+    if view := App.GetRender().GetView(ctx); len(view) > 0 {
+        return App.GetView().Render(view, data)
+    }
+
+    return nil
+}
+
+// xml node wraps value of any type for xml encoding
+type xmlNode struct {
+    value interface{}
+}
+
+func (x xmlNode) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+    rv := reflect.ValueOf(x.value)
+    for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+        if rv.IsNil() {
+            return enc.EncodeElement("", start)
+        }
+        rv = rv.Elem()
+    }
+
+    switch rv.Kind() {
+    case reflect.Invalid:
+        return enc.EncodeElement("", start)
+
+    case reflect.Map:
+        if e := enc.EncodeToken(start); e != nil {
+            return e
+        }
+
+        keys := make([]string, 0, rv.Len())
+        values := make(map[string]interface{})
+        for _, key := range rv.MapKeys() {
+            k := Util.ToString(key.Interface())
+            keys = append(keys, k)
+            values[k] = rv.MapIndex(key).Interface()
+        }
+
+        sort.Strings(keys)
+        for _, k := range keys {
+            child := xml.StartElement{Name: xml.Name{Local: k}}
+            if e := enc.EncodeElement(xmlNode{values[k]}, child); e != nil {
+                return e
+            }
+        }
+
+        return enc.EncodeToken(start.End())
+
+    case reflect.Slice, reflect.Array:
+        if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+            return enc.EncodeElement(string(rv.Bytes()), start)
+        }
+
+        if e := enc.EncodeToken(start); e != nil {
+            return e
+        }
+
+        for i, n := 0, rv.Len(); i < n; i++ {
+            child := xml.StartElement{Name: xml.Name{Local: "item"}}
+            if e := enc.EncodeElement(xmlNode{rv.Index(i).Interface()}, child); e != nil {
+                return e
+            }
+        }
+
+        return enc.EncodeToken(start.End())
+
+    default:
+        return enc.EncodeElement(rv.Interface(), start)
+    }
+}
@@ -0,0 +1,139 @@
+package pgo
+
+import (
+    "encoding/xml"
+    "net/http"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func newTestRender() *Render {
+    r := &Render{}
+    r.Construct()
+    return r
+}
+
+func TestParseAccept(t *testing.T) {
+    items := parseAccept("text/html;q=0.8, application/json, application/xml;q=0.9, image/png;q=0")
+    want := []acceptItem{{"application/json", 1}, {"application/xml", 0.9}, {"text/html", 0.8}}
+    if !reflect.DeepEqual(items, want) {
+        t.Errorf("want %v, got %v", want, items)
+    }
+}
+
+func TestRenderNegotiate(t *testing.T) {
+    r := newTestRender()
+    tests := map[string][]string{
+        "":                                 {"application/json", "application/xml", "text/xml", "text/html"},
+        "*/*":                              {"application/json", "application/xml", "text/xml", "text/html"},
+        "application/xml":                  {"application/xml"},
+        "text/*":                           {"text/xml", "text/html"},
+        "text/html, application/json;q=.5": {"text/html", "application/json"},
+        "image/png":                        {},
+    }
+
+    for accept, want := range tests {
+        if got := r.Negotiate(accept); !reflect.DeepEqual(got, want) {
+            t.Errorf("%q: want %v, got %v", accept, want, got)
+        }
+    }
+
+    r.SetDefaultType("application/xml")
+    if got := r.Negotiate("*/*"); got[0] != "application/xml" {
+        t.Errorf("default type xml: want xml first, got %v", got)
+    }
+}
+
+func TestContextRender(t *testing.T) {
+    render := func(accept string) (int, string, string) {
+        ctx, w := newRequestContext("GET", "/render", nil)
+        ctx.GetInput().Header.Set("Accept", accept)
+        ctx.Render(http.StatusCreated, map[string]interface{}{"id": 1})
+        return w.Code, w.Header().Get("Content-Type"), w.Body.String()
+    }
+
+    if code, ct, body := render(""); code != http.StatusCreated || ct != "application/json; charset=utf-8" || body != `{"id":1}` {
+        t.Errorf("no accept: want 201 json, got %d %s %s", code, ct, body)
+    }
+
+    if code, ct, _ := render("application/xml"); code != http.StatusCreated || ct != "application/xml; charset=utf-8" {
+        t.Errorf("accept xml: want 201 xml, got %d %s", code, ct)
+    }
+
+    // html is not acceptable without view
+    if code, _, _ := render("text/html"); code != http.StatusNotAcceptable {
+        t.Errorf("html without view: want 406, got %d", code)
+    }
+
+    if code, _, _ := render("text/html, application/json;q=0.5"); code != http.StatusCreated {
+        t.Errorf("html without view and json: want json, got %d", code)
+    }
+}
+
+func TestXmlRenderer(t *testing.T) {
+    type user struct {
+        Name string `xml:"name,attr"`
+        Age  int    `xml:"age"`
+    }
+
+    x := &XmlRenderer{}
+    output := string(x.Render(nil, map[string]interface{}{
+        "status": 200,
+        "data":   []interface{}{1, "a", nil},
+        "user":   &user{"foo", 20},
+        "raw":    []byte("bytes"),
+    }))
+
+    want := xml.Header + `<response><data><item>1</item><item>a</item><item></item></data>` +
+        `<raw>bytes</raw><status>200</status><user name="foo"><age>20</age></user></response>`
+    if output != want {
+        t.Errorf("want %s, got %s", want, output)
+    }
+}
+
+func TestContextGetXmlBody(t *testing.T) {
+    type order struct {
+        Id    int      `xml:"id"`
+        Items []string `xml:"items>item"`
+    }
+
+    newCtx := func(ct, body string) *Context {
+        ctx, _ := newRequestContext("POST", "/order", strings.NewReader(body))
+        ctx.GetInput().Header.Set("Content-Type", ct)
+        return ctx
+    }
+
+    var o order
+    body := "<order><id>12</id><items><item>a</item><item>b</item></items></order>"
+    if e := newCtx("application/xml; charset=utf-8", body).GetXmlBody(&o); e != nil {
+        t.Fatal(e)
+    }
+
+    if !reflect.DeepEqual(o, order{12, []string{"a", "b"}}) {
+        t.Errorf("want order 12 [a b], got %+v", o)
+    }
+
+    for ct, body := range map[string]string{"application/json": body, "text/xml": "<order><id>"} {
+        e, ok := newCtx(ct, body).GetXmlBody(&o).(*Exception)
+        if !ok || e.GetStatus() != http.StatusBadRequest {
+            t.Errorf("%s %s: want 400 exception, got %v", ct, body, e)
+        }
+    }
+}
+
+func TestControllerOutputXml(t *testing.T) {
+    ctx, _ := newRequestContext("GET", "/xml", nil)
+    c := &Controller{}
+    c.SetContext(ctx)
+    c.OutputXml([]int{1, 2}, http.StatusOK, "ok")
+
+    want := xml.Header + "<response><data><item>1</item><item>2</item></data><message>ok</message><status>200</status></response>"
+    if c.Status != http.StatusOK || string(c.Output) != want {
+        t.Errorf("want 200 %s, got %d %s", want, c.Status, c.Output)
+    }
+
+    if ct := ctx.GetOutput().Header().Get("Content-Type"); ct != "application/xml; charset=utf-8" {
+        t.Errorf("want xml content type, got %q", ct)
+    }
+}
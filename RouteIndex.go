@@ -0,0 +1,79 @@
+package pgo
+
+import (
+    "strings"
+)
+
+// trie of literal prefixes of route rules, so matching tests only
+// rules whose prefix matches the path, the cost is O(path length)
+// plus candidates instead of all rules, rules are still tested in
+// added order, so precedence is same as linear matching.
+type routeIndex struct {
+    root *indexNode
+}
+
+// candidates of node are precomputed when adding rules, they are
+// rules without literal prefix and rules whose prefix ends at this
+// node or its ancestors, sorted because rules are added in order.
+type indexNode struct {
+    children   map[byte]*indexNode
+    candidates []int
+}
+
+func newRouteIndex() *routeIndex {
+    return &routeIndex{root: &indexNode{}}
+}
+
+// add rule at position i of router rules, i must be greater than
+// position of rules added before
+func (x *routeIndex) add(i int, rule *routeRule) {
+    node, prefix := x.root, rulePrefix(rule)
+    for j := 0; j < len(prefix); j++ {
+        if node.children == nil {
+            node.children = make(map[byte]*indexNode)
+        }
+
+        child := node.children[prefix[j]]
+        if child == nil {
+            child = &indexNode{candidates: append([]int(nil), node.candidates...)}
+            node.children[prefix[j]] = child
+        }
+        node = child
+    }
+
+    // rule without prefix is added to root, so it's candidate of all nodes
+    node.addCandidate(i)
+}
+
+func (n *indexNode) addCandidate(i int) {
+    n.candidates = append(n.candidates, i)
+    for _, child := range n.children {
+        child.addCandidate(i)
+    }
+}
+
+// get sorted index of rules may match path, the result is shared
+// and must not be modified
+func (x *routeIndex) candidates(path string) []int {
+    node := x.root
+    for j := 0; j < len(path); j++ {
+        child := node.children[path[j]]
+        if child == nil {
+            break
+        }
+        node = child
+    }
+
+    return node.candidates
+}
+
+// literal prefix every match of rule starts with, only anchored
+// pattern has a prefix, because unanchored one matches anywhere
+func rulePrefix(rule *routeRule) string {
+    if !strings.HasPrefix(rule.pattern, "^") {
+        return ""
+    }
+
+    prefix, _ := rule.rePat.LiteralPrefix()
+    return prefix
+}
@@ -0,0 +1,81 @@
+package pgo
+
+import (
+    "fmt"
+    "reflect"
+    "testing"
+)
+
+func TestRouteIndexCandidates(t *testing.T) {
+    r := newTestRouter()
+    r.AddRoute("^/user/list$", "user/list", nil)
+    r.AddRoute("/any$", "any/index", nil)
+    r.AddRoute("^/user/(\\d+)$", "user/view", nil)
+    r.AddRoute("^/admin/user$", "admin/user", nil)
+    r.AddRoute("^/u", "u/index", nil)
+
+    tests := map[string][]int{
+        "/user/list": {0, 1, 2, 4},
+        "/user/12":   {1, 2, 4},
+        "/admin":     {1},
+        "/other":     {1},
+        "":           {1},
+    }
+
+    for path, want := range tests {
+        if got := r.index.candidates(path); !reflect.DeepEqual(got, want) {
+            t.Errorf("%q: want %v, got %v", path, want, got)
+        }
+    }
+}
+
+func TestRouteIndexAddAfterMatch(t *testing.T) {
+    r := newTestRouter()
+    r.AddRoute("^/user/list$", "user/list", nil)
+    if route, _ := r.Resolve("/user/list", "GET"); route != "user/List" {
+        t.Fatalf("want user/List, got %q", route)
+    }
+
+    // rule without prefix added later is candidate of existing nodes
+    r.AddRoute("/(\\w+)/list$", "item/list", nil)
+    if got := r.index.candidates("/user/list"); !reflect.DeepEqual(got, []int{0, 1}) {
+        t.Errorf("want [0 1], got %v", got)
+    }
+
+    if route, _ := r.Resolve("/item/list", "GET"); route != "item/List" {
+        t.Errorf("want item/List, got %q", route)
+    }
+}
+
+func newBenchRouter(n int) *Router {
+    r := newTestRouter()
+    for i := 0; i < n; i++ {
+        r.AddRoute(fmt.Sprintf("^/api/v1/item%d/(\\d+)$", i), fmt.Sprintf("item%d/view", i), nil)
+    }
+    return r
+}
+
+func BenchmarkRouterResolve(b *testing.B) {
+    for _, n := range []int{10, 100, 1000} {
+        r := newBenchRouter(n)
+        path := fmt.Sprintf("/api/v1/item%d/12", n-1)
+
+        b.Run(fmt.Sprintf("routes-%d", n), func(b *testing.B) {
+            b.ReportAllocs()
+            for i := 0; i < b.N; i++ {
+                if route, _ := r.Resolve(path, "GET"); len(route) == 0 {
+                    b.Fatal("route not matched")
+                }
+            }
+        })
+    }
+}
+
+func BenchmarkRouteIndexCandidates(b *testing.B) {
+    r := newBenchRouter(1000)
+    b.ReportAllocs()
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        r.index.candidates("/api/v1/item999/12")
+    }
+}
@@ -1,8 +1,15 @@
 package pgo
 
 import (
+    "encoding/json"
+    "net/http"
+    "reflect"
     "regexp"
+    "runtime"
+    "sort"
     "strings"
+    "sync"
+    "sync/atomic"
 
     "github.com/pinguo/pgo/Util"
 )
@@ -17,33 +24,181 @@ func routeFormatFunc(s string) string {
     return s
 }
 
+// format CamelCase to route string, eg.
+// /Api/FooBar/SayHello => /api/foo-bar/say-hello
+func routeUnformat(s string) string {
+    buf := make([]byte, 0, len(s)+4)
+    for i := 0; i < len(s); i++ {
+        c := s[i]
+        if c >= 'A' && c <= 'Z' {
+            if i > 0 && s[i-1] != '/' {
+                buf = append(buf, '-')
+            }
+            c += 'a' - 'A'
+        }
+        buf = append(buf, c)
+    }
+    return string(buf)
+}
+
 type routeRule struct {
-    rePat   *regexp.Regexp
-    pattern string
-    route   string
+    rePat    *regexp.Regexp
+    pattern  string
+    route    string
+    catchAll string // name of catch-all param, eg. "path" of "/files/{*path}"
+    catchAt  int    // position of catch-all in pattern, longer prefix wins
+    method   string
+    meta     map[string]interface{}
+    handler  func(ctx *Context)
+
+    pluginConf []interface{}    // route plugin configurations
+    skips      map[string]bool  // class of server plugins to skip
+    chain      []IPlugin        // plugin chain of this route
+    chainLock  sync.Mutex
+    chainReady int32            // 1 if chain is built
+}
+
+// route info for documentation and gateway config
+type RouteInfo struct {
+    Method  string                 `json:"method"`
+    Pattern string                 `json:"pattern"`
+    Handler string                 `json:"handler"`
+    Plugins []string               `json:"plugins"` // plugin names of chain
+    Meta    map[string]interface{} `json:"meta,omitempty"`
 }
 
 // router component, configuration:
 // "router": {
 //     "rules": [
 //         "^/foo/all$ => /foo/index",
-//         "^/api/user/(\\d+)$ => /api/user"
-//     ]
+//         "^/api/user/(\\d+)$ => /api/user",
+//         {
+//             "pattern": "^/api/item/(\\d+)$",
+//             "route": "/api/item/view",
+//             "method": "GET",
+//             "summary": "get item by id",
+//             "tags": ["item"],
+//             "plugins": [{"class": "@pgo/Plugin/RateLimit", "rate": 5}],
+//             "skipPlugins": ["@pgo/Plugin/ResponseCache"]
+//         }
+//     ],
+//     "routesPath": "/_routes",
+//     "openApiPath": "/_openapi",
+//     "caseInsensitive": false,
+//     "trailingSlash": "strict",
+//     "autoOptions": false,
+//     "autoHead": false,
+//     "missInChain": false
 // }
+//
+// rule in object form matches the specified method only, keys other
+// than pattern, route, method, plugins and skipPlugins are kept as route
+// metadata, routesPath serves route list as json, openApiPath serves
+// OpenAPI document of routes(see OpenApi), they're disabled if empty.
+// plugins run after server plugins and before the action, skipPlugins
+// removes server plugins of the specified classes for this route.
+// caseInsensitive matches rules ignoring case and lowers path before
+// default routing, so use hyphen(foo-bar) instead of camel case path,
+// trailingSlash is one of: strict(default, path is matched as is),
+// ignore(trailing slash is removed before matching), redirect(301 to
+// path without trailing slash, 308 for non-GET to keep method).
+// autoOptions responds OPTIONS with Allow header for path without
+// OPTIONS rule or action, autoHead runs GET route for HEAD without
+// HEAD rule or action, body is discarded and Content-Length is kept.
+// handlers set by SetNotFoundHandler and SetMethodNotAllowedHandler
+// replace the default 404 response, they run without plugins unless
+// missInChain is true, method mismatch is 404 if no 405 handler is set.
+// "/{*name}" as the last segment of pattern is a catch-all param, it
+// captures the rest of path including slashes, eg. "^/files/{*path}$"
+// captures "a/b.txt" of "/files/a/b.txt" and "" of "/files", it's
+// passed as action param and named group "path", rules with catch-all
+// are matched after other rules, the longest prefix wins among them.
 type Router struct {
-    reFmt *regexp.Regexp
-    rules []routeRule
+    reFmt           *regexp.Regexp
+    rules           []*routeRule
+    index           *routeIndex
+    caseInsensitive bool
+    trailingSlash   string
+    autoOptions     bool
+    autoHead        bool
+    missInChain     bool
+    notFound        func(ctx *Context)
+    notAllowed      func(ctx *Context)
 }
 
 func (r *Router) Construct() {
     r.reFmt = regexp.MustCompile(`([/-][a-z])`)
-    r.rules = make([]routeRule, 0, 10)
+    r.rules = make([]*routeRule, 0, 10)
+    r.index = newRouteIndex()
+    r.trailingSlash = TrailingSlashStrict
+}
+
+// run not found and method not allowed handlers in plugin chain
+func (r *Router) SetMissInChain(v bool) {
+    r.missInChain = v
+}
+
+// set handler of unmatched path, it should end the request with 404
+func (r *Router) SetNotFoundHandler(handler func(ctx *Context)) {
+    r.notFound = handler
+}
+
+// set handler of path matched by other methods, Allow header is set
+// before calling, it should end the request with 405
+func (r *Router) SetMethodNotAllowedHandler(handler func(ctx *Context)) {
+    r.notAllowed = handler
+}
+
+// match path ignoring case, existing rules are recompiled
+func (r *Router) SetCaseInsensitive(v bool) {
+    r.caseInsensitive = v
+    r.index = newRouteIndex()
+    for i, rule := range r.rules {
+        rule.rePat = regexp.MustCompile(r.patternOf(rule.pattern))
+        r.index.add(i, rule)
+    }
+}
+
+func (r *Router) SetAutoOptions(v bool) {
+    r.autoOptions = v
+}
+
+func (r *Router) SetAutoHead(v bool) {
+    r.autoHead = v
+}
+
+// set trailing slash mode: strict, ignore or redirect
+func (r *Router) SetTrailingSlash(mode string) {
+    switch mode {
+    case TrailingSlashStrict, TrailingSlashIgnore, TrailingSlashRedirect:
+        r.trailingSlash = mode
+    default:
+        panic("Router: invalid trailingSlash mode, " + mode)
+    }
 }
 
 // config rules, format: `^/api/user/(\d+)$ => /api/user`
+// or object with pattern, route, method and metadata
 func (r *Router) SetRules(rules []interface{}) {
     for _, v := range rules {
-        parts := strings.Split(v.(string), "=>")
+        if m, ok := v.(map[string]interface{}); ok {
+            meta := make(map[string]interface{})
+            for key, val := range m {
+                meta[key] = val
+            }
+
+            pattern, route := Util.ToString(meta["pattern"]), Util.ToString(meta["route"])
+            if len(pattern) == 0 || len(route) == 0 {
+                panic("Router: invalid rule: " + Util.ToString(v))
+            }
+
+            delete(meta, "pattern")
+            delete(meta, "route")
+            r.AddRoute(pattern, route, meta)
+            continue
+        }
+
+        parts := strings.Split(Util.ToString(v), "=>")
         if len(parts) != 2 {
             panic("Router: invalid rule: " + Util.ToString(v))
         }
@@ -54,29 +209,362 @@ func (r *Router) SetRules(rules []interface{}) {
     }
 }
 
+// set path to serve route list as json, eg. "/_routes"
+func (r *Router) SetRoutesPath(path string) {
+    if len(path) > 0 {
+        path = Util.CleanPath(path)
+        r.AddHandler("^"+regexp.QuoteMeta(path)+"$", r.serveRoutes, map[string]interface{}{
+            "method":  http.MethodGet,
+            "summary": "list registered routes",
+        })
+    }
+}
+
 // add one route, the captured group will be passed to
-// action method as function params
-func (r *Router) AddRoute(pattern, route string) {
-    rePat := regexp.MustCompile(pattern)
-    rule := routeRule{rePat, pattern, route}
+// action method as function params, optional meta is route
+// metadata(eg. summary, tags), "method" in meta restricts
+// the request method of this route, "plugins" and "skipPlugins"
+// in meta attach or remove plugins for this route.
+func (r *Router) AddRoute(pattern, route string, meta ...map[string]interface{}) {
+    r.addRule(pattern, route, nil, meta)
+}
+
+// add function handler for path pattern, handler rules are
+// checked before route rules, eg.
+// router.AddHandler("^/ping$", func(ctx *pgo.Context) {
+//     ctx.End(http.StatusOK, []byte("pong"))
+// })
+func (r *Router) AddHandler(pattern string, handler func(ctx *Context), meta ...map[string]interface{}) {
+    if handler == nil {
+        panic("Router: handler cannot be nil, " + pattern)
+    }
+
+    r.addRule(pattern, "", handler, meta)
+}
+
+func (r *Router) addRule(pattern, route string, handler func(ctx *Context), meta []map[string]interface{}) {
+    rule := &routeRule{rePat: regexp.MustCompile(r.patternOf(pattern)), pattern: pattern, route: route, handler: handler}
+    rule.catchAll, rule.catchAt = catchAllOf(pattern)
+    if len(meta) > 0 && meta[0] != nil {
+        // copy meta, the map of caller is kept as is
+        rule.meta = make(map[string]interface{}, len(meta[0]))
+        for key, val := range meta[0] {
+            rule.meta[key] = val
+        }
+
+        method, _ := rule.meta["method"].(string)
+        rule.method = strings.ToUpper(method)
+        rule.pluginConf, _ = rule.meta["plugins"].([]interface{})
+        if skips, ok := rule.meta["skipPlugins"].([]interface{}); ok {
+            rule.skips = make(map[string]bool)
+            for _, class := range skips {
+                rule.skips[Util.ToString(class)] = true
+            }
+        }
+
+        delete(rule.meta, "method")
+        delete(rule.meta, "plugins")
+        delete(rule.meta, "skipPlugins")
+    }
+
+    r.index.add(len(r.rules), rule)
     r.rules = append(r.rules, rule)
 }
 
-// resolve path to route and action params, then format route to CamelCase
-func (r *Router) Resolve(path string) (route string, params []string) {
+// resolve path to route and action params, then format route to CamelCase,
+// rule with method is skipped if method is specified and not matched
+func (r *Router) Resolve(path string, method ...string) (route string, params []string) {
+    rule, params := r.match(path, false, method)
+    return r.routeOf(rule, path), params
+}
+
+// resolve path to function handler added by AddHandler, nil if not found
+func (r *Router) ResolveHandler(path string, method ...string) func(ctx *Context) {
+    if rule, _ := r.match(path, true, method); rule != nil {
+        return rule.handler
+    }
+
+    return nil
+}
+
+// get canonical path for redirect, ok is false if path is canonical
+// or trailingSlash is not redirect mode
+func (r *Router) Canonical(path string) (canonical string, ok bool) {
+    if r.trailingSlash != TrailingSlashRedirect || len(path) <= 1 || path[len(path)-1] != '/' {
+        return path, false
+    }
+
+    return r.cleanPath(path), true
+}
+
+// clean path and remove trailing slash if not strict
+func (r *Router) cleanPath(path string) string {
     path = Util.CleanPath(path)
+    if r.trailingSlash != TrailingSlashStrict && len(path) > 1 && path[len(path)-1] == '/' {
+        path = path[:len(path)-1]
+    }
+
+    return path
+}
+
+func (r *Router) patternOf(pattern string) string {
+    pattern = catchAllRe.ReplaceAllString(pattern, "(?:/(?P<$1>.*))?$2")
+    if r.caseInsensitive {
+        return "(?i)" + pattern
+    }
+
+    return pattern
+}
+
+// match handler rules or route rules, nil if not matched, rules
+// with catch-all are used only if no other rule matches
+func (r *Router) match(path string, handler bool, method []string) (*routeRule, []string) {
+    var fallback *routeRule
+    var fallbackParams []string
+
+    path = r.cleanPath(path)
+    for _, i := range r.index.candidates(path) {
+        rule := r.rules[i]
+        if (rule.handler != nil) != handler || !rule.matchMethod(method) {
+            continue
+        }
 
-    if len(r.rules) != 0 {
-        for _, rule := range r.rules {
-            matches := rule.rePat.FindStringSubmatch(path)
-            if len(matches) != 0 {
-                path = rule.route
-                params = matches[1:]
-                break
+        if matches := rule.rePat.FindStringSubmatch(path); len(matches) == 0 {
+            continue
+        } else if len(rule.catchAll) == 0 {
+            return rule, matches[1:]
+        } else if fallback == nil || rule.catchAt > fallback.catchAt {
+            fallback, fallbackParams = rule, matches[1:]
+        }
+    }
+
+    return fallback, fallbackParams
+}
+
+// match handler rules first, then route rules,
+// HEAD falls back to GET rules if autoHead enabled
+func (r *Router) matchAll(path, method string) (*routeRule, []string) {
+    handler, handlerParams := r.match(path, true, []string{method})
+    if handler != nil && len(handler.catchAll) == 0 {
+        return handler, handlerParams
+    }
+
+    // route rule without catch-all is more specific than catch-all handler
+    rule, params := r.match(path, false, []string{method})
+    if rule == nil || handler != nil && len(rule.catchAll) > 0 && handler.catchAt >= rule.catchAt {
+        rule, params = handler, handlerParams
+    }
+
+    if rule == nil && method == http.MethodHead && r.autoHead {
+        return r.matchAll(path, http.MethodGet)
+    }
+
+    return rule, params
+}
+
+// get sorted methods allowed for path, nil if path is not routable
+func (r *Router) AllowedMethods(path string) []string {
+    allowed := make(map[string]bool)
+    for method := range httpMethods {
+        rule, _ := r.matchAll(path, method)
+        if rule != nil && rule.handler != nil {
+            allowed[method] = true
+        } else if id, _ := App.GetServer().findAction(r.routeOf(rule, path), method); len(id) > 0 {
+            allowed[method] = true
+        }
+    }
+
+    if len(allowed) == 0 {
+        return nil
+    } else if r.autoOptions {
+        allowed[http.MethodOptions] = true
+    }
+
+    methods := make([]string, 0, len(allowed))
+    for method := range allowed {
+        methods = append(methods, method)
+    }
+
+    sort.Strings(methods)
+    return methods
+}
+
+// check if OPTIONS of path should be answered automatically
+func (r *Router) isAutoOptions(rule *routeRule, path string) bool {
+    if !r.autoOptions || (rule != nil && rule.method == http.MethodOptions) {
+        return false
+    }
+
+    if rule == nil || rule.handler == nil {
+        _, actionId := App.GetServer().findAction(r.routeOf(rule, path), http.MethodOptions)
+        return actionId != http.MethodOptions
+    }
+
+    return true
+}
+
+// get CamelCase route of rule, path is used if rule is nil
+func (r *Router) routeOf(rule *routeRule, path string) string {
+    if rule != nil {
+        path = rule.route
+    } else if path = r.cleanPath(path); r.caseInsensitive {
+        path = strings.ToLower(path)
+    }
+
+    return r.reFmt.ReplaceAllStringFunc(path, routeFormatFunc)
+}
+
+// get registered routes, including rules, function handlers
+// and controller actions reachable by default routing, plugins
+// are names of server plugins not skipped and route plugins
+func (r *Router) Routes() []RouteInfo {
+    names := App.GetServer().GetPluginNames()
+    routes := make([]RouteInfo, 0, len(r.rules))
+    for _, rule := range r.rules {
+        info := RouteInfo{Method: rule.method, Pattern: rule.pattern, Plugins: rule.getPluginNames(names), Meta: rule.meta}
+        if rule.handler != nil {
+            info.Handler = runtime.FuncForPC(reflect.ValueOf(rule.handler).Pointer()).Name()
+        } else {
+            info.Handler = r.reFmt.ReplaceAllStringFunc(Util.CleanPath(rule.route), routeFormatFunc)
+        }
+
+        routes = append(routes, info)
+    }
+
+    prefix := ControllerWeb
+    if ModeWeb != App.mode {
+        prefix = ControllerCmd
+    }
+
+    implicit := make([]RouteInfo, 0)
+    for name, item := range App.GetContainer().items {
+        actions, ok := item.info.(map[string]int)
+        if !ok || len(name) <= 2*len(prefix) || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, prefix) {
+            continue
+        }
+
+        id := name[len(prefix) : len(name)-len(prefix)]
+        for action := range actions {
+            info := RouteInfo{Pattern: routeUnformat(id + "/" + action), Handler: id + "/" + action, Plugins: names}
+            if action == DefaultAction {
+                info.Pattern = routeUnformat(id)
+            } else if _, ok := httpMethods[action]; ok {
+                info.Method, info.Pattern = action, routeUnformat(id)
             }
+
+            implicit = append(implicit, info)
         }
     }
 
-    route = r.reFmt.ReplaceAllStringFunc(path, routeFormatFunc)
-    return
+    sort.Slice(implicit, func(i, j int) bool {
+        if implicit[i].Pattern == implicit[j].Pattern {
+            return implicit[i].Method < implicit[j].Method
+        }
+        return implicit[i].Pattern < implicit[j].Pattern
+    })
+
+    return append(routes, implicit...)
+}
+
+func (r *Router) serveRoutes(ctx *Context) {
+    output, _ := json.Marshal(r.Routes())
+    ctx.SetHeader("Content-Type", "application/json; charset=utf-8")
+    ctx.End(http.StatusOK, output)
+}
+
+// get plugin names of chain of this rule, see getChain
+func (rule *routeRule) getPluginNames(names []string) []string {
+    chain := make([]string, 0, len(names)+len(rule.pluginConf))
+    for _, name := range names {
+        if !rule.skips[name] {
+            chain = append(chain, name)
+        }
+    }
+
+    for _, v := range rule.pluginConf {
+        if m, ok := v.(map[string]interface{}); ok {
+            v = m["class"]
+        }
+        chain = append(chain, Util.ToString(v))
+    }
+
+    return chain
+}
+
+// get plugin chain of this rule, servers plugins are
+// filtered by skips, route plugins are inserted before last,
+// failed build panics and is retried by the next call
+func (rule *routeRule) getChain(plugins []IPlugin, names []string) []IPlugin {
+    if len(rule.pluginConf) == 0 && len(rule.skips) == 0 {
+        return plugins
+    } else if atomic.LoadInt32(&rule.chainReady) == 1 {
+        return rule.chain
+    }
+
+    rule.chainLock.Lock()
+    defer rule.chainLock.Unlock()
+
+    if rule.chainReady == 1 {
+        return rule.chain
+    }
+
+    last := len(plugins) - 1
+    chain := make([]IPlugin, 0, len(plugins)+len(rule.pluginConf))
+    for i, plugin := range plugins[:last] {
+        if !rule.skips[names[i]] {
+            chain = append(chain, plugin)
+        }
+    }
+
+    for _, v := range rule.pluginConf {
+        plugin, ok := CreateObject(v).(IPlugin)
+        if !ok {
+            panic("Router: invalid plugin, " + Util.ToString(v))
+        }
+
+        chain = append(chain, plugin)
+    }
+
+    rule.chain = append(chain, plugins[last])
+    atomic.StoreInt32(&rule.chainReady, 1)
+    return rule.chain
+}
+
+// build plugin chains of rules added so far, eg. when server starts,
+// so a broken route plugin config fails startup instead of requests
+func (r *Router) buildChains(plugins []IPlugin, names []string) {
+    for _, rule := range r.rules {
+        rule.getChain(plugins, names)
+    }
+}
+
+func (rule *routeRule) matchMethod(method []string) bool {
+    if len(rule.method) == 0 || len(method) == 0 || len(method[0]) == 0 {
+        return true
+    }
+
+    return rule.method == strings.ToUpper(method[0])
+}
+
+// catch-all param is recognised as the last segment only, braces keep
+// regexp like "^/a/*b$" as it is
+var catchAllRe = regexp.MustCompile(`/\{\*([A-Za-z_][A-Za-z0-9_]*)\}(\$?)$`)
+
+// get name and position of catch-all param in pattern
+func catchAllOf(pattern string) (string, int) {
+    if loc := catchAllRe.FindStringSubmatchIndex(pattern); loc != nil {
+        return pattern[loc[2]:loc[3]], loc[0]
+    }
+
+    return "", 0
+}
+
+var httpMethods = map[string]bool{
+    http.MethodGet:     true,
+    http.MethodHead:    true,
+    http.MethodPost:    true,
+    http.MethodPut:     true,
+    http.MethodPatch:   true,
+    http.MethodDelete:  true,
+    http.MethodOptions: true,
 }
@@ -0,0 +1,234 @@
+package pgo
+
+import (
+    "encoding/json"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func newTestRouter() *Router {
+    r := &Router{}
+    r.Construct()
+    return r
+}
+
+func TestRouterMetaWithoutMethod(t *testing.T) {
+    r := newTestRouter()
+    r.AddRoute("^/user/list$", "user/list", map[string]interface{}{"summary": "list user"})
+
+    for _, method := range []string{"GET", "POST", "DELETE"} {
+        if route, _ := r.Resolve("/user/list", method); route != "user/List" {
+            t.Errorf("%s /user/list: want user/List, got %q", method, route)
+        }
+    }
+
+    for _, info := range r.Routes() {
+        if info.Method != "" {
+            t.Errorf("route without method: want empty method, got %q", info.Method)
+        }
+    }
+}
+
+func TestRouterMetaMethod(t *testing.T) {
+    r := newTestRouter()
+    meta := map[string]interface{}{"method": "post", "skipPlugins": []interface{}{"auth"}}
+    r.AddRoute("^/user/edit$", "user/edit", meta)
+    r.AddRoute("^/admin/edit$", "admin/edit", meta)
+
+    if route, _ := r.Resolve("/user/edit", "POST"); route != "user/Edit" {
+        t.Errorf("POST /user/edit: want user/Edit, got %q", route)
+    }
+
+    if route, _ := r.Resolve("/user/edit", "GET"); route == "user/Edit" {
+        t.Errorf("GET /user/edit: want no match, got %q", route)
+    }
+
+    // meta of caller is reused by the second rule
+    if route, _ := r.Resolve("/admin/edit", "GET"); route == "admin/Edit" || len(meta) != 2 {
+        t.Errorf("want meta of caller kept, got %q %v", route, meta)
+    }
+}
+
+func TestRouterHandler(t *testing.T) {
+    r := newTestRouter()
+    called := false
+    r.AddHandler("^/ping$", func(ctx *Context) { called = true }, map[string]interface{}{"summary": "ping"})
+
+    handler := r.ResolveHandler("/ping", "GET")
+    if handler == nil {
+        t.Fatal("GET /ping: want handler, got nil")
+    }
+
+    handler(nil)
+    if !called {
+        t.Error("handler is not called")
+    }
+
+    if r.ResolveHandler("/pong", "GET") != nil {
+        t.Error("GET /pong: want nil handler")
+    }
+}
+
+type RouteListController struct {
+    Controller
+}
+
+func (c *RouteListController) ActionIndex()    {}
+func (c *RouteListController) ActionUserInfo() {}
+func (c *RouteListController) ActionPOST()     {}
+
+func TestRouterRoutes(t *testing.T) {
+    App.GetContainer().BindName("Controller/RouteListController", &RouteListController{})
+    defer delete(App.GetContainer().items, "Controller/RouteListController")
+    names := App.GetServer().GetPluginNames()
+
+    r := newTestRouter()
+    r.AddRoute("^/user/(\\d+)$", "user/view", map[string]interface{}{
+        "method":  "GET",
+        "summary": "view user",
+        "plugins": []interface{}{map[string]interface{}{"class": "@pgo/Plugin/RateLimit"}},
+    })
+    r.AddHandler("^/ping$", func(ctx *Context) {}, nil)
+
+    routes := r.Routes()
+    if len(routes) < 2 {
+        t.Fatalf("want rules listed first, got %+v", routes)
+    }
+
+    rule := routes[0]
+    wantPlugins := append(append([]string{}, names...), "@pgo/Plugin/RateLimit")
+    if rule.Method != "GET" || rule.Pattern != "^/user/(\\d+)$" || rule.Handler != "/User/View" || !reflect.DeepEqual(rule.Plugins, wantPlugins) {
+        t.Errorf("want rule route, got %+v", rule)
+    }
+
+    if len(rule.Meta) != 1 || rule.Meta["summary"] != "view user" {
+        t.Errorf("want meta without method and plugins, got %v", rule.Meta)
+    }
+
+    if handler := routes[1].Handler; !strings.HasSuffix(handler, "TestRouterRoutes.func1") {
+        t.Errorf("want function name of handler, got %q", handler)
+    }
+
+    implicit := make(map[string]string)
+    for _, info := range routes[2:] {
+        if strings.HasPrefix(info.Handler, "/RouteList/") {
+            implicit[info.Method+" "+info.Pattern] = info.Handler
+        }
+    }
+
+    want := map[string]string{
+        " /route-list":           "/RouteList/Index",
+        " /route-list/user-info": "/RouteList/UserInfo",
+        "POST /route-list":       "/RouteList/POST",
+    }
+
+    if !reflect.DeepEqual(implicit, want) {
+        t.Errorf("want controller actions %v, got %v", want, implicit)
+    }
+}
+
+func TestRouterServeRoutes(t *testing.T) {
+    r := newTestRouter()
+    r.AddRoute("^/user/list$", "user/list", nil)
+
+    ctx, w := newRequestContext("GET", "/_routes", nil)
+    r.serveRoutes(ctx)
+
+    var routes []RouteInfo
+    if e := json.Unmarshal(w.Body.Bytes(), &routes); e != nil || len(routes) == 0 || routes[0].Pattern != "^/user/list$" {
+        t.Errorf("want json route list, got %q %v", w.Body.String(), e)
+    }
+
+    if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+        t.Errorf("want json content type, got %q", ct)
+    }
+}
+
+func TestRouterTrailingSlash(t *testing.T) {
+    tests := []struct {
+        mode, route, canonical string
+        redirect               bool
+    }{
+        {TrailingSlashStrict, "/User/List/", "/user/list/", false},
+        {TrailingSlashIgnore, "user/List", "/user/list/", false},
+        {TrailingSlashRedirect, "user/List", "/user/list", true},
+    }
+
+    for _, test := range tests {
+        r := newTestRouter()
+        r.SetTrailingSlash(test.mode)
+        r.AddRoute("^/user/list$", "user/list", nil)
+
+        if route, _ := r.Resolve("/user/list/", "GET"); route != test.route {
+            t.Errorf("%s: want route %q, got %q", test.mode, test.route, route)
+        }
+
+        if path, ok := r.Canonical("/user/list/"); path != test.canonical || ok != test.redirect {
+            t.Errorf("%s: want canonical %q %v, got %q %v", test.mode, test.canonical, test.redirect, path, ok)
+        }
+
+        if _, ok := r.Canonical("/"); ok {
+            t.Errorf("%s: want root path canonical", test.mode)
+        }
+    }
+}
+
+func TestRouterCaseInsensitive(t *testing.T) {
+    r := newTestRouter()
+    r.AddRoute(`^/User/(\d+)$`, "user/view", nil)
+
+    if route, _ := r.Resolve("/user/1", "GET"); route == "user/View" {
+        t.Errorf("case sensitive: want no match, got %q", route)
+    }
+
+    // existing rules are recompiled
+    r.SetCaseInsensitive(true)
+    if route, params := r.Resolve("/USER/1", "GET"); route != "user/View" || !reflect.DeepEqual(params, []string{"1"}) {
+        t.Errorf("case insensitive: want user/View [1], got %q %v", route, params)
+    }
+
+    // default routing lowers path
+    if route, _ := r.Resolve("/ADMIN/INDEX", "GET"); route != "/Admin/Index" {
+        t.Errorf("default route: want /Admin/Index, got %q", route)
+    }
+}
+
+func TestRouterCatchAll(t *testing.T) {
+    r := newTestRouter()
+    r.AddRoute("^/files/{*path}$", "file/view", nil)
+    r.AddRoute("^/files/index$", "file/index", nil)
+
+    tests := []struct {
+        path, route string
+        params      []string
+    }{
+        {"/files/a/b.txt", "file/View", []string{"a/b.txt"}},
+        {"/files", "file/View", []string{""}},
+        {"/files/index", "file/Index", []string{}},
+    }
+
+    for _, test := range tests {
+        if route, params := r.Resolve(test.path, "GET"); route != test.route || !reflect.DeepEqual(params, test.params) {
+            t.Errorf("%s: want %s %q, got %s %q", test.path, test.route, test.params, route, params)
+        }
+    }
+}
+
+func TestRouterRegexpNotCatchAll(t *testing.T) {
+    r := newTestRouter()
+    r.AddRoute("^/a/*b$", "a/b", nil)
+    r.AddRoute("^/x/*y/z$", "x/z", nil)
+
+    for path, want := range map[string]string{"/ab": "a/B", "/a//b": "a/B", "/xy/z": "x/Z", "/x/c": "/X/C"} {
+        if route, _ := r.Resolve(path, "GET"); route != want {
+            t.Errorf("%s: want %s of regexp, got %s", path, want, route)
+        }
+    }
+
+    for _, rule := range r.rules {
+        if len(rule.catchAll) > 0 {
+            t.Errorf("%s: want no catch-all, got %s", rule.pattern, rule.catchAll)
+        }
+    }
+}
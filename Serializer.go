@@ -0,0 +1,90 @@
+package pgo
+
+import (
+    "bytes"
+    "encoding/gob"
+    "encoding/json"
+    "fmt"
+    "reflect"
+
+    "github.com/pinguo/pgo/Util"
+)
+
+// create serializer of cache client, v is "json", "gob" or class
+// of an ISerializer, eg. "@app/Lib/MsgpackSerializer"
+func CreateSerializer(v interface{}) ISerializer {
+    switch v {
+    case "json":
+        return &JsonSerializer{}
+    case "gob":
+        return &GobSerializer{}
+    }
+
+    if serializer, ok := CreateObject(v).(ISerializer); ok {
+        return serializer
+    }
+
+    panic(fmt.Sprintf("invalid serializer: %s", Util.ToString(v)))
+}
+
+// serialize non-scalar value by serializer, scalar value is kept as
+// is so it's stored as plain text, eg. for Incr, value is returned as
+// is if serializer is nil
+func Serialize(serializer ISerializer, value interface{}) interface{} {
+    if serializer == nil || value == nil || isScalar(reflect.TypeOf(value)) {
+        return value
+    }
+
+    data, e := serializer.Serialize(value)
+    if e != nil {
+        panic(fmt.Sprintf("failed to serialize %T, %s", value, e))
+    }
+
+    return data
+}
+
+// decode value stored by Serialize to ptr, scalar ptr and value of
+// nil serializer are decoded by Value.TryDecode
+func Unserialize(serializer ISerializer, v *Value, ptr interface{}) error {
+    if serializer != nil && !isScalar(reflect.TypeOf(ptr).Elem()) {
+        return serializer.Unserialize(v.Bytes(), ptr)
+    }
+
+    return v.TryDecode(ptr)
+}
+
+func isScalar(rt reflect.Type) bool {
+    switch rt.Kind() {
+    case reflect.Slice:
+        return rt.Elem().Kind() == reflect.Uint8
+    case reflect.Struct, reflect.Map, reflect.Array, reflect.Ptr, reflect.Interface:
+        return false
+    }
+    return true
+}
+
+// json serializer
+type JsonSerializer struct {
+}
+
+func (j *JsonSerializer) Serialize(v interface{}) ([]byte, error) {
+    return json.Marshal(v)
+}
+
+func (j *JsonSerializer) Unserialize(data []byte, ptr interface{}) error {
+    return json.Unmarshal(data, ptr)
+}
+
+// gob serializer
+type GobSerializer struct {
+}
+
+func (g *GobSerializer) Serialize(v interface{}) ([]byte, error) {
+    buf := &bytes.Buffer{}
+    e := gob.NewEncoder(buf).Encode(v)
+    return buf.Bytes(), e
+}
+
+func (g *GobSerializer) Unserialize(data []byte, ptr interface{}) error {
+    return gob.NewDecoder(bytes.NewReader(data)).Decode(ptr)
+}
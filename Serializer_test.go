@@ -0,0 +1,52 @@
+package pgo
+
+import (
+    "reflect"
+    "testing"
+)
+
+type serializerUser struct {
+    Name string
+    Tags []string
+}
+
+func TestSerializerRoundTrip(t *testing.T) {
+    for _, name := range []string{"json", "gob"} {
+        s := CreateSerializer(name)
+        data := Serialize(s, &serializerUser{"pgo", []string{"go"}})
+        if _, ok := data.([]byte); !ok {
+            t.Fatalf("%s: want struct serialized to bytes, got %T", name, data)
+        }
+
+        user := serializerUser{}
+        if e := Unserialize(s, NewValue(data), &user); e != nil || !reflect.DeepEqual(user, serializerUser{"pgo", []string{"go"}}) {
+            t.Errorf("%s: want user decoded, got %+v %v", name, user, e)
+        }
+    }
+}
+
+func TestSerializerScalar(t *testing.T) {
+    s := CreateSerializer("gob")
+    if v := Serialize(s, 10); v != 10 {
+        t.Errorf("scalar: want kept as is, got %v", v)
+    }
+
+    n := 0
+    if e := Unserialize(s, NewValue("10"), &n); e != nil || n != 10 {
+        t.Errorf("scalar: want decoded as plain text, got %d %v", n, e)
+    }
+
+    if v := Serialize(nil, map[string]int{"a": 1}); !reflect.DeepEqual(v, map[string]int{"a": 1}) {
+        t.Errorf("nil serializer: want value as is, got %v", v)
+    }
+}
+
+func TestCreateSerializerInvalid(t *testing.T) {
+    defer func() {
+        if recover() == nil {
+            t.Error("want panic for class not implementing ISerializer")
+        }
+    }()
+
+    CreateSerializer("@pgo/Router")
+}
@@ -1,14 +1,27 @@
 package pgo
 
 import (
+    "bufio"
+    "bytes"
     "context"
+    "crypto/tls"
+    "encoding/json"
+    "errors"
     "flag"
+    "fmt"
+    "io"
+    "io/fs"
+    "io/ioutil"
+    "net"
     "net/http"
     "os"
     "os/signal"
     "path/filepath"
     "reflect"
+    "regexp"
     "runtime"
+    "runtime/debug"
+    "strconv"
     "strings"
     "sync"
     "sync/atomic"
@@ -18,6 +31,8 @@ import (
     "github.com/pinguo/pgo/Util"
 )
 
+var errInvalidHost = errors.New("invalid host")
+
 // server configuration:
 // "server": {
 //     "addr": "0.0.0.0:8000",
@@ -27,23 +42,161 @@ import (
 //     "fileEnable": true,
 //     "gzipEnable": true,
 //     "gzipMinBytes": 1024,
+//     "maxBodyBytes": 10485760,
+//     "maxMemoryBytes": 33554432,
 //     "statsInterval": "60s",
-//     "errorLogOff": [404]
+//     "slowWarnRatio": 0.8,
+//     "slowThreshold": "1s",
+//     "errorLogOff": [404],
+//     "versionPath": "/version",
+//     "proxyProtocol": false,
+//     "trustedProxies": ["10.0.0.0/8"],
+//     "stopTimeout": "10s",
+//     "serverTiming": false,
+//     "altSvc": "h3=\":443\"; ma=86400",
+//     "cookieKeys": ["${COOKIE_KEY}", "${COOKIE_KEY_OLD}"],
+//     "h2c": false,
+//     "adminPath": "/_admin",
+//     "adminToken": "${ADMIN_TOKEN}",
+//     "maxConcurrent": 0,
+//     "concurrentWait": "0s",
+//     "shedRetryAfter": "1s",
+//     "allowedHosts": ["example.com", "*.example.com"],
+//     "certs": [{"cert": "@app/cert/a.pem", "key": "@app/cert/a.key", "hosts": ["a.com"]}],
+//     "plugins": [
+//         "@pgo/Plugin/ResponseCache",
+//         {"class": "@app/Lib/Plugin/Auth", "realm": "api"}
+//     ]
 // }
+//
+// plugins run in order for each web request, a plugin continues the
+// chain by ctx.Next(), the controller action runs after the last one.
+// slowWarnRatio warns request running beyond the ratio of its deadline,
+// deadline is the writeTimeout, 0 to disable. slowThreshold warns request
+// finished slower than it with route, status and Server-Timing phases if
+// serverTiming is enabled, 0 to disable. maxMemoryBytes limits
+// multipart form kept in memory, the rest is spilled to temp files.
+// versionPath serves App.GetBuildInfo() as json, disabled if empty.
+// proxyProtocol requires PROXY protocol(v1/v2) header on each connection
+// to recover client address, enable it only behind a TCP load balancer,
+// if trustedProxies is set, connections from other peers are rejected.
+// trustedProxies are peers whose X-Forwarded-For, X-Client-Ip and
+// X-Real-Ip headers are honoured by ctx.GetClientIp, see GetClientIp.
+// stopTimeout limits graceful shutdown, including in-flight requests and
+// background jobs started by ctx.Go. serverTiming adds Server-Timing
+// header of routing, middleware, action, render and ctx.Timing phases.
+// altSvc is sent as Alt-Svc header over tls while a server added by
+// AddAltSvcServer is running, pgo has no http3 server of its own, it
+// only advertises one provided by app(eg. quic-go). cookieKeys are keys of signed and encrypted
+// cookies, the first one is used to sign, all are accepted to verify,
+// so a new key is prepended for rotation, empty keys are ignored.
+// static files are served from public path, or fs.FS set by SetFileFS.
+// h2c serves http/2 without tls on the same port(go1.24+), so grpc
+// handler set by SetGrpc shares the listener with http/1.1 requests.
+// adminToken enables POST {adminPath}/drain and {adminPath}/shutdown
+// with "Authorization: Bearer <token>" header for deploy tooling, drain
+// fails readiness and rejects new requests, shutdown stops as SIGTERM.
+// maxConcurrent caps requests being served(0 for unlimited), request
+// beyond it waits a slot up to concurrentWait, then gets 503 with
+// Retry-After of shedRetryAfter, readiness and admin paths are exempt,
+// in-flight and shed count are reported by stats, see GetInFlight.
+// certs serves https on addr, certificate is selected by SNI, the first
+// is default, certificate files are reloaded on SIGHUP, see SetCerts.
+// allowedHosts rejects request whose Host(port ignored) is not listed
+// with 400 before anything else, "*.example.com" matches subdomains of
+// example.com, it guards against host header attacks, empty to disable.
 type Server struct {
     http *http.Server
 
-    FileEnable   bool // static file in public path enabled
-    GzipEnable   bool // gzip output compress enabled
-    GzipMinBytes int  // minimum bytes for gzip output
+    FileEnable     bool // static file in public path enabled
+    GzipEnable     bool // gzip output compress enabled
+    GzipMinBytes   int  // minimum bytes for gzip output
+    MaxBodyBytes   int  // maximum bytes for buffered request body
+    MaxMemoryBytes int  // maximum bytes of multipart form in memory
+
+    fileFs fs.FS // file system of static files, nil for public path
 
     statsInterval time.Duration // interval for output server stats
+    slowWarnRatio float64       // warn ratio of request deadline
+    slowThreshold time.Duration // warn threshold of request duration
     errorLogOff   map[int]bool  // close error log for specific code
+    versionPath   string        // path to serve build info
+    proxyProtocol bool          // PROXY protocol enabled
+    trustedProxies []*net.IPNet // peers whose forwarded headers are honoured
+    stopTimeout   time.Duration // max time for graceful shutdown
+    serverTiming  bool          // Server-Timing header enabled
+    cookieKeys    [][]byte      // keys of signed cookie, first to sign
+
+    exitCode int // exit code of registered command
 
     totalReq uint64 // total requests since server start
     numReq   uint64 // num requests since last stats output
+
+    panicHandlers []PanicHandler // handlers called after panic recovered
+    errorHandler  ErrorHandler   // handler to render 5xx response
+
+    altSvc     string        // Alt-Svc header value
+    extras     []extraServer // extra servers sharing the handler
+    altRunning int32         // num of running servers advertised by altSvc
+
+    grpc     http.Handler // handler of grpc requests, eg. *grpc.Server
+    grpcStop func()       // stop grpc on shutdown, eg. GracefulStop
+
+    adminPath  string    // path prefix of admin endpoints
+    adminToken string    // token of admin endpoints, empty to disable
+    draining   int32     // drain mode, 1 if draining
+    stopChan   chan bool // shutdown requested by admin endpoint
+
+    maxConcurrent  int           // max concurrent requests, 0 for unlimited
+    concurrentWait time.Duration // max wait of queued request
+    shedRetryAfter time.Duration // Retry-After of shed request
+    slots          chan struct{} // slots of concurrent requests
+    inFlight       int64         // num requests being served
+    numShed        uint64        // num requests shed since server start
+
+    allowedHosts []string // permitted hosts, "*." prefix for subdomains
+
+    tlsCerts []tlsCert    // configuration of tls certificates
+    certs    atomic.Value // loaded certificates, *certStore
+
+    pluginConf  []interface{} // plugin configurations
+    pluginUses  []pluginUse   // plugins registered by Use
+    plugins     []IPlugin     // plugin chain, router plugin is the last
+    pluginNames []string      // plugin class names
+    pluginLock  sync.Mutex
+    pluginReady int32         // 1 if plugin chain is built
+}
+
+// plugin registered by Use, inserted before or after target if set,
+// configured plugin has conf instead of plugin
+type pluginUse struct {
+    name   string
+    plugin IPlugin
+    conf   interface{}
+    target string
+    after  bool
+}
+
+// extra server sharing the handler, eg. http3 advertised by altSvc
+type extraServer struct {
+    serve      func(handler http.Handler) error
+    shutdown   func(ctx context.Context) error
+    advertised bool
+}
+
+// adapter to use ordinary function as plugin
+type PluginFunc func(ctx *Context)
+
+func (f PluginFunc) HandleRequest(ctx *Context) {
+    f(ctx)
 }
 
+// handler to report recovered panic, eg. send to error-tracking service
+type PanicHandler func(ctx *Context, recovered interface{}, stack []byte)
+
+// handler to render response of 5xx panic, eg. branded error page
+type ErrorHandler func(ctx *Context, status int, recovered interface{})
+
 func (s *Server) Construct() {
     s.http = &http.Server{
         Addr:           DefaultServerAddr,
@@ -56,8 +209,96 @@ func (s *Server) Construct() {
     s.FileEnable = true
     s.GzipEnable = true
     s.GzipMinBytes = 1024
+    s.MaxBodyBytes = DefaultBodyBytes
+    s.MaxMemoryBytes = DefaultMemoryBytes
 
     s.statsInterval = 60 * time.Second
+    s.adminPath = DefaultAdminPath
+    s.stopChan = make(chan bool, 1)
+    s.stopTimeout = 10 * time.Second
+    s.shedRetryAfter = time.Second
+}
+
+func (s *Server) SetAltSvc(v string) {
+    s.altSvc = v
+}
+
+// add extra server sharing the handler of this server, it's started
+// with the http server and shutdown gracefully with it
+func (s *Server) AddExtraServer(serve func(handler http.Handler) error, shutdown func(ctx context.Context) error) {
+    s.extras = append(s.extras, extraServer{serve, shutdown, false})
+}
+
+// add extra server of alternative service, altSvc is advertised on
+// tls responses while it's running, the server(eg. http3 of quic-go)
+// is provided by app, pgo only shares the handler and shutdown, eg.
+// h3 := &http3.Server{Addr: ":443", TLSConfig: tlsConf}
+// pgo.App.GetServer().AddAltSvcServer(func(h http.Handler) error {
+//     h3.Handler = h
+//     return h3.ListenAndServe()
+// }, func(ctx context.Context) error { return h3.Shutdown(ctx) })
+func (s *Server) AddAltSvcServer(serve func(handler http.Handler) error, shutdown func(ctx context.Context) error) {
+    s.extras = append(s.extras, extraServer{serve, shutdown, true})
+}
+
+func (s *Server) SetCookieKeys(keys []interface{}) {
+    s.cookieKeys = make([][]byte, 0, len(keys))
+    for _, v := range keys {
+        if key := Util.ToString(v); len(key) > 0 {
+            s.cookieKeys = append(s.cookieKeys, []byte(key))
+        }
+    }
+}
+
+// get keys of signed cookie, panic if not configured
+func (s *Server) GetCookieKeys() [][]byte {
+    if len(s.cookieKeys) == 0 {
+        panic("Server: cookieKeys is not configured")
+    }
+
+    return s.cookieKeys
+}
+
+// set file system of static files, root of fs is the public path,
+// eg. embed.FS for single binary, nil to serve from disk
+func (s *Server) SetFileFS(fsys fs.FS) {
+    s.fileFs = fsys
+}
+
+// serve http/2 without tls besides http/1.1, eg. for grpc clients
+func (s *Server) SetH2c(enable bool) {
+    enableH2c(s.http, enable)
+}
+
+// set handler of grpc requests(http/2 with application/grpc content
+// type), eg. *grpc.Server which implements http.Handler, so grpc is not
+// a dependency of framework, stop is called on shutdown if not nil:
+// g := grpc.NewServer()
+// pb.RegisterGreeterServer(g, &greeter{})
+// pgo.App.GetServer().SetH2c(true)
+// pgo.App.GetServer().SetGrpc(g, g.Stop)
+func (s *Server) SetGrpc(handler http.Handler, stop func()) {
+    s.grpc, s.grpcStop = handler, stop
+}
+
+func (s *Server) SetAdminPath(path string) {
+    if len(path) > 0 {
+        path = Util.CleanPath(path)
+    }
+
+    s.adminPath = path
+}
+
+func (s *Server) SetAdminToken(token string) {
+    s.adminToken = token
+}
+
+func (s *Server) SetServerTiming(enable bool) {
+    s.serverTiming = enable
+}
+
+func (s *Server) SetStopTimeout(timeout string) {
+    s.stopTimeout, _ = time.ParseDuration(timeout)
 }
 
 func (s *Server) SetAddr(addr string) {
@@ -76,6 +317,98 @@ func (s *Server) SetMaxHeaderBytes(maxBytes int) {
     s.http.MaxHeaderBytes = maxBytes
 }
 
+func (s *Server) SetSlowWarnRatio(ratio float64) {
+    if ratio < 0 || ratio >= 1 {
+        panic("Server: slowWarnRatio must be in [0, 1)")
+    }
+
+    s.slowWarnRatio = ratio
+}
+
+func (s *Server) SetSlowThreshold(threshold string) {
+    s.slowThreshold, _ = time.ParseDuration(threshold)
+}
+
+func (s *Server) SetVersionPath(path string) {
+    if len(path) > 0 {
+        path = Util.CleanPath(path)
+    }
+
+    s.versionPath = path
+}
+
+func (s *Server) SetProxyProtocol(enable bool) {
+    s.proxyProtocol = enable
+}
+
+// set ips or cidrs of proxies trusted to forward client ip
+func (s *Server) SetTrustedProxies(ips []interface{}) {
+    s.trustedProxies = make([]*net.IPNet, 0, len(ips))
+    for _, v := range ips {
+        ipNet, e := Util.ParseIpNet(strings.TrimSpace(Util.ToString(v)))
+        if e != nil {
+            panic("Server: invalid trusted proxy, " + e.Error())
+        }
+
+        s.trustedProxies = append(s.trustedProxies, ipNet)
+    }
+}
+
+// check if ip is of a trusted proxy
+func (s *Server) IsTrustedProxy(ip string) bool {
+    if parsed := net.ParseIP(ip); parsed != nil {
+        for _, ipNet := range s.trustedProxies {
+            if ipNet.Contains(parsed) {
+                return true
+            }
+        }
+    }
+
+    return false
+}
+
+// set permitted values of Host header, empty to allow any host, IPv6
+// is with or without brackets, eg. ["example.com", "*.example.com", "::1"]
+func (s *Server) SetAllowedHosts(hosts []interface{}) {
+    s.allowedHosts = make([]string, 0, len(hosts))
+    for _, v := range hosts {
+        if host := normalizeHost(Util.ToString(v)); len(host) > 0 {
+            s.allowedHosts = append(s.allowedHosts, host)
+        }
+    }
+}
+
+// check if host(with optional port) is allowed by allowedHosts
+func (s *Server) IsAllowedHost(host string) bool {
+    if len(s.allowedHosts) == 0 {
+        return true
+    }
+
+    if h, _, e := net.SplitHostPort(host); e == nil {
+        host = h
+    }
+
+    host = normalizeHost(host)
+    for _, allowed := range s.allowedHosts {
+        if allowed == host || allowed == "*" {
+            return true
+        } else if strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) && len(host) > len(allowed)-1 {
+            return true
+        }
+    }
+
+    return false
+}
+
+// lowercase host without brackets of IPv6 and trailing dot
+func normalizeHost(host string) string {
+    host = strings.ToLower(strings.TrimSpace(host))
+    if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+        host = host[1 : len(host)-1]
+    }
+    return strings.TrimSuffix(host, ".")
+}
+
 func (s *Server) SetErrorLogOff(codes []interface{}) {
     s.errorLogOff = make(map[int]bool)
     for _, v := range codes {
@@ -83,10 +416,176 @@ func (s *Server) SetErrorLogOff(codes []interface{}) {
     }
 }
 
+// set plugin configurations, plugins are created on the first request,
+// because plugin class is usually bound after server initialization
+func (s *Server) SetPlugins(plugins []interface{}) {
+    s.pluginConf = plugins
+}
+
+// append named plugin to the chain after configured plugins, the chain
+// runs in order: configured plugins, then plugins of Use, UseBefore and
+// UseAfter in registering order, then route plugins and the action.
+// a plugin stops the chain by not calling ctx.Next(), eg. responding
+// 401 in auth, plugins before it still run their code after ctx.Next().
+// name is used by UseBefore, UseAfter and route skips, name of configured
+// plugin is its class, eg. "@pgo/Plugin/Auth". plugins must be registered
+// before the first request, eg. in main before pgo.Run().
+func (s *Server) Use(name string, plugin IPlugin) {
+    s.addUse(pluginUse{name: name, plugin: plugin})
+}
+
+// insert named plugin before plugin of target name, see Use
+func (s *Server) UseBefore(target, name string, plugin IPlugin) {
+    s.addUse(pluginUse{name: name, plugin: plugin, target: target})
+}
+
+// insert named plugin after plugin of target name, see Use
+func (s *Server) UseAfter(target, name string, plugin IPlugin) {
+    s.addUse(pluginUse{name: name, plugin: plugin, target: target, after: true})
+}
+
+func (s *Server) addUse(use pluginUse) {
+    if len(use.name) == 0 || use.plugin == nil {
+        panic("Server: plugin name and plugin cannot be empty")
+    } else if s.isPluginReady() {
+        panic("Server: plugin chain is built, cannot add plugin " + use.name)
+    }
+
+    // target must be configured or registered before
+    uses := append(s.pluginUses[:len(s.pluginUses):len(s.pluginUses)], use)
+    if _, e := orderPlugins(s.pluginConf, uses); e != nil {
+        panic(e.Error())
+    }
+
+    s.pluginUses = uses
+}
+
+// get names of plugins in chain order, router plugin excluded,
+// plugins are not loaded by it, eg. to list routes in main
+func (s *Server) GetPluginNames() []string {
+    if s.isPluginReady() {
+        return append([]string(nil), s.pluginNames...)
+    }
+
+    ordered, e := orderPlugins(s.pluginConf, s.pluginUses)
+    if e != nil {
+        panic(e.Error())
+    }
+
+    names := make([]string, 0, len(ordered))
+    for _, use := range ordered {
+        names = append(names, use.name)
+    }
+
+    return names
+}
+
+// get plugin chain, router plugin is the last, chain is built once
+// by the first call, eg. when server starts, failed build panics and
+// is retried by the next call, so a broken chain is never cached
+func (s *Server) GetPlugins() []IPlugin {
+    if s.isPluginReady() {
+        return s.plugins
+    }
+
+    s.pluginLock.Lock()
+    defer s.pluginLock.Unlock()
+
+    if !s.isPluginReady() {
+        s.loadPlugins()
+    }
+
+    return s.plugins
+}
+
+func (s *Server) isPluginReady() bool {
+    return atomic.LoadInt32(&s.pluginReady) == 1
+}
+
+// build plugin chain, fields are set only if all plugins are created
+func (s *Server) loadPlugins() {
+    ordered, e := orderPlugins(s.pluginConf, s.pluginUses)
+    if e != nil {
+        panic(e.Error())
+    }
+
+    plugins := make([]IPlugin, 0, len(ordered)+1)
+    names := make([]string, 0, len(ordered))
+    for _, use := range ordered {
+        if use.plugin == nil {
+            plugin, ok := CreateObject(use.conf).(IPlugin)
+            if !ok {
+                panic("Server: invalid plugin, " + Util.ToString(use.conf))
+            }
+            use.plugin = plugin
+        }
+
+        plugins = append(plugins, use.plugin)
+        names = append(names, use.name)
+    }
+
+    s.plugins, s.pluginNames = append(plugins, PluginFunc(s.handleRoute)), names
+    atomic.StoreInt32(&s.pluginReady, 1)
+}
+
+// get configured plugins and plugins of Use in chain order, plugin of
+// configuration is nil, it's created by loadPlugins, error if target
+// of UseBefore or UseAfter is not found
+func orderPlugins(conf []interface{}, uses []pluginUse) ([]pluginUse, error) {
+    ordered := make([]pluginUse, 0, len(conf)+len(uses))
+    for _, v := range conf {
+        name := Util.ToString(v)
+        if m, ok := v.(map[string]interface{}); ok {
+            name = Util.ToString(m["class"])
+        }
+
+        ordered = append(ordered, pluginUse{name: name, conf: v})
+    }
+
+    for _, use := range uses {
+        pos := len(ordered)
+        if len(use.target) > 0 {
+            pos = -1
+            for i := range ordered {
+                if ordered[i].name == use.target {
+                    pos = i
+                    break
+                }
+            }
+
+            if pos == -1 {
+                return nil, fmt.Errorf("Server: plugin %s not found for %s", use.target, use.name)
+            } else if use.after {
+                pos++
+            }
+        }
+
+        ordered = append(ordered[:pos], append([]pluginUse{use}, ordered[pos:]...)...)
+    }
+
+    return ordered, nil
+}
+
 func (s *Server) SetStatsInterval(interval string) {
     s.statsInterval, _ = time.ParseDuration(interval)
 }
 
+// add handler called after a request panic is recovered and logged,
+// handlers run in order, panic of a handler is isolated and logged
+func (s *Server) OnPanic(handler PanicHandler) {
+    s.panicHandlers = append(s.panicHandlers, handler)
+}
+
+// set handler to render response of 5xx panic recovered by server or
+// default Controller.HandlePanic, it replaces the default output
+func (s *Server) SetErrorHandler(handler ErrorHandler) {
+    s.errorHandler = handler
+}
+
+func (s *Server) GetErrorHandler() ErrorHandler {
+    return s.errorHandler
+}
+
 func (s *Server) IsErrorLogOff(status int) bool {
     return s.errorLogOff[status]
 }
@@ -111,11 +610,12 @@ func (s *Server) Serve() {
             GLogger().Info("stop running http at %s", s.http.Addr)
         }
 
+        App.Stop(s.stopTimeout)
         App.GetLog().Flush()
     }()
     // debug pprof
     if enableDebugServer == true {
-        go func() {
+        Go(func() {
             dAddr := App.GetConfig().GetString("params.debugServer.addr", "0.0.0.0:8100")
             GLogger().Info("start running debug http at %s", dAddr)
             ds := &http.Server{
@@ -124,20 +624,68 @@ func (s *Server) Serve() {
                 WriteTimeout: 40 * time.Second,
             }
             ds.ListenAndServe()
-        }()
+        })
+    }
+    // missing conf directory is fatal in strict mode if no config fs
+    App.GetConfig().checkDir()
+
+    // report config errors skipped in permissive mode
+    for _, e := range App.GetConfig().GetErrors() {
+        GLogger().Warn("%s", e.Error())
     }
+
+    // report path problems found on init
+    for _, w := range App.warnings {
+        GLogger().Warn("%s", w)
+    }
+
+    info := App.GetBuildInfo()
+    GLogger().Info("build info, version:%s, commit:%s, buildTime:%s, go:%s, pgo:%s",
+        info.Version, info.Commit, info.BuildTime, info.GoVersion, info.PgoVersion)
+
     if App.GetMode() == ModeCmd {
+        App.finishBoot()
+        s.logBanner()
         GLogger().Info("start running command %s", flag.Lookup("cmd").Value)
         s.ServeCMD()
     } else {
+        // load health component to register readiness endpoint,
+        // and run warmup hooks before accepting traffic
+        App.GetHealth().Warmup()
+        s.addAdminHandlers()
+        App.GetStatus().addStatsHandler()
+        s.addVersionHandler()
+
+        // build plugin chains before accepting traffic, so a broken
+        // plugin config fails startup instead of requests
+        App.GetRouter().buildChains(s.GetPlugins(), s.pluginNames)
+
+        // components loaded so far are eager, the rest are lazy
+        App.finishBoot()
+        s.logBanner()
+
         GLogger().Info("start running http at %s", s.http.Addr)
         wg := sync.WaitGroup{}
         wg.Add(1)
 
+        for _, extra := range s.extras {
+            extra := extra
+            Go(func() {
+                if extra.advertised {
+                    atomic.AddInt32(&s.altRunning, 1)
+                    defer atomic.AddInt32(&s.altRunning, -1)
+                }
+
+                if e := extra.serve(s); e != nil && e != http.ErrServerClosed {
+                    GLogger().Error("extra server failed, %s", e)
+                }
+            })
+        }
+
         // new goroutine to handle signal and statistics
-        go s.handleSigAndStats(&wg)
+        Go(func() { s.handleSigAndStats(&wg) })
 
-        if e := s.http.ListenAndServe(); e != http.ErrServerClosed {
+        if e := s.listenAndServe(); e != http.ErrServerClosed {
             GLogger().Fatal("ListenAndServe failed, %s", e)
         } else {
             wg.Wait() // wait completion of shutdown
@@ -145,9 +693,77 @@ func (s *Server) Serve() {
     }
 }
 
+// listen on addr and serve, listener is wrapped if proxyProtocol enabled
+func (s *Server) listenAndServe() error {
+    if !s.proxyProtocol && !s.IsTls() {
+        return s.http.ListenAndServe()
+    }
+
+    ln, e := net.Listen("tcp", s.http.Addr)
+    if e != nil {
+        return e
+    }
+
+    if s.proxyProtocol {
+        proxyLn := &proxyListener{Listener: ln, timeout: s.http.ReadTimeout}
+        if len(s.trustedProxies) > 0 {
+            proxyLn.trusted = s.IsTrustedProxy
+        }
+        ln = proxyLn
+    }
+
+    if s.IsTls() {
+        ln = tls.NewListener(ln, s.tlsConfig())
+    }
+
+    return s.http.Serve(ln)
+}
+
+// add handler of versionPath if set
+func (s *Server) addVersionHandler() {
+    if len(s.versionPath) > 0 {
+        App.GetRouter().AddHandler("^"+regexp.QuoteMeta(s.versionPath)+"$", s.serveVersion, map[string]interface{}{
+            "method":  http.MethodGet,
+            "summary": "get build info",
+        })
+    }
+}
+
+func (s *Server) serveVersion(ctx *Context) {
+    output, _ := json.Marshal(App.GetBuildInfo())
+    ctx.SetHeader("Content-Type", "application/json; charset=utf-8")
+    ctx.End(http.StatusOK, output)
+}
+
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
     atomic.AddUint64(&s.numReq, 1)
 
+    // probes usually come with ip as host, so they are exempt like drain
+    if !s.IsAllowedHost(r.Host) && !s.isDrainExempt(r.URL.Path) {
+        http.Error(w, errInvalidHost.Error(), http.StatusBadRequest)
+        return
+    }
+
+    if s.IsDraining() && !s.isDrainExempt(r.URL.Path) {
+        w.Header().Set("Connection", "close")
+        http.Error(w, errDraining.Error(), http.StatusServiceUnavailable)
+        return
+    }
+
+    atomic.AddInt64(&s.inFlight, 1)
+    defer atomic.AddInt64(&s.inFlight, -1)
+
+    slots, ok := s.acquire(w, r)
+    if !ok {
+        return
+    }
+    defer s.release(slots)
+
+    if s.grpc != nil && r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+        s.grpc.ServeHTTP(w, r)
+        return
+    }
+
     if s.FileEnable {
         // process static file
         if ext := filepath.Ext(r.URL.Path); len(ext) > 0 {
@@ -156,33 +772,122 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
         }
     }
 
+    // advertise alternative service only if it's reachable
+    if len(s.altSvc) > 0 && r.TLS != nil && atomic.LoadInt32(&s.altRunning) > 0 {
+        w.Header().Set("Alt-Svc", s.altSvc)
+    }
+
     // process http service
     ctx := &Context{}
     ctx.SetInput(r)
-    ctx.SetOutput(w)
+    ctx.writer = &sentWriter{ResponseWriter: w}
+    ctx.SetOutput(ctx.writer)
     ctx.Init()
+    defer ctx.cleanup()
+    defer s.observe(ctx)
+
+    if s.slowThreshold > 0 {
+        defer s.checkSlow(ctx)
+    }
+
+    if timeout := s.http.WriteTimeout; timeout > 0 {
+        ctx.setDeadline(ctx.startTime.Add(timeout))
+        if s.slowWarnRatio > 0 {
+            timer := time.AfterFunc(time.Duration(float64(timeout)*s.slowWarnRatio), func() {
+                ctx.Warn("slow request, %s %s, elapsed %dms of %dms deadline",
+                    r.Method, ctx.GetPath(), ctx.GetElapseMs(), timeout/time.Millisecond)
+            })
+            defer timer.Stop()
+        }
+    }
+
     s.handleRequest(ctx)
 }
 
+// record route stats of finished request, request size is
+// Content-Length, 0 if unknown, response size is body written
+func (s *Server) observe(ctx *Context) {
+    reqBytes := ctx.GetInput().ContentLength
+    if reqBytes < 0 {
+        reqBytes = 0
+    }
+
+    App.GetStatus().observe(ctx, reqBytes, ctx.writer.size)
+}
+
+// warn request slower than slowThreshold
+func (s *Server) checkSlow(ctx *Context) {
+    elapse := time.Since(ctx.startTime)
+    if elapse <= s.slowThreshold {
+        return
+    }
+
+    route := ctx.GetPath()
+    if id := ctx.GetControllerId(); len(id) > 0 {
+        route = id + "/" + ctx.GetActionId()
+    }
+
+    phases := make([]string, 0, len(ctx.timings))
+    for _, t := range ctx.timings {
+        phases = append(phases, fmt.Sprintf("%s=%dms", t.name, t.dur/time.Millisecond))
+    }
+
+    ctx.Warn("slow request, %s %s, route:%s, status:%d, elapsed %dms of %dms threshold, logId:%s, timing[%s]",
+        ctx.GetMethod(), ctx.GetPath(), route, ctx.status, elapse/time.Millisecond,
+        s.slowThreshold/time.Millisecond, ctx.GetLogId(), strings.Join(phases, " "))
+}
+
+// log startup summary after boot if banner enabled
+func (s *Server) logBanner() {
+    if App.banner {
+        GLogger().Info("%s", App.GetBanner())
+    }
+}
+
 func (s *Server) ServeCMD() {
     ctx := &Context{}
     ctx.Init()
 
+    // run registered command, others are routed to controllers,
+    // name of neither lists commands with non-zero exit code
+    if name := flag.Lookup("cmd").Value.String(); App.hasCommand(name) || !s.hasRoute(ctx) {
+        s.exitCode = App.runCommand(ctx, name)
+        return
+    }
+    ctx.setPlugins([]IPlugin{PluginFunc(s.handleRoute)})
+
     s.handleRequest(ctx)
 }
 
 // goroutine to handle signal and statistics
 func (s *Server) handleSigAndStats(wg *sync.WaitGroup) {
+    defer wg.Done()
+
     sig := make(chan os.Signal)
     signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
     timer := time.Tick(s.statsInterval)
 
+    // reload certificates on SIGHUP
+    hup := make(chan os.Signal, 1)
+    if s.IsTls() {
+        signal.Notify(hup, syscall.SIGHUP)
+        defer signal.Stop(hup)
+    }
+
     for {
         select {
         case <-sig:
-            ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-            s.http.Shutdown(ctx)
-            goto end
+            s.stop()
+            return
+        case <-s.stopChan:
+            s.stop()
+            return
+        case <-hup:
+            if e := s.ReloadCerts(); e != nil {
+                GLogger().Error("reload certs failed, %s", e)
+            } else {
+                GLogger().Notice("certs reloaded")
+            }
         case <-timer:
             memStats := runtime.MemStats{}
             runtime.ReadMemStats(&memStats)
@@ -190,8 +895,8 @@ func (s *Server) handleSigAndStats(wg *sync.WaitGroup) {
             numReq := atomic.SwapUint64(&s.numReq, 0)
             s.totalReq += numReq
 
-            GLogger().Info("app stats, totalReq:%d, lastReq:%d, numGO:%d, sysMem(mb):%d, totalGC(ms):%d, lastGC(ms):%d",
-                s.totalReq, numReq,
+            GLogger().Info("app stats, totalReq:%d, lastReq:%d, inFlight:%d, shed:%d, numGO:%d, sysMem(mb):%d, totalGC(ms):%d, lastGC(ms):%d",
+                s.totalReq, numReq, s.GetInFlight(), s.GetShedCount(),
                 runtime.NumGoroutine(),
                 memStats.Sys/(1<<20),
                 memStats.PauseTotalNs/1e6,
@@ -199,18 +904,43 @@ func (s *Server) handleSigAndStats(wg *sync.WaitGroup) {
             )
         }
     }
+}
+
+// stop gracefully within stop timeout, extra servers and http server
+// are shutdown first, then background jobs are drained, App.Stop of
+// Serve is a no-op after it
+func (s *Server) stop() {
+    deadline := time.Now().Add(s.stopTimeout)
+    ctx, cancel := context.WithDeadline(context.Background(), deadline)
+    for _, extra := range s.extras {
+        if e := extra.shutdown(ctx); e != nil {
+            GLogger().Warn("shutdown extra server failed, %s", e)
+        }
+    }
+    if s.grpcStop != nil {
+        s.grpcStop()
+    }
+    s.http.Shutdown(ctx)
+    cancel()
 
-end:
-    wg.Done()
+    // drain background jobs in the rest of stop timeout
+    App.Stop(time.Until(deadline))
 }
 
-// handle file in public path, no gzip support
+// handle file in public path, no gzip support, range requests
+// are handled by http.ServeContent, including multiple ranges
+// (multipart/byteranges), 416 for unsatisfiable range and If-Range
 func (s *Server) handleFile(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodGet && r.Method != http.MethodHead {
         http.Error(w, "", http.StatusMethodNotAllowed)
         return
     }
 
+    if s.fileFs != nil {
+        s.handleFsFile(w, r)
+        return
+    }
+
     file := filepath.Join(App.GetPublicPath(), Util.CleanPath(r.URL.Path))
     h, e := os.Open(file)
     if e != nil {
@@ -220,21 +950,145 @@ func (s *Server) handleFile(w http.ResponseWriter, r *http.Request) {
 
     defer h.Close()
 
-    f, _ := h.Stat()
+    f, e := h.Stat()
+    if e != nil || f.IsDir() {
+        http.Error(w, "", http.StatusNotFound)
+        return
+    }
+
     http.ServeContent(w, r, file, f.ModTime(), h)
 }
 
+// serve static file from fs, file without Seek is read into memory,
+// modification time of embed.FS is zero, so Last-Modified is omitted
+func (s *Server) handleFsFile(w http.ResponseWriter, r *http.Request) {
+    name := strings.TrimPrefix(Util.CleanPath(r.URL.Path), "/")
+    h, e := s.fileFs.Open(name)
+    if e != nil {
+        http.Error(w, "", http.StatusNotFound)
+        return
+    }
+
+    defer h.Close()
+
+    f, e := h.Stat()
+    if e != nil || f.IsDir() {
+        http.Error(w, "", http.StatusNotFound)
+        return
+    }
+
+    rs, ok := h.(io.ReadSeeker)
+    if !ok {
+        data, e := ioutil.ReadAll(h)
+        if e != nil {
+            http.Error(w, "", http.StatusInternalServerError)
+            return
+        }
+        rs = bytes.NewReader(data)
+    }
+
+    http.ServeContent(w, r, name, f.ModTime(), rs)
+}
+
 func (s *Server) handleRequest(ctx *Context) {
     defer func() {
         // process unhandled panic
         if v := recover(); v != nil {
+            if v == http.ErrAbortHandler {
+                panic(v)
+            }
+
+            stack := debug.Stack()
+            s.abortIfSent(ctx, v, stack)
             s.handlePanic(ctx, v)
+            s.reportPanic(ctx, v, stack)
         }
     }()
 
+    // web request, match route rule to get plugin chain of the route
+    if ctx.plugins == nil {
+        if path, ok := App.GetRouter().Canonical(ctx.GetPath()); ok {
+            s.redirect(ctx, path)
+            return
+        }
+
+        stop := ctx.Timing("routing")
+        rule, params := App.GetRouter().matchAll(ctx.GetPath(), ctx.GetMethod())
+        miss := s.missHandler(ctx, rule)
+        ctx.setRule(rule, params, miss)
+        stop()
+        if miss != nil && !App.GetRouter().missInChain {
+            miss(ctx)
+            return
+        }
+
+        if plugins := s.GetPlugins(); rule != nil {
+            ctx.setPlugins(rule.getChain(plugins, s.pluginNames))
+        } else {
+            ctx.setPlugins(plugins)
+        }
+    }
+
+    // run plugin chain
+    ctx.routeTime = time.Now()
+    ctx.Next()
+}
+
+// redirect to canonical path, 308 for non-GET to keep method and body
+func (s *Server) redirect(ctx *Context, path string) {
+    status := http.StatusMovedPermanently
+    if method := ctx.GetMethod(); method != http.MethodGet && method != http.MethodHead {
+        status = http.StatusPermanentRedirect
+    }
+
+    if query := ctx.GetInput().URL.RawQuery; len(query) > 0 {
+        path += "?" + query
+    }
+
+    ctx.SetHeader("Location", path)
+    ctx.End(status, nil)
+}
+
+// last plugin of the chain, resolve route and run controller action
+func (s *Server) handleRoute(ctx *Context) {
     // get request path and resolve route
-    path := ctx.GetPath()
-    route, params := App.GetRouter().Resolve(path)
+    if !ctx.routeTime.IsZero() {
+        ctx.AddTiming("middleware", time.Since(ctx.routeTime))
+    }
+
+    path, method, router := ctx.GetPath(), ctx.GetMethod(), App.GetRouter()
+    rule, params, miss, matched := ctx.getRule()
+    if !matched {
+        rule, params = router.matchAll(path, method)
+        miss = s.missHandler(ctx, rule)
+    }
+
+    if method == http.MethodOptions && router.isAutoOptions(rule, path) {
+        if allowed := router.AllowedMethods(path); len(allowed) > 0 {
+            ctx.SetHeader("Allow", strings.Join(allowed, ", "))
+            ctx.End(http.StatusNoContent, nil)
+            return
+        }
+    }
+
+    if method == http.MethodHead && router.autoHead && ctx.output != nil {
+        w := &headWriter{ResponseWriter: ctx.output}
+        ctx.SetOutput(w)
+        defer w.flush(ctx)
+    }
+
+    if rule != nil && rule.handler != nil {
+        rule.handler(ctx)
+        return
+    }
+
+    // miss handler is resolved once with the rule, side effects included
+    if miss != nil {
+        miss(ctx)
+        return
+    }
+
+    route := router.routeOf(rule, path)
 
     // get new controller bind to this route
     rv, info := s.createController(route, ctx)
@@ -243,25 +1097,34 @@ func (s *Server) handleRequest(ctx *Context) {
     // get action method by sequence number
     actionMap := info.(map[string]int)
     actionId := ctx.GetActionId()
-    action := rv.Method(actionMap[actionId])
+    s.runAction(ctx, controller, rv.Method(actionMap[actionId]), actionId, rule, params)
+}
 
-    // fill empty string for missing param
-    numIn := action.Type().NumIn()
-    if len(params) < numIn {
-        fill := make([]string, numIn-len(params))
-        params = append(params, fill...)
-    }
+// call action of route directly without routing rules and plugins, eg.
+// to test an action, route params are passed as action arguments:
+// ctx, w := Test.NewTestContext("GET", "/user/view?id=1", nil)
+// App.GetServer().CallAction(ctx, "/user/view")
+func (s *Server) CallAction(ctx *Context, route string, params ...string) {
+    rv, info := s.createController(App.GetRouter().routeOf(nil, route), ctx)
+    controller := rv.Interface().(IController)
 
-    // prepare params for action call
-    callParams := make([]reflect.Value, 0)
-    for _, param := range params {
-        callParams = append(callParams, reflect.ValueOf(param))
-    }
+    actionId := ctx.GetActionId()
+    s.runAction(ctx, controller, rv.Method(info.(map[string]int)[actionId]), actionId, nil, params)
+}
 
+// run action with controller hooks, panic is handled by controller
+func (s *Server) runAction(ctx *Context, controller IController, action reflect.Value, actionId string, rule *routeRule, params []string) {
     defer func() {
         // process controller panic
         if v := recover(); v != nil {
+            if v == http.ErrAbortHandler {
+                panic(v)
+            }
+
+            stack := debug.Stack()
+            s.abortIfSent(ctx, v, stack)
             controller.HandlePanic(v)
+            s.reportPanic(ctx, v, stack)
         }
 
         // send action output
@@ -271,21 +1134,157 @@ func (s *Server) handleRequest(ctx *Context) {
     // before action hook
     controller.BeforeAction(actionId)
 
-    // call action method
-    action.Call(callParams)
+    // bind params and call action method
+    stop := ctx.Timing("action")
+    action.Call(bindParams(ctx, rule, action, params))
+    stop()
 
     // after action hook
     controller.AfterAction(actionId)
 }
 
+// get custom handler if request is not routable, nil if routable or
+// no custom handler, Allow header is set for method not allowed
+func (s *Server) missHandler(ctx *Context, rule *routeRule) func(ctx *Context) {
+    router := App.GetRouter()
+    if (router.notFound == nil && router.notAllowed == nil) || (rule != nil && rule.handler != nil) {
+        return nil
+    }
+
+    path, method := ctx.GetPath(), ctx.GetMethod()
+    if method == http.MethodOptions && router.isAutoOptions(rule, path) {
+        return nil
+    }
+
+    if id, _ := s.findAction(router.routeOf(rule, path), method); len(id) > 0 {
+        return nil
+    }
+
+    if router.notAllowed != nil {
+        if allowed := router.AllowedMethods(path); len(allowed) > 0 {
+            ctx.PushLog("status", http.StatusMethodNotAllowed)
+            ctx.SetHeader("Allow", strings.Join(allowed, ", "))
+            return router.notAllowed
+        }
+    }
+
+    if router.notFound != nil {
+        ctx.PushLog("status", http.StatusNotFound)
+        return router.notFound
+    }
+
+    return nil
+}
+
+// response writer of http request to know whether header is sent,
+// a panic after header is sent aborts the connection, see abortIfSent
+type sentWriter struct {
+    http.ResponseWriter
+    sent bool
+    size int64 // bytes of body written
+}
+
+func (w *sentWriter) WriteHeader(status int) {
+    w.sent = w.sent || status >= http.StatusOK || status == http.StatusSwitchingProtocols
+    w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *sentWriter) Write(b []byte) (int, error) {
+    w.sent = true
+    n, e := w.ResponseWriter.Write(b)
+    w.size += int64(n)
+    return n, e
+}
+
+func (w *sentWriter) Flush() {
+    if f, ok := w.ResponseWriter.(http.Flusher); ok {
+        w.sent = true
+        f.Flush()
+    }
+}
+
+func (w *sentWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+    if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+        w.sent = true
+        return h.Hijack()
+    }
+
+    return nil, nil, http.ErrNotSupported
+}
+
+// for http.ResponseController
+func (w *sentWriter) Unwrap() http.ResponseWriter {
+    return w.ResponseWriter
+}
+
+// writer for auto HEAD, body is discarded and counted for Content-Length
+type headWriter struct {
+    http.ResponseWriter
+    status int
+    size   int
+}
+
+func (w *headWriter) WriteHeader(status int) {
+    if w.status == 0 {
+        w.status = status
+    }
+}
+
+func (w *headWriter) Write(b []byte) (int, error) {
+    w.WriteHeader(http.StatusOK)
+    w.size += len(b)
+    return len(b), nil
+}
+
+// send header with Content-Length, later output goes to the real writer
+func (w *headWriter) flush(ctx *Context) {
+    ctx.SetOutput(w.ResponseWriter)
+    if w.status == 0 {
+        return
+    }
+
+    if len(w.Header().Get("Content-Length")) == 0 {
+        w.Header().Set("Content-Length", strconv.Itoa(w.size))
+    }
+
+    w.ResponseWriter.WriteHeader(w.status)
+}
+
 func (s *Server) createController(route string, ctx *Context) (reflect.Value, interface{}) {
+    controllerId, actionId := s.findAction(route, ctx.GetMethod())
+    if len(controllerId) == 0 {
+        panic(NewException(http.StatusNotFound, "route not found, %s", route))
+    }
+
+    rv, info := App.GetContainer().GetValue(s.getControllerName(controllerId), nil)
+
+    ctx.SetControllerId(controllerId)
+    ctx.SetActionId(actionId)
+    rv.Interface().(IObject).SetContext(ctx)
+
+    return rv, info
+}
+
+// check whether path of ctx is resolved to a handler or an action
+func (s *Server) hasRoute(ctx *Context) bool {
+    path, method, router := ctx.GetPath(), ctx.GetMethod(), App.GetRouter()
+    rule, _ := router.matchAll(path, method)
+    if rule != nil && rule.handler != nil {
+        return true
+    }
+
+    controllerId, _ := s.findAction(router.routeOf(rule, path), method)
+    return len(controllerId) > 0
+}
+
+// find controller and action of route for method, controllerId
+// is empty if not found, HEAD falls back to GET if autoHead enabled
+func (s *Server) findAction(route, method string) (controllerId, actionId string) {
     if "/" == route {
         route += DefaultController
     }
 
-    var controllerId, actionId string
     di := App.GetContainer()
-
     pos := strings.LastIndexByte(route, '/')
     if pos > 0 && di.Has(s.getControllerName(route[:pos])) {
         controllerId = route[:pos]
@@ -294,33 +1293,25 @@ func (s *Server) createController(route string, ctx *Context) (reflect.Value, in
         controllerId = route
         actionId = ""
     } else {
-        panic(NewException(http.StatusNotFound, "route not found, %s", route))
+        return "", ""
     }
 
-    rv, info := di.GetValue(s.getControllerName(controllerId), nil)
-    actions := info.(map[string]int)
-
+    actions, _ := di.GetInfo(s.getControllerName(controllerId)).(map[string]int)
     if len(actionId) > 0 {
         if _, ok := actions[actionId]; !ok {
-            panic(NewException(http.StatusNotFound, "route not found, %s", route))
+            return "", ""
         }
+    } else if _, ok := actions[DefaultAction]; ok {
+        actionId = DefaultAction
+    } else if _, ok := actions[method]; ok {
+        actionId = method
+    } else if _, ok := actions[http.MethodGet]; ok && method == http.MethodHead && App.GetRouter().autoHead {
+        actionId = http.MethodGet
     } else {
-        if _, ok := actions[DefaultAction]; ok {
-            actionId = DefaultAction
-        } else {
-            method := ctx.GetMethod()
-            if _, ok := actions[method]; !ok {
-                panic(NewException(http.StatusNotFound, "route not found, %s", route))
-            }
-            actionId = method
-        }
+        return "", ""
     }
 
-    ctx.SetControllerId(controllerId)
-    ctx.SetActionId(actionId)
-    rv.Interface().(IObject).SetContext(ctx)
-
-    return rv, info
+    return controllerId, actionId
 }
 
 func (s *Server) getControllerName(id string) string {
@@ -331,13 +1322,53 @@ func (s *Server) getControllerName(id string) string {
     }
 }
 
+// call panic handlers in order, isolated from each other's panic
+func (s *Server) reportPanic(ctx *Context, v interface{}, stack []byte) {
+    for _, handler := range s.panicHandlers {
+        func() {
+            defer func() {
+                if e := recover(); e != nil {
+                    ctx.Error("panic handler failed, %s", Util.ToString(e))
+                }
+            }()
+
+            handler(ctx, v, stack)
+        }()
+    }
+}
+
+// a partial response can not be fixed by error output if header is
+// sent, so the connection is aborted by http.ErrAbortHandler to tell
+// client that response is broken, otherwise headers of the broken
+// response are reset and the connection is kept alive.
+func (s *Server) abortIfSent(ctx *Context, v interface{}, stack []byte) {
+    if ctx.writer == nil {
+        return
+    }
+
+    if ctx.writer.sent {
+        ctx.Error("panic after response is sent, abort connection, %s, trace[%s]", Util.ToString(v), Util.PanicTrace(TraceMaxDepth, false))
+        s.reportPanic(ctx, v, stack)
+        panic(http.ErrAbortHandler)
+    }
+
+    header := ctx.writer.Header()
+    header.Del("Content-Length")
+    header.Del("Content-Encoding")
+    header.Del("Transfer-Encoding")
+}
+
 func (s *Server) handlePanic(ctx *Context, v interface{}) {
     status := http.StatusInternalServerError
-    switch e := v.(type) {
-    case *Exception:
+    if e, ok := AsException(v); ok {
         status = e.GetStatus()
+    }
+
+    if s.errorHandler != nil && status >= http.StatusInternalServerError {
+        s.errorHandler(ctx, status, v)
+    } else if e, ok := AsException(v); ok {
         ctx.End(status, []byte(App.GetStatus().GetText(status, ctx, e.GetMessage())))
-    default:
+    } else {
         ctx.End(status, []byte(http.StatusText(status)))
     }
 
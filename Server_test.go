@@ -0,0 +1,371 @@
+package pgo
+
+import (
+    "crypto/tls"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "runtime"
+    "strings"
+    "sync/atomic"
+    "testing"
+    "testing/fstest"
+    "time"
+)
+
+func TestServerAltSvc(t *testing.T) {
+    s := App.GetServer()
+    s.SetAltSvc(`h3=":443"; ma=86400`)
+    defer s.SetAltSvc("")
+
+    serve := func(useTls bool) string {
+        r := httptest.NewRequest("GET", "/alt-svc/test", nil)
+        if useTls {
+            r.TLS = &tls.ConnectionState{}
+        }
+
+        w := httptest.NewRecorder()
+        s.ServeHTTP(w, r)
+        return w.Header().Get("Alt-Svc")
+    }
+
+    if v := serve(true); v != "" {
+        t.Errorf("without alt-svc server: want no Alt-Svc, got %q", v)
+    }
+
+    atomic.AddInt32(&s.altRunning, 1)
+    defer atomic.AddInt32(&s.altRunning, -1)
+
+    if v := serve(false); v != "" {
+        t.Errorf("plain http: want no Alt-Svc, got %q", v)
+    }
+
+    if v := serve(true); v != `h3=":443"; ma=86400` {
+        t.Errorf("tls with alt-svc server: want Alt-Svc, got %q", v)
+    }
+}
+
+func TestServerAddAltSvcServer(t *testing.T) {
+    s := &Server{}
+    s.Construct()
+    s.AddExtraServer(func(h http.Handler) error { return nil }, nil)
+    s.AddAltSvcServer(func(h http.Handler) error { return nil }, nil)
+
+    if len(s.extras) != 2 || s.extras[0].advertised || !s.extras[1].advertised {
+        t.Errorf("want plain and advertised extra servers, got %+v", s.extras)
+    }
+}
+
+func TestServerMissHandlerInChain(t *testing.T) {
+    router := App.GetRouter()
+    router.SetMissInChain(true)
+    defer router.SetMissInChain(false)
+
+    calls, pushLog := 0, ""
+    router.SetNotFoundHandler(func(ctx *Context) {
+        calls++
+        pushLog = ctx.GetPushLogString()
+        ctx.End(http.StatusNotFound, []byte("custom not found"))
+    })
+    defer router.SetNotFoundHandler(nil)
+
+    w := httptest.NewRecorder()
+    App.GetServer().ServeHTTP(w, httptest.NewRequest("GET", "/miss/in/chain", nil))
+
+    if w.Code != http.StatusNotFound || w.Body.String() != "custom not found" {
+        t.Errorf("want custom 404, got %d %q", w.Code, w.Body.String())
+    }
+
+    if calls != 1 || pushLog != "status=404" {
+        t.Errorf("want handler called once with one status log, got %d calls, push log %q", calls, pushLog)
+    }
+}
+
+func TestServerTrailingSlashRedirect(t *testing.T) {
+    router := App.GetRouter()
+    router.SetTrailingSlash(TrailingSlashRedirect)
+    defer router.SetTrailingSlash(TrailingSlashStrict)
+
+    router.AddHandler("^/slash/item$", func(ctx *Context) {
+        ctx.End(http.StatusOK, []byte("item"))
+    })
+
+    for method, status := range map[string]int{"GET": http.StatusMovedPermanently, "POST": http.StatusPermanentRedirect} {
+        w := httptest.NewRecorder()
+        App.GetServer().ServeHTTP(w, httptest.NewRequest(method, "/slash/item/?id=1", nil))
+        if w.Code != status || w.Header().Get("Location") != "/slash/item?id=1" {
+            t.Errorf("%s: want %d to /slash/item?id=1, got %d %q", method, status, w.Code, w.Header().Get("Location"))
+        }
+    }
+
+    w := httptest.NewRecorder()
+    App.GetServer().ServeHTTP(w, httptest.NewRequest("GET", "/slash/item", nil))
+    if w.Code != http.StatusOK || w.Body.String() != "item" {
+        t.Errorf("canonical path: want 200 item, got %d %q", w.Code, w.Body.String())
+    }
+}
+
+func TestServerVersionPath(t *testing.T) {
+    info := App.buildInfo
+    defer func() { App.buildInfo = info }()
+
+    App.SetBuildInfo("1.2.0", "abc123", "2026-01-02T03:04:05Z")
+    if got := App.GetBuildInfo(); got.Version != "1.2.0" || got.Commit != "abc123" || got.BuildTime != "2026-01-02T03:04:05Z" {
+        t.Errorf("want build info set, got %+v", got)
+    }
+
+    s := App.GetServer()
+    s.SetVersionPath("/test/version")
+    defer s.SetVersionPath("")
+    s.addVersionHandler()
+
+    w := httptest.NewRecorder()
+    s.ServeHTTP(w, httptest.NewRequest("GET", "/test/version", nil))
+
+    var got BuildInfo
+    if e := json.Unmarshal(w.Body.Bytes(), &got); e != nil || w.Code != http.StatusOK {
+        t.Fatalf("want json build info, got %d %q", w.Code, w.Body.String())
+    }
+
+    want := BuildInfo{"1.2.0", "abc123", "2026-01-02T03:04:05Z", runtime.Version(), FrameworkVersion}
+    if got != want {
+        t.Errorf("want %+v, got %+v", want, got)
+    }
+
+    if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+        t.Errorf("want json content type, got %q", ct)
+    }
+}
+
+func TestServerCustomErrorHandlers(t *testing.T) {
+    router, s := App.GetRouter(), App.GetServer()
+    router.SetMethodNotAllowedHandler(func(ctx *Context) {
+        ctx.End(http.StatusMethodNotAllowed, []byte("custom not allowed"))
+    })
+    defer router.SetMethodNotAllowedHandler(nil)
+
+    var recovered []interface{}
+    s.SetErrorHandler(func(ctx *Context, status int, v interface{}) {
+        recovered = append(recovered, v)
+        ctx.End(status, []byte(fmt.Sprintf("custom error %d", status)))
+    })
+    defer s.SetErrorHandler(nil)
+
+    router.AddHandler("^/custom/item$", func(ctx *Context) {
+        switch ctx.GetQuery("fail", "") {
+        case "panic":
+            panic("boom")
+        case "unavailable":
+            panic(NewException(http.StatusServiceUnavailable, "busy"))
+        case "invalid":
+            panic(NewException(http.StatusBadRequest, "invalid id"))
+        }
+        ctx.End(http.StatusOK, []byte("item"))
+    }, map[string]interface{}{"method": "GET"})
+
+    serve := func(method, path string) *httptest.ResponseRecorder {
+        w := httptest.NewRecorder()
+        s.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+        return w
+    }
+
+    w := serve("POST", "/custom/item")
+    if w.Code != http.StatusMethodNotAllowed || w.Body.String() != "custom not allowed" || !strings.Contains(w.Header().Get("Allow"), "GET") {
+        t.Errorf("405: want custom handler with Allow, got %d %q %q", w.Code, w.Body.String(), w.Header().Get("Allow"))
+    }
+
+    if w := serve("GET", "/custom/item?fail=panic"); w.Code != http.StatusInternalServerError || w.Body.String() != "custom error 500" {
+        t.Errorf("500: want custom handler, got %d %q", w.Code, w.Body.String())
+    }
+
+    if w := serve("GET", "/custom/item?fail=unavailable"); w.Code != http.StatusServiceUnavailable || w.Body.String() != "custom error 503" {
+        t.Errorf("503: want custom handler, got %d %q", w.Code, w.Body.String())
+    }
+
+    if w := serve("GET", "/custom/item?fail=invalid"); w.Code != http.StatusBadRequest || strings.HasPrefix(w.Body.String(), "custom error") {
+        t.Errorf("400: want default output, got %d %q", w.Code, w.Body.String())
+    }
+
+    if len(recovered) != 2 || recovered[0] != "boom" {
+        t.Errorf("want recovered values of 5xx passed to handler, got %v", recovered)
+    }
+}
+
+func TestServerIsAllowedHost(t *testing.T) {
+    s := &Server{}
+    s.Construct()
+    if !s.IsAllowedHost("evil.com") {
+        t.Error("empty allowed hosts: want any host allowed")
+    }
+
+    s.SetAllowedHosts([]interface{}{"Example.com", "*.api.example.com", "::1", "[fe80::1]"})
+    tests := map[string]bool{
+        "example.com":          true,
+        "EXAMPLE.com:8080":     true,
+        "example.com.":         true,
+        "v1.api.example.com":   true,
+        "api.example.com":      false,
+        "evilapi.example.com":  false,
+        "evil.com":             false,
+        "[::1]":                true,
+        "[::1]:8080":           true,
+        "[fe80::1]":            true,
+        "[fe80::2]:8080":       false,
+        "":                     false,
+    }
+
+    for host, want := range tests {
+        if got := s.IsAllowedHost(host); got != want {
+            t.Errorf("%q: want %v, got %v", host, want, got)
+        }
+    }
+}
+
+func TestServerAllowedHostsProbeExempt(t *testing.T) {
+    s := App.GetServer()
+    s.SetAllowedHosts([]interface{}{"example.com"})
+    defer s.SetAllowedHosts(nil)
+
+    serve := func(path string) int {
+        r := httptest.NewRequest("GET", path, nil)
+        r.Host = "10.0.0.1:8080"
+        w := httptest.NewRecorder()
+        s.ServeHTTP(w, r)
+        return w.Code
+    }
+
+    if code := serve("/allowed/hosts"); code != http.StatusBadRequest {
+        t.Errorf("disallowed host: want 400, got %d", code)
+    }
+
+    if code := serve(App.GetHealth().GetPath()); code == http.StatusBadRequest {
+        t.Error("health path: want exempt from host check, got 400")
+    }
+}
+
+func TestServerFileRange(t *testing.T) {
+    s := &Server{}
+    s.Construct()
+    s.SetFileFS(fstest.MapFS{"app.js": &fstest.MapFile{Data: []byte("console.log(1)")}})
+
+    r := httptest.NewRequest("GET", "/app.js", nil)
+    r.Header.Set("Range", "bytes=8-")
+    w := httptest.NewRecorder()
+    s.handleFile(w, r)
+
+    if w.Code != http.StatusPartialContent || w.Body.String() != "log(1)" {
+        t.Errorf("want 206 log(1), got %d %q", w.Code, w.Body.String())
+    }
+
+    w = httptest.NewRecorder()
+    s.handleFile(w, httptest.NewRequest("POST", "/app.js", nil))
+    if w.Code != http.StatusMethodNotAllowed {
+        t.Errorf("post static file: want 405, got %d", w.Code)
+    }
+}
+
+func TestServerAutoOptionsAndHead(t *testing.T) {
+    router := App.GetRouter()
+    router.SetAutoOptions(true)
+    router.SetAutoHead(true)
+    defer router.SetAutoOptions(false)
+    defer router.SetAutoHead(false)
+
+    router.AddHandler("^/auto/item$", func(ctx *Context) {
+        ctx.SetHeader("X-Item", "1")
+        ctx.End(http.StatusOK, []byte("item body"))
+    }, map[string]interface{}{"method": "GET"})
+    router.AddHandler("^/auto/item$", func(ctx *Context) {
+        ctx.End(http.StatusCreated, nil)
+    }, map[string]interface{}{"method": "POST"})
+    router.AddHandler("^/auto/custom$", func(ctx *Context) {
+        ctx.End(http.StatusOK, []byte("custom options"))
+    }, map[string]interface{}{"method": "OPTIONS"})
+
+    serve := func(method, path string) *httptest.ResponseRecorder {
+        w := httptest.NewRecorder()
+        App.GetServer().ServeHTTP(w, httptest.NewRequest(method, path, nil))
+        return w
+    }
+
+    w := serve("OPTIONS", "/auto/item")
+    if w.Code != http.StatusNoContent || w.Header().Get("Allow") != "GET, HEAD, OPTIONS, POST" {
+        t.Errorf("auto options: want 204 with Allow, got %d %q", w.Code, w.Header().Get("Allow"))
+    }
+
+    if w := serve("OPTIONS", "/auto/custom"); w.Body.String() != "custom options" {
+        t.Errorf("options rule: want own handler, got %d %q", w.Code, w.Body.String())
+    }
+
+    w = serve("HEAD", "/auto/item")
+    if w.Code != http.StatusOK || w.Body.Len() != 0 || w.Header().Get("X-Item") != "1" {
+        t.Errorf("auto head: want GET headers without body, got %d %q %v", w.Code, w.Body.String(), w.Header())
+    }
+
+    if cl := w.Header().Get("Content-Length"); cl != "9" {
+        t.Errorf("auto head: want Content-Length of GET body, got %q", cl)
+    }
+
+    router.SetAutoHead(false)
+    if w := serve("HEAD", "/auto/item"); w.Code == http.StatusOK {
+        t.Errorf("auto head disabled: want no GET fallback, got %d", w.Code)
+    }
+}
+
+func TestServerTimingHeader(t *testing.T) {
+    s := App.GetServer()
+    App.GetRouter().AddHandler("^/server/timing$", func(ctx *Context) {
+        ctx.AddTiming("db", 2*time.Millisecond)
+        ctx.Timing("cache")()
+        ctx.End(http.StatusOK, []byte("ok"))
+    }, nil)
+
+    serve := func() string {
+        w := httptest.NewRecorder()
+        s.ServeHTTP(w, httptest.NewRequest("GET", "/server/timing", nil))
+        return w.Header().Get("Server-Timing")
+    }
+
+    if v := serve(); v != "" {
+        t.Errorf("disabled: want no Server-Timing, got %q", v)
+    }
+
+    s.SetServerTiming(true)
+    defer s.SetServerTiming(false)
+
+    v := serve()
+    for _, metric := range []string{"routing;dur=", "middleware;dur=", "db;dur=2.000", "cache;dur="} {
+        if !strings.Contains(v, metric) {
+            t.Errorf("want %q in Server-Timing, got %q", metric, v)
+        }
+    }
+
+    if !strings.HasPrefix(v, "routing;") {
+        t.Errorf("want phases in order, got %q", v)
+    }
+}
+
+func TestServerLogIdPropagation(t *testing.T) {
+    var logId string
+    path := fmt.Sprintf("/log/id%d", time.Now().UnixNano())
+    App.GetRouter().AddHandler("^"+path+"$", func(ctx *Context) {
+        logId = ctx.Logger.GetLogId()
+        ctx.End(http.StatusOK, nil)
+    }, nil)
+
+    r := httptest.NewRequest("GET", path, nil)
+    r.Header.Set(LogIdHeader, "upstream-id")
+    w := httptest.NewRecorder()
+    App.GetServer().ServeHTTP(w, r)
+
+    if v := w.Header().Get(LogIdHeader); v != "upstream-id" || logId != "upstream-id" {
+        t.Errorf("want log id of upstream in response and logger, got %q %q", v, logId)
+    }
+
+    w = httptest.NewRecorder()
+    App.GetServer().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+    if v := w.Header().Get(LogIdHeader); len(v) == 0 || v == "upstream-id" || v != logId {
+        t.Errorf("without header: want new log id, got %q %q", v, logId)
+    }
+}
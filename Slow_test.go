@@ -0,0 +1,75 @@
+package pgo
+
+import (
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestServerSlowRequestLog(t *testing.T) {
+    s := App.GetServer()
+    s.SetSlowThreshold("10ms")
+    defer s.SetSlowThreshold("0s")
+
+    // unique path so rule of the previous run(-count) is not matched
+    path := fmt.Sprintf("/slow/request%d", time.Now().UnixNano())
+    target := &recordTarget{}
+    App.GetRouter().AddHandler("^"+path+"$", func(ctx *Context) {
+        ctx.SetTap(target.Process)
+        if ctx.GetQuery("sleep", "") == "1" {
+            stop := ctx.Timing("db")
+            time.Sleep(20 * time.Millisecond)
+            stop()
+        }
+        ctx.End(http.StatusOK, nil)
+    }, nil)
+
+    serve := func(query string) []string {
+        target.items = nil
+        s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path+query, nil))
+        return target.messages()
+    }
+
+    if msgs := serve(""); len(msgs) != 0 {
+        t.Errorf("fast request: want no slow log, got %v", msgs)
+    }
+
+    s.SetServerTiming(true)
+    defer s.SetServerTiming(false)
+
+    msgs := serve("?sleep=1")
+    want := fmt.Sprintf("slow request, GET %s, route:%s, status:200", path, path)
+    if len(msgs) != 1 || !strings.HasPrefix(msgs[0], want) || !strings.Contains(msgs[0], "of 10ms threshold") {
+        t.Fatalf("slow request: want %q, got %v", want, msgs)
+    }
+
+    if !strings.Contains(msgs[0], "db=2") {
+        t.Errorf("slow request: want timing phases, got %q", msgs[0])
+    }
+}
+
+func TestServerSlowDeadlineWarn(t *testing.T) {
+    s := App.GetServer()
+    defer func(timeout time.Duration, ratio float64) {
+        s.http.WriteTimeout, s.slowWarnRatio = timeout, ratio
+    }(s.http.WriteTimeout, s.slowWarnRatio)
+    s.SetWriteTimeout("100ms")
+    s.SetSlowWarnRatio(0.2)
+
+    path := fmt.Sprintf("/slow/deadline%d", time.Now().UnixNano())
+    target := &recordTarget{}
+    App.GetRouter().AddHandler("^"+path+"$", func(ctx *Context) {
+        ctx.SetTap(target.Process)
+        time.Sleep(50 * time.Millisecond)
+        ctx.End(http.StatusOK, nil)
+    }, nil)
+
+    s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+    msgs := target.messages()
+    if len(msgs) != 1 || !strings.HasPrefix(msgs[0], "slow request, GET "+path) || !strings.HasSuffix(msgs[0], "of 100ms deadline") {
+        t.Errorf("want warn at ratio of deadline, got %v", msgs)
+    }
+}
@@ -1,8 +1,13 @@
 package pgo
 
 import (
+    "context"
+    "encoding/json"
+    "errors"
     "fmt"
     "net/http"
+    "regexp"
+    "time"
 
     "github.com/pinguo/pgo/Util"
 )
@@ -12,16 +17,32 @@ import (
 //     "useI18n": false,
 //     "mapping": {
 //         "11002": "Verify Sign Error"
-//     }
+//     },
+//     "maxRoutes": 200,
+//     "statsPath": "/_stats"
 // }
+//
+// errors added by AddError are mapped to status and code when
+// rendered by ctx.EndError, context.DeadlineExceeded maps to 504.
+// request count, error rate, latency and size percentiles of web
+// requests are aggregated per route, see Snapshot, the first maxRoutes
+// routes are tracked and the rest are aggregated as "_other", 0 to
+// disable, statsPath serves the snapshot as json, disabled if empty.
 type Status struct {
-    useI18n bool
-    mapping map[int]string
+    useI18n   bool
+    mapping   map[int]string
+    errors    []*Exception
+    stats     *routeStats
+    statsPath string
 }
 
 func (s *Status) Construct() {
     s.useI18n = false
     s.mapping = make(map[int]string)
+    s.errors = make([]*Exception, 0)
+    s.stats = newRouteStats(DefaultStatsRoutes)
+
+    s.AddError(context.DeadlineExceeded, http.StatusGatewayTimeout)
 }
 
 func (s *Status) SetUseI18n(useI18n bool) {
@@ -34,6 +55,90 @@ func (s *Status) SetMapping(m map[string]interface{}) {
     }
 }
 
+// set max routes tracked separately, 0 to disable route stats
+func (s *Status) SetMaxRoutes(n int) {
+    if n > 0 {
+        s.stats = newRouteStats(n)
+    } else {
+        s.stats = nil
+    }
+}
+
+// set path to serve route stats as json, eg. "/_stats"
+func (s *Status) SetStatsPath(path string) {
+    if len(path) > 0 {
+        path = Util.CleanPath(path)
+    }
+
+    s.statsPath = path
+}
+
+// get snapshot of route stats, routes are ordered by count
+func (s *Status) Snapshot() StatusSnapshot {
+    if s.stats == nil {
+        return StatusSnapshot{Routes: make([]RouteStats, 0)}
+    }
+
+    return s.stats.snapshot()
+}
+
+// record finished request of context
+func (s *Status) observe(ctx *Context, reqBytes, respBytes int64) {
+    if s.stats != nil {
+        status := ctx.status
+        if status == 0 {
+            status = http.StatusOK
+        }
+
+        s.stats.observe(statsRouteOf(ctx), status, time.Since(ctx.startTime), reqBytes, respBytes)
+    }
+}
+
+// add handler of statsPath if set
+func (s *Status) addStatsHandler() {
+    if len(s.statsPath) > 0 {
+        App.GetRouter().AddHandler("^"+regexp.QuoteMeta(s.statsPath)+"$", s.serveStats, map[string]interface{}{
+            "method":  http.MethodGet,
+            "summary": "get route stats",
+        })
+    }
+}
+
+func (s *Status) serveStats(ctx *Context) {
+    output, _ := json.Marshal(s.Snapshot())
+    ctx.SetHeader("Content-Type", "application/json; charset=utf-8")
+    ctx.End(http.StatusOK, output)
+}
+
+// map error to http status and optional code, matched by errors.Is,
+// eg. status.AddError(sql.ErrNoRows, http.StatusNotFound, 10404)
+func (s *Status) AddError(err error, status int, code ...int) {
+    e := WrapException(err, status)
+    if len(code) > 0 {
+        e.code = code[0]
+    }
+
+    s.errors = append(s.errors, e)
+}
+
+// get exception of err, err is an exception, wraps an exception
+// or is mapped by AddError, otherwise ok is false with status 500
+func (s *Status) GetException(err error) (e *Exception, ok bool) {
+    if e, ok = AsException(err); ok {
+        return e, true
+    }
+
+    for i := len(s.errors) - 1; i >= 0; i-- {
+        if m := s.errors[i]; errors.Is(err, m.cause) {
+            e := WrapException(err, m.status)
+            e.code = m.code
+            return e, true
+        }
+    }
+
+    return WrapException(err, http.StatusInternalServerError), false
+}
+
 func (s *Status) GetText(status int, ctx *Context, dft ...string) string {
     txt, ok := s.mapping[status]
     if !ok {
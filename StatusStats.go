@@ -0,0 +1,212 @@
+package pgo
+
+import (
+    "math"
+    "sort"
+    "sync"
+    "time"
+)
+
+const (
+    histBuckets   = 128 // bucket i holds values up to 2^(i/4), about 71m in microseconds
+    histPerDouble = 4
+    overflowRoute = "_other"
+    missRoute     = "_unmatched"
+)
+
+// streaming histogram of log buckets, quantile error is within 19%
+type histogram struct {
+    counts [histBuckets]uint64
+    count  uint64
+    sum    float64
+    min    float64
+    max    float64
+}
+
+func (h *histogram) add(v float64) {
+    i := 0
+    if v > 1 {
+        if i = int(math.Ceil(math.Log2(v) * histPerDouble)); i >= histBuckets {
+            i = histBuckets - 1
+        }
+    }
+
+    h.counts[i]++
+    h.count++
+    h.sum += v
+    if v < h.min || h.count == 1 {
+        h.min = v
+    }
+    if v > h.max {
+        h.max = v
+    }
+}
+
+// get upper bound of bucket of quantile q, within min and max
+func (h *histogram) quantile(q float64) float64 {
+    if h.count == 0 {
+        return 0
+    }
+
+    rank := uint64(math.Ceil(q * float64(h.count)))
+    var seen uint64
+    for i, n := range h.counts {
+        if seen += n; seen >= rank && n > 0 {
+            return math.Max(math.Min(math.Pow(2, float64(i)/histPerDouble), h.max), h.min)
+        }
+    }
+
+    return h.max
+}
+
+// get stats with values divided by unit
+func (h *histogram) stats(unit float64) HistogramStats {
+    s := HistogramStats{Count: h.count, Max: h.max / unit}
+    if h.count > 0 {
+        s.Mean = h.sum / float64(h.count) / unit
+        s.P50, s.P90, s.P99 = h.quantile(0.5)/unit, h.quantile(0.9)/unit, h.quantile(0.99)/unit
+    }
+
+    return s
+}
+
+// stats of a histogram
+type HistogramStats struct {
+    Count uint64  `json:"count"`
+    Mean  float64 `json:"mean"`
+    Max   float64 `json:"max"`
+    P50   float64 `json:"p50"`
+    P90   float64 `json:"p90"`
+    P99   float64 `json:"p99"`
+}
+
+// stats of a route, errors are 5xx responses, clientErrors are 4xx
+type RouteStats struct {
+    Route         string         `json:"route"`
+    Count         uint64         `json:"count"`
+    Errors        uint64         `json:"errors"`
+    ClientErrors  uint64         `json:"clientErrors"`
+    ErrorRate     float64        `json:"errorRate"`
+    LatencyMs     HistogramStats `json:"latencyMs"`
+    RequestBytes  HistogramStats `json:"requestBytes"`
+    ResponseBytes HistogramStats `json:"responseBytes"`
+}
+
+// snapshot of route stats since start, routes are ordered by count
+type StatusSnapshot struct {
+    Since  time.Time    `json:"since"`
+    Routes []RouteStats `json:"routes"`
+}
+
+type routeStat struct {
+    lock         sync.Mutex
+    errors       uint64
+    clientErrors uint64
+    latency      histogram // microseconds
+    reqBytes     histogram
+    respBytes    histogram
+}
+
+// route stats of requests, only the first maxRoutes routes are tracked
+// separately, requests of other routes are aggregated as "_other"
+type routeStats struct {
+    lock      sync.RWMutex
+    since     time.Time
+    maxRoutes int
+    routes    map[string]*routeStat
+}
+
+func newRouteStats(maxRoutes int) *routeStats {
+    return &routeStats{since: time.Now(), maxRoutes: maxRoutes, routes: make(map[string]*routeStat)}
+}
+
+func (s *routeStats) get(route string) *routeStat {
+    s.lock.RLock()
+    stat, ok := s.routes[route]
+    s.lock.RUnlock()
+    if ok {
+        return stat
+    }
+
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    if stat, ok = s.routes[route]; !ok {
+        if len(s.routes) >= s.maxRoutes && route != overflowRoute {
+            if stat, ok = s.routes[overflowRoute]; ok {
+                return stat
+            }
+            route = overflowRoute
+        }
+
+        stat = &routeStat{}
+        s.routes[route] = stat
+    }
+
+    return stat
+}
+
+func (s *routeStats) observe(route string, status int, elapse time.Duration, reqBytes, respBytes int64) {
+    stat := s.get(route)
+    stat.lock.Lock()
+    defer stat.lock.Unlock()
+
+    if status >= 500 {
+        stat.errors++
+    } else if status >= 400 {
+        stat.clientErrors++
+    }
+
+    stat.latency.add(float64(elapse) / float64(time.Microsecond))
+    stat.reqBytes.add(float64(reqBytes))
+    stat.respBytes.add(float64(respBytes))
+}
+
+func (s *routeStats) snapshot() StatusSnapshot {
+    s.lock.RLock()
+    defer s.lock.RUnlock()
+
+    snapshot := StatusSnapshot{Since: s.since, Routes: make([]RouteStats, 0, len(s.routes))}
+    for route, stat := range s.routes {
+        stat.lock.Lock()
+        rs := RouteStats{
+            Route:         route,
+            Count:         stat.latency.count,
+            Errors:        stat.errors,
+            ClientErrors:  stat.clientErrors,
+            LatencyMs:     stat.latency.stats(1000),
+            RequestBytes:  stat.reqBytes.stats(1),
+            ResponseBytes: stat.respBytes.stats(1),
+        }
+        stat.lock.Unlock()
+
+        if rs.Count > 0 {
+            rs.ErrorRate = float64(rs.Errors) / float64(rs.Count)
+        }
+        snapshot.Routes = append(snapshot.Routes, rs)
+    }
+
+    sort.Slice(snapshot.Routes, func(i, j int) bool {
+        if snapshot.Routes[i].Count == snapshot.Routes[j].Count {
+            return snapshot.Routes[i].Route < snapshot.Routes[j].Route
+        }
+        return snapshot.Routes[i].Count > snapshot.Routes[j].Count
+    })
+
+    return snapshot
+}
+
+// get stats key of route of context, pattern of matched rule,
+// controller action of default routing or "_unmatched"
+func statsRouteOf(ctx *Context) string {
+    if rule := ctx.rule; rule != nil {
+        if len(rule.method) > 0 {
+            return rule.method + " " + rule.pattern
+        }
+        return rule.pattern
+    } else if id := ctx.GetControllerId(); len(id) > 0 {
+        return id + "/" + ctx.GetActionId()
+    }
+
+    return missRoute
+}
@@ -0,0 +1,111 @@
+package pgo
+
+import (
+    "encoding/json"
+    "math"
+    "net/http"
+    "testing"
+    "time"
+)
+
+func TestHistogramQuantile(t *testing.T) {
+    h := &histogram{}
+    for i := 1; i <= 100; i++ {
+        h.add(float64(i))
+    }
+
+    for q, want := range map[float64]float64{0.5: 50, 0.9: 90, 0.99: 99} {
+        if got := h.quantile(q); math.Abs(got-want)/want > 0.19 {
+            t.Errorf("p%v: want about %v, got %v", q*100, want, got)
+        }
+    }
+
+    s := h.stats(10)
+    if s.Count != 100 || s.Max != 10 || s.Mean != 5.05 {
+        t.Errorf("want stats in unit of 10, got %+v", s)
+    }
+
+    // values up to 1 share the first bucket, bound is clamped to max
+    h = &histogram{}
+    h.add(0.5)
+    h.add(300)
+    if h.quantile(0.1) != 1 || h.quantile(1) != 300 {
+        t.Errorf("want quantiles 1 and 300, got %v %v", h.quantile(0.1), h.quantile(1))
+    }
+
+    if (&histogram{}).stats(1) != (HistogramStats{}) {
+        t.Error("empty: want zero stats")
+    }
+}
+
+func TestRouteStatsOverflow(t *testing.T) {
+    s := newRouteStats(2)
+    s.observe("GET ^/a$", http.StatusOK, time.Millisecond, 10, 100)
+    s.observe("GET ^/a$", http.StatusInternalServerError, 3*time.Millisecond, 10, 100)
+    s.observe("GET ^/a$", http.StatusNotFound, 2*time.Millisecond, 10, 100)
+    s.observe("GET ^/b$", http.StatusOK, time.Millisecond, 0, 20)
+    s.observe("GET ^/c$", http.StatusOK, time.Millisecond, 0, 20)
+    s.observe("GET ^/d$", http.StatusOK, time.Millisecond, 0, 20)
+
+    routes := s.snapshot().Routes
+    if len(routes) != 3 || routes[0].Route != "GET ^/a$" || routes[1].Route != overflowRoute || routes[2].Route != "GET ^/b$" {
+        t.Fatalf("want routes ordered by count with overflow aggregated, got %+v", routes)
+    }
+
+    a := routes[0]
+    if a.Count != 3 || a.Errors != 1 || a.ClientErrors != 1 || math.Abs(a.ErrorRate-1.0/3) > 1e-9 {
+        t.Errorf("want 5xx and 4xx counted apart, got %+v", a)
+    }
+
+    if a.LatencyMs.Max != 3 || a.LatencyMs.Mean != 2 || a.RequestBytes.Mean != 10 || a.ResponseBytes.Max != 100 {
+        t.Errorf("want latency in ms and sizes in bytes, got %+v", a)
+    }
+
+    if routes[1].Count != 2 {
+        t.Errorf("want 2 requests in %s, got %d", overflowRoute, routes[1].Count)
+    }
+}
+
+func TestStatsRouteOf(t *testing.T) {
+    ctx, _ := newRequestContext("GET", "/nowhere", nil)
+    if route := statsRouteOf(ctx); route != missRoute {
+        t.Errorf("want %s, got %s", missRoute, route)
+    }
+
+    ctx.SetControllerId("user")
+    ctx.SetActionId("view")
+    if route := statsRouteOf(ctx); route != "user/view" {
+        t.Errorf("want user/view, got %s", route)
+    }
+
+    ctx.rule = &routeRule{pattern: "^/user/(\\d+)$", method: "GET"}
+    if route := statsRouteOf(ctx); route != "GET ^/user/(\\d+)$" {
+        t.Errorf("want method and pattern of rule, got %s", route)
+    }
+}
+
+func TestStatusServeStats(t *testing.T) {
+    s := &Status{}
+    s.Construct()
+    s.SetMaxRoutes(0)
+    if routes := s.Snapshot().Routes; routes == nil || len(routes) != 0 {
+        t.Errorf("disabled: want empty routes, got %v", routes)
+    }
+
+    s.SetMaxRoutes(10)
+    ctx, _ := newRequestContext("GET", "/ping", nil)
+    ctx.status = http.StatusServiceUnavailable
+    s.observe(ctx, 0, 2)
+
+    ctx, w := newRequestContext("GET", "/_stats", nil)
+    s.serveStats(ctx)
+
+    var snapshot StatusSnapshot
+    if err := json.Unmarshal(w.Body.Bytes(), &snapshot); err != nil {
+        t.Fatalf("want json snapshot, got %v", err)
+    }
+
+    if len(snapshot.Routes) != 1 || snapshot.Routes[0].Route != missRoute || snapshot.Routes[0].Errors != 1 {
+        t.Errorf("want error of unmatched route, got %+v", snapshot.Routes)
+    }
+}
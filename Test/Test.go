@@ -0,0 +1,84 @@
+package Test
+
+import (
+    "encoding/json"
+    "io"
+    "net/http"
+    "net/http/httptest"
+
+    "github.com/pinguo/pgo"
+)
+
+// ResponseRecorder records response written by controller action or
+// server, with typed getters for assertion in test, eg.
+// ctx, w := Test.NewTestContext("GET", "/user/view?id=1", nil)
+// pgo.App.GetServer().CallAction(ctx, "/user/view")
+// if w.GetStatus() != 200 || w.GetHeader("Content-Type") != "..." {...}
+type ResponseRecorder struct {
+    *httptest.ResponseRecorder
+}
+
+func NewRecorder() *ResponseRecorder {
+    return &ResponseRecorder{httptest.NewRecorder()}
+}
+
+// get response status, 200 if status is not written
+func (r *ResponseRecorder) GetStatus() int {
+    return r.Code
+}
+
+func (r *ResponseRecorder) GetHeader(name string) string {
+    return r.Header().Get(name)
+}
+
+func (r *ResponseRecorder) GetBody() []byte {
+    return r.Body.Bytes()
+}
+
+func (r *ResponseRecorder) GetString() string {
+    return r.Body.String()
+}
+
+// decode json body to v, eg. the standard json output of controller:
+// var res struct{Status int; Message string; Data *User}
+// e := w.GetJson(&res)
+func (r *ResponseRecorder) GetJson(v interface{}) error {
+    return json.Unmarshal(r.Body.Bytes(), v)
+}
+
+// create context of request with a recorder as output, body can be nil,
+// use NewRequest to customize request, eg. set header or cookie, route
+// params are passed to Server.CallAction, and mocks are injected by
+// App.SetComponent before the action is called, eg.
+// pgo.App.SetComponent("redis", mockRedis)
+// ctx, w := Test.NewTestContext("POST", "/user/edit?id=1", strings.NewReader(`{"name":"x"}`))
+// ctx.GetInput().Header.Set("Content-Type", "application/json")
+// pgo.App.GetServer().CallAction(ctx, "/user/edit", "1")
+func NewTestContext(method, target string, body io.Reader) (*pgo.Context, *ResponseRecorder) {
+    return NewRequestContext(NewRequest(method, target, body))
+}
+
+// create context of the given request with a recorder as output
+func NewRequestContext(r *http.Request) (*pgo.Context, *ResponseRecorder) {
+    w := NewRecorder()
+    ctx := &pgo.Context{}
+    ctx.SetInput(r)
+    ctx.SetOutput(w)
+    ctx.Init()
+
+    return ctx, w
+}
+
+// create request for test, target is a path or an absolute url
+func NewRequest(method, target string, body io.Reader) *http.Request {
+    return httptest.NewRequest(method, target, body)
+}
+
+// serve request through the server, including routing and plugins,
+// and return the recorded response
+func Serve(r *http.Request) *ResponseRecorder {
+    w := NewRecorder()
+    pgo.App.GetServer().ServeHTTP(w, r)
+
+    return w
+}
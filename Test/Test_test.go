@@ -0,0 +1,69 @@
+package Test_test
+
+import (
+    "fmt"
+    "net/http"
+    "strings"
+
+    "github.com/pinguo/pgo"
+    "github.com/pinguo/pgo/Test"
+)
+
+type ExampleController struct {
+    pgo.Controller
+}
+
+func (e *ExampleController) ActionView() {
+    id := e.GetContext().GetQuery("id", "0")
+    e.GetContext().SetHeader("X-User", id)
+    e.OutputJson(pgo.Map{"id": id}, http.StatusOK)
+}
+
+func (e *ExampleController) ActionEdit(id int) {
+    var form struct {
+        Name string `json:"name"`
+    }
+
+    e.GetContext().GetJsonBody(&form)
+    e.OutputJson(pgo.Map{"id": id, "name": form.Name}, http.StatusOK)
+}
+
+func init() {
+    pgo.App.GetContainer().BindName("Controller/ExampleController", &ExampleController{})
+
+    // console log is written async and races with stdout of examples
+    pgo.App.GetLog().SetLevels(pgo.LevelNone)
+}
+
+func ExampleNewTestContext() {
+    ctx, w := Test.NewTestContext("GET", "/example/view?id=1", nil)
+    pgo.App.GetServer().CallAction(ctx, "/example/view")
+
+    var res struct {
+        Status int
+        Data   struct{ Id string }
+    }
+
+    w.GetJson(&res)
+    fmt.Println(w.GetStatus(), w.GetHeader("X-User"), res.Status, res.Data.Id)
+    // Output: 200 1 200 1
+}
+
+func ExampleNewTestContext_routeParams() {
+    ctx, w := Test.NewTestContext("POST", "/example/edit", strings.NewReader(`{"name":"foo"}`))
+    ctx.GetInput().Header.Set("Content-Type", "application/json")
+    pgo.App.GetServer().CallAction(ctx, "/example/edit", "12")
+
+    fmt.Println(w.GetStatus(), strings.Contains(w.GetString(), `"name":"foo"`), strings.Contains(w.GetString(), `"id":12`))
+    // Output: 200 true true
+}
+
+func ExampleServe() {
+    pgo.App.GetRouter().AddHandler("^/example/ping$", func(ctx *pgo.Context) {
+        ctx.End(http.StatusOK, []byte("pong"))
+    }, nil)
+
+    w := Test.Serve(Test.NewRequest("GET", "/example/ping", nil))
+    fmt.Println(w.GetStatus(), w.GetString(), len(w.GetHeader(pgo.LogIdHeader)) > 0)
+    // Output: 200 pong true
+}
@@ -0,0 +1,134 @@
+package pgo
+
+import (
+    "crypto/tls"
+    "crypto/x509"
+    "fmt"
+    "strings"
+
+    "github.com/pinguo/pgo/Util"
+)
+
+// certificate configuration, hosts are names to select it by SNI,
+// eg. "example.com" or "*.example.com", names of certificate are
+// used if hosts is empty
+type tlsCert struct {
+    cert  string
+    key   string
+    hosts []string
+}
+
+// loaded certificates indexed by host name
+type certStore struct {
+    hosts map[string]*tls.Certificate
+    dft   *tls.Certificate
+}
+
+// set tls certificates and serve https, the first one is default for
+// request without SNI or with unmatched SNI, eg.
+// "certs": [
+//     {"cert": "@app/cert/a.pem", "key": "@app/cert/a.key", "hosts": ["a.com", "*.a.com"]},
+//     {"cert": "@app/cert/b.pem", "key": "@app/cert/b.key"}
+// ]
+func (s *Server) SetCerts(certs []interface{}) {
+    s.tlsCerts = make([]tlsCert, 0, len(certs))
+    for _, v := range certs {
+        conf, ok := v.(map[string]interface{})
+        if !ok {
+            panic(fmt.Sprintf("Server: invalid cert config, %v", v))
+        }
+
+        cert := tlsCert{cert: Util.ToString(conf["cert"]), key: Util.ToString(conf["key"])}
+        if hosts, ok := conf["hosts"].([]interface{}); ok {
+            for _, host := range hosts {
+                cert.hosts = append(cert.hosts, strings.ToLower(Util.ToString(host)))
+            }
+        }
+
+        s.tlsCerts = append(s.tlsCerts, cert)
+    }
+
+    if e := s.ReloadCerts(); e != nil {
+        panic(e.Error())
+    }
+}
+
+// reload certificate files, eg. after renewal, current certificates
+// are kept if any fails, it's called on SIGHUP if certs are configured
+func (s *Server) ReloadCerts() error {
+    store := &certStore{hosts: make(map[string]*tls.Certificate)}
+    for _, conf := range s.tlsCerts {
+        cert, e := tls.LoadX509KeyPair(GetAlias(conf.cert), GetAlias(conf.key))
+        if e != nil {
+            return fmt.Errorf("Server: failed to load cert %s, %s", conf.cert, e)
+        }
+
+        hosts := conf.hosts
+        if len(hosts) == 0 {
+            if leaf, e := x509.ParseCertificate(cert.Certificate[0]); e == nil {
+                hosts = leaf.DNSNames
+                if len(hosts) == 0 && len(leaf.Subject.CommonName) > 0 {
+                    hosts = []string{leaf.Subject.CommonName}
+                }
+            }
+        }
+
+        for _, host := range hosts {
+            // the former wins for duplicate host
+            if host = strings.ToLower(host); store.hosts[host] == nil {
+                store.hosts[host] = &cert
+            }
+        }
+
+        if store.dft == nil {
+            store.dft = &cert
+        }
+    }
+
+    s.certs.Store(store)
+    return nil
+}
+
+// check whether tls certificates are configured
+func (s *Server) IsTls() bool {
+    return len(s.tlsCerts) > 0
+}
+
+// select certificate by SNI, exact name first, then wildcard of
+// parent domain, default certificate for others
+func (s *Server) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+    store, _ := s.certs.Load().(*certStore)
+    if store == nil || store.dft == nil {
+        return nil, fmt.Errorf("Server: no certificate")
+    }
+
+    name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+    if cert, ok := store.hosts[name]; ok {
+        return cert, nil
+    }
+
+    if pos := strings.IndexByte(name, '.'); pos > 0 {
+        if cert, ok := store.hosts["*"+name[pos:]]; ok {
+            return cert, nil
+        }
+    }
+
+    return store.dft, nil
+}
+
+// tls config of server, http/2 is negotiated by ALPN
+func (s *Server) tlsConfig() *tls.Config {
+    conf := s.http.TLSConfig
+    if conf == nil {
+        conf = &tls.Config{MinVersion: tls.VersionTLS12}
+    } else {
+        conf = conf.Clone()
+    }
+
+    conf.GetCertificate = s.getCertificate
+    if len(conf.NextProtos) == 0 {
+        conf.NextProtos = []string{"h2", "http/1.1"}
+    }
+
+    return conf
+}
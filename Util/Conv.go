@@ -124,6 +124,50 @@ func ToString(v interface{}) string {
     }
 }
 
+// ParseBool parse bool string tolerantly, true/false, 1/0,
+// yes/no, on/off are accepted, ok is false for invalid string
+func ParseBool(s string) (b bool, ok bool) {
+    s = strings.TrimSpace(s)
+    switch strings.ToLower(s) {
+    case "yes", "y", "on":
+        return true, true
+    case "no", "n", "off":
+        return false, true
+    }
+
+    if b, e := strconv.ParseBool(s); e == nil {
+        return b, true
+    }
+
+    return false, false
+}
+
+// ParseInt parse int string tolerantly, decimal, hexadecimal,
+// octal and float string are accepted, ok is false for invalid string
+func ParseInt(s string) (i int, ok bool) {
+    s = strings.TrimSpace(s)
+    if i64, e := strconv.ParseInt(s, 0, 0); e == nil {
+        return int(i64), true
+    } else if f64, e := strconv.ParseFloat(s, 64); e == nil {
+        return int(f64), true
+    }
+
+    return 0, false
+}
+
+// ParseFloat parse float string tolerantly, float and int string
+// are accepted, ok is false for invalid string
+func ParseFloat(s string) (f float64, ok bool) {
+    s = strings.TrimSpace(s)
+    if f64, e := strconv.ParseFloat(s, 64); e == nil {
+        return f64, true
+    } else if i64, e := strconv.ParseInt(s, 0, 0); e == nil {
+        return float64(i64), true
+    }
+
+    return 0, false
+}
+
 func str2bool(s string) bool {
     s = strings.TrimSpace(s)
     if b, e := strconv.ParseBool(s); e == nil {
@@ -0,0 +1,45 @@
+package Util
+
+import (
+    "testing"
+)
+
+func TestParseBool(t *testing.T) {
+    tests := map[string][2]bool{
+        "true": {true, true}, "1": {true, true}, "Yes": {true, true}, "on": {true, true}, " y ": {true, true},
+        "false": {false, true}, "0": {false, true}, "NO": {false, true}, "off": {false, true},
+        "abc": {false, false}, "": {false, false},
+    }
+
+    for s, want := range tests {
+        if b, ok := ParseBool(s); b != want[0] || ok != want[1] {
+            t.Errorf("%q: want %v %v, got %v %v", s, want[0], want[1], b, ok)
+        }
+    }
+}
+
+func TestParseInt(t *testing.T) {
+    tests := map[string]int{"12": 12, " -3 ": -3, "0x1f": 31, "0o17": 15, "9.8": 9}
+    for s, want := range tests {
+        if i, ok := ParseInt(s); !ok || i != want {
+            t.Errorf("%q: want %d, got %d %v", s, want, i, ok)
+        }
+    }
+
+    if _, ok := ParseInt("abc"); ok {
+        t.Error("abc: want invalid")
+    }
+}
+
+func TestParseFloat(t *testing.T) {
+    tests := map[string]float64{"1.5": 1.5, "2": 2, "0x10": 16, "-0.25": -0.25}
+    for s, want := range tests {
+        if f, ok := ParseFloat(s); !ok || f != want {
+            t.Errorf("%q: want %v, got %v %v", s, want, f, ok)
+        }
+    }
+
+    if _, ok := ParseFloat("1.2.3"); ok {
+        t.Error("1.2.3: want invalid")
+    }
+}
@@ -0,0 +1,71 @@
+package Util
+
+import (
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha256"
+    "errors"
+    "io"
+)
+
+var ErrDecrypt = errors.New("decrypt failed")
+
+// derive a 32 bytes sub key of purpose from master key,
+// so one configured key can be used for signing and encryption
+func DeriveKey(key []byte, purpose string) []byte {
+    return HmacSha256(key, []byte(purpose))
+}
+
+// hmac-sha256 of data
+func HmacSha256(key, data []byte) []byte {
+    mac := hmac.New(sha256.New, key)
+    mac.Write(data)
+    return mac.Sum(nil)
+}
+
+// encrypt by aes-gcm, key must be 16, 24 or 32 bytes,
+// output is nonce followed by sealed data, aad is authenticated
+// but not encrypted, eg. name of cookie
+func AesGcmEncrypt(key, plain, aad []byte) ([]byte, error) {
+    gcm, e := newGcm(key)
+    if e != nil {
+        return nil, e
+    }
+
+    nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plain)+gcm.Overhead())
+    if _, e := io.ReadFull(rand.Reader, nonce); e != nil {
+        return nil, e
+    }
+
+    return gcm.Seal(nonce, nonce, plain, aad), nil
+}
+
+// decrypt data encrypted by AesGcmEncrypt
+func AesGcmDecrypt(key, data, aad []byte) ([]byte, error) {
+    gcm, e := newGcm(key)
+    if e != nil {
+        return nil, e
+    }
+
+    if len(data) < gcm.NonceSize()+gcm.Overhead() {
+        return nil, ErrDecrypt
+    }
+
+    plain, e := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], aad)
+    if e != nil {
+        return nil, ErrDecrypt
+    }
+
+    return plain, nil
+}
+
+func newGcm(key []byte) (cipher.AEAD, error) {
+    block, e := aes.NewCipher(key)
+    if e != nil {
+        return nil, e
+    }
+
+    return cipher.NewGCM(block)
+}
@@ -0,0 +1,86 @@
+package Util
+
+import (
+    "bufio"
+    "fmt"
+    "os"
+    "strings"
+)
+
+// LoadEnvFile load variables from .env file into process environment,
+// variables already set are not overwritten, format:
+// # comment
+// export FOO=bar
+// NAME="hello world" # inline comment
+// RAW='no ${expand} or \n escape'
+func LoadEnvFile(path string) error {
+    h, e := os.Open(path)
+    if e != nil {
+        return e
+    }
+
+    defer h.Close()
+
+    scanner, num := bufio.NewScanner(h), 0
+    for scanner.Scan() {
+        num++
+        line := strings.TrimSpace(scanner.Text())
+        if len(line) == 0 || line[0] == '#' {
+            continue
+        }
+
+        line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+        pos := strings.IndexByte(line, '=')
+        if pos <= 0 {
+            return fmt.Errorf("%s:%d: invalid line", path, num)
+        }
+
+        key := strings.TrimSpace(line[:pos])
+        value, e := parseEnvValue(strings.TrimSpace(line[pos+1:]))
+        if e != nil {
+            return fmt.Errorf("%s:%d: %s", path, num, e)
+        }
+
+        if _, ok := os.LookupEnv(key); !ok {
+            os.Setenv(key, value)
+        }
+    }
+
+    return scanner.Err()
+}
+
+func parseEnvValue(v string) (string, error) {
+    if len(v) == 0 {
+        return "", nil
+    }
+
+    switch quote := v[0]; quote {
+    case '"', '\'':
+        end := strings.IndexByte(v[1:], quote)
+        for quote == '"' && end > 0 && v[end] == '\\' {
+            next := strings.IndexByte(v[end+2:], quote)
+            if next == -1 {
+                end = -1
+                break
+            }
+            end += next + 1
+        }
+
+        if end == -1 {
+            return "", fmt.Errorf("unterminated quote")
+        }
+
+        value := v[1 : end+1]
+        if quote == '"' {
+            value = strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\"`, `"`, `\\`, `\`).Replace(value)
+        }
+
+        return value, nil
+    }
+
+    if pos := strings.Index(v, " #"); pos != -1 {
+        v = v[:pos]
+    }
+
+    return strings.TrimSpace(v), nil
+}
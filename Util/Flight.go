@@ -0,0 +1,94 @@
+package Util
+
+import (
+    "fmt"
+    "sync"
+)
+
+var defaultFlight = NewSingleFlight()
+
+// Do run fn by the default SingleFlight, see SingleFlight.Do
+func Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+    return defaultFlight.Do(key, fn)
+}
+
+// NewSingleFlight new single-flight group
+func NewSingleFlight() *SingleFlight {
+    return &SingleFlight{calls: make(map[string]*flightCall)}
+}
+
+type flightCall struct {
+    wg    sync.WaitGroup
+    value interface{}
+    err   error
+}
+
+// SingleFlight deduplicate concurrent calls of the same key
+type SingleFlight struct {
+    lock  sync.Mutex
+    calls map[string]*flightCall
+}
+
+// Do run fn and return its result, concurrent callers of the same
+// key wait for the running call and share its result instead of
+// running fn again, panic of fn is returned to all callers as error.
+func (s *SingleFlight) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+    s.lock.Lock()
+    if call, ok := s.calls[key]; ok {
+        s.lock.Unlock()
+        call.wg.Wait()
+        return call.value, call.err
+    }
+
+    call := &flightCall{}
+    call.wg.Add(1)
+    s.calls[key] = call
+    s.lock.Unlock()
+
+    func() {
+        defer func() {
+            if v := recover(); v != nil {
+                call.value, call.err = nil, fmt.Errorf("singleflight: %s panic, %s", key, ToString(v))
+            }
+        }()
+
+        call.value, call.err = fn()
+    }()
+
+    s.lock.Lock()
+    if s.calls[key] == call {
+        delete(s.calls, key)
+    }
+    s.lock.Unlock()
+    call.wg.Done()
+
+    return call.value, call.err
+}
+
+// FlightResult result of DoChan
+type FlightResult struct {
+    Value interface{}
+    Err   error
+}
+
+// DoChan like Do, but run fn in a new goroutine and return a channel
+// receiving the result, so caller can stop waiting, eg. on timeout,
+// while fn goes on for other callers.
+func (s *SingleFlight) DoChan(key string, fn func() (interface{}, error)) <-chan FlightResult {
+    ch := make(chan FlightResult, 1)
+    go func() {
+        value, err := s.Do(key, fn)
+        ch <- FlightResult{value, err}
+    }()
+
+    return ch
+}
+
+// Forget forget the running call of key, later callers run fn
+// instead of waiting for the running call
+func (s *SingleFlight) Forget(key string) {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    delete(s.calls, key)
+}
@@ -0,0 +1,125 @@
+package Util
+
+import (
+    "errors"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+// start n callers of key, fn blocks until release is closed
+func startCalls(s *SingleFlight, n int, key string, fn func() (interface{}, error)) (*sync.WaitGroup, []interface{}, []error) {
+    wg := &sync.WaitGroup{}
+    values, errs := make([]interface{}, n), make([]error, n)
+    for i := 0; i < n; i++ {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            values[i], errs[i] = s.Do(key, fn)
+        }(i)
+    }
+
+    return wg, values, errs
+}
+
+func TestSingleFlightDo(t *testing.T) {
+    s := NewSingleFlight()
+    calls := int32(0)
+    release := make(chan struct{})
+    fn := func() (interface{}, error) {
+        atomic.AddInt32(&calls, 1)
+        <-release
+        return "value", nil
+    }
+
+    wg, values, errs := startCalls(s, 8, "key", fn)
+    time.Sleep(20 * time.Millisecond)
+    close(release)
+    wg.Wait()
+
+    if n := atomic.LoadInt32(&calls); n != 1 {
+        t.Errorf("want fn called once, got %d", n)
+    }
+
+    for i := range values {
+        if values[i] != "value" || errs[i] != nil {
+            t.Errorf("caller %d: want shared value, got %v %v", i, values[i], errs[i])
+        }
+    }
+
+    // result of finished call is not kept
+    if v, _ := s.Do("key", func() (interface{}, error) { return "again", nil }); v != "again" {
+        t.Errorf("after finished: want fn called again, got %v", v)
+    }
+}
+
+func TestSingleFlightError(t *testing.T) {
+    s := NewSingleFlight()
+    release := make(chan struct{})
+    wg, _, errs := startCalls(s, 4, "key", func() (interface{}, error) {
+        <-release
+        return nil, errors.New("fail")
+    })
+
+    time.Sleep(20 * time.Millisecond)
+    close(release)
+    wg.Wait()
+
+    for i, e := range errs {
+        if e == nil || e.Error() != "fail" {
+            t.Errorf("caller %d: want shared error, got %v", i, e)
+        }
+    }
+
+    _, e := s.Do("panic", func() (interface{}, error) { panic("boom") })
+    if e == nil || !strings.Contains(e.Error(), "boom") {
+        t.Errorf("panic: want error, got %v", e)
+    }
+}
+
+func TestSingleFlightDoChan(t *testing.T) {
+    s := NewSingleFlight()
+    release := make(chan struct{})
+    ch := s.DoChan("key", func() (interface{}, error) {
+        <-release
+        return 1, nil
+    })
+
+    select {
+    case <-ch:
+        t.Fatal("want result after release")
+    case <-time.After(20 * time.Millisecond):
+    }
+
+    // late caller shares the running call
+    late := s.DoChan("key", func() (interface{}, error) { return 2, nil })
+    time.Sleep(20 * time.Millisecond)
+    close(release)
+
+    if r := <-ch; r.Value != 1 || r.Err != nil {
+        t.Errorf("want 1, got %v %v", r.Value, r.Err)
+    }
+
+    if r := <-late; r.Value != 1 {
+        t.Errorf("late caller: want shared 1, got %v", r.Value)
+    }
+}
+
+func TestSingleFlightForget(t *testing.T) {
+    s := NewSingleFlight()
+    release := make(chan struct{})
+    defer close(release)
+
+    s.DoChan("key", func() (interface{}, error) {
+        <-release
+        return 1, nil
+    })
+    time.Sleep(20 * time.Millisecond)
+
+    s.Forget("key")
+    if v, _ := s.Do("key", func() (interface{}, error) { return 2, nil }); v != 2 {
+        t.Errorf("after forget: want new call, got %v", v)
+    }
+}
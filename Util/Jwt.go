@@ -0,0 +1,194 @@
+package Util
+
+import (
+    "crypto"
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/rsa"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "strings"
+    "time"
+)
+
+var (
+    ErrJwtMalformed   = errors.New("jwt: malformed token")
+    ErrJwtAlgorithm   = errors.New("jwt: unsupported algorithm")
+    ErrJwtKey         = errors.New("jwt: invalid key for algorithm")
+    ErrJwtSignature   = errors.New("jwt: invalid signature")
+    ErrJwtExpired     = errors.New("jwt: token is expired")
+    ErrJwtNotValidYet = errors.New("jwt: token is not valid yet")
+    ErrJwtIssuer      = errors.New("jwt: invalid issuer")
+    ErrJwtAudience    = errors.New("jwt: invalid audience")
+)
+
+// get verifying key by alg of token header, eg. select key by "kid"
+type JwtKeyFunc func(alg string, header map[string]interface{}) (interface{}, error)
+
+// options of parsing jwt, zero value checks exp and nbf without skew
+type JwtOptions struct {
+    Skew     time.Duration    // clock skew tolerance of exp and nbf
+    Issuer   string           // expected iss if not empty
+    Audience string           // expected aud if not empty
+    Now      func() time.Time // current time, default time.Now
+}
+
+// sign claims to compact jwt, alg is HS256 with []byte key or
+// RS256 with *rsa.PrivateKey, standard claims(exp, nbf, iat, iss,
+// sub, aud, jti) are set by caller, numeric dates are unix seconds.
+func JwtSign(claims map[string]interface{}, alg string, key interface{}) (string, error) {
+    header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+    payload, e := json.Marshal(claims)
+    if e != nil {
+        return "", e
+    }
+
+    enc := base64.RawURLEncoding
+    signing := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+
+    var sig []byte
+    switch alg {
+    case "HS256":
+        secret, ok := key.([]byte)
+        if !ok || len(secret) == 0 {
+            return "", ErrJwtKey
+        }
+        mac := hmac.New(sha256.New, secret)
+        mac.Write([]byte(signing))
+        sig = mac.Sum(nil)
+    case "RS256":
+        private, ok := key.(*rsa.PrivateKey)
+        if !ok {
+            return "", ErrJwtKey
+        }
+        sum := sha256.Sum256([]byte(signing))
+        if sig, e = rsa.SignPKCS1v15(rand.Reader, private, crypto.SHA256, sum[:]); e != nil {
+            return "", e
+        }
+    default:
+        return "", ErrJwtAlgorithm
+    }
+
+    return signing + "." + enc.EncodeToString(sig), nil
+}
+
+// parse and verify compact jwt, return its claims, key type must match
+// alg of header to prevent algorithm confusion, HS256 requires []byte,
+// RS256 requires *rsa.PublicKey, "none" is always rejected.
+func JwtParse(token string, keyFunc JwtKeyFunc, opts *JwtOptions) (map[string]interface{}, error) {
+    parts := strings.Split(token, ".")
+    if len(parts) != 3 {
+        return nil, ErrJwtMalformed
+    }
+
+    enc := base64.RawURLEncoding
+    var header, claims map[string]interface{}
+    if b, e := enc.DecodeString(parts[0]); e != nil || json.Unmarshal(b, &header) != nil {
+        return nil, ErrJwtMalformed
+    }
+
+    if b, e := enc.DecodeString(parts[1]); e != nil || json.Unmarshal(b, &claims) != nil || claims == nil {
+        return nil, ErrJwtMalformed
+    }
+
+    sig, e := enc.DecodeString(parts[2])
+    if e != nil {
+        return nil, ErrJwtMalformed
+    }
+
+    alg, _ := header["alg"].(string)
+    key, e := keyFunc(alg, header)
+    if e != nil {
+        return nil, e
+    }
+
+    signing := parts[0] + "." + parts[1]
+    switch alg {
+    case "HS256":
+        secret, ok := key.([]byte)
+        if !ok || len(secret) == 0 {
+            return nil, ErrJwtKey
+        }
+        mac := hmac.New(sha256.New, secret)
+        mac.Write([]byte(signing))
+        if !hmac.Equal(sig, mac.Sum(nil)) {
+            return nil, ErrJwtSignature
+        }
+    case "RS256":
+        public, ok := key.(*rsa.PublicKey)
+        if !ok {
+            return nil, ErrJwtKey
+        }
+        sum := sha256.Sum256([]byte(signing))
+        if rsa.VerifyPKCS1v15(public, crypto.SHA256, sum[:], sig) != nil {
+            return nil, ErrJwtSignature
+        }
+    default:
+        return nil, ErrJwtAlgorithm
+    }
+
+    if opts == nil {
+        opts = &JwtOptions{}
+    }
+
+    if e := opts.validate(claims); e != nil {
+        return nil, e
+    }
+
+    return claims, nil
+}
+
+// validate exp, nbf, iss and aud of claims
+func (o *JwtOptions) validate(claims map[string]interface{}) error {
+    now := time.Now()
+    if o.Now != nil {
+        now = o.Now()
+    }
+
+    if v, ok := claims["exp"]; ok {
+        exp, ok := v.(float64)
+        if !ok {
+            return ErrJwtMalformed
+        } else if !now.Before(jwtTime(exp).Add(o.Skew)) {
+            return ErrJwtExpired
+        }
+    }
+
+    if v, ok := claims["nbf"]; ok {
+        nbf, ok := v.(float64)
+        if !ok {
+            return ErrJwtMalformed
+        } else if now.Add(o.Skew).Before(jwtTime(nbf)) {
+            return ErrJwtNotValidYet
+        }
+    }
+
+    if len(o.Issuer) > 0 && claims["iss"] != o.Issuer {
+        return ErrJwtIssuer
+    }
+
+    if len(o.Audience) > 0 {
+        switch aud := claims["aud"].(type) {
+        case string:
+            if aud == o.Audience {
+                return nil
+            }
+        case []interface{}:
+            for _, v := range aud {
+                if v == o.Audience {
+                    return nil
+                }
+            }
+        }
+        return ErrJwtAudience
+    }
+
+    return nil
+}
+
+func jwtTime(v float64) time.Time {
+    sec := int64(v)
+    return time.Unix(sec, int64((v-float64(sec))*1e9))
+}
@@ -0,0 +1,113 @@
+package Util
+
+import (
+    "crypto/rand"
+    "crypto/rsa"
+    "encoding/base64"
+    "strings"
+    "testing"
+    "time"
+)
+
+func hsKey(secret string) JwtKeyFunc {
+    return func(alg string, header map[string]interface{}) (interface{}, error) {
+        return []byte(secret), nil
+    }
+}
+
+func TestJwtHS256(t *testing.T) {
+    token, e := JwtSign(map[string]interface{}{"sub": "u1"}, "HS256", []byte("secret"))
+    if e != nil {
+        t.Fatalf("sign: %s", e)
+    }
+
+    claims, e := JwtParse(token, hsKey("secret"), nil)
+    if e != nil || claims["sub"] != "u1" {
+        t.Errorf("want sub u1, got %v %v", claims, e)
+    }
+
+    if _, e := JwtParse(token, hsKey("other"), nil); e != ErrJwtSignature {
+        t.Errorf("other secret: want ErrJwtSignature, got %v", e)
+    }
+
+    // tamper payload and keep signature
+    parts := strings.Split(token, ".")
+    parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin"}`))
+    if _, e := JwtParse(strings.Join(parts, "."), hsKey("secret"), nil); e != ErrJwtSignature {
+        t.Errorf("tampered payload: want ErrJwtSignature, got %v", e)
+    }
+
+    for _, bad := range []string{"", "a.b", "a.b.c", parts[0] + ".e30.!"} {
+        if _, e := JwtParse(bad, hsKey("secret"), nil); e != ErrJwtMalformed {
+            t.Errorf("%q: want ErrJwtMalformed, got %v", bad, e)
+        }
+    }
+}
+
+func TestJwtRS256(t *testing.T) {
+    key, e := rsa.GenerateKey(rand.Reader, 2048)
+    if e != nil {
+        t.Fatal(e)
+    }
+
+    token, e := JwtSign(map[string]interface{}{"sub": "u1"}, "RS256", key)
+    if e != nil {
+        t.Fatalf("sign: %s", e)
+    }
+
+    rsKey := func(alg string, header map[string]interface{}) (interface{}, error) {
+        return &key.PublicKey, nil
+    }
+
+    if claims, e := JwtParse(token, rsKey, nil); e != nil || claims["sub"] != "u1" {
+        t.Errorf("want sub u1, got %v %v", claims, e)
+    }
+
+    // hs256 token verified with public key bytes must not pass
+    forged, _ := JwtSign(map[string]interface{}{"sub": "admin"}, "HS256", []byte("public key"))
+    if _, e := JwtParse(forged, rsKey, nil); e != ErrJwtKey {
+        t.Errorf("algorithm confusion: want ErrJwtKey, got %v", e)
+    }
+
+    if _, e := JwtSign(nil, "RS256", []byte("secret")); e != ErrJwtKey {
+        t.Errorf("rs256 with secret: want ErrJwtKey, got %v", e)
+    }
+}
+
+func TestJwtAlgNone(t *testing.T) {
+    enc := base64.RawURLEncoding
+    token := enc.EncodeToString([]byte(`{"alg":"none"}`)) + "." + enc.EncodeToString([]byte(`{"sub":"u1"}`)) + "."
+    if _, e := JwtParse(token, hsKey("secret"), nil); e != ErrJwtAlgorithm {
+        t.Errorf("alg none: want ErrJwtAlgorithm, got %v", e)
+    }
+}
+
+func TestJwtValidate(t *testing.T) {
+    now := time.Unix(1000, 0)
+    opts := func(skew time.Duration) *JwtOptions {
+        return &JwtOptions{Skew: skew, Issuer: "pgo", Audience: "api", Now: func() time.Time { return now }}
+    }
+
+    tests := []struct {
+        claims map[string]interface{}
+        skew   time.Duration
+        want   error
+    }{
+        {map[string]interface{}{"iss": "pgo", "aud": "api", "exp": 1001}, 0, nil},
+        {map[string]interface{}{"iss": "pgo", "aud": "api", "exp": 1000}, 0, ErrJwtExpired},
+        {map[string]interface{}{"iss": "pgo", "aud": "api", "exp": 999}, 5 * time.Second, nil},
+        {map[string]interface{}{"iss": "pgo", "aud": "api", "nbf": 1005}, 0, ErrJwtNotValidYet},
+        {map[string]interface{}{"iss": "pgo", "aud": "api", "nbf": 1005}, 5 * time.Second, nil},
+        {map[string]interface{}{"iss": "pgo", "aud": "api", "exp": "soon"}, 0, ErrJwtMalformed},
+        {map[string]interface{}{"iss": "other", "aud": "api"}, 0, ErrJwtIssuer},
+        {map[string]interface{}{"iss": "pgo", "aud": []string{"web", "api"}}, 0, nil},
+        {map[string]interface{}{"iss": "pgo", "aud": "web"}, 0, ErrJwtAudience},
+    }
+
+    for i, test := range tests {
+        token, _ := JwtSign(test.claims, "HS256", []byte("secret"))
+        if _, e := JwtParse(token, hsKey("secret"), opts(test.skew)); e != test.want {
+            t.Errorf("case %d %v: want %v, got %v", i, test.claims, test.want, e)
+        }
+    }
+}
@@ -0,0 +1,82 @@
+package Util
+
+import (
+    "sync"
+    "time"
+)
+
+// NewTokenBucket new token bucket which refills rate tokens
+// per second and holds at most burst tokens, bucket is full
+// initially.
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+    if burst <= 0 {
+        burst = 1
+    }
+
+    return &TokenBucket{
+        rate:   rate,
+        burst:  float64(burst),
+        tokens: float64(burst),
+        last:   time.Now(),
+    }
+}
+
+// TokenBucket concurrency-safe token bucket rate limiter
+type TokenBucket struct {
+    lock   sync.Mutex
+    rate   float64
+    burst  float64
+    tokens float64
+    last   time.Time
+}
+
+// Allow take one token if available without waiting
+func (t *TokenBucket) Allow() bool {
+    _, ok := t.Take(0)
+    return ok
+}
+
+// Take reserve one token, wait is the duration caller must sleep
+// before the token becomes valid, if wait exceeds maxWait nothing
+// is reserved and ok is false.
+func (t *TokenBucket) Take(maxWait time.Duration) (wait time.Duration, ok bool) {
+    t.lock.Lock()
+    defer t.lock.Unlock()
+
+    now := time.Now()
+    if elapse := now.Sub(t.last).Seconds(); elapse > 0 {
+        t.tokens += elapse * t.rate
+        if t.tokens > t.burst {
+            t.tokens = t.burst
+        }
+    }
+
+    t.last = now
+    if t.tokens >= 1 {
+        t.tokens--
+        return 0, true
+    }
+
+    if t.rate <= 0 {
+        return 0, false
+    }
+
+    wait = time.Duration((1 - t.tokens) / t.rate * float64(time.Second))
+    if wait > maxWait {
+        return 0, false
+    }
+
+    t.tokens--
+    return wait, true
+}
+
+// Refund give back one token reserved by Take, eg. caller
+// stops waiting before the token becomes valid.
+func (t *TokenBucket) Refund() {
+    t.lock.Lock()
+    defer t.lock.Unlock()
+
+    if t.tokens++; t.tokens > t.burst {
+        t.tokens = t.burst
+    }
+}
@@ -0,0 +1,66 @@
+package Util
+
+import (
+    "testing"
+    "time"
+)
+
+func TestTokenBucketBurst(t *testing.T) {
+    b := NewTokenBucket(1, 3)
+    for i := 0; i < 3; i++ {
+        if !b.Allow() {
+            t.Fatalf("token %d of burst: want allowed", i)
+        }
+    }
+
+    if b.Allow() {
+        t.Error("bucket exhausted: want denied")
+    }
+}
+
+func TestTokenBucketTake(t *testing.T) {
+    b := NewTokenBucket(10, 1)
+    b.Allow()
+
+    if _, ok := b.Take(10 * time.Millisecond); ok {
+        t.Error("wait of 100ms exceeds max wait: want not reserved")
+    }
+
+    wait, ok := b.Take(time.Second)
+    if !ok || wait <= 0 || wait > 100*time.Millisecond {
+        t.Errorf("want reserved with wait in (0, 100ms], got %v %v", wait, ok)
+    }
+
+    // the next token is behind the reserved one
+    if wait2, ok := b.Take(time.Second); !ok || wait2 <= wait {
+        t.Errorf("want second wait longer than %v, got %v %v", wait, wait2, ok)
+    }
+}
+
+func TestTokenBucketRefund(t *testing.T) {
+    b := NewTokenBucket(1, 1)
+    b.Allow()
+
+    if _, ok := b.Take(2 * time.Second); !ok {
+        t.Fatal("want token reserved")
+    }
+
+    b.Refund()
+    b.Refund()
+    if !b.Allow() {
+        t.Error("after refund: want the returned token allowed")
+    }
+
+    if b.Allow() {
+        t.Error("refund must not exceed burst")
+    }
+}
+
+func TestTokenBucketZeroRate(t *testing.T) {
+    b := NewTokenBucket(0, 1)
+    b.Allow()
+
+    if _, ok := b.Take(time.Hour); ok {
+        t.Error("zero rate never refills: want not reserved")
+    }
+}
@@ -90,3 +90,35 @@ func MapSet(m map[string]interface{}, key string, val interface{}) {
         }
     }
 }
+
+// MapCopy copy map recursively, nested maps are not shared
+func MapCopy(m map[string]interface{}) map[string]interface{} {
+    c := make(map[string]interface{}, len(m))
+    for k, v := range m {
+        if vm, ok := v.(map[string]interface{}); ok {
+            c[k] = MapCopy(vm)
+        } else {
+            c[k] = v
+        }
+    }
+
+    return c
+}
+
+// MapFlatten flatten nested map to dot separated keys,
+// eg. {"a": {"b": 1}} => {"a.b": 1}, empty map is kept
+func MapFlatten(m map[string]interface{}) map[string]interface{} {
+    flat := make(map[string]interface{})
+    mapFlatten(flat, "", m)
+    return flat
+}
+
+func mapFlatten(flat map[string]interface{}, prefix string, m map[string]interface{}) {
+    for k, v := range m {
+        if vm, ok := v.(map[string]interface{}); ok && len(vm) > 0 {
+            mapFlatten(flat, prefix+k+".", vm)
+        } else {
+            flat[prefix+k] = v
+        }
+    }
+}
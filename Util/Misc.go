@@ -66,6 +66,20 @@ func GenUniqueId() string {
     )
 }
 
+// ParseIpNet parse ip or cidr, single ip is converted to cidr of itself
+func ParseIpNet(ip string) (*net.IPNet, error) {
+    if !strings.Contains(ip, "/") {
+        if strings.Contains(ip, ":") {
+            ip += "/128"
+        } else {
+            ip += "/32"
+        }
+    }
+
+    _, ipNet, e := net.ParseCIDR(ip)
+    return ipNet, e
+}
+
 // ExpandEnv expand env variables, format: ${env}, ${env||default}
 func ExpandEnv(data []byte) []byte {
     rf := func(s []byte) []byte {
@@ -87,6 +101,60 @@ func ExpandEnv(data []byte) []byte {
     return envRe.ReplaceAllFunc(data, rf)
 }
 
+// StripJsonComments strip // and /* */ comments and trailing commas
+// outside of strings, so JSONC content can be parsed by encoding/json,
+// newlines of comments are kept to preserve line numbers of errors
+func StripJsonComments(data []byte) []byte {
+    output := make([]byte, 0, len(data))
+    inString, comma := false, -1
+
+    for i := 0; i < len(data); i++ {
+        c := data[i]
+        if inString {
+            output = append(output, c)
+            if c == '\\' && i+1 < len(data) {
+                i++
+                output = append(output, data[i])
+            } else if c == '"' {
+                inString = false
+            }
+            continue
+        }
+
+        switch {
+        case c == '/' && i+1 < len(data) && data[i+1] == '/':
+            for i < len(data) && data[i] != '\n' {
+                i++
+            }
+            if i < len(data) {
+                output = append(output, '\n')
+            }
+        case c == '/' && i+1 < len(data) && data[i+1] == '*':
+            for i += 2; i < len(data) && !(data[i] == '*' && i+1 < len(data) && data[i+1] == '/'); i++ {
+                if data[i] == '\n' {
+                    output = append(output, '\n')
+                }
+            }
+            i++
+        case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+            output = append(output, c)
+        case (c == '}' || c == ']') && comma != -1:
+            output = append(output[:comma], output[comma+1:]...)
+            output = append(output, c)
+            comma = -1
+        default:
+            if comma = -1; c == ',' {
+                comma = len(output)
+            } else if c == '"' {
+                inString = true
+            }
+            output = append(output, c)
+        }
+    }
+
+    return output
+}
+
 // FormatLanguage format lang to ll-CC format
 func FormatLanguage(lang string) string {
     matches := langRe.FindStringSubmatch(lang)
@@ -0,0 +1,35 @@
+package Util
+
+import (
+    "encoding/json"
+    "testing"
+)
+
+func TestStripJsonComments(t *testing.T) {
+    tests := []struct {
+        name, input, want string
+    }{
+        {"url in string", `{"url": "http://example.com/a//b"}`, `{"url": "http://example.com/a//b"}`},
+        {"block in string", `{"glob": "/* not comment */"}`, `{"glob": "/* not comment */"}`},
+        {"escaped quote", `{"s": "say \"hi\" // not comment"}`, `{"s": "say \"hi\" // not comment"}`},
+        {"escaped backslash", `{"path": "c:\\", "n": 1} // end`, `{"path": "c:\\", "n": 1} `},
+        {"comma in string", `{"s": "a,", }`, `{"s": "a," }`},
+        {"line comment", "{\n  \"a\": 1 // one\n}", "{\n  \"a\": 1 \n}"},
+        {"block comment keeps lines", "/* a\nb */{\"a\": /* x */ 1}", "\n{\"a\":  1}"},
+        {"comment after trailing comma", "{\"a\": 1, // last\n}", "{\"a\": 1 \n}"},
+        {"block before closing bracket", `[1, 2, /* end */]`, `[1, 2 ]`},
+        {"nested trailing commas", `{"a": [1, ], "b": {"c": 2, }, }`, `{"a": [1 ], "b": {"c": 2 } }`},
+        {"comma before comment and value", `[1, /* x */ 2]`, `[1,  2]`},
+    }
+
+    for _, test := range tests {
+        got := string(StripJsonComments([]byte(test.input)))
+        if got != test.want {
+            t.Errorf("%s: want %q, got %q", test.name, test.want, got)
+        }
+
+        if !json.Valid([]byte(got)) {
+            t.Errorf("%s: want valid json, got %q", test.name, got)
+        }
+    }
+}
@@ -2,6 +2,7 @@ package Util
 
 import (
     "reflect"
+    "strings"
 )
 
 // STMergeSame merge none zero field of s2 into s1,
@@ -88,3 +89,110 @@ func STMergeField(s1, s2 interface{}) {
         field1.Set(field2.Convert(field1.Type()))
     }
 }
+
+// STBindValues bind url values like map into fields of struct s,
+// field name is taken from tag if present, otherwise the field
+// name with lower first letter is used, eg. `query:"user_id"`.
+// missing or invalid values are skipped and the field keeps its
+// original value, embedded struct is bound recursively.
+func STBindValues(s interface{}, values map[string][]string, tag string) {
+    v := reflect.ValueOf(s)
+    if v.Kind() != reflect.Ptr || v.IsNil() {
+        panic("STBindValues: param 1 must be pointer")
+    }
+
+    if v = v.Elem(); v.Kind() != reflect.Struct {
+        panic("STBindValues: param 1 must be pointer of struct")
+    }
+
+    bindStructValues(v, values, tag)
+}
+
+func bindStructValues(v reflect.Value, values map[string][]string, tag string) {
+    rt := v.Type()
+    for i, n := 0, v.NumField(); i < n; i++ {
+        sf, field := rt.Field(i), v.Field(i)
+        if !field.CanSet() {
+            continue
+        }
+
+        if sf.Anonymous && field.Kind() == reflect.Struct {
+            bindStructValues(field, values, tag)
+            continue
+        }
+
+        name := sf.Tag.Get(tag)
+        if name == "-" {
+            continue
+        } else if len(name) == 0 {
+            name = strings.ToLower(sf.Name[:1]) + sf.Name[1:]
+        }
+
+        vs, ok := values[name]
+        if !ok || len(vs) == 0 {
+            continue
+        }
+
+        if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+            slice := reflect.MakeSlice(field.Type(), 0, len(vs))
+            for _, s := range vs {
+                elem := reflect.New(field.Type().Elem()).Elem()
+                if setStringValue(elem, s) {
+                    slice = reflect.Append(slice, elem)
+                }
+            }
+
+            field.Set(slice)
+        } else {
+            setStringValue(field, vs[0])
+        }
+    }
+}
+
+// ParseValue parse string s into v of scalar kind(string, bool,
+// int, uint, float or []byte), v must be settable, false is returned
+// if s is invalid for the kind
+func ParseValue(v reflect.Value, s string) bool {
+    return setStringValue(v, s)
+}
+
+// set field by string value, false is returned if failed
+func setStringValue(field reflect.Value, s string) bool {
+    switch field.Kind() {
+    case reflect.String:
+        field.SetString(s)
+    case reflect.Bool:
+        b, ok := ParseBool(s)
+        if !ok {
+            return false
+        }
+        field.SetBool(b)
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        i, ok := ParseInt(s)
+        if !ok || field.OverflowInt(int64(i)) {
+            return false
+        }
+        field.SetInt(int64(i))
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        i, ok := ParseInt(s)
+        if !ok || i < 0 || field.OverflowUint(uint64(i)) {
+            return false
+        }
+        field.SetUint(uint64(i))
+    case reflect.Float32, reflect.Float64:
+        f, ok := ParseFloat(s)
+        if !ok || field.OverflowFloat(f) {
+            return false
+        }
+        field.SetFloat(f)
+    case reflect.Slice:
+        if field.Type().Elem().Kind() != reflect.Uint8 {
+            return false
+        }
+        field.SetBytes([]byte(s))
+    default:
+        return false
+    }
+
+    return true
+}
@@ -2,33 +2,81 @@ package pgo
 
 import (
     "bytes"
+    "encoding/json"
     "fmt"
     "html/template"
     "io"
+    "io/fs"
+    "io/ioutil"
+    "os"
     "path/filepath"
+    "strconv"
+    "strings"
     "sync"
+    "time"
 )
 
+// functions available in all views, eg.
+// {{range flashes .ctx "success"}}<p>{{.}}</p>{{end}}
+var viewFuncs = template.FuncMap{
+    "flashes": func(ctx *Context, category ...string) []string {
+        return ctx.GetFlashes(append(category, "")[0])
+    },
+}
+
 // view component, configuration:
 // "view": {
 //     "suffix": ".html",
 //     "commons": [
 //         "@view/common/header.html",
 //         "@view/common/footer.html"
-//     ]
+//     ],
+//     "assetUrl": "/static/",
+//     "assetManifest": "@public/manifest.json",
+//     "assetVersion": ""
 // }
+//
+// {{asset "css/app.css"}} maps asset to fingerprinted file in manifest
+// generated by build, eg. {"css/app.css": "css/app.1f3e2a.css"}, and
+// gets "/static/css/app.1f3e2a.css", asset not in manifest or without
+// manifest gets query version, eg. "/static/css/app.css?v=1.2.0",
+// assetVersion defaults to build version or start time, manifest is
+// loaded once in prod env and reloaded on change in other envs.
+//
+// templates are read from disk by default, SetFS reads them from fs.FS
+// instead, eg. embed.FS for single binary, root of fs is @view, so
+// "@view/common/header.html" is "common/header.html" in fs.
 type View struct {
+    fsys      fs.FS
     suffix    string
     commons   []string
     funcMap   template.FuncMap
     templates map[string]*template.Template
     lock      sync.RWMutex
+
+    assetUrl      string
+    assetManifest string
+    assetVersion  string
+    assets        map[string]string
+    assetMtime    time.Time
+    assetLoaded   bool
+    assetLock     sync.RWMutex
 }
 
 func (v *View) Construct() {
     v.suffix = ".html"
     v.commons = make([]string, 0)
     v.templates = make(map[string]*template.Template)
+    v.assetUrl = "/"
+    v.assetManifest = "@public/manifest.json"
+}
+
+func (v *View) Init() {
+    if len(v.assetVersion) == 0 {
+        if v.assetVersion = App.GetBuildInfo().Version; len(v.assetVersion) == 0 {
+            v.assetVersion = strconv.FormatInt(time.Now().Unix(), 10)
+        }
+    }
 }
 
 // set view file suffix
@@ -53,6 +101,82 @@ func (v *View) SetCommons(commons []interface{}) {
     }
 }
 
+// set file system of templates, root of fs is @view, use fs.Sub
+// to strip prefix of embed.FS, nil to read from disk
+func (v *View) SetFS(fsys fs.FS) {
+    v.lock.Lock()
+    defer v.lock.Unlock()
+
+    v.fsys = fsys
+    v.templates = make(map[string]*template.Template)
+}
+
+// set url prefix of assets, eg. "/static/" or cdn url
+func (v *View) SetAssetUrl(url string) {
+    if !strings.HasSuffix(url, "/") {
+        url += "/"
+    }
+
+    v.assetUrl = url
+}
+
+// set path of asset manifest, empty to disable manifest
+func (v *View) SetAssetManifest(path string) {
+    v.assetManifest = path
+}
+
+// set query version of asset not in manifest
+func (v *View) SetAssetVersion(version string) {
+    v.assetVersion = version
+}
+
+// get url of asset, fingerprinted file in manifest is used if exists,
+// otherwise version is appended as query, eg. "css/app.css" gets
+// "/css/app.1f3e2a.css" or "/css/app.css?v=1.2.0"
+func (v *View) Asset(path string) string {
+    path = strings.TrimPrefix(path, "/")
+    if file, ok := v.getAssets()[path]; ok {
+        return v.assetUrl + strings.TrimPrefix(file, "/")
+    }
+
+    return v.assetUrl + path + "?v=" + v.assetVersion
+}
+
+// get asset manifest, loaded once in prod env, reloaded on change in others
+func (v *View) getAssets() map[string]string {
+    v.assetLock.RLock()
+    assets, loaded, mtime := v.assets, v.assetLoaded, v.assetMtime
+    v.assetLock.RUnlock()
+
+    if len(v.assetManifest) == 0 || loaded && App.GetEnv() == DefaultEnv {
+        return assets
+    }
+
+    path := GetAlias(v.assetManifest)
+    info, e := os.Stat(path)
+    if loaded && (e != nil && assets == nil || e == nil && info.ModTime().Equal(mtime)) {
+        return assets
+    }
+
+    v.assetLock.Lock()
+    defer v.assetLock.Unlock()
+
+    v.assets, v.assetLoaded = nil, true
+    if e != nil {
+        return nil
+    }
+
+    v.assetMtime = info.ModTime()
+    if content, e := ioutil.ReadFile(path); e != nil {
+        GLogger().Warn("failed to read asset manifest, %s", e)
+    } else if e := json.Unmarshal(content, &v.assets); e != nil {
+        v.assets = nil
+        GLogger().Warn("failed to parse asset manifest, %s, %s", path, e)
+    }
+
+    return v.assets
+}
+
 // add custom func map
 func (v *View) AddFuncMap(funcMap template.FuncMap) {
     v.funcMap = funcMap
@@ -100,7 +224,8 @@ func (v *View) loadTemplate(view string) {
         files = append(files, v.commons...)
     }
 
-    tpl := template.New(filepath.Base(view))
+    tpl := template.New(filepath.Base(view)).Funcs(viewFuncs)
+    tpl.Funcs(template.FuncMap{"asset": v.Asset})
 
     // add custom func map
     if len(v.funcMap) > 0 {
@@ -108,7 +233,16 @@ func (v *View) loadTemplate(view string) {
     }
 
     // parse template files
-    _, e := tpl.ParseFiles(files...)
+    var e error
+    if v.fsys != nil {
+        for i, file := range files {
+            files[i] = v.fsPath(file)
+        }
+        _, e = tpl.ParseFS(v.fsys, files...)
+    } else {
+        _, e = tpl.ParseFiles(files...)
+    }
+
     if e != nil {
         panic(fmt.Sprintf("failed to parse template, %s, %s", view, e))
     }
@@ -116,6 +250,15 @@ func (v *View) loadTemplate(view string) {
     v.templates[view] = tpl
 }
 
+// get path in fs of normalized view path
+func (v *View) fsPath(view string) string {
+    if rel, e := filepath.Rel(App.GetViewPath(), view); e == nil && !strings.HasPrefix(rel, "..") {
+        return filepath.ToSlash(rel)
+    }
+
+    return strings.TrimPrefix(filepath.ToSlash(view), "/")
+}
+
 func (v *View) normalize(view string) string {
     if ext := filepath.Ext(view); len(ext) == 0 {
         view = view + v.suffix
@@ -0,0 +1,40 @@
+package pgo
+
+import (
+    "testing"
+    "testing/fstest"
+)
+
+func TestViewRenderFS(t *testing.T) {
+    v := &View{}
+    v.Construct()
+    v.SetCommons([]interface{}{"@view/common/header.html"})
+    v.SetFS(fstest.MapFS{
+        "common/header.html": &fstest.MapFile{Data: []byte(`{{define "header"}}<h1>{{.title}}</h1>{{end}}`)},
+        "user/view.html":     &fstest.MapFile{Data: []byte(`{{template "header" .}}<p>{{.name}}</p>`)},
+    })
+
+    data := map[string]interface{}{"title": "User", "name": "<foo>"}
+    if out := string(v.Render("user/view", data)); out != "<h1>User</h1><p>&lt;foo&gt;</p>" {
+        t.Errorf("want view with common header rendered from fs, got %q", out)
+    }
+
+    // templates parsed from the previous fs are dropped
+    v.SetFS(fstest.MapFS{
+        "common/header.html": &fstest.MapFile{Data: []byte(`{{define "header"}}{{end}}`)},
+        "user/view.html":     &fstest.MapFile{Data: []byte(`{{template "header" .}}v2 {{.name}}`)},
+    })
+
+    if out := string(v.Render("user/view.html", data)); out != "v2 &lt;foo&gt;" {
+        t.Errorf("want view of the new fs, got %q", out)
+    }
+
+    func() {
+        defer func() {
+            if recover() == nil {
+                t.Error("missing view: want panic")
+            }
+        }()
+        v.Render("user/missing", data)
+    }()
+}